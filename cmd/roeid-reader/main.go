@@ -1,32 +1,205 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/application"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
 	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
 )
 
 // Romanian CEI application AID (D2 76 00 01 24 01)
 var ceiAID = []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01}
 
+// options are the command line settings of a card session
+type options struct {
+	command      string
+	simulatorDir string
+	readerSpec   string
+	anyCard      bool
+	autoRecover  bool
+	bridgeListen string
+	remoteURL    string
+	bridgeCert   string
+	bridgeKey    string
+	bridgeCA     string
+	recordFile   string
+	replayFile   string
+	logFormat    string
+	logLevel     string
+	logTLV       bool
+	unsafeTrace  bool
+	pinRef       uint
+	pinSource    string
+	forcePIN     bool
+	noPINPad     bool
+}
+
 func main() {
+	var opts options
+	flag.StringVar(&opts.simulatorDir, "simulator", "",
+		"use a virtual CEI card loaded from a fixture directory instead of PC/SC")
+	flag.StringVar(&opts.readerSpec, "reader", "",
+		`reader to use: NAME, "name:NAME", "index:N", "match:REGEX", "card" or "cei"`)
+	listReaders := flag.Bool("list-readers", false, "list PC/SC readers and exit")
+	monitor := flag.Bool("monitor", false, "print reader and card events until interrupted")
+	flag.BoolVar(&opts.anyCard, "any-card", false, "do not refuse cards identified as unsupported from their ATR")
+	flag.BoolVar(&opts.autoRecover, "recover", false,
+		"reconnect and re-select the CEI application after a card reset or contact loss")
+	flag.StringVar(&opts.bridgeListen, "bridge-listen", "",
+		"serve the card to remote terminals on this address (e.g. :7443) instead of reading it")
+	flag.StringVar(&opts.remoteURL, "remote", "", "use the card of a bridge server (e.g. https://kiosk:7443)")
+	flag.StringVar(&opts.bridgeCert, "bridge-cert", "", "bridge certificate (PEM) for -bridge-listen or -remote")
+	flag.StringVar(&opts.bridgeKey, "bridge-key", "", "bridge private key (PEM)")
+	flag.StringVar(&opts.bridgeCA, "bridge-ca", "", "CA (PEM) issuing the certificates of the other bridge end")
+	flag.StringVar(&opts.recordFile, "record", "", "record the card session to a trace file (contains the PIN)")
+	flag.StringVar(&opts.replayFile, "replay", "", "replay a recorded trace file instead of using a card")
+	flag.StringVar(&opts.logFormat, "log-format", "human", "card log format: human, text or json")
+	flag.StringVar(&opts.logLevel, "log-level", "debug", "minimum card log level (debug logs every APDU)")
+	flag.BoolVar(&opts.logTLV, "log-tlv", false, "decode BER-TLV payloads of logged APDUs into named data objects")
+	flag.BoolVar(&opts.unsafeTrace, "unsafe-full-trace", false,
+		"UNSAFE: log PINs, PACE secrets and personal data in clear (lab debugging with test cards only)")
+	flag.UintVar(&opts.pinRef, "pin-ref", uint(domain.PIN1Reference), "PIN reference for verify, change-pin and unblock-pin (1 = PIN1, 2 = PIN2)")
+	flag.StringVar(&opts.pinSource, "pin-source", "tty",
+		`where PINs and PUKs are read from: "tty" (no echo), "pinentry[:PROGRAM]" or "fd:N" (one per line)`)
+	flag.BoolVar(&opts.forcePIN, "force-pin", false, "verify even when only one PIN try is left (a blocked PIN needs the PUK)")
+	flag.BoolVar(&opts.noPINPad, "no-pinpad", false, "enter PINs with -pin-source even when the reader has a PIN pad")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Commands:")
+		for _, c := range pinCommands {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %-12s %s\n", c.name, c.usage)
+		}
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	opts.command = flag.Arg(0)
+
+	// Errors are only fatal here, once run has saved the trace and
+	// disconnected the card
+	var err error
+	switch {
+	case *listReaders:
+		err = printReaders()
+	case *monitor:
+		err = monitorReaders()
+	default:
+		// Ctrl+C abandons a pending card operation instead of leaving the reader stuck
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err = run(ctx, opts)
+		stop()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run connects to the card, selects the CEI application and runs the
+// command, or serves the card with -bridge-listen
+func run(ctx context.Context, opts options) error {
+	command, err := lookupPINCommand(opts.command)
+	if err != nil {
+		return err
+	}
+	if opts.pinRef > 0xFF {
+		return fmt.Errorf("invalid -pin-ref: %d", opts.pinRef)
+	}
+	pinProvider, err := newPINProvider(opts.pinSource)
+	if err != nil {
+		return fmt.Errorf("invalid -pin-source: %w", err)
+	}
+
+	selector, err := domain.ParseReaderSelector(opts.readerSpec)
+	if err != nil {
+		return fmt.Errorf("invalid -reader: %w", err)
+	}
+	logger, err := newLogger(opts.logFormat, opts.logLevel)
+	if err != nil {
+		return err
+	}
+	logger.SetAnnotateTLV(opts.logTLV)
+	if opts.unsafeTrace {
+		fmt.Fprintln(os.Stderr, "WARNING: -unsafe-full-trace logs PINs, PACE secrets and personal data in clear.")
+		fmt.Fprintln(os.Stderr, "WARNING: never use it with citizens' cards or keep its output.")
+		logger.SetUnsafeFullTrace(true)
+	}
+
 	fmt.Println("=== Romanian eID Reader ===")
 	fmt.Println()
 
-	// Initialize smart card service with APDU logging
+	// Choose the card source: PC/SC reader or virtual CEI simulator
 	transport := infrastructure.NewPCSCTransport()
-	logger := infrastructure.NewAPDULogger(os.Stdout)
-	service := application.NewSmartCardService(transport, logger)
+	transport.SetReaderSelector(selector)
+	var connector domain.Connector = transport
+	if opts.simulatorDir != "" {
+		simulator, err := infrastructure.LoadCardSimulator(opts.simulatorDir)
+		if err != nil {
+			return fmt.Errorf("failed to load simulator: %w", err)
+		}
+		connector = simulator
+	}
+	if opts.remoteURL != "" {
+		tlsConfig, err := infrastructure.NewBridgeTLSConfig(opts.bridgeCert, opts.bridgeKey, opts.bridgeCA, false)
+		if err != nil {
+			return fmt.Errorf("invalid bridge TLS settings: %w", err)
+		}
+		connector = infrastructure.NewRemoteTransport(opts.remoteURL, tlsConfig)
+	}
+
+	var replay *infrastructure.ReplayCard
+	if opts.replayFile != "" {
+		replay, err = infrastructure.LoadReplayCard(opts.replayFile)
+		if err != nil {
+			return fmt.Errorf("failed to load trace: %w", err)
+		}
+		connector = replay
+	}
+	if opts.recordFile != "" {
+		fmt.Fprintf(os.Stderr, "WARNING: %s will contain the PIN and personal data read from the card.\n", opts.recordFile)
+		recorder := infrastructure.NewRecordingConnector(connector)
+		connector = recorder
+		// Failed sessions are saved too: they are the ones worth replaying
+		defer func() {
+			if err := recorder.Save(opts.recordFile); err != nil {
+				log.Printf("Failed to save trace: %v", err)
+			}
+		}()
+	}
+
+	if opts.bridgeListen != "" {
+		return serveBridge(ctx, opts.bridgeListen, connector, transport, logger, opts.bridgeCert, opts.bridgeKey, opts.bridgeCA)
+	}
+
+	// Initialize smart card service with APDU logging
+	service := application.NewSmartCardService(connector, logger)
+	if !opts.anyCard {
+		// Refuse foreign eIDs early; cards not in the database may still be CEIs
+		service.RequireSupportedCard(true)
+	}
+	// Keep the last PIN try: a blocked CEI PIN means a trip to the SPCLEP office
+	service.ProtectLastPINTry(!opts.forcePIN)
+	service.SetPINProvider(pinProvider)
+	service.UsePINPad(!opts.noPINPad)
+	if opts.autoRecover {
+		service.SetRecoveryPolicy(application.DefaultRecoveryPolicy(ceiAID))
+		service.OnSessionInvalidated(func(cause error) {
+			fmt.Printf("Session re-established after: %v (PIN must be verified again)\n", cause)
+		})
+	}
 
 	// Connect to card (logs reader selection, ATR, protocol)
 	fmt.Println("Connecting to smart card...")
-	if err := service.Connect(); err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+	if err := service.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
 	}
 	defer service.Disconnect()
 
@@ -34,9 +207,9 @@ func main() {
 
 	// SELECT Romanian eID application
 	fmt.Printf("Selecting CEI application (AID: %02X)...\n", ceiAID)
-	resp, err := service.SelectApplication(ceiAID)
+	resp, err := service.SelectApplication(ctx, ceiAID)
 	if err != nil {
-		log.Fatalf("Failed to SELECT application: %v", err)
+		return fmt.Errorf("failed to SELECT application: %w", err)
 	}
 	if len(resp.Data) > 0 {
 		fmt.Printf("FCI data: %02X\n", resp.Data)
@@ -44,23 +217,123 @@ func main() {
 
 	fmt.Println()
 
-	// Prompt user for PIN1
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Enter PIN1: ")
-	pinInput, _ := reader.ReadString('\n')
-	pin := []byte(strings.TrimSpace(pinInput))
+	if err := command.run(ctx, service, byte(opts.pinRef)); err != nil {
+		return fmt.Errorf("%s failed: %w", command.name, describePINError(err))
+	}
+
+	// A replay that stopped early or swallowed a divergence must still fail
+	if replay != nil {
+		if err := replay.Verify(); err != nil {
+			return fmt.Errorf("replay does not match the trace: %w", err)
+		}
+	}
+	return nil
+}
 
-	// VERIFY PIN1 (reference 0x01)
-	fmt.Println("Verifying PIN1...")
-	if err := service.VerifyPIN(pin, 0x01); err != nil {
-		log.Fatalf("PIN1 verification failed: %v", err)
+// newLogger creates the card logger from the -log-format and -log-level flags
+func newLogger(format, level string) (*infrastructure.APDULogger, error) {
+	logFormat, err := infrastructure.ParseLogFormat(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -log-format: %w", err)
+	}
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level: %w", err)
 	}
+	logger := infrastructure.NewAPDULoggerFormat(os.Stdout, logFormat)
+	logger.SetLevel(logLevel)
+	return logger, nil
+}
 
-	fmt.Println()
-	fmt.Println("✓ PIN1 verified successfully!")
-	fmt.Println()
+// printReaders lists PC/SC readers with their index, card presence, ATR and
+// secure PIN entry and PACE support
+func printReaders() error {
+	readers, err := infrastructure.NewPCSCTransport().Readers()
+	if err != nil {
+		return fmt.Errorf("failed to list readers: %w", err)
+	}
+	for i, reader := range readers {
+		if reader.IsConnected() {
+			fmt.Printf("%d: %s (card present, ATR: %02X)%s\n", i, reader.Name(), reader.ATR(), readerCapabilities(reader))
+		} else {
+			fmt.Printf("%d: %s (empty)%s\n", i, reader.Name(), readerCapabilities(reader))
+		}
+	}
+	return nil
+}
+
+// readerCapabilities describes the PIN pad and PACE support of a reader
+func readerCapabilities(reader domain.Reader) string {
+	var capabilities []string
+	if reader.Features().PINPad() {
+		capabilities = append(capabilities, "PIN pad")
+	}
+	if reader.Features().PACE() {
+		capabilities = append(capabilities, "PACE")
+	}
+	if len(capabilities) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(capabilities, ", ") + "]"
+}
 
-	// TODO: Implement PACE protocol phases to establish secure messaging
-	// TODO: Read and parse protected identity data
-	fmt.Println("Next steps: Implement PACE protocol (see IMPLEMENTATION_ROADMAP.md)")
+// serveBridge exposes connector to remote terminals until interrupted. When
+// serving a PC/SC reader, its events are forwarded and card removal
+// invalidates the remote session.
+func serveBridge(ctx context.Context, addr string, connector domain.Connector,
+	transport *infrastructure.PCSCTransport, logger *infrastructure.APDULogger, certFile, keyFile, caFile string) error {
+	tlsConfig, err := infrastructure.NewBridgeTLSConfig(certFile, keyFile, caFile, true)
+	if err != nil {
+		return fmt.Errorf("invalid bridge TLS settings: %w", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	if l, ok := connector.(infrastructure.LoggerSetter); ok {
+		l.SetLogger(logger)
+	}
+	bridge := infrastructure.NewBridgeServer(connector)
+	bridge.SetLogger(logger)
+
+	if connector == domain.Connector(transport) {
+		monitor, err := infrastructure.NewCardMonitor()
+		if err != nil {
+			return fmt.Errorf("failed to start monitor: %w", err)
+		}
+		monitor.AddInvalidator(transport)
+		monitor.Start()
+		defer monitor.Close()
+		go bridge.Publish(monitor.Events())
+	}
+
+	fmt.Printf("Serving card bridge on %s (Ctrl+C to stop)...\n", listener.Addr())
+	if err := bridge.Serve(ctx, listener, tlsConfig); err != nil {
+		return fmt.Errorf("bridge stopped: %w", err)
+	}
+	return nil
+}
+
+// monitorReaders prints reader hot-plug and card insertion/removal events
+// until interrupted
+func monitorReaders() error {
+	monitor, err := infrastructure.NewCardMonitor()
+	if err != nil {
+		return fmt.Errorf("failed to start monitor: %w", err)
+	}
+	monitor.Start()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		monitor.Close()
+	}()
+
+	fmt.Println("Monitoring readers (Ctrl+C to stop)...")
+	for event := range monitor.Events() {
+		fmt.Println(event)
+	}
+	return nil
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+const simulatorFixture = "../../internal/smartcard/infrastructure/testdata/cei"
+
+// simulatorOptions returns the options of a session on the CEI simulator
+// whose PINs are read from a file holding pins, one per line
+func simulatorOptions(t *testing.T, pins string) options {
+	t.Helper()
+	pinFile, err := os.CreateTemp(t.TempDir(), "pins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pinFile.Close() })
+	if _, err := pinFile.WriteString(pins); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pinFile.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	return options{
+		simulatorDir: simulatorFixture,
+		logFormat:    "human",
+		logLevel:     "error",
+		pinRef:       uint(domain.PIN1Reference),
+		pinSource:    fmt.Sprintf("fd:%d", pinFile.Fd()),
+		noPINPad:     true,
+	}
+}
+
+func TestRun_RecordsFailedSession(t *testing.T) {
+	opts := simulatorOptions(t, "0000\n")
+	opts.recordFile = filepath.Join(t.TempDir(), "session.json")
+
+	err := run(context.Background(), opts)
+	if !errors.Is(err, domain.ErrVerificationFailed) {
+		t.Fatalf("run() error = %v, want the wrong PIN failure", err)
+	}
+
+	trace, err := infrastructure.LoadCardTrace(opts.recordFile)
+	if err != nil {
+		t.Fatalf("LoadCardTrace() error = %v, want the failed session saved", err)
+	}
+	var last infrastructure.TraceEntry
+	for _, entry := range trace.Entries {
+		if entry.Kind == infrastructure.TraceExchange {
+			last = entry
+		}
+	}
+	if last.Response != "63c2" {
+		t.Errorf("last recorded exchange = %+v, want the failed VERIFY answered 63C2", last)
+	}
+}
+
+func TestRun_ReplayFailsOnUnreplayedEntries(t *testing.T) {
+	opts := simulatorOptions(t, "")
+	opts.command = "pin-status"
+	opts.recordFile = filepath.Join(t.TempDir(), "session.json")
+	if err := run(context.Background(), opts); err != nil {
+		t.Fatalf("recording run() error = %v", err)
+	}
+	opts.simulatorDir = ""
+	opts.replayFile, opts.recordFile = opts.recordFile, ""
+
+	if err := run(context.Background(), opts); err != nil {
+		t.Fatalf("replay run() error = %v", err)
+	}
+
+	// A trace the command stops short of must fail the replay
+	trace, err := infrastructure.LoadCardTrace(opts.replayFile)
+	if err != nil {
+		t.Fatalf("LoadCardTrace() error = %v", err)
+	}
+	trace.Entries = append(trace.Entries, trace.Entries[len(trace.Entries)-1])
+	if err := trace.Save(opts.replayFile); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := run(context.Background(), opts); err == nil {
+		t.Error("replay run() with an unreplayed entry succeeded, want an error")
+	}
+}
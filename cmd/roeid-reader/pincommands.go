@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/application"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+// pinCommand is a CLI subcommand run once the CEI application is selected
+type pinCommand struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, service *application.SmartCardService, pinRef byte) error
+}
+
+// pinCommands are the subcommands; the first is the default
+var pinCommands = []pinCommand{
+	{"verify", "verify a PIN (default)", runVerify},
+	{"pin-status", "show the tries left for PIN1 and PIN2 without spending one", runPINStatus},
+	{"change-pin", "change a PIN, presenting the current one", runChangePIN},
+	{"unblock-pin", "reset the retry counter of a PIN with the PUK and set a new PIN", runUnblockPIN},
+}
+
+// lookupPINCommand returns the subcommand called name
+func lookupPINCommand(name string) (pinCommand, error) {
+	if name == "" {
+		return pinCommands[0], nil
+	}
+	for _, c := range pinCommands {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return pinCommand{}, fmt.Errorf("unknown command %q (run with -h for the list)", name)
+}
+
+// runVerify asks for a PIN and verifies it
+func runVerify(ctx context.Context, service *application.SmartCardService, pinRef byte) error {
+	fmt.Printf("Verifying %s...\n", pinName(pinRef))
+	if err := service.VerifyPINInteractive(ctx, pinRef); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("✓ %s verified successfully!\n", pinName(pinRef))
+	fmt.Println()
+
+	// TODO: Implement PACE protocol phases to establish secure messaging
+	// TODO: Read and parse protected identity data
+	fmt.Println("Next steps: Implement PACE protocol (see IMPLEMENTATION_ROADMAP.md)")
+	return nil
+}
+
+// runPINStatus prints the retry counters of PIN1 and PIN2
+func runPINStatus(ctx context.Context, service *application.SmartCardService, _ byte) error {
+	for _, ref := range []byte{domain.PIN1Reference, domain.PIN2Reference} {
+		status, err := service.PINRetries(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pinName(ref), err)
+		}
+		switch {
+		case status.Verified:
+			fmt.Printf("%s: verified\n", pinName(ref))
+		case status.Blocked():
+			fmt.Printf("%s: blocked (unblock-pin with the PUK)\n", pinName(ref))
+		default:
+			fmt.Printf("%s: %d tries left\n", pinName(ref), status.RetriesLeft)
+		}
+	}
+	return nil
+}
+
+// runChangePIN asks for the current and new PIN and changes it
+func runChangePIN(ctx context.Context, service *application.SmartCardService, pinRef byte) error {
+	if err := service.ChangePINInteractive(ctx, pinRef); err != nil {
+		return err
+	}
+	fmt.Printf("✓ %s changed\n", pinName(pinRef))
+	return nil
+}
+
+// runUnblockPIN asks for the PUK and a new PIN and unblocks the PIN
+func runUnblockPIN(ctx context.Context, service *application.SmartCardService, pinRef byte) error {
+	if err := service.UnblockPINInteractive(ctx, pinRef); err != nil {
+		return err
+	}
+	fmt.Printf("✓ %s unblocked\n", pinName(pinRef))
+	return nil
+}
+
+// newPINProvider creates the PIN source named by -pin-source: "tty",
+// "pinentry[:PROGRAM]" or "fd:N"
+func newPINProvider(spec string) (domain.PINProvider, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "tty":
+		return infrastructure.NewTerminalPINProvider(os.Stdin, os.Stderr), nil
+	case "pinentry":
+		return infrastructure.NewPinentryProvider(arg), nil
+	case "fd":
+		fd, err := strconv.ParseUint(arg, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor %q", arg)
+		}
+		return infrastructure.NewFDPINProvider(uintptr(fd)), nil
+	default:
+		return nil, fmt.Errorf(`unknown PIN source %q (want "tty", "pinentry[:PROGRAM]" or "fd:N")`, spec)
+	}
+}
+
+// pinName returns the CEI name of a PIN reference
+func pinName(pinRef byte) string {
+	switch pinRef {
+	case domain.PIN1Reference:
+		return "PIN1"
+	case domain.PIN2Reference:
+		return "PIN2"
+	default:
+		return fmt.Sprintf("PIN %02X", pinRef)
+	}
+}
+
+// describePINError explains how to get past the last-try protection; card
+// failures already carry the status hint and retries left
+func describePINError(err error) error {
+	if errors.Is(err, domain.ErrLastPINTry) {
+		return fmt.Errorf("%w; a wrong PIN now blocks it, run again with -force-pin if you are sure", err)
+	}
+	return err
+}
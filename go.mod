@@ -2,4 +2,9 @@ module github.com/andrei-dascalu/roeid-reader
 
 go 1.25.6
 
-require github.com/ebfe/scard v0.0.0-20241214075232-7af069cabc25
+require (
+	github.com/ebfe/scard v0.0.0-20241214075232-7af069cabc25
+	golang.org/x/term v0.35.0
+)
+
+require golang.org/x/sys v0.36.0 // indirect
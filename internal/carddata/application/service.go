@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"fmt"
 
 	domain "github.com/andrei-dascalu/roeid-reader/internal/carddata/domain"
@@ -17,7 +18,7 @@ func NewCardDataService() *CardDataService {
 }
 
 // ReadIdentity reads the personal identity record from the card
-func (s *CardDataService) ReadIdentity() (*domain.Identity, error) {
+func (s *CardDataService) ReadIdentity(ctx context.Context) (*domain.Identity, error) {
 	// TODO: Use SmartCardService with secure messaging
 	// TODO: Navigate CEI file system to EF.PersonalData
 	// TODO: Parse TLV-encoded fields
@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+)
+
+// EncryptCBC encrypts whole blocks with AES-CBC
+func EncryptCBC(key, iv, data []byte) ([]byte, error) {
+	return cryptCBC(key, iv, data, true)
+}
+
+// DecryptCBC decrypts whole blocks with AES-CBC
+func DecryptCBC(key, iv, data []byte) ([]byte, error) {
+	return cryptCBC(key, iv, data, false)
+}
+
+// cryptCBC runs AES-CBC in one direction
+func cryptCBC(key, iv, data []byte, encrypt bool) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%d bytes are not a whole number of AES blocks", len(data))
+	}
+	out := make([]byte, len(data))
+	if encrypt {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	} else {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	}
+	return out, nil
+}
+
+// PadISO9797 appends 80 and zeros up to a whole AES block (ISO/IEC 9797-1
+// padding method 2)
+func PadISO9797(data []byte) []byte {
+	padded := append(append([]byte{}, data...), 0x80)
+	for len(padded)%aes.BlockSize != 0 {
+		padded = append(padded, 0x00)
+	}
+	return padded
+}
+
+// UnpadISO9797 removes ISO/IEC 9797-1 padding method 2
+func UnpadISO9797(data []byte) ([]byte, error) {
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i] == 0x80 {
+			return data[:i], nil
+		}
+		if data[i] != 0x00 {
+			break
+		}
+	}
+	return nil, errors.New("invalid padding")
+}
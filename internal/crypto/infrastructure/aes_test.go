@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPadISO9797(t *testing.T) {
+	tests := []struct {
+		length int
+		padded int
+	}{
+		{0, 16},
+		{15, 16},
+		{16, 32},
+	}
+	for _, tt := range tests {
+		data := bytes.Repeat([]byte{0x80}, tt.length) // Padding bytes inside the data
+		padded := PadISO9797(data)
+		if len(padded) != tt.padded || padded[tt.length] != 0x80 {
+			t.Errorf("PadISO9797(%d bytes) = %02X", tt.length, padded)
+		}
+		if unpadded, err := UnpadISO9797(padded); err != nil || !bytes.Equal(unpadded, data) {
+			t.Errorf("UnpadISO9797() = %02X, %v, want %02X", unpadded, err, data)
+		}
+	}
+
+	if _, err := UnpadISO9797([]byte{0x01, 0x00}); err == nil {
+		t.Error("UnpadISO9797() should reject data without padding")
+	}
+}
+
+func TestCBC_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x2B}, 16)
+	iv := make([]byte, 16)
+	data := PadISO9797([]byte("secure messaging"))
+
+	encrypted, err := EncryptCBC(key, iv, data)
+	if err != nil || bytes.Equal(encrypted, data) {
+		t.Fatalf("EncryptCBC() = %02X, %v", encrypted, err)
+	}
+	if decrypted, err := DecryptCBC(key, iv, encrypted); err != nil || !bytes.Equal(decrypted, data) {
+		t.Errorf("DecryptCBC() = %02X, %v, want %02X", decrypted, err, data)
+	}
+	if _, err := EncryptCBC(key, iv, data[:5]); err == nil {
+		t.Error("EncryptCBC() should reject partial blocks")
+	}
+}
@@ -1,11 +1,157 @@
 package infrastructure
 
-// BrainpoolCurve is a placeholder for BrainpoolP256r1 implementation
+import (
+	"errors"
+	"math/big"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/crypto/domain"
+)
+
+// BrainpoolCurve is a short Weierstrass curve y² = x³ + ax + b over GF(p)
+// with a base point of prime order n. The arithmetic is affine on math/big
+// and not constant-time.
 type BrainpoolCurve struct {
-	// Domain parameters
+	name       string
+	p, a, b, n *big.Int
+	g          *domain.Point
 }
 
-// NewBrainpoolP256r1 creates a new instance
+// NewBrainpoolP256r1 returns the domain parameters of RFC 5639 §3.4, used by
+// the CEI for PACE (standardized parameter ID 13)
 func NewBrainpoolP256r1() *BrainpoolCurve {
-	return &BrainpoolCurve{}
+	return &BrainpoolCurve{
+		name: "brainpoolP256r1",
+		p:    hexInt("A9FB57DBA1EEA9BC3E660A909D838D726E3BF623D52620282013481D1F6E5377"),
+		a:    hexInt("7D5A0975FC2C3057EEF67530417AFFE7FB8055C126DC5C6CE94A4B44F330B5D9"),
+		b:    hexInt("26DC5C6CE94A4B44F330B5D9BBD77CBF958416295CF7E1CE6BCCDC18FF8C07B6"),
+		n:    hexInt("A9FB57DBA1EEA9BC3E660A909D838D718C397AA3B561A6F7901E0E82974856A7"),
+		g: domain.NewPoint(
+			hexInt("8BD2AEB9CB7E57CB2C4B482FFC81B7AFB9DE27E1E3BD23C23A4453BD9ACE3262"),
+			hexInt("547EF835C3DAC4FD97F8461A14611DC9C27745132DED8E545C1D54C72F046997"),
+		),
+	}
+}
+
+// hexInt decodes a big-endian hex constant
+func hexInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex constant " + s)
+	}
+	return n
+}
+
+// Name returns the curve name
+func (c *BrainpoolCurve) Name() string { return c.name }
+
+// P returns the field prime
+func (c *BrainpoolCurve) P() *big.Int { return c.p }
+
+// A returns the coefficient a
+func (c *BrainpoolCurve) A() *big.Int { return c.a }
+
+// B returns the coefficient b
+func (c *BrainpoolCurve) B() *big.Int { return c.b }
+
+// G returns the base point
+func (c *BrainpoolCurve) G() *domain.Point { return c.g }
+
+// Order returns the order n of the base point
+func (c *BrainpoolCurve) Order() *big.Int { return c.n }
+
+// Size returns the length of a field element in bytes
+func (c *BrainpoolCurve) Size() int {
+	return (c.p.BitLen() + 7) / 8
+}
+
+// WithGenerator returns the curve with another base point of the same order,
+// e.g. the one mapped from the PACE nonce
+func (c *BrainpoolCurve) WithGenerator(g *domain.Point) *BrainpoolCurve {
+	mapped := *c
+	mapped.g = g
+	return &mapped
+}
+
+// IsOnCurve reports whether p is a finite point of the curve
+func (c *BrainpoolCurve) IsOnCurve(p *domain.Point) bool {
+	if p.IsPointAtInfinity() || p.X.Sign() < 0 || p.Y.Sign() < 0 || p.X.Cmp(c.p) >= 0 || p.Y.Cmp(c.p) >= 0 {
+		return false
+	}
+	left := new(big.Int).Mul(p.Y, p.Y)
+	right := new(big.Int).Mul(p.X, p.X)
+	right.Add(right, c.a).Mul(right, p.X).Add(right, c.b)
+	return left.Sub(left, right).Mod(left, c.p).Sign() == 0
+}
+
+// Add returns p1 + p2; nil is the point at infinity
+func (c *BrainpoolCurve) Add(p1, p2 *domain.Point) *domain.Point {
+	switch {
+	case p1.IsPointAtInfinity():
+		return p2
+	case p2.IsPointAtInfinity():
+		return p1
+	}
+
+	var slope *big.Int
+	if p1.X.Cmp(p2.X) == 0 {
+		sum := new(big.Int).Add(p1.Y, p2.Y)
+		if sum.Mod(sum, c.p).Sign() == 0 {
+			return nil // p2 = -p1
+		}
+		// Tangent: (3x² + a) / 2y
+		slope = new(big.Int).Mul(p1.X, p1.X)
+		slope.Mul(slope, big.NewInt(3)).Add(slope, c.a)
+		slope.Mul(slope, c.inverse(new(big.Int).Lsh(p1.Y, 1)))
+	} else {
+		// Chord: (y2 - y1) / (x2 - x1)
+		slope = new(big.Int).Sub(p2.Y, p1.Y)
+		slope.Mul(slope, c.inverse(new(big.Int).Sub(p2.X, p1.X)))
+	}
+	slope.Mod(slope, c.p)
+
+	x := new(big.Int).Mul(slope, slope)
+	x.Sub(x, p1.X).Sub(x, p2.X).Mod(x, c.p)
+	y := new(big.Int).Sub(p1.X, x)
+	y.Mul(y, slope).Sub(y, p1.Y).Mod(y, c.p)
+	return domain.NewPoint(x, y)
+}
+
+// inverse returns 1/v mod p
+func (c *BrainpoolCurve) inverse(v *big.Int) *big.Int {
+	return new(big.Int).ModInverse(new(big.Int).Mod(v, c.p), c.p)
+}
+
+// ScalarMult returns k·p by double-and-add
+func (c *BrainpoolCurve) ScalarMult(k *big.Int, p *domain.Point) *domain.Point {
+	var result *domain.Point
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = c.Add(result, result)
+		if k.Bit(i) == 1 {
+			result = c.Add(result, p)
+		}
+	}
+	return result
+}
+
+// Marshal returns the uncompressed encoding 04 || X || Y
+func (c *BrainpoolCurve) Marshal(p *domain.Point) []byte {
+	size := c.Size()
+	out := make([]byte, 1+2*size)
+	out[0] = 0x04
+	p.X.FillBytes(out[1 : 1+size])
+	p.Y.FillBytes(out[1+size:])
+	return out
+}
+
+// Unmarshal parses an uncompressed point and checks that it is on the curve
+func (c *BrainpoolCurve) Unmarshal(data []byte) (*domain.Point, error) {
+	size := c.Size()
+	if len(data) != 1+2*size || data[0] != 0x04 {
+		return nil, errors.New("not an uncompressed point")
+	}
+	p := domain.NewPoint(new(big.Int).SetBytes(data[1:1+size]), new(big.Int).SetBytes(data[1+size:]))
+	if !c.IsOnCurve(p) {
+		return nil, errors.New("point not on the curve")
+	}
+	return p, nil
 }
@@ -0,0 +1,32 @@
+package infrastructure
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBrainpoolP256r1(t *testing.T) {
+	c := NewBrainpoolP256r1()
+	if !c.IsOnCurve(c.G()) {
+		t.Fatal("generator is not on the curve")
+	}
+	if !c.ScalarMult(c.Order(), c.G()).IsPointAtInfinity() {
+		t.Error("n·G should be the point at infinity")
+	}
+
+	k := big.NewInt(12345)
+	sum := c.Add(c.ScalarMult(k, c.G()), c.G())
+	if want := c.ScalarMult(big.NewInt(12346), c.G()); sum.X.Cmp(want.X) != 0 || sum.Y.Cmp(want.Y) != 0 {
+		t.Error("k·G + G should equal (k+1)·G")
+	}
+
+	decoded, err := c.Unmarshal(c.Marshal(sum))
+	if err != nil || decoded.X.Cmp(sum.X) != 0 || decoded.Y.Cmp(sum.Y) != 0 {
+		t.Errorf("Unmarshal(Marshal(P)) = %v, %v", decoded, err)
+	}
+	bad := c.Marshal(sum)
+	bad[len(bad)-1] ^= 0x01
+	if _, err := c.Unmarshal(bad); err == nil {
+		t.Error("Unmarshal() should reject a point off the curve")
+	}
+}
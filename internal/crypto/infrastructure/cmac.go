@@ -1,14 +1,64 @@
 package infrastructure
 
-// CMACProvider wraps AES-CMAC operations
-// Uses golang.org/x/crypto/cmac
-type CMACProvider struct {
-	// Key will be stored here during session
-}
+import (
+	"crypto/aes"
+	"crypto/subtle"
+)
+
+// CMACProvider computes AES-CMAC (RFC 4493, NIST SP 800-38B), the MAC of
+// PACE tokens and secure messaging
+type CMACProvider struct{}
 
 // NewCMACProvider creates a new CMAC provider
 func NewCMACProvider() *CMACProvider {
 	return &CMACProvider{}
 }
 
-// TODO: Implement Compute(key, data) -> []byte
+// Compute returns the 16-byte AES-CMAC of data under key
+func (CMACProvider) Compute(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subkeys K1 and K2 from L = AES(K, 0^128)
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	k1 = cmacShift(k1)
+	k2 := cmacShift(append([]byte{}, k1...))
+
+	last := make([]byte, aes.BlockSize)
+	n := len(data)
+	if n > 0 && n%aes.BlockSize == 0 {
+		subtle.XORBytes(last, data[n-aes.BlockSize:], k1)
+		data = data[:n-aes.BlockSize]
+	} else {
+		tail := data[n-n%aes.BlockSize:]
+		copy(last, tail)
+		last[len(tail)] = 0x80
+		subtle.XORBytes(last, last, k2)
+		data = data[:n-len(tail)]
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < len(data); i += aes.BlockSize {
+		subtle.XORBytes(mac, mac, data[i:i+aes.BlockSize])
+		block.Encrypt(mac, mac)
+	}
+	subtle.XORBytes(mac, mac, last)
+	block.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// cmacShift shifts a block left by one bit, adding Rb on carry (RFC 4493 §2.3)
+func cmacShift(b []byte) []byte {
+	carry := b[0] >> 7
+	for i := 0; i < len(b)-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[len(b)-1] <<= 1
+	if carry != 0 {
+		b[len(b)-1] ^= 0x87
+	}
+	return b
+}
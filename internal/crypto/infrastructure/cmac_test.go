@@ -0,0 +1,30 @@
+package infrastructure
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestCMACProvider_Compute(t *testing.T) {
+	// RFC 4493 §4
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	message, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+
+	tests := []struct {
+		length int
+		want   string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+	}
+	for _, tt := range tests {
+		mac, err := NewCMACProvider().Compute(key, message[:tt.length])
+		if err != nil {
+			t.Fatalf("Compute() error = %v", err)
+		}
+		if got := hex.EncodeToString(mac); got != tt.want {
+			t.Errorf("Compute(%d bytes) = %s, want %s", tt.length, got, tt.want)
+		}
+	}
+}
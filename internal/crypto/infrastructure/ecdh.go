@@ -1,13 +1,50 @@
 package infrastructure
 
-// ECDH provides elliptic curve Diffie-Hellman operations
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/crypto/domain"
+)
+
+// ECDH provides elliptic curve Diffie-Hellman operations on a curve, which
+// for PACE is the one mapped from the nonce
 type ECDH struct {
-	// Curve reference
+	curve domain.EllipticCurve
 }
 
-// NewECDH creates a new ECDH instance
-func NewECDH() *ECDH {
-	return &ECDH{}
+// NewECDH creates an ECDH instance on curve
+func NewECDH(curve domain.EllipticCurve) *ECDH {
+	return &ECDH{curve: curve}
 }
 
-// TODO: Implement shared secret computation
+// GenerateKey draws a private key in [1, n-1] and returns it with the public
+// key k·G
+func (e *ECDH) GenerateKey(random io.Reader) (*big.Int, *domain.Point, error) {
+	n := e.curve.Order()
+	highest := new(big.Int).Sub(n, big.NewInt(1))
+	buf := make([]byte, (n.BitLen()+7)/8)
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to draw a private key: %w", err)
+		}
+		k := new(big.Int).SetBytes(buf)
+		if k.Sign() > 0 && k.Cmp(highest) <= 0 {
+			return k, e.curve.ScalarMult(k, e.curve.G()), nil
+		}
+	}
+}
+
+// SharedSecret returns the x-coordinate of private·peer, as many bytes as a
+// field element
+func (e *ECDH) SharedSecret(private *big.Int, peer *domain.Point) ([]byte, error) {
+	shared := e.curve.ScalarMult(private, peer)
+	if shared.IsPointAtInfinity() {
+		return nil, errors.New("shared point is the point at infinity")
+	}
+	secret := make([]byte, (e.curve.P().BitLen()+7)/8)
+	shared.X.FillBytes(secret)
+	return secret, nil
+}
@@ -0,0 +1,25 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestECDH_SharedSecret(t *testing.T) {
+	ecdh := NewECDH(NewBrainpoolP256r1())
+	privateA, publicA, err := ecdh.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	privateB, publicB, _ := ecdh.GenerateKey(rand.Reader)
+
+	secretA, err := ecdh.SharedSecret(privateA, publicB)
+	if err != nil {
+		t.Fatalf("SharedSecret() error = %v", err)
+	}
+	secretB, _ := ecdh.SharedSecret(privateB, publicA)
+	if len(secretA) != 32 || !bytes.Equal(secretA, secretB) {
+		t.Errorf("SharedSecret() = %02X and %02X, want the same 32 bytes", secretA, secretB)
+	}
+}
@@ -0,0 +1,33 @@
+package infrastructure
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+)
+
+// Key derivation counters (BSI TR-03110-3 §A.2.3)
+const (
+	KDFCounterEnc      uint32 = 1 // K_enc, secure messaging encryption
+	KDFCounterMAC      uint32 = 2 // K_mac, secure messaging authentication
+	KDFCounterPassword uint32 = 3 // K_π, PACE nonce encryption
+)
+
+// PACEKDF is the key derivation function of PACE for 128-bit AES keys
+// (BSI TR-03110-3 §A.2.3): SHA-1(secret || counter) truncated
+type PACEKDF struct{}
+
+// NewPACEKDF creates the PACE key derivation function
+func NewPACEKDF() *PACEKDF {
+	return &PACEKDF{}
+}
+
+// Derive derives a key of length bytes (at most 16) from a shared secret or
+// password
+func (PACEKDF) Derive(secret []byte, counter uint32, length int) ([]byte, error) {
+	if length <= 0 || length > 16 {
+		return nil, fmt.Errorf("PACE KDF cannot derive %d-byte keys with SHA-1", length)
+	}
+	digest := sha1.Sum(binary.BigEndian.AppendUint32(append([]byte{}, secret...), counter))
+	return digest[:length], nil
+}
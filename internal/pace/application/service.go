@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"fmt"
 
 	domainPace "github.com/andrei-dascalu/roeid-reader/internal/pace/domain"
@@ -20,12 +21,16 @@ func NewPACEService() *PACEService {
 }
 
 // Execute runs the full PACE protocol
-func (s *PACEService) Execute(password *domainPace.Password, nonce *domainPace.Nonce) error {
+func (s *PACEService) Execute(ctx context.Context, password *domainPace.Password, nonce *domainPace.Nonce) error {
 	// TODO: Implement phases 1-4
 	// Phase 1: Derive K_pi from password
 	// Phase 2: Decrypt nonce, map to EC domain
-	// Phase 3: Perform ECDH on mapped curve
+	// Phase 3: Perform ECDH on mapped curve (take the random source for the
+	// ephemeral keys as a dependency, so that -record/-replay can pass
+	// RecordingCard.Random or ReplayCard.Random)
 	// Phase 4: Exchange and verify authentication tags
+	// Run all phases inside SmartCardService.WithTransaction so that no other
+	// application interleaves APDUs while the session keys are established
 
 	return fmt.Errorf("PACE protocol not yet implemented")
 }
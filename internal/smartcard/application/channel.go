@@ -0,0 +1,143 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// Channel is a logical channel opened with MANAGE CHANNEL. Every command sent
+// through it carries the channel number in CLA, so each channel keeps its own
+// selected application and current file (e.g. the signature application stays
+// selected while identity files are read on the basic channel).
+//
+// A card reset closes all logical channels: after a Reconnect with a reset,
+// a recovery or a Disconnect, handles fail with ErrChannelClosedByReset and
+// must be opened again.
+type Channel struct {
+	service  *SmartCardService
+	number   int
+	closed   bool
+	closedBy error // Why the service closed the channel, nil after Close
+}
+
+// OpenChannel asks the card for a new logical channel (MANAGE CHANNEL open,
+// ISO/IEC 7816-4 §11.1.2). Cards without logical channels answer 6881, which
+// is returned as a StatusError with Code StatusFunctionNotFound.
+func (s *SmartCardService) OpenChannel(ctx context.Context) (*Channel, error) {
+	apdu := &domain.APDU{
+		CLA: 0x00, // Opened from the basic channel: the new channel starts at the MF
+		INS: 0x70, // MANAGE CHANNEL
+		P1:  0x00, // Open
+		P2:  0x00, // Channel number assigned by the card
+		Le:  1,
+	}
+
+	resp, err := s.transmit(ctx, apdu)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() == domain.StatusFunctionNotFound {
+		statusErr := domain.NewStatusError(resp)
+		statusErr.Detail = "card does not support logical channels"
+		return nil, statusErr
+	}
+	if !resp.IsSuccess() {
+		return nil, domain.NewCommandError(apdu, resp)
+	}
+
+	if len(resp.Data) != 1 {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			fmt.Sprintf("MANAGE CHANNEL returned %d bytes, want a channel number", len(resp.Data)), nil)
+	}
+	number := int(resp.Data[0])
+	if number == domain.BasicChannel || number > domain.MaxLogicalChannel {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			fmt.Sprintf("MANAGE CHANNEL returned invalid channel %d", number), nil)
+	}
+	channel := &Channel{service: s, number: number}
+	s.channels = append(s.channels, channel)
+	return channel, nil
+}
+
+// closeChannels marks the open channels closed, e.g. because the card was
+// reset and no longer knows them
+func (s *SmartCardService) closeChannels(cause error) {
+	for _, channel := range s.channels {
+		channel.closed = true
+		channel.closedBy = cause
+	}
+	s.channels = nil
+}
+
+// Number returns the logical channel number
+func (c *Channel) Number() int {
+	return c.number
+}
+
+// Command returns a copy of apdu addressed to the channel. Secure messaging
+// must wrap the returned command, not the original, because an authenticated
+// header covers CLA.
+func (c *Channel) Command(apdu *domain.APDU) (*domain.APDU, error) {
+	cla, err := domain.ChannelCLA(apdu.CLA, c.number)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"cannot address logical channel", err)
+	}
+	addressed := *apdu
+	addressed.CLA = cla
+	return &addressed, nil
+}
+
+// Transmit sends an APDU on the channel
+func (c *Channel) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if c.closed {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			fmt.Sprintf("logical channel %d is closed", c.number), c.closedBy)
+	}
+	addressed, err := c.Command(apdu)
+	if err != nil {
+		return nil, err
+	}
+	return c.service.transmit(ctx, addressed)
+}
+
+// SelectApplication selects an application on the channel, leaving the
+// selection of the other channels untouched
+func (c *Channel) SelectApplication(ctx context.Context, aid []byte) (*domain.Response, error) {
+	apdu := selectApplicationAPDU(aid)
+	resp, err := c.Transmit(ctx, apdu)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return resp, domain.NewCommandError(apdu, resp)
+	}
+	return resp, nil
+}
+
+// Close releases the channel on the card (MANAGE CHANNEL close). The handle
+// cannot be used afterwards.
+func (c *Channel) Close(ctx context.Context) error {
+	if c.closed {
+		return nil
+	}
+	apdu := &domain.APDU{
+		CLA: 0x00,
+		INS: 0x70,           // MANAGE CHANNEL
+		P1:  0x80,           // Close
+		P2:  byte(c.number), // Channel to close
+	}
+	resp, err := c.Transmit(ctx, apdu)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return domain.NewCommandError(apdu, resp)
+	}
+	c.closed = true
+	c.service.channels = slices.DeleteFunc(c.service.channels, func(open *Channel) bool { return open == c })
+	return nil
+}
@@ -0,0 +1,163 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+func TestSmartCardService_OpenChannel(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x70, 0x00, 0x00, 0x01}, []byte{0x02}, 0x9000).
+		Expect([]byte{0x02, 0xA4, 0x04, 0x00, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x00}, nil, 0x9000).
+		Expect([]byte{0x0E, 0xB0, 0x00, 0x00, 0x04}, nil, 0x9000).
+		Expect([]byte{0x02, 0x70, 0x80, 0x02}, nil, 0x9000)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	channel, err := service.OpenChannel(ctx)
+	if err != nil {
+		t.Fatalf("OpenChannel() error = %v", err)
+	}
+	if channel.Number() != 2 {
+		t.Errorf("Number() = %d, want 2", channel.Number())
+	}
+	if _, err := channel.SelectApplication(ctx, testAID); err != nil {
+		t.Fatalf("SelectApplication() error = %v", err)
+	}
+	// Secure messaging indication is kept alongside the channel number
+	if _, err := channel.Transmit(ctx, &domain.APDU{CLA: 0x0C, INS: 0xB0, Le: 4}); err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if err := channel.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := channel.Transmit(ctx, &domain.APDU{INS: 0xB0, Le: 1}); err == nil {
+		t.Error("Transmit() on a closed channel should fail")
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_OpenChannel_NotSupported(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x70, 0x00, 0x00, 0x01}, nil, 0x6881)
+	service := newTestService(t, card)
+
+	_, err := service.OpenChannel(context.Background())
+	var statusErr *domain.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != domain.StatusFunctionNotFound {
+		t.Fatalf("OpenChannel() error = %v, want status 6881", err)
+	}
+	if err.Error() != "card does not support logical channels" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestSmartCardService_OpenChannel_NoneLeft(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x70, 0x00, 0x00, 0x01}, nil, 0x6A81)
+	service := newTestService(t, card)
+
+	_, err := service.OpenChannel(context.Background())
+	var statusErr *domain.StatusError
+	if !errors.As(err, &statusErr) || statusErr.INS != 0x70 || statusErr.Hint != "no logical channel left to open" {
+		t.Errorf("OpenChannel() error = %v, want a MANAGE CHANNEL command error", err)
+	}
+}
+
+func TestChannel_Command_FurtherInterindustry(t *testing.T) {
+	channel := &Channel{number: 5}
+
+	apdu, err := channel.Command(&domain.APDU{CLA: 0x10, INS: 0xB0})
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if apdu.CLA != 0x51 {
+		t.Errorf("CLA = %02X, want 51", apdu.CLA)
+	}
+
+	// Authenticated SM headers have no further interindustry encoding
+	if _, err := channel.Command(&domain.APDU{CLA: 0x0C, INS: 0xB0}); err == nil {
+		t.Error("Command() with CLA 0C on channel 5 should fail")
+	}
+}
+
+func TestChannel_Simulator(t *testing.T) {
+	sim, err := infrastructure.LoadCardSimulator("../infrastructure/testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	service := NewSmartCardService(sim, nil)
+	ctx := context.Background()
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	channel, err := service.OpenChannel(ctx)
+	if err != nil {
+		t.Fatalf("OpenChannel() error = %v", err)
+	}
+	if _, err := channel.SelectApplication(ctx, testAID); err != nil {
+		t.Fatalf("SelectApplication() on channel error = %v", err)
+	}
+
+	// The application stays selected on the channel while the basic channel
+	// reads EF.DIR under the MF
+	if _, err := service.Transmit(ctx, &domain.APDU{INS: 0xA4, P1: 0x02, P2: 0x0C, Data: []byte{0x2F, 0x00}}); err != nil {
+		t.Fatalf("SELECT EF.DIR error = %v", err)
+	}
+	resp, err := channel.Transmit(ctx, &domain.APDU{INS: 0xA4, P1: 0x02, P2: 0x0C, Data: []byte{0xC0, 0x00}})
+	if err != nil || !resp.IsSuccess() {
+		t.Fatalf("SELECT EF.Certificate on channel = %v, %v", resp, err)
+	}
+
+	if err := channel.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestChannel_ClosedByReset(t *testing.T) {
+	sim, err := infrastructure.LoadCardSimulator("../infrastructure/testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	service := NewSmartCardService(sim, nil)
+	ctx := context.Background()
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	// Leave acknowledges a reset by another application, which closed the
+	// channels on the card as well
+	for _, mode := range []domain.ResetMode{domain.ResetLeave, domain.ResetWarm} {
+		channel, err := service.OpenChannel(ctx)
+		if err != nil {
+			t.Fatalf("OpenChannel() error = %v", err)
+		}
+		if err := service.Reconnect(ctx, mode); err != nil {
+			t.Fatalf("Reconnect(%s) error = %v", mode, err)
+		}
+		_, err = channel.Transmit(ctx, &domain.APDU{INS: 0xA4, P1: 0x00, P2: 0x0C})
+		if !errors.Is(err, domain.ErrChannelClosedByReset) {
+			t.Errorf("Transmit() after Reconnect(%s) error = %v, want ErrChannelClosedByReset", mode, err)
+		}
+		if err := channel.Close(ctx); err != nil {
+			t.Errorf("Close() after Reconnect(%s) error = %v", mode, err)
+		}
+	}
+
+	// Channels opened after the reset work
+	channel, err := service.OpenChannel(ctx)
+	if err != nil {
+		t.Fatalf("OpenChannel() after a reset error = %v", err)
+	}
+	if _, err := channel.Transmit(ctx, &domain.APDU{INS: 0xA4, P1: 0x00, P2: 0x0C}); err != nil {
+		t.Errorf("Transmit() on a new channel error = %v", err)
+	}
+}
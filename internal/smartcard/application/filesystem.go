@@ -0,0 +1,217 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// maxReadBinaryOffset is the largest offset of READ BINARY with even INS,
+// which has 15 bits for it
+const maxReadBinaryOffset = 0x7FFF
+
+// SelectMF selects the master file (SELECT by FID 3F00)
+func (s *SmartCardService) SelectMF(ctx context.Context, ret domain.SelectResponse) (*domain.FileControlParameters, error) {
+	return s.SelectFile(ctx, domain.MasterFileID, ret)
+}
+
+// SelectFile selects an MF, DF or EF by file identifier (ISO/IEC 7816-4
+// §11.2.2). Which files are reachable by FID from the current DF depends on
+// the card. The parameters are nil with SelectReturnNone.
+func (s *SmartCardService) SelectFile(ctx context.Context, fid uint16, ret domain.SelectResponse) (*domain.FileControlParameters, error) {
+	return s.selectFile(ctx, 0x00, []byte{byte(fid >> 8), byte(fid)}, ret)
+}
+
+// SelectPath selects a file by path from the MF or from the current DF
+func (s *SmartCardService) SelectPath(ctx context.Context, path domain.FilePath, ret domain.SelectResponse) (*domain.FileControlParameters, error) {
+	switch {
+	case path.FromMF && len(path.FIDs) == 0:
+		return s.SelectMF(ctx, ret)
+	case path.FromMF:
+		return s.selectFile(ctx, 0x08, path.Bytes(), ret) // Path from the MF
+	case len(path.FIDs) == 0:
+		return nil, errors.New("empty file path")
+	default:
+		return s.selectFile(ctx, 0x09, path.Bytes(), ret) // Path from the current DF
+	}
+}
+
+// SelectParent selects the parent DF of the current DF
+func (s *SmartCardService) SelectParent(ctx context.Context, ret domain.SelectResponse) (*domain.FileControlParameters, error) {
+	return s.selectFile(ctx, 0x03, nil, ret)
+}
+
+// selectFile sends SELECT and decodes the returned FCP or FCI
+func (s *SmartCardService) selectFile(ctx context.Context, p1 byte, data []byte, ret domain.SelectResponse) (*domain.FileControlParameters, error) {
+	apdu := &domain.APDU{
+		CLA:  0x00,
+		INS:  0xA4, // SELECT
+		P1:   p1,
+		P2:   byte(ret), // First or only occurrence
+		Data: data,
+	}
+	if ret != domain.SelectReturnNone {
+		apdu.Le = domain.MaxShortLe
+	}
+
+	resp, err := s.transmit(ctx, apdu)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, domain.NewCommandError(apdu, resp)
+	}
+	if ret == domain.SelectReturnNone || len(resp.Data) == 0 {
+		return nil, nil
+	}
+
+	fcp, err := domain.ParseFileControlParameters(resp.Data)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"SELECT returned invalid file control parameters", err)
+	}
+	return fcp, nil
+}
+
+// ReadBinary reads up to length bytes (0 for 256) of the current EF from
+// offset. Fewer bytes are returned at the end of the file.
+func (s *SmartCardService) ReadBinary(ctx context.Context, offset, length int) ([]byte, error) {
+	apdu, err := readBinaryAPDU(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := s.readChunk(ctx, apdu)
+	return data, err
+}
+
+// ReadBinarySFI reads up to length bytes (0 for 256) from offset (0 to 255)
+// of the EF with short identifier sfi in the current DF, which becomes the
+// current EF
+func (s *SmartCardService) ReadBinarySFI(ctx context.Context, sfi byte, offset, length int) ([]byte, error) {
+	if sfi == 0 || sfi > 30 {
+		return nil, fmt.Errorf("invalid short EF identifier %d", sfi)
+	}
+	if offset < 0 || offset > 0xFF {
+		return nil, fmt.Errorf("READ BINARY offset %d out of range 0-255 with a short EF identifier", offset)
+	}
+	apdu := &domain.APDU{
+		CLA: 0x00,
+		INS: 0xB0,       // READ BINARY
+		P1:  0x80 | sfi, // b8=1: short EF identifier in b5-b1
+		P2:  byte(offset),
+		Le:  readLength(length),
+	}
+	data, _, err := s.readChunk(ctx, apdu)
+	return data, err
+}
+
+// ReadFile selects an EF by file identifier and reads all of it, in one
+// transaction. The size comes from the FCP; without it the file is read
+// until the card reports its end.
+func (s *SmartCardService) ReadFile(ctx context.Context, fid uint16) ([]byte, error) {
+	var content []byte
+	err := s.WithTransaction(ctx, func(domain.Card) error {
+		fcp, err := s.SelectFile(ctx, fid, domain.SelectReturnFCP)
+		if err != nil {
+			return err
+		}
+		size := -1
+		if fcp != nil {
+			if fcp.IsDF() {
+				return fmt.Errorf("file %04X is a DF", fid)
+			}
+			size = fcp.Size
+		}
+		content, err = s.readToEnd(ctx, nil, size)
+		return err
+	})
+	return content, err
+}
+
+// ReadFileSFI reads all of the EF with short identifier sfi in the current
+// DF, without selecting it first
+func (s *SmartCardService) ReadFileSFI(ctx context.Context, sfi byte) ([]byte, error) {
+	var content []byte
+	err := s.WithTransaction(ctx, func(domain.Card) error {
+		first, err := s.ReadBinarySFI(ctx, sfi, 0, domain.MaxShortLe)
+		if err != nil || len(first) < domain.MaxShortLe {
+			content = first
+			return err
+		}
+		content, err = s.readToEnd(ctx, first, -1)
+		return err
+	})
+	return content, err
+}
+
+// readToEnd appends the rest of the current EF to content, in chunks of up
+// to 256 bytes. With a known size it stops there; otherwise at a short chunk,
+// 6282, or an offset rejected with 6B00 (cards that only notice the end when
+// reading past it).
+func (s *SmartCardService) readToEnd(ctx context.Context, content []byte, size int) ([]byte, error) {
+	for size < 0 || len(content) < size {
+		length := domain.MaxShortLe
+		if size >= 0 {
+			length = min(length, size-len(content))
+		}
+		apdu, err := readBinaryAPDU(len(content), length)
+		if err != nil {
+			return nil, err
+		}
+
+		data, eof, err := s.readChunk(ctx, apdu)
+		var statusErr *domain.StatusError
+		if size < 0 && len(content) > 0 && errors.As(err, &statusErr) && statusErr.Code == domain.StatusWrongOffset {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, data...)
+		if eof {
+			break
+		}
+	}
+	return content, nil
+}
+
+// readChunk sends READ BINARY and reports whether the end of the file was
+// reached: fewer bytes than asked for, or warning 6282
+func (s *SmartCardService) readChunk(ctx context.Context, apdu *domain.APDU) ([]byte, bool, error) {
+	resp, err := s.transmit(ctx, apdu)
+	if err != nil {
+		return nil, false, err
+	}
+	switch resp.StatusCode() {
+	case domain.StatusSuccess:
+		return resp.Data, len(resp.Data) < apdu.Le, nil
+	case domain.StatusWarningEOF:
+		return resp.Data, true, nil
+	}
+	return nil, false, domain.NewCommandError(apdu, resp)
+}
+
+// readBinaryAPDU builds READ BINARY of the current EF
+func readBinaryAPDU(offset, length int) (*domain.APDU, error) {
+	if offset < 0 || offset > maxReadBinaryOffset {
+		return nil, fmt.Errorf("READ BINARY offset %d out of range 0-%d", offset, maxReadBinaryOffset)
+	}
+	return &domain.APDU{
+		CLA: 0x00,
+		INS: 0xB0,              // READ BINARY
+		P1:  byte(offset >> 8), // b8=0: offset in b7-b1 and P2
+		P2:  byte(offset),
+		Le:  readLength(length),
+	}, nil
+}
+
+// readLength maps a requested length to Le, 0 meaning as much as fits in a
+// short response
+func readLength(length int) int {
+	if length <= 0 {
+		return domain.MaxShortLe
+	}
+	return length
+}
@@ -0,0 +1,198 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+// sequence returns n bytes counting up from 0
+func sequence(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestSmartCardService_SelectFile(t *testing.T) {
+	fcp := []byte{0x62, 0x0E, 0x80, 0x02, 0x00, 0x16, 0x82, 0x01, 0x01, 0x83, 0x02, 0x01, 0x1C, 0x88, 0x01, 0xE0}
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x00, 0x0C, 0x02, 0x3F, 0x00}, nil, 0x9000).
+		Expect([]byte{0x00, 0xA4, 0x08, 0x04, 0x02, 0x01, 0x1C, 0x00}, fcp, 0x9000).
+		Expect([]byte{0x00, 0xA4, 0x09, 0x0C, 0x04, 0xDF, 0x01, 0x01, 0x01}, nil, 0x9000).
+		Expect([]byte{0x00, 0xA4, 0x03, 0x0C}, nil, 0x9000).
+		Expect([]byte{0x00, 0xA4, 0x00, 0x04, 0x02, 0x01, 0x99, 0x00}, nil, 0x6A82)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	if info, err := service.SelectMF(ctx, domain.SelectReturnNone); err != nil || info != nil {
+		t.Fatalf("SelectMF() = %v, %v, want no parameters", info, err)
+	}
+	info, err := service.SelectPath(ctx, domain.FilePath{FromMF: true, FIDs: []uint16{0x011C}}, domain.SelectReturnFCP)
+	if err != nil {
+		t.Fatalf("SelectPath() error = %v", err)
+	}
+	if info.Size != 22 || info.FID != 0x011C || info.SFI != 28 {
+		t.Errorf("SelectPath() = %+v, want EF 011C of 22 bytes with SFI 28", info)
+	}
+	if _, err := service.SelectPath(ctx, domain.FilePath{FIDs: []uint16{0xDF01, 0x0101}}, domain.SelectReturnNone); err != nil {
+		t.Fatalf("SelectPath() from the current DF error = %v", err)
+	}
+	if _, err := service.SelectParent(ctx, domain.SelectReturnNone); err != nil {
+		t.Fatalf("SelectParent() error = %v", err)
+	}
+	if _, err := service.SelectFile(ctx, 0x0199, domain.SelectReturnFCP); !errors.Is(err, domain.ErrFileNotFound) {
+		t.Errorf("SelectFile() error = %v, want ErrFileNotFound", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_ReadFile(t *testing.T) {
+	selectEF := []byte{0x00, 0xA4, 0x00, 0x04, 0x02, 0x01, 0x01, 0x00}
+	content := sequence(300)
+	tests := []struct {
+		name string
+		card *infrastructure.ScriptedCard
+		want []byte
+	}{
+		{
+			name: "size from the FCP",
+			card: infrastructure.NewScriptedCard().
+				Expect(selectEF, []byte{0x62, 0x07, 0x80, 0x02, 0x01, 0x2C, 0x82, 0x01, 0x01}, 0x9000).
+				Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, content[:256], 0x9000).
+				Expect([]byte{0x00, 0xB0, 0x01, 0x00, 0x2C}, content[256:], 0x9000),
+			want: content,
+		},
+		{
+			name: "end reported with 6282",
+			card: infrastructure.NewScriptedCard().
+				Expect(selectEF, []byte{0x62, 0x03, 0x82, 0x01, 0x01}, 0x9000).
+				Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, content[:256], 0x9000).
+				Expect([]byte{0x00, 0xB0, 0x01, 0x00, 0x00}, content[256:], 0x6282),
+			want: content,
+		},
+		{
+			name: "end reported with 6B00",
+			card: infrastructure.NewScriptedCard().
+				Expect(selectEF, []byte{0x62, 0x03, 0x82, 0x01, 0x01}, 0x9000).
+				Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, content[:256], 0x9000).
+				Expect([]byte{0x00, 0xB0, 0x01, 0x00, 0x00}, nil, 0x6B00),
+			want: content[:256],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, tt.card)
+			got, err := service.ReadFile(context.Background(), 0x0101)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadFile() returned %d bytes, want %d", len(got), len(tt.want))
+			}
+			if err := tt.card.Verify(); err != nil {
+				t.Error(err)
+			}
+			if tt.card.Transactions() != 1 {
+				t.Errorf("Transactions() = %d, want the file read in one", tt.card.Transactions())
+			}
+		})
+	}
+}
+
+func TestSmartCardService_ReadFile_Errors(t *testing.T) {
+	selectDF := []byte{0x00, 0xA4, 0x00, 0x04, 0x02, 0xDF, 0x01, 0x00}
+	card := infrastructure.NewScriptedCard().
+		Expect(selectDF, []byte{0x62, 0x03, 0x82, 0x01, 0x38}, 0x9000).
+		Expect([]byte{0x00, 0xA4, 0x00, 0x04, 0x02, 0x01, 0x01, 0x00}, []byte{0x62, 0x04, 0x80, 0x02, 0x00, 0x10}, 0x9000).
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x10}, nil, 0x6982)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	if _, err := service.ReadFile(ctx, 0xDF01); err == nil {
+		t.Error("ReadFile() of a DF expected error")
+	}
+	if _, err := service.ReadFile(ctx, 0x0101); !errors.Is(err, domain.ErrSecurityStatusNotSatisfied) {
+		t.Errorf("ReadFile() error = %v, want ErrSecurityStatusNotSatisfied", err)
+	}
+	if _, err := service.ReadBinary(ctx, 0x8000, 0); err == nil {
+		t.Error("ReadBinary() beyond offset 7FFF expected error")
+	}
+}
+
+func TestSmartCardService_ReadBinarySFI(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0xB0, 0x9C, 0x04, 0x08}, sequence(8), 0x9000).
+		Expect([]byte{0x00, 0xB0, 0x81, 0x00, 0x00}, sequence(256), 0x9000).
+		Expect([]byte{0x00, 0xB0, 0x01, 0x00, 0x00}, sequence(4), 0x9000)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	data, err := service.ReadBinarySFI(ctx, 28, 4, 8)
+	if err != nil || len(data) != 8 {
+		t.Fatalf("ReadBinarySFI() = %02X, %v, want 8 bytes", data, err)
+	}
+	data, err = service.ReadFileSFI(ctx, 1)
+	if err != nil || len(data) != 260 {
+		t.Fatalf("ReadFileSFI() = %d bytes, %v, want 260", len(data), err)
+	}
+	if _, err := service.ReadBinarySFI(ctx, 31, 0, 0); err == nil {
+		t.Error("ReadBinarySFI() with SFI 31 expected error")
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_FileSystem_Simulator(t *testing.T) {
+	sim, err := infrastructure.LoadCardSimulator("../infrastructure/testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	service := NewSmartCardService(sim, nil)
+	ctx := context.Background()
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	path, _ := domain.ParseFilePath("3F00/011C")
+	info, err := service.SelectPath(ctx, path, domain.SelectReturnFCP)
+	if err != nil {
+		t.Fatalf("SelectPath() error = %v", err)
+	}
+	cardAccess, err := service.ReadBinary(ctx, 0, 0)
+	if err != nil || len(cardAccess) != info.Size {
+		t.Fatalf("ReadBinary() = %d bytes, %v, want %d", len(cardAccess), err, info.Size)
+	}
+	if bySFI, err := service.ReadFileSFI(ctx, info.SFI); err != nil || !bytes.Equal(bySFI, cardAccess) {
+		t.Errorf("ReadFileSFI() = %02X, %v, want %02X", bySFI, err, cardAccess)
+	}
+
+	if _, err := service.SelectApplication(ctx, testAID); err != nil {
+		t.Fatalf("SelectApplication() error = %v", err)
+	}
+	certificate, err := service.ReadFile(ctx, 0xC000)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	want, _ := os.ReadFile("../infrastructure/testdata/cei/certificate.txt")
+	if !bytes.Equal(certificate, want) {
+		t.Errorf("ReadFile() returned %d bytes, want the %d of certificate.txt", len(certificate), len(want))
+	}
+	if _, err := service.ReadFile(ctx, 0x0101); !errors.Is(err, domain.ErrSecurityStatusNotSatisfied) {
+		t.Errorf("ReadFile() of EF.PersonalData before VERIFY error = %v, want ErrSecurityStatusNotSatisfied", err)
+	}
+
+	if info, err := service.SelectParent(ctx, domain.SelectReturnFCP); err != nil || info.FID != domain.MasterFileID {
+		t.Errorf("SelectParent() = %+v, %v, want the MF", info, err)
+	}
+}
@@ -0,0 +1,158 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// ProtectLastPINTry makes VerifyPIN and ChangePIN query the retry counter
+// first and refuse with domain.ErrLastPINTry instead of spending the last
+// try. A blocked CEI PIN can only be unblocked at the issuing office, so this
+// is on by default; ProtectLastPINTry(false) forces the last try.
+func (s *SmartCardService) ProtectLastPINTry(protect bool) {
+	s.protectLastPINTry = protect
+}
+
+// SetPINFormat sets the format of the PIN with reference pinRef. The CEI
+// formats of PIN1 and PIN2 are set by default; other references use
+// domain.GenericPINFormat.
+func (s *SmartCardService) SetPINFormat(pinRef byte, format domain.PINFormat) {
+	s.pinFormats[pinRef] = format
+}
+
+// PINFormat returns the format of the PIN with reference pinRef
+func (s *SmartCardService) PINFormat(pinRef byte) domain.PINFormat {
+	if format, ok := s.pinFormats[pinRef]; ok {
+		return format
+	}
+	return domain.GenericPINFormat
+}
+
+// PINRetries queries the state of a PIN with a VERIFY without data, which does
+// not spend a try (ISO/IEC 7816-4 §11.5.6)
+func (s *SmartCardService) PINRetries(ctx context.Context, pinRef byte) (*domain.PINStatus, error) {
+	apdu := retryQueryAPDU(pinRef)
+	resp, err := s.transmit(ctx, apdu)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewPINStatus(apdu, resp)
+}
+
+// ChangePIN sends CHANGE REFERENCE DATA to replace a PIN, presenting the
+// current one (ISO/IEC 7816-4 §11.5.7). Both must match the PIN format; a
+// wrong current PIN spends a try, unless it is the last one and
+// ProtectLastPINTry is set.
+func (s *SmartCardService) ChangePIN(ctx context.Context, current, replacement []byte, pinRef byte) error {
+	format := s.PINFormat(pinRef)
+	currentData, err := format.Encode(current)
+	if err != nil {
+		return err
+	}
+	defer clear(currentData)
+	replacementData, err := newPINFormat(format).Encode(replacement)
+	if err != nil {
+		return err
+	}
+	defer clear(replacementData)
+
+	apdu := &domain.APDU{
+		CLA:  0x00,
+		INS:  0x24,   // CHANGE REFERENCE DATA
+		P1:   0x00,   // Current and new reference data
+		P2:   pinRef, // PIN reference
+		Data: append(currentData, replacementData...),
+	}
+	return s.pinCommand(ctx, apdu, s.protectLastPINTry)
+}
+
+// UnblockPIN sends RESET RETRY COUNTER with the PUK (ISO/IEC 7816-4
+// §11.5.10). With a replacement, which must match the PIN format, the PIN is
+// also changed; otherwise only its retry counter is reset.
+func (s *SmartCardService) UnblockPIN(ctx context.Context, puk, replacement []byte, pinRef byte) error {
+	data, err := pukFormat().Encode(puk)
+	if err != nil {
+		return err
+	}
+	defer clear(data)
+
+	apdu := &domain.APDU{
+		CLA:  0x00,
+		INS:  0x2C,   // RESET RETRY COUNTER
+		P1:   0x01,   // Resetting code only
+		P2:   pinRef, // PIN reference
+		Data: data,
+	}
+	if len(replacement) > 0 {
+		replacementData, err := newPINFormat(s.PINFormat(pinRef)).Encode(replacement)
+		if err != nil {
+			return err
+		}
+		defer clear(replacementData)
+		apdu.P1 = 0x00 // Resetting code and new reference data
+		apdu.Data = append(apdu.Data, replacementData...)
+	}
+	return s.pinCommand(ctx, apdu, false)
+}
+
+// pukFormat is the format of the PUK, whose reference is not known
+func pukFormat() domain.PINFormat {
+	format := domain.GenericPINFormat
+	format.Name = "PUK"
+	return format
+}
+
+// newPINFormat names the replacement PIN in validation errors
+func newPINFormat(format domain.PINFormat) domain.PINFormat {
+	format.Name = "new " + format.Name
+	return format
+}
+
+// pinCommand sends a PIN command inside a transaction, first checking the
+// retry counter of the PIN in P2 when checkLastTry is set. Failed checks are
+// returned as *domain.PINError. The command data is wiped afterwards; callers
+// wipe the buffers it was assembled from.
+func (s *SmartCardService) pinCommand(ctx context.Context, apdu *domain.APDU, checkLastTry bool) error {
+	defer clear(apdu.Data)
+	return s.WithTransaction(ctx, func(domain.Card) error {
+		if checkLastTry {
+			if err := s.checkLastPINTry(ctx, apdu.P2); err != nil {
+				return err
+			}
+		}
+
+		resp, err := s.transmit(ctx, apdu)
+		if err != nil {
+			return err
+		}
+		if !resp.IsSuccess() {
+			return domain.NewCommandError(apdu, resp)
+		}
+		return nil
+	})
+}
+
+// checkLastPINTry refuses to go on when the PIN has one try left. A blocked
+// PIN is left to VERIFY, which fails without side effects.
+func (s *SmartCardService) checkLastPINTry(ctx context.Context, pinRef byte) error {
+	status, err := s.PINRetries(ctx, pinRef)
+	if err != nil {
+		return err
+	}
+	if !status.Verified && status.RetriesLeft == 1 {
+		return fmt.Errorf("PIN %02X: %w", pinRef, domain.ErrLastPINTry)
+	}
+	return nil
+}
+
+// retryQueryAPDU builds VERIFY without data for a PIN reference
+func retryQueryAPDU(pinRef byte) *domain.APDU {
+	return &domain.APDU{
+		CLA: 0x00,
+		INS: 0x20,   // VERIFY
+		P1:  0x00,   // No information given
+		P2:  pinRef, // PIN reference
+	}
+}
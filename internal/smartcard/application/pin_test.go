@@ -0,0 +1,317 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+func TestSmartCardService_PINRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		sw       uint16
+		verified bool
+		retries  int
+		blocked  bool
+	}{
+		{"retries left", 0x63C3, false, 3, false},
+		{"verified", 0x9000, true, 0, false},
+		{"blocked", 0x6983, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := infrastructure.NewScriptedCard().
+				Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, tt.sw)
+			service := newTestService(t, card)
+
+			status, err := service.PINRetries(context.Background(), domain.PIN1Reference)
+			if err != nil {
+				t.Fatalf("PINRetries() error = %v", err)
+			}
+			if status.Verified != tt.verified || status.RetriesLeft != tt.retries || status.Blocked() != tt.blocked {
+				t.Errorf("PINRetries() = %+v (blocked %v), want verified %v, %d retries, blocked %v",
+					status, status.Blocked(), tt.verified, tt.retries, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestSmartCardService_PINRetries_Unsupported(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x6A86)
+	service := newTestService(t, card)
+
+	if _, err := service.PINRetries(context.Background(), domain.PIN1Reference); !errors.Is(err, domain.ErrIncorrectP1P2) {
+		t.Errorf("PINRetries() error = %v, want ErrIncorrectP1P2", err)
+	}
+}
+
+func TestSmartCardService_ChangePIN(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C2).
+		Expect([]byte{0x00, 0x24, 0x00, 0x01, 0x08, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38}, nil, 0x63C1)
+	service := newTestService(t, card)
+
+	err := service.ChangePIN(context.Background(), []byte("1234"), []byte("5678"), domain.PIN1Reference)
+	var pinErr *domain.PINError
+	if !errors.As(err, &pinErr) || pinErr.RetriesLeft != 1 {
+		t.Fatalf("ChangePIN() error = %v, want PINError with 1 retry left", err)
+	}
+	if pinErr.Hint != "wrong current PIN" {
+		t.Errorf("Hint = %q, want %q", pinErr.Hint, "wrong current PIN")
+	}
+}
+
+func TestSmartCardService_UnblockPIN(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x2C, 0x01, 0x02, 0x04, 0x39, 0x39, 0x39, 0x39}, nil, 0x9000).
+		Expect([]byte{0x00, 0x2C, 0x00, 0x02, 0x0A, 0x39, 0x39, 0x39, 0x39, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36}, nil, 0x9000)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	if err := service.UnblockPIN(ctx, []byte("9999"), nil, domain.PIN2Reference); err != nil {
+		t.Errorf("UnblockPIN() error = %v", err)
+	}
+	if err := service.UnblockPIN(ctx, []byte("9999"), []byte("123456"), domain.PIN2Reference); err != nil {
+		t.Errorf("UnblockPIN() with new PIN error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_ProtectLastPINTry(t *testing.T) {
+	// On by default
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C1).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C2).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}, nil, 0x9000)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	if err := service.VerifyPIN(ctx, []byte("1234"), domain.PIN1Reference); !errors.Is(err, domain.ErrLastPINTry) {
+		t.Fatalf("VerifyPIN() with one try left error = %v, want ErrLastPINTry", err)
+	}
+	if err := service.VerifyPIN(ctx, []byte("1234"), domain.PIN1Reference); err != nil {
+		t.Fatalf("VerifyPIN() error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_ProtectLastPINTry_ChangePIN(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C1).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C2).
+		Expect([]byte{0x00, 0x24, 0x00, 0x01, 0x08, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38}, nil, 0x9000)
+	service := newTestService(t, card)
+	ctx := context.Background()
+
+	if err := service.ChangePIN(ctx, []byte("1234"), []byte("5678"), domain.PIN1Reference); !errors.Is(err, domain.ErrLastPINTry) {
+		t.Fatalf("ChangePIN() with one try left error = %v, want ErrLastPINTry", err)
+	}
+	if err := service.ChangePIN(ctx, []byte("1234"), []byte("5678"), domain.PIN1Reference); err != nil {
+		t.Fatalf("ChangePIN() error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_PINManagement_Simulator(t *testing.T) {
+	sim, err := infrastructure.LoadCardSimulator("../infrastructure/testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	service := NewSmartCardService(sim, nil)
+	service.ProtectLastPINTry(false) // Spend the last try to block PIN2
+	ctx := context.Background()
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	// Block PIN2, unblock it with the PUK and a new PIN, then change it back
+	for range 3 {
+		service.VerifyPIN(ctx, []byte("000000"), domain.PIN2Reference)
+	}
+	if status, err := service.PINRetries(ctx, domain.PIN2Reference); err != nil || !status.Blocked() {
+		t.Fatalf("PINRetries() = %+v, %v, want blocked", status, err)
+	}
+	if err := service.UnblockPIN(ctx, []byte("12345678"), []byte("654321"), domain.PIN2Reference); err != nil {
+		t.Fatalf("UnblockPIN() error = %v", err)
+	}
+	if err := service.ChangePIN(ctx, []byte("654321"), []byte("123456"), domain.PIN2Reference); err != nil {
+		t.Fatalf("ChangePIN() error = %v", err)
+	}
+	if err := service.VerifyPIN(ctx, []byte("123456"), domain.PIN2Reference); err != nil {
+		t.Errorf("VerifyPIN() with the changed PIN error = %v", err)
+	}
+}
+
+func TestSmartCardService_PINFormat(t *testing.T) {
+	// Nothing reaches the card when a PIN does not match its format
+	service := newTestService(t, infrastructure.NewScriptedCard())
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"PIN1 too long", service.VerifyPIN(ctx, []byte("123456789"), domain.PIN1Reference), "PIN1 must have 4 digits"},
+		{"PIN2 letters", service.VerifyPIN(ctx, []byte("12345a"), domain.PIN2Reference), "PIN2 must contain only digits"},
+		{"new PIN", service.ChangePIN(ctx, []byte("1234"), []byte("12345"), domain.PIN1Reference), "new PIN1 must have 4 digits"},
+		{"PUK", service.UnblockPIN(ctx, nil, nil, domain.PIN1Reference), "PUK must have 4 to 12 digits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, domain.ErrInvalidPIN) || !strings.Contains(tt.err.Error(), tt.want) {
+				t.Errorf("error = %v, want ErrInvalidPIN: %s", tt.err, tt.want)
+			}
+		})
+	}
+
+	// Other references can be given a format
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x81}, nil, 0x63C3).
+		Expect([]byte{0x00, 0x20, 0x00, 0x81, 0x08, 0x24, 0x12, 0x34, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, nil, 0x9000)
+	service = newTestService(t, card)
+	service.SetPINFormat(0x81, domain.PINFormat{Encoding: domain.PINEncodingFormat2, MinLength: 4, MaxLength: 12})
+	if err := service.VerifyPIN(ctx, []byte("1234"), 0x81); err != nil {
+		t.Errorf("VerifyPIN() with format 2 error = %v", err)
+	}
+}
+
+// scriptedProvider answers PIN requests in order and records them and the
+// PIN pad entries
+type scriptedProvider struct {
+	answers  []string
+	requests []domain.PINRequest
+	pinPad   []domain.PINRequest
+}
+
+func (p *scriptedProvider) PINPadEntry(req domain.PINRequest) {
+	p.pinPad = append(p.pinPad, req)
+}
+
+func (p *scriptedProvider) RequestSecret(_ context.Context, req domain.PINRequest) (*domain.Secret, error) {
+	p.requests = append(p.requests, req)
+	if len(p.answers) == 0 {
+		return nil, domain.ErrPINEntryCancelled
+	}
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return domain.NewSecret([]byte(answer)), nil
+}
+
+func TestSmartCardService_VerifyPINInteractive(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C2).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}, nil, 0x9000)
+	service := newTestService(t, card)
+	provider := &scriptedProvider{answers: []string{"1234"}}
+	service.SetPINProvider(provider)
+
+	if err := service.VerifyPINInteractive(context.Background(), domain.PIN1Reference); err != nil {
+		t.Fatalf("VerifyPINInteractive() error = %v", err)
+	}
+	if len(provider.requests) != 1 || provider.requests[0].Prompt() != "Enter PIN1 (2 tries left)" {
+		t.Errorf("requests = %+v, want one for PIN1 with 2 tries left", provider.requests)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_VerifyPINInteractive_NotAsked(t *testing.T) {
+	tests := []struct {
+		name    string
+		sw      uint16
+		protect bool
+		want    error
+	}{
+		{"blocked", 0x6983, false, domain.ErrAuthenticationMethodBlocked},
+		{"last try protected", 0x63C1, true, domain.ErrLastPINTry},
+		{"already verified", 0x9000, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := infrastructure.NewScriptedCard().
+				Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, tt.sw)
+			service := newTestService(t, card)
+			service.ProtectLastPINTry(tt.protect)
+			provider := &scriptedProvider{answers: []string{"1234"}}
+			service.SetPINProvider(provider)
+
+			err := service.VerifyPINInteractive(context.Background(), domain.PIN1Reference)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("VerifyPINInteractive() error = %v, want %v", err, tt.want)
+			}
+			if len(provider.requests) != 0 {
+				t.Errorf("the PIN provider should not be asked, got %+v", provider.requests)
+			}
+		})
+	}
+}
+
+func TestSmartCardService_ChangePINInteractive(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []string
+		want    error
+	}{
+		{"mismatch", []string{"1234", "5678", "5679"}, domain.ErrNewPINMismatch},
+		{"invalid new PIN", []string{"1234", "56789"}, domain.ErrInvalidPIN},
+		{"cancelled", []string{"1234"}, domain.ErrPINEntryCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing but the retry query reaches the card
+			card := infrastructure.NewScriptedCard().
+				Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C3)
+			service := newTestService(t, card)
+			service.SetPINProvider(&scriptedProvider{answers: tt.answers})
+
+			if err := service.ChangePINInteractive(context.Background(), domain.PIN1Reference); !errors.Is(err, tt.want) {
+				t.Errorf("ChangePINInteractive() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmartCardService_PINInteractive_Simulator(t *testing.T) {
+	sim, err := infrastructure.LoadCardSimulator("../infrastructure/testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	service := NewSmartCardService(sim, nil)
+	ctx := context.Background()
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	provider := &scriptedProvider{answers: []string{"12345678", "4321", "4321", "1234", "5678", "5678", "5678"}}
+	service.SetPINProvider(provider)
+
+	if err := service.UnblockPINInteractive(ctx, domain.PIN1Reference); err != nil {
+		t.Fatalf("UnblockPINInteractive() error = %v", err)
+	}
+	if err := service.ChangePINInteractive(ctx, domain.PIN1Reference); !errors.Is(err, domain.ErrVerificationFailed) {
+		t.Fatalf("ChangePINInteractive() with the old PIN error = %v, want ErrVerificationFailed", err)
+	}
+	provider.answers = []string{"4321", "5678", "5678", "5678"}
+	if err := service.ChangePINInteractive(ctx, domain.PIN1Reference); err != nil {
+		t.Fatalf("ChangePINInteractive() error = %v", err)
+	}
+	sim.Reconnect(ctx, domain.ResetWarm)
+	if err := service.VerifyPINInteractive(ctx, domain.PIN1Reference); err != nil {
+		t.Errorf("VerifyPINInteractive() error = %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package application
+
+import (
+	"context"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// UsePINPad sets whether VerifyPIN, VerifyPINInteractive and
+// ChangePINInteractive let the user type PINs on the reader's PIN pad when it
+// has one (the default). PINs typed there never reach the host.
+func (s *SmartCardService) UsePINPad(use bool) {
+	s.usePINPad = use
+}
+
+// ReaderFeatures returns the PC/SC part 10 features of the connected reader,
+// empty when the card is not reached through a reader (simulator)
+func (s *SmartCardService) ReaderFeatures(ctx context.Context) (domain.ReaderFeatures, error) {
+	if s.card == nil {
+		return nil, errNotConnected()
+	}
+	controller, ok := s.card.(domain.Controller)
+	if !ok {
+		return domain.ReaderFeatures{}, nil
+	}
+	return controller.ReaderFeatures(ctx)
+}
+
+// canVerifyOnPINPad reports whether a PIN of reference pinRef is verified on
+// the reader's PIN pad rather than entered on the host
+func (s *SmartCardService) canVerifyOnPINPad(ctx context.Context, pinRef byte) (bool, error) {
+	if _, err := domain.NewVerifyPINStructure(retryQueryAPDU(pinRef), s.PINFormat(pinRef)); err != nil {
+		return false, nil
+	}
+	_, _, ok, err := s.pinPadControl(ctx, domain.FeatureVerifyPINDirect)
+	return ok, err
+}
+
+// verifyOnPINPad verifies a PIN typed on the reader's PIN pad. It reports
+// false when the PIN has to be entered on the host instead.
+func (s *SmartCardService) verifyOnPINPad(ctx context.Context, req domain.PINRequest) (bool, error) {
+	apdu := retryQueryAPDU(req.Reference) // The reader appends the PIN block
+	structure, err := domain.NewVerifyPINStructure(apdu, req.Format)
+	if err != nil {
+		return false, nil
+	}
+	return s.pinPadEntry(ctx, domain.FeatureVerifyPINDirect, apdu, structure, req)
+}
+
+// changeOnPINPad changes a PIN with the current PIN, the new one and its
+// confirmation typed on the reader's PIN pad. It reports false when the PINs
+// have to be entered on the host instead.
+func (s *SmartCardService) changeOnPINPad(ctx context.Context, req domain.PINRequest) (bool, error) {
+	apdu := &domain.APDU{
+		CLA: 0x00,
+		INS: 0x24,          // CHANGE REFERENCE DATA
+		P1:  0x00,          // Current and new reference data
+		P2:  req.Reference, // PIN reference
+	}
+	structure, err := domain.NewModifyPINStructure(apdu, req.Format)
+	if err != nil {
+		return false, nil
+	}
+
+	replacement := s.pinRequest(domain.SecretNewPIN, req.Reference, -1)
+	confirmation := replacement
+	confirmation.Confirm = true
+	return s.pinPadEntry(ctx, domain.FeatureModifyPINDirect, apdu, structure, req, replacement, confirmation)
+}
+
+// pinPadEntry runs a PIN pad feature with the entry structure of apdu,
+// telling the PIN provider what the user is asked for. It reports false when
+// the reader does not have the feature or PIN pads are not used.
+func (s *SmartCardService) pinPadEntry(ctx context.Context, feature domain.ReaderFeature, apdu *domain.APDU,
+	structure []byte, requests ...domain.PINRequest) (bool, error) {
+	controller, code, ok, err := s.pinPadControl(ctx, feature)
+	if !ok || err != nil {
+		return false, err
+	}
+
+	if notifier, ok := s.pinProvider.(domain.PINPadNotifier); ok {
+		for _, req := range requests {
+			notifier.PINPadEntry(req)
+		}
+	}
+	if s.logger != nil {
+		s.logger.LogInfo("%s entered on the PIN pad (%s)", requests[0].Name, feature)
+	}
+
+	var out []byte
+	err = s.WithTransaction(ctx, func(domain.Card) error {
+		var err error
+		out, err = controller.Control(ctx, code, structure)
+		return err
+	})
+	if err != nil {
+		s.recoverFrom(ctx, err)
+		return true, err
+	}
+	if len(out) < 2 {
+		return true, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"PIN pad returned no status word", nil)
+	}
+	return true, domain.NewPINPadError(apdu, domain.NewResponse(out))
+}
+
+// pinPadControl returns the reader and the control code of a PIN pad
+// feature. It reports false when the reader does not have the feature or PIN
+// pads are not used.
+func (s *SmartCardService) pinPadControl(ctx context.Context, feature domain.ReaderFeature) (domain.Controller, uint32, bool, error) {
+	controller, ok := s.card.(domain.Controller)
+	if !s.usePINPad || !ok {
+		return nil, 0, false, nil
+	}
+	features, err := controller.ReaderFeatures(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	code, ok := features.ControlCode(feature)
+	return controller, code, ok, nil
+}
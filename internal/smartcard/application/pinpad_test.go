@@ -0,0 +1,214 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+const (
+	testVerifyPINControl = 0x42330006
+	testModifyPINControl = 0x42330007
+)
+
+// pinPadFeatures are the features of a reader with a PIN pad
+var pinPadFeatures = domain.ReaderFeatures{
+	domain.FeatureVerifyPINDirect: testVerifyPINControl,
+	domain.FeatureModifyPINDirect: testModifyPINControl,
+}
+
+// pinPadStructure builds the PIN pad input expected for a CEI PIN command
+func pinPadStructure(t *testing.T, ins, pinRef byte) []byte {
+	t.Helper()
+	apdu := &domain.APDU{CLA: 0x00, INS: ins, P1: 0x00, P2: pinRef}
+	build := domain.NewVerifyPINStructure
+	if ins == 0x24 {
+		build = domain.NewModifyPINStructure
+	}
+	structure, err := build(apdu, domain.CEIPINFormats()[pinRef])
+	if err != nil {
+		t.Fatalf("PIN pad structure: %v", err)
+	}
+	return structure
+}
+
+func TestSmartCardService_VerifyPINInteractive_PINPad(t *testing.T) {
+	tests := []struct {
+		name string
+		sw   []byte
+		want error
+	}{
+		{"verified", []byte{0x90, 0x00}, nil},
+		{"wrong PIN", []byte{0x63, 0xC1}, domain.ErrVerificationFailed},
+		{"cancelled on the PIN pad", []byte{0x64, 0x01}, domain.ErrPINEntryCancelled},
+		{"PIN pad timeout", []byte{0x64, 0x00}, domain.ErrPINPadTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := infrastructure.NewScriptedCard().
+				Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C2).
+				ExpectControl(testVerifyPINControl, pinPadStructure(t, 0x20, domain.PIN1Reference), tt.sw)
+			card.SetReaderFeatures(pinPadFeatures)
+			service := newTestService(t, card)
+			provider := &scriptedProvider{answers: []string{"1234"}}
+			service.SetPINProvider(provider)
+
+			if err := service.VerifyPINInteractive(context.Background(), domain.PIN1Reference); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyPINInteractive() error = %v, want %v", err, tt.want)
+			}
+			if len(provider.requests) != 0 {
+				t.Errorf("the PIN provider should not be asked, got %+v", provider.requests)
+			}
+			if len(provider.pinPad) != 1 || provider.pinPad[0].Prompt() != "Enter PIN1 (2 tries left)" {
+				t.Errorf("PIN pad entries = %+v, want one for PIN1 with 2 tries left", provider.pinPad)
+			}
+			if err := card.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSmartCardService_ChangePINInteractive_PINPad(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x02}, nil, 0x63C3).
+		ExpectControl(testModifyPINControl, pinPadStructure(t, 0x24, domain.PIN2Reference), []byte{0x90, 0x00})
+	card.SetReaderFeatures(pinPadFeatures)
+	service := newTestService(t, card)
+	provider := &scriptedProvider{}
+	service.SetPINProvider(provider)
+
+	if err := service.ChangePINInteractive(context.Background(), domain.PIN2Reference); err != nil {
+		t.Fatalf("ChangePINInteractive() error = %v", err)
+	}
+	var prompts []string
+	for _, req := range provider.pinPad {
+		prompts = append(prompts, req.Prompt())
+	}
+	want := []string{"Enter PIN2 (3 tries left)", "Enter new PIN2", "Repeat new PIN2"}
+	if len(prompts) != len(want) || prompts[0] != want[0] || prompts[1] != want[1] || prompts[2] != want[2] {
+		t.Errorf("PIN pad entries = %q, want %q", prompts, want)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_VerifyPINInteractive_HostEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		features domain.ReaderFeatures
+		usePad   bool
+		format   domain.PINFormat
+	}{
+		{"no PIN pad", nil, true, domain.CEIPIN1Format},
+		{"PIN pad disabled", pinPadFeatures, false, domain.CEIPIN1Format},
+		{"format unsupported by the PIN pad", pinPadFeatures, true,
+			domain.PINFormat{Name: "PIN1", Encoding: domain.PINEncodingASCII, MinLength: 4, MaxLength: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := infrastructure.NewScriptedCard().
+				Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C3).
+				Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}, nil, 0x9000)
+			card.SetReaderFeatures(tt.features)
+			service := newTestService(t, card)
+			service.UsePINPad(tt.usePad)
+			service.SetPINFormat(domain.PIN1Reference, tt.format)
+			provider := &scriptedProvider{answers: []string{"1234"}}
+			service.SetPINProvider(provider)
+
+			if err := service.VerifyPINInteractive(context.Background(), domain.PIN1Reference); err != nil {
+				t.Fatalf("VerifyPINInteractive() error = %v", err)
+			}
+			if len(provider.requests) != 1 || len(provider.pinPad) != 0 {
+				t.Errorf("requests = %+v, PIN pad entries = %+v, want one host request", provider.requests, provider.pinPad)
+			}
+			if err := card.Verify(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSmartCardService_VerifyPIN_PINPad(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		ExpectControl(testVerifyPINControl, pinPadStructure(t, 0x20, domain.PIN1Reference), []byte{0x90, 0x00}).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}, nil, 0x9000)
+	card.SetReaderFeatures(pinPadFeatures)
+	service := newTestService(t, card)
+	service.ProtectLastPINTry(false) // Script without retry counter queries
+	ctx := context.Background()
+
+	if err := service.VerifyPIN(ctx, nil, domain.PIN1Reference); err != nil {
+		t.Fatalf("VerifyPIN() on the PIN pad error = %v", err)
+	}
+
+	// A PIN from the host is not sent around the PIN pad
+	if err := service.VerifyPIN(ctx, []byte("1234"), domain.PIN1Reference); !errors.Is(err, domain.ErrPINPadEntryRequired) {
+		t.Fatalf("VerifyPIN() with a host PIN error = %v, want ErrPINPadEntryRequired", err)
+	}
+	service.UsePINPad(false)
+	if err := service.VerifyPIN(ctx, []byte("1234"), domain.PIN1Reference); err != nil {
+		t.Fatalf("VerifyPIN() with the PIN pad disabled error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_VerifyPIN_PINPadRecordAndReplay(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		ExpectControl(testVerifyPINControl, pinPadStructure(t, 0x20, domain.PIN1Reference), []byte{0x90, 0x00})
+	card.SetReaderFeatures(pinPadFeatures)
+	recorder := infrastructure.NewRecordingConnector(card)
+	ctx := context.Background()
+
+	// Recording must not downgrade PIN pad entry to the host
+	service := NewSmartCardService(recorder, nil)
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	service.ProtectLastPINTry(false)
+	if err := service.VerifyPIN(ctx, nil, domain.PIN1Reference); err != nil {
+		t.Fatalf("VerifyPIN() on the recorded PIN pad error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	replay, err := infrastructure.NewReplayCard(recorder.Trace())
+	if err != nil {
+		t.Fatalf("NewReplayCard() error = %v", err)
+	}
+	service = NewSmartCardService(replay, nil)
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	service.ProtectLastPINTry(false)
+	if err := service.VerifyPIN(ctx, nil, domain.PIN1Reference); err != nil {
+		t.Fatalf("VerifyPIN() on the replayed PIN pad error = %v", err)
+	}
+	if err := replay.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_ReaderFeatures(t *testing.T) {
+	card := infrastructure.NewScriptedCard()
+	card.SetReaderFeatures(domain.ReaderFeatures{domain.FeatureExecutePACE: 0x42330020})
+	service := newTestService(t, card)
+
+	features, err := service.ReaderFeatures(context.Background())
+	if err != nil {
+		t.Fatalf("ReaderFeatures() error = %v", err)
+	}
+	if !features.PACE() || features.PINPad() {
+		t.Errorf("ReaderFeatures() = %v, want PACE without PIN pad", features)
+	}
+}
@@ -0,0 +1,162 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// SetPINProvider sets where VerifyPINInteractive, ChangePINInteractive and
+// UnblockPINInteractive get PINs and PUKs from
+func (s *SmartCardService) SetPINProvider(provider domain.PINProvider) {
+	s.pinProvider = provider
+}
+
+// VerifyPINInteractive asks the PIN provider for a PIN, telling it how many
+// tries are left, and verifies it. A verified PIN is not asked again; with
+// ProtectLastPINTry the user is not asked when only one try is left. On a
+// reader with a PIN pad the PIN is typed there instead (see UsePINPad).
+func (s *SmartCardService) VerifyPINInteractive(ctx context.Context, pinRef byte) error {
+	retries, verified, err := s.retriesBeforePrompt(ctx, pinRef)
+	if err != nil || verified {
+		return err
+	}
+	if s.protectLastPINTry && retries == 1 {
+		return fmt.Errorf("PIN %02X: %w", pinRef, domain.ErrLastPINTry)
+	}
+
+	request := s.pinRequest(domain.SecretPIN, pinRef, retries)
+	if onPINPad, err := s.verifyOnPINPad(ctx, request); onPINPad || err != nil {
+		return err
+	}
+
+	pin, err := s.requestSecret(ctx, request)
+	if err != nil {
+		return err
+	}
+	defer pin.Wipe()
+	return s.verifyPIN(ctx, pin.Bytes(), pinRef, false)
+}
+
+// ChangePINInteractive asks the PIN provider for the current PIN and twice
+// for the new one, then changes it. A wrong current PIN spends a try, so
+// ProtectLastPINTry applies as for VerifyPINInteractive. A PIN pad that can
+// change PINs collects all three entries.
+func (s *SmartCardService) ChangePINInteractive(ctx context.Context, pinRef byte) error {
+	retries, _, err := s.retriesBeforePrompt(ctx, pinRef)
+	if err != nil {
+		return err
+	}
+	if s.protectLastPINTry && retries == 1 {
+		return fmt.Errorf("PIN %02X: %w", pinRef, domain.ErrLastPINTry)
+	}
+
+	request := s.pinRequest(domain.SecretPIN, pinRef, retries)
+	if onPINPad, err := s.changeOnPINPad(ctx, request); onPINPad || err != nil {
+		return err
+	}
+
+	current, err := s.requestSecret(ctx, request)
+	if err != nil {
+		return err
+	}
+	defer current.Wipe()
+	replacement, err := s.requestNewPIN(ctx, pinRef)
+	if err != nil {
+		return err
+	}
+	defer replacement.Wipe()
+
+	return s.ChangePIN(ctx, current.Bytes(), replacement.Bytes(), pinRef)
+}
+
+// UnblockPINInteractive asks the PIN provider for the PUK and twice for a new
+// PIN, then resets the retry counter of the PIN
+func (s *SmartCardService) UnblockPINInteractive(ctx context.Context, pinRef byte) error {
+	puk, err := s.requestSecret(ctx, domain.PINRequest{
+		Kind:        domain.SecretPUK,
+		Reference:   pinRef,
+		Name:        "PUK",
+		Format:      pukFormat(),
+		RetriesLeft: -1,
+	})
+	if err != nil {
+		return err
+	}
+	defer puk.Wipe()
+	replacement, err := s.requestNewPIN(ctx, pinRef)
+	if err != nil {
+		return err
+	}
+	defer replacement.Wipe()
+
+	return s.UnblockPIN(ctx, puk.Bytes(), replacement.Bytes(), pinRef)
+}
+
+// retriesBeforePrompt returns the tries left for a PIN, -1 when the card does
+// not report them. A blocked PIN fails before the user is asked.
+func (s *SmartCardService) retriesBeforePrompt(ctx context.Context, pinRef byte) (retries int, verified bool, err error) {
+	status, err := s.PINRetries(ctx, pinRef)
+	var statusErr *domain.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return -1, false, nil // No retry counter query on this card
+	case err != nil:
+		return 0, false, err
+	case status.Blocked():
+		return 0, false, fmt.Errorf("%s: %w", s.PINFormat(pinRef).Name, domain.ErrAuthenticationMethodBlocked)
+	}
+	return status.RetriesLeft, status.Verified, nil
+}
+
+// requestNewPIN asks for a new PIN, checks its format and asks again to
+// confirm it
+func (s *SmartCardService) requestNewPIN(ctx context.Context, pinRef byte) (*domain.Secret, error) {
+	request := s.pinRequest(domain.SecretNewPIN, pinRef, -1)
+	replacement, err := s.requestSecret(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.Format.Validate(replacement.Bytes()); err != nil {
+		replacement.Wipe()
+		return nil, err
+	}
+
+	request.Confirm = true
+	confirmation, err := s.requestSecret(ctx, request)
+	if err != nil {
+		replacement.Wipe()
+		return nil, err
+	}
+	defer confirmation.Wipe()
+	if !replacement.Equal(confirmation) {
+		replacement.Wipe()
+		return nil, domain.ErrNewPINMismatch
+	}
+	return replacement, nil
+}
+
+// pinRequest describes a PIN of reference pinRef for the PIN provider
+func (s *SmartCardService) pinRequest(kind domain.SecretKind, pinRef byte, retries int) domain.PINRequest {
+	format := s.PINFormat(pinRef)
+	if kind == domain.SecretNewPIN {
+		format = newPINFormat(format)
+	}
+	return domain.PINRequest{
+		Kind:        kind,
+		Reference:   pinRef,
+		Name:        format.Name,
+		Format:      format,
+		RetriesLeft: retries,
+	}
+}
+
+// requestSecret asks the PIN provider for a secret
+func (s *SmartCardService) requestSecret(ctx context.Context, req domain.PINRequest) (*domain.Secret, error) {
+	if s.pinProvider == nil {
+		return nil, errors.New("no PIN provider set")
+	}
+	return s.pinProvider.RequestSecret(ctx, req)
+}
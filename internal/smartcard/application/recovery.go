@@ -0,0 +1,128 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// RecoveryPolicy controls automatic recovery of a session whose card was
+// reset by another application or briefly lost contact
+type RecoveryPolicy struct {
+	ResetMode   domain.ResetMode // How to reconnect (leave acknowledges a foreign reset)
+	MaxAttempts int              // Reconnect attempts before giving up
+	RetryDelay  time.Duration    // Wait between attempts (contact bounce)
+	ReselectAID []byte           // Application selected again after reconnecting
+}
+
+// DefaultRecoveryPolicy reconnects up to three times and re-selects aid
+func DefaultRecoveryPolicy(aid []byte) *RecoveryPolicy {
+	return &RecoveryPolicy{
+		ResetMode:   domain.ResetLeave,
+		MaxAttempts: 3,
+		RetryDelay:  500 * time.Millisecond,
+		ReselectAID: aid,
+	}
+}
+
+// SetRecoveryPolicy enables automatic recovery; nil (the default) disables it.
+// The command that hit the failure is not retried: it may have depended on
+// security state (verified PIN, secure messaging) that did not survive.
+func (s *SmartCardService) SetRecoveryPolicy(policy *RecoveryPolicy) {
+	s.recovery = policy
+}
+
+// OnSessionInvalidated registers a handler called whenever the card session
+// is re-established, so upper layers can drop PACE and secure messaging state.
+// The cause is the error that triggered recovery, nil for an explicit Reconnect.
+func (s *SmartCardService) OnSessionInvalidated(handler func(cause error)) {
+	s.invalidationHandlers = append(s.invalidationHandlers, handler)
+}
+
+// Reconnect re-establishes the session with the card (e.g. after a reset by
+// another application) and re-selects the recovery policy's application.
+// The open logical channels are closed in every mode: ResetLeave acknowledges
+// a reset that already closed them on the card.
+func (s *SmartCardService) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	if s.card == nil {
+		return errNotConnected()
+	}
+	s.notifyInvalidated(nil)
+	return s.reconnect(ctx, mode)
+}
+
+// reconnect reconnects the card and re-selects the application
+func (s *SmartCardService) reconnect(ctx context.Context, mode domain.ResetMode) error {
+	reconnector, ok := s.card.(domain.Reconnector)
+	if !ok {
+		return domain.NewTransportError(domain.ErrConnectionLost,
+			"card does not support reconnecting", nil)
+	}
+
+	s.recovering = true
+	defer func() { s.recovering = false }()
+
+	if err := reconnector.Reconnect(ctx, mode); err != nil {
+		return err
+	}
+	s.closeChannels(domain.ErrChannelClosedByReset)
+	if s.recovery != nil && len(s.recovery.ReselectAID) > 0 {
+		if _, err := s.SelectApplication(ctx, s.recovery.ReselectAID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverFrom applies the recovery policy after a failed exchange
+func (s *SmartCardService) recoverFrom(ctx context.Context, err error) {
+	var transportErr *domain.TransportError
+	if s.recovery == nil || s.recovering || !errors.As(err, &transportErr) {
+		return
+	}
+	switch transportErr.Recovery() {
+	case domain.RecoveryReconnect, domain.RecoveryReinsertCard:
+	default:
+		return
+	}
+	if ctx.Err() != nil {
+		return // The caller gave up; do not start reconnecting on its behalf
+	}
+
+	// The reset or contact loss behind the error closed the logical channels
+	s.closeChannels(domain.ErrChannelClosedByReset)
+	s.notifyInvalidated(transportErr)
+	for attempt := 1; attempt <= s.recovery.MaxAttempts; attempt++ {
+		err = s.reconnect(ctx, s.recovery.ResetMode)
+		if err == nil {
+			return
+		}
+		if attempt < s.recovery.MaxAttempts && !sleepContext(ctx, s.recovery.RetryDelay) {
+			break
+		}
+	}
+	if s.logger != nil {
+		s.logger.LogError(err)
+	}
+}
+
+// notifyInvalidated calls the session invalidation handlers
+func (s *SmartCardService) notifyInvalidated(cause error) {
+	for _, handler := range s.invalidationHandlers {
+		handler(cause)
+	}
+}
+
+// sleepContext waits for d, returning false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
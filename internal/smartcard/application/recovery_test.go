@@ -0,0 +1,128 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+var (
+	selectCEI  = []byte{0x00, 0xA4, 0x04, 0x00, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x00}
+	readBinary = []byte{0x00, 0xB0, 0x00, 0x00, 0x00}
+	errReset   = domain.NewTransportError(domain.ErrCardReset, "APDU transmission failed", nil)
+)
+
+func TestSmartCardService_Recovery(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect(selectCEI, nil, 0x9000).
+		ExpectError(readBinary, errReset).
+		Expect(selectCEI, nil, 0x9000). // Re-selected by the recovery policy
+		Expect(readBinary, []byte{0x01}, 0x9000)
+	service := newTestService(t, card)
+	service.SetRecoveryPolicy(DefaultRecoveryPolicy(testAID))
+
+	var causes []error
+	service.OnSessionInvalidated(func(cause error) { causes = append(causes, cause) })
+
+	ctx := context.Background()
+	if _, err := service.SelectApplication(ctx, testAID); err != nil {
+		t.Fatalf("SelectApplication() error = %v", err)
+	}
+
+	// The failing command is reported, not retried
+	_, err := service.Transmit(ctx, &domain.APDU{INS: 0xB0, Le: 256})
+	if !errors.Is(err, domain.ErrCardReset) {
+		t.Fatalf("Transmit() error = %v, want ErrCardReset", err)
+	}
+	if !reflect.DeepEqual(card.Reconnects(), []domain.ResetMode{domain.ResetLeave}) {
+		t.Errorf("Reconnects() = %v, want one reconnect leaving the card", card.Reconnects())
+	}
+	if len(causes) != 1 || causes[0] != errReset {
+		t.Errorf("invalidation causes = %v, want [%v]", causes, errReset)
+	}
+
+	// The session works again with the application selected
+	if _, err := service.Transmit(ctx, &domain.APDU{INS: 0xB0, Le: 256}); err != nil {
+		t.Fatalf("Transmit() after recovery error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_Recovery_Disabled(t *testing.T) {
+	card := infrastructure.NewScriptedCard().ExpectError(readBinary, errReset)
+	service := newTestService(t, card)
+
+	invalidated := false
+	service.OnSessionInvalidated(func(error) { invalidated = true })
+
+	_, err := service.Transmit(context.Background(), &domain.APDU{INS: 0xB0, Le: 256})
+	if !errors.Is(err, domain.ErrCardReset) {
+		t.Fatalf("Transmit() error = %v, want ErrCardReset", err)
+	}
+	if len(card.Reconnects()) != 0 || invalidated {
+		t.Error("no recovery should happen without a policy")
+	}
+}
+
+func TestSmartCardService_Recovery_NotRecoverable(t *testing.T) {
+	busy := domain.NewTransportError(domain.ErrReaderBusy, "failed to begin transaction", nil)
+	card := infrastructure.NewScriptedCard().ExpectError(readBinary, busy)
+	service := newTestService(t, card)
+	service.SetRecoveryPolicy(DefaultRecoveryPolicy(nil))
+
+	service.Transmit(context.Background(), &domain.APDU{INS: 0xB0, Le: 256})
+	if len(card.Reconnects()) != 0 {
+		t.Errorf("Reconnects() = %v, want none for a busy reader", card.Reconnects())
+	}
+}
+
+func TestSmartCardService_Reconnect(t *testing.T) {
+	card := infrastructure.NewScriptedCard()
+	service := newTestService(t, card)
+
+	var causes []error
+	service.OnSessionInvalidated(func(cause error) { causes = append(causes, cause) })
+
+	if err := service.Reconnect(context.Background(), domain.ResetCold); err != nil {
+		t.Fatalf("Reconnect() error = %v", err)
+	}
+	if !reflect.DeepEqual(card.Reconnects(), []domain.ResetMode{domain.ResetCold}) {
+		t.Errorf("Reconnects() = %v, want [cold reset]", card.Reconnects())
+	}
+	if len(causes) != 1 || causes[0] != nil {
+		t.Errorf("invalidation causes = %v, want [nil]", causes)
+	}
+}
+
+func TestSmartCardService_Reconnect_Simulator(t *testing.T) {
+	simulator, err := infrastructure.LoadCardSimulator("../infrastructure/testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	service := NewSmartCardService(simulator, nil)
+	ctx := context.Background()
+	service.Connect(ctx)
+	service.SetRecoveryPolicy(DefaultRecoveryPolicy(testAID))
+
+	if err := service.VerifyPIN(ctx, []byte("1234"), 0x01); err != nil {
+		t.Fatalf("VerifyPIN() error = %v", err)
+	}
+	if err := service.Reconnect(ctx, domain.ResetWarm); err != nil {
+		t.Fatalf("Reconnect() error = %v", err)
+	}
+
+	// A warm reset clears the PIN status; the CEI application is selected again
+	resp, err := service.Transmit(ctx, &domain.APDU{INS: 0xB0, P1: 0x81, Le: 256})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if resp.StatusCode() != domain.StatusSecurityAuthFailed {
+		t.Errorf("READ BINARY SFI 1 after reset = %04X, want 6982", resp.StatusCode())
+	}
+}
@@ -1,130 +1,345 @@
 package application
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
 	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
 )
 
 // SmartCardService orchestrates smart card operations
 type SmartCardService struct {
-	transport *infrastructure.PCSCTransport
+	connector domain.Connector
+	card      domain.Card
 	logger    *infrastructure.APDULogger
+
+	// Per-reader choice between extended length and chaining for large commands
+	lengthPolicies      map[string]domain.LengthPolicy
+	defaultLengthPolicy domain.LengthPolicy
+
+	// Time allowed for each command exchange, per instruction
+	timeouts domain.CommandTimeouts
+
+	// Refuse cards identified from their ATR as unsupported
+	requireSupported  bool
+	allowUnknownCards bool
+
+	// Query the retry counter before VERIFY and keep the last try
+	protectLastPINTry bool
+
+	// Length, charset and encoding of the PINs, per reference
+	pinFormats map[byte]domain.PINFormat
+
+	// Source of the secrets for the interactive PIN methods
+	pinProvider domain.PINProvider
+
+	// Let the user type PINs on the reader's PIN pad when it has one
+	usePINPad bool
+
+	// Logical channels opened on the card, closed by a reset
+	channels []*Channel
+
+	// Opt-in session recovery after a card reset or contact loss
+	recovery             *RecoveryPolicy
+	recovering           bool
+	invalidationHandlers []func(cause error)
 }
 
-// NewSmartCardService creates a new smart card service
+// NewSmartCardService creates a new smart card service on top of any connector
+// (PC/SC transport, scripted card)
 func NewSmartCardService(
-	transport *infrastructure.PCSCTransport,
+	connector domain.Connector,
 	logger *infrastructure.APDULogger,
 ) *SmartCardService {
 	// Wire up logger to transport for automatic APDU logging
-	transport.SetLogger(logger)
+	if l, ok := connector.(infrastructure.LoggerSetter); ok && logger != nil {
+		l.SetLogger(logger)
+	}
 	return &SmartCardService{
-		transport: transport,
-		logger:    logger,
+		connector:           connector,
+		logger:              logger,
+		lengthPolicies:      make(map[string]domain.LengthPolicy),
+		defaultLengthPolicy: domain.LengthPolicyExtended,
+		timeouts:            domain.DefaultCommandTimeouts(),
+		pinFormats:          domain.CEIPINFormats(),
+		usePINPad:           true,
+		protectLastPINTry:   true,
 	}
 }
 
 // Connect establishes connection to a smart card
-func (s *SmartCardService) Connect() error {
-	return s.transport.Connect()
+func (s *SmartCardService) Connect(ctx context.Context) error {
+	card, err := s.connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s.requireSupported {
+		status, err := card.Status()
+		if err == nil {
+			err = domain.CheckCardSupported(status, s.allowUnknownCards)
+		}
+		if err != nil {
+			card.Disconnect()
+			return err
+		}
+	}
+	s.card = card
+	return nil
+}
+
+// RequireSupportedCard makes Connect refuse, before any APDU is sent, cards
+// whose ATR identifies them as unsupported. Cards that cannot be identified
+// are accepted only with allowUnknown.
+func (s *SmartCardService) RequireSupportedCard(allowUnknown bool) {
+	s.requireSupported = true
+	s.allowUnknownCards = allowUnknown
 }
 
 // Disconnect closes the smart card connection
 func (s *SmartCardService) Disconnect() error {
-	return s.transport.Disconnect()
+	if s.card == nil {
+		return nil
+	}
+	err := s.card.Disconnect()
+	s.card = nil
+	s.closeChannels(domain.ErrChannelClosedByReset)
+	return err
 }
 
 // Status returns current card status (ATR, protocol, reader)
 func (s *SmartCardService) Status() (*domain.CardStatus, error) {
-	return s.transport.Status()
+	if s.card == nil {
+		return nil, errNotConnected()
+	}
+	return s.card.Status()
 }
 
 // SelectApplication sends SELECT APDU to activate an application (ISO/IEC 7816-4)
-func (s *SmartCardService) SelectApplication(aid []byte) (*domain.Response, error) {
-	apdu := &domain.APDU{
+func (s *SmartCardService) SelectApplication(ctx context.Context, aid []byte) (*domain.Response, error) {
+	apdu := selectApplicationAPDU(aid)
+	resp, err := s.transmit(ctx, apdu)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.IsSuccess() {
+		return resp, domain.NewCommandError(apdu, resp)
+	}
+
+	return resp, nil
+}
+
+// selectApplicationAPDU builds SELECT by DF name for the basic channel
+func selectApplicationAPDU(aid []byte) *domain.APDU {
+	return &domain.APDU{
 		CLA:  0x00, // ISO/IEC 7816-4: Inter-industry command
 		INS:  0xA4, // SELECT
 		P1:   0x04, // Select by DF name (AID)
 		P2:   0x00, // First or only occurrence
 		Data: aid,
-		Le:   0x00, // Accept any response length
+		Le:   domain.MaxShortLe, // Accept any response length
 	}
+}
 
-	resp, err := s.transport.Transmit(apdu)
-	if err != nil {
-		return nil, err
+// WithTransaction runs fn with exclusive access to the card, so that other
+// applications cannot interleave APDUs in a multi-command operation.
+// Transactions nest; a card reset by another application aborts the session.
+func (s *SmartCardService) WithTransaction(ctx context.Context, fn func(card domain.Card) error) error {
+	if s.card == nil {
+		return errNotConnected()
 	}
+	return domain.WithTransaction(ctx, s.card, fn)
+}
 
-	if !resp.IsSuccess() {
-		return resp, domain.NewStatusError(resp)
+// VerifyPIN sends VERIFY APDU to authenticate with PIN (ISO/IEC 7816-4). The
+// PIN is checked and encoded with the format of its reference first. On a
+// reader with a PIN pad (see UsePINPad) the user types the PIN there: pin
+// must then be empty, and a PIN from the host is refused with
+// domain.ErrPINPadEntryRequired rather than sent around the pad.
+func (s *SmartCardService) VerifyPIN(ctx context.Context, pin []byte, pinRef byte) error {
+	onPINPad, err := s.canVerifyOnPINPad(ctx, pinRef)
+	if err != nil {
+		return err
+	}
+	if !onPINPad {
+		return s.verifyPIN(ctx, pin, pinRef, s.protectLastPINTry)
+	}
+	if len(pin) > 0 {
+		return fmt.Errorf("PIN %02X: %w", pinRef, domain.ErrPINPadEntryRequired)
 	}
 
-	return resp, nil
+	return s.WithTransaction(ctx, func(domain.Card) error {
+		if s.protectLastPINTry {
+			if err := s.checkLastPINTry(ctx, pinRef); err != nil {
+				return err
+			}
+		}
+		_, err := s.verifyOnPINPad(ctx, s.pinRequest(domain.SecretPIN, pinRef, -1))
+		return err
+	})
 }
 
-// VerifyPIN sends VERIFY APDU to authenticate with PIN (ISO/IEC 7816-4)
-func (s *SmartCardService) VerifyPIN(pin []byte, pinRef byte) error {
-	// PIN is typically padded/truncated to 8 bytes for CEI cards
-	if len(pin) > 8 {
-		pin = pin[:8]
+// verifyPIN sends VERIFY, first checking the retry counter when
+// checkLastTry is set
+func (s *SmartCardService) verifyPIN(ctx context.Context, pin []byte, pinRef byte, checkLastTry bool) error {
+	data, err := s.PINFormat(pinRef).Encode(pin)
+	if err != nil {
+		return err
 	}
+	defer clear(data)
 
 	apdu := &domain.APDU{
 		CLA:  0x00,   // ISO/IEC 7816-4: Inter-industry command
 		INS:  0x20,   // VERIFY
 		P1:   0x00,   // No information given
 		P2:   pinRef, // PIN reference (e.g., 0x01 for PIN1)
-		Data: pin,
+		Data: data,
 	}
 
-	resp, err := s.transport.Transmit(apdu)
-	if err != nil {
-		return err
-	}
+	return s.WithTransaction(ctx, func(domain.Card) error {
+		if checkLastTry {
+			if err := s.checkLastPINTry(ctx, pinRef); err != nil {
+				return err
+			}
+		}
 
-	if !resp.IsSuccess() {
-		return domain.NewStatusError(resp)
-	}
+		resp, err := s.transmit(ctx, apdu)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if !resp.IsSuccess() {
+			return domain.NewCommandError(apdu, resp)
+		}
+
+		return nil
+	})
 }
 
 // Transmit sends a raw APDU command (logging handled by transport)
-func (s *SmartCardService) Transmit(apdu *domain.APDU) (*domain.Response, error) {
-	return s.transport.Transmit(apdu)
+func (s *SmartCardService) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	return s.transmit(ctx, apdu)
 }
 
-// TransmitBytes sends raw APDU bytes and returns the full response
-func (s *SmartCardService) TransmitBytes(data []byte) ([]byte, error) {
-	if len(data) < 4 {
+// SetCommandTimeout sets the time allowed for one exchange of an instruction.
+// Zero removes the limit; a deadline on the caller's context still applies.
+func (s *SmartCardService) SetCommandTimeout(ins byte, timeout time.Duration) {
+	s.timeouts.Set(ins, timeout)
+}
+
+// SetDefaultCommandTimeout sets the time allowed for instructions without
+// their own timeout
+func (s *SmartCardService) SetDefaultCommandTimeout(timeout time.Duration) {
+	s.timeouts.Default = timeout
+}
+
+// SetLengthPolicy sets how large commands are sent through a reader.
+// An empty reader name sets the default for readers without their own policy.
+func (s *SmartCardService) SetLengthPolicy(reader string, policy domain.LengthPolicy) {
+	if reader == "" {
+		s.defaultLengthPolicy = policy
+		return
+	}
+	s.lengthPolicies[reader] = policy
+}
+
+// LengthPolicy returns the policy for the connected reader
+func (s *SmartCardService) LengthPolicy() domain.LengthPolicy {
+	if s.card != nil {
+		if status, err := s.card.Status(); err == nil {
+			if policy, ok := s.lengthPolicies[status.Reader]; ok {
+				return policy
+			}
+		}
+	}
+	return s.defaultLengthPolicy
+}
+
+// TransmitLarge sends an APDU of any size, using extended length or command
+// chaining according to the connected reader's length policy
+func (s *SmartCardService) TransmitLarge(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if err := apdu.Validate(); err != nil {
 		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
-			"APDU too short: minimum 4 bytes required", nil)
+			"invalid APDU", err)
+	}
+	if !apdu.IsExtended() || s.LengthPolicy() == domain.LengthPolicyExtended {
+		return s.transmit(ctx, apdu)
 	}
 
-	apdu := &domain.APDU{
-		CLA: data[0],
-		INS: data[1],
-		P1:  data[2],
-		P2:  data[3],
-	}
-
-	// Parse optional Lc/Data/Le fields
-	if len(data) > 4 {
-		lc := int(data[4])
-		if len(data) > 5+lc {
-			apdu.Data = data[5 : 5+lc]
-			if len(data) > 5+lc {
-				apdu.Le = data[5+lc]
+	chained := *apdu
+	chained.Extended = false
+	if chained.Le > domain.MaxShortLe {
+		// Short Le "00" asks for everything; the rest arrives via 61xx/GET RESPONSE
+		chained.Le = domain.MaxShortLe
+	}
+	return s.TransmitChained(ctx, &chained)
+}
+
+// TransmitChained sends a command using ISO/IEC 7816-4 command chaining:
+// the data is split into short APDUs, every intermediate part must be
+// acknowledged with 9000 and the response to the last part is returned
+func (s *SmartCardService) TransmitChained(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	var resp *domain.Response
+	err := s.WithTransaction(ctx, func(domain.Card) error {
+		parts := apdu.Chain(domain.MaxShortLc)
+		for _, part := range parts[:len(parts)-1] {
+			partResp, err := s.transmit(ctx, part)
+			if err != nil {
+				return err
+			}
+			if !partResp.IsSuccess() {
+				resp = partResp
+				return domain.NewCommandError(part, partResp)
 			}
-		} else if len(data) == 5 {
-			// Just Le, no data
-			apdu.Le = data[4]
-		} else {
-			apdu.Data = data[5:]
 		}
+
+		var err error
+		resp, err = s.transmit(ctx, parts[len(parts)-1])
+		return err
+	})
+	return resp, err
+}
+
+// transmit forwards an APDU to the connected card and applies the recovery
+// policy when the session broke
+func (s *SmartCardService) transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if s.card == nil {
+		return nil, errNotConnected()
+	}
+	resp, err := s.exchange(ctx, apdu)
+	if err != nil {
+		s.recoverFrom(ctx, err)
+	}
+	return resp, err
+}
+
+// exchange sends one APDU, bounded by the timeout of its instruction
+func (s *SmartCardService) exchange(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if timeout := s.timeouts.For(apdu); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return s.card.Transmit(ctx, apdu)
+}
+
+// errNotConnected reports an operation attempted before Connect
+func errNotConnected() error {
+	return domain.NewTransportError(domain.ErrNoCard, "not connected to card", nil)
+}
+
+// TransmitBytes sends raw APDU bytes and returns the full response
+func (s *SmartCardService) TransmitBytes(ctx context.Context, data []byte) ([]byte, error) {
+	apdu, err := domain.ParseAPDU(data)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid APDU", err)
 	}
 
-	resp, err := s.transport.Transmit(apdu)
+	resp, err := s.transmit(ctx, apdu)
 	if err != nil {
 		return nil, err
 	}
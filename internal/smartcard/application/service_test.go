@@ -0,0 +1,378 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/infrastructure"
+)
+
+var testAID = []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01}
+
+func newTestService(t *testing.T, card *infrastructure.ScriptedCard) *SmartCardService {
+	t.Helper()
+	service := NewSmartCardService(card, nil)
+	if err := service.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return service
+}
+
+func TestSmartCardService_SelectApplication(t *testing.T) {
+	fci := []byte{0x6F, 0x08, 0x84, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01}
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x04, 0x00, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x00}, fci, 0x9000)
+	service := newTestService(t, card)
+
+	resp, err := service.SelectApplication(context.Background(), testAID)
+	if err != nil {
+		t.Fatalf("SelectApplication() error = %v", err)
+	}
+	if len(resp.Data) != len(fci) {
+		t.Errorf("Data length = %d, want %d", len(resp.Data), len(fci))
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_SelectApplication_NotFound(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x04, 0x00, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x00}, nil, 0x6A82)
+	service := newTestService(t, card)
+
+	_, err := service.SelectApplication(context.Background(), testAID)
+	var statusErr *domain.StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("SelectApplication() error = %v, want StatusError", err)
+	}
+	if statusErr.Code != domain.StatusFileNotFound {
+		t.Errorf("Code = %04X, want %04X", statusErr.Code, domain.StatusFileNotFound)
+	}
+}
+
+func TestSmartCardService_VerifyPIN(t *testing.T) {
+	// The retry counter is queried first to protect the last try
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C3).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}, nil, 0x9000)
+	service := newTestService(t, card)
+
+	if err := service.VerifyPIN(context.Background(), []byte("1234"), 0x01); err != nil {
+		t.Errorf("VerifyPIN() error = %v", err)
+	}
+	if card.Transactions() != 1 {
+		t.Errorf("Transactions() = %d, want the query and VERIFY inside one transaction", card.Transactions())
+	}
+}
+
+func TestSmartCardService_VerifyPIN_WrongPIN(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C3).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x30, 0x30, 0x30, 0x30}, nil, 0x63C2)
+	service := newTestService(t, card)
+
+	err := service.VerifyPIN(context.Background(), []byte("0000"), 0x01)
+	var statusErr *domain.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 0x63C2 {
+		t.Errorf("VerifyPIN() error = %v, want status 63C2", err)
+	}
+}
+
+func TestSmartCardService_TransmitBytes(t *testing.T) {
+	card := infrastructure.NewScriptedCard().
+		Expect([]byte{0x00, 0x84, 0x00, 0x00, 0x08}, []byte{1, 2, 3, 4, 5, 6, 7, 8}, 0x9000)
+	service := newTestService(t, card)
+
+	got, err := service.TransmitBytes(context.Background(), []byte{0x00, 0x84, 0x00, 0x00, 0x08})
+	if err != nil {
+		t.Fatalf("TransmitBytes() error = %v", err)
+	}
+	want := []byte{1, 2, 3, 4, 5, 6, 7, 8, 0x90, 0x00}
+	if string(got) != string(want) {
+		t.Errorf("TransmitBytes() = %02X, want %02X", got, want)
+	}
+}
+
+func TestSmartCardService_TransmitBytes_Extended(t *testing.T) {
+	data := make([]byte, 300)
+	command := append([]byte{0x00, 0x2A, 0x9E, 0x9A, 0x00, 0x01, 0x2C}, data...)
+	command = append(command, 0x00, 0x00)
+	card := infrastructure.NewScriptedCard().Expect(command, []byte{0xAB}, 0x9000)
+	service := newTestService(t, card)
+
+	got, err := service.TransmitBytes(context.Background(), command)
+	if err != nil {
+		t.Fatalf("TransmitBytes() error = %v", err)
+	}
+	if string(got) != string([]byte{0xAB, 0x90, 0x00}) {
+		t.Errorf("TransmitBytes() = %02X, want AB9000", got)
+	}
+
+	if _, err := service.TransmitBytes(context.Background(), []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01, 0x2C, 0x01}); err == nil {
+		t.Error("TransmitBytes() should reject data shorter than the extended Lc")
+	}
+}
+
+func TestSmartCardService_TransmitBytes_Case4KeepsLe(t *testing.T) {
+	command := []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x3F, 0x00, 0x00}
+	card := infrastructure.NewScriptedCard().Expect(command, []byte{0x6F, 0x00}, 0x9000)
+	service := newTestService(t, card)
+
+	if _, err := service.TransmitBytes(context.Background(), command); err != nil {
+		t.Fatalf("TransmitBytes() error = %v", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	_, err := service.TransmitBytes(context.Background(), []byte{0x00, 0xB0, 0x00, 0x00, 0x10, 0x00})
+	if !errors.Is(err, domain.ErrMalformedAPDU) || !errors.Is(err, domain.ErrTransmissionFailed) {
+		t.Errorf("TransmitBytes() error = %v, want a malformed APDU", err)
+	}
+}
+
+func TestSmartCardService_NotConnected(t *testing.T) {
+	service := NewSmartCardService(infrastructure.NewScriptedCard(), nil)
+
+	_, err := service.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xA4})
+	var transportErr *domain.TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != domain.ErrNoCard {
+		t.Errorf("Transmit() error = %v, want ErrNoCard", err)
+	}
+	if _, err := service.Status(); err == nil {
+		t.Error("Status() should fail before Connect")
+	}
+	if err := service.Disconnect(); err != nil {
+		t.Errorf("Disconnect() before Connect error = %v", err)
+	}
+}
+
+func chainedScript(cla byte, data []byte, finalSW uint16) *infrastructure.ScriptedCard {
+	card := infrastructure.NewScriptedCard()
+	for offset := 0; offset < len(data); offset += domain.MaxShortLc {
+		end := min(offset+domain.MaxShortLc, len(data))
+		command := []byte{cla | domain.CLAChainingBit, 0x2A, 0x9E, 0x9A, byte(end - offset)}
+		sw := uint16(0x9000)
+		if end == len(data) {
+			command[0] = cla
+			sw = finalSW
+		}
+		card.Expect(append(command, data[offset:end]...), nil, sw)
+	}
+	return card
+}
+
+func TestSmartCardService_TransmitChained(t *testing.T) {
+	data := make([]byte, 520)
+	card := chainedScript(0x00, data, 0x9000)
+	service := newTestService(t, card)
+
+	resp, err := service.TransmitChained(context.Background(), &domain.APDU{CLA: 0x00, INS: 0x2A, P1: 0x9E, P2: 0x9A, Data: data})
+	if err != nil {
+		t.Fatalf("TransmitChained() error = %v", err)
+	}
+	if !resp.IsSuccess() {
+		t.Errorf("StatusCode() = %04X, want 9000", resp.StatusCode())
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_WithTransaction_Nested(t *testing.T) {
+	data := make([]byte, 300)
+	card := chainedScript(0x00, data, 0x9000).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C3).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}, nil, 0x9000)
+	service := newTestService(t, card)
+
+	err := service.WithTransaction(context.Background(), func(domain.Card) error {
+		if _, err := service.TransmitChained(context.Background(), &domain.APDU{CLA: 0x00, INS: 0x2A, P1: 0x9E, P2: 0x9A, Data: data}); err != nil {
+			return err
+		}
+		return service.VerifyPIN(context.Background(), []byte("1234"), 0x01)
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+	if card.Transactions() != 1 {
+		t.Errorf("Transactions() = %d, want nested operations in one transaction", card.Transactions())
+	}
+	if err := card.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSmartCardService_TransmitChained_IntermediateError(t *testing.T) {
+	data := make([]byte, 300)
+	card := infrastructure.NewScriptedCard().
+		Expect(append([]byte{0x10, 0x2A, 0x9E, 0x9A, 0xFF}, data[:255]...), nil, 0x6884)
+	service := newTestService(t, card)
+
+	_, err := service.TransmitChained(context.Background(), &domain.APDU{CLA: 0x00, INS: 0x2A, P1: 0x9E, P2: 0x9A, Data: data})
+	var statusErr *domain.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 0x6884 {
+		t.Errorf("TransmitChained() error = %v, want status 6884", err)
+	}
+	if err := card.Verify(); err != nil {
+		t.Errorf("transaction should be ended after a failed chain: %v", err)
+	}
+}
+
+func TestSmartCardService_TransmitLarge_Policy(t *testing.T) {
+	data := make([]byte, 300)
+	apdu := &domain.APDU{CLA: 0x00, INS: 0x2A, P1: 0x9E, P2: 0x9A, Data: data}
+
+	// Default policy: one extended APDU
+	extended := infrastructure.NewScriptedCard().ExpectAPDU(apdu, nil, 0x9000)
+	service := newTestService(t, extended)
+	if _, err := service.TransmitLarge(context.Background(), apdu); err != nil {
+		t.Fatalf("TransmitLarge() extended error = %v", err)
+	}
+	if err := extended.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	// Reader-specific policy: chaining
+	chained := chainedScript(0x00, data, 0x9000)
+	service = newTestService(t, chained)
+	service.SetLengthPolicy("Scripted Card Reader", domain.LengthPolicyChaining)
+	if service.LengthPolicy() != domain.LengthPolicyChaining {
+		t.Fatalf("LengthPolicy() = %v, want chaining", service.LengthPolicy())
+	}
+	if _, err := service.TransmitLarge(context.Background(), apdu); err != nil {
+		t.Fatalf("TransmitLarge() chaining error = %v", err)
+	}
+	if err := chained.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+// hangingCard never answers: Transmit returns only when ctx is done
+type hangingCard struct {
+	deadline time.Time
+}
+
+func (c *hangingCard) Connect(ctx context.Context) (domain.Card, error) { return c, nil }
+func (c *hangingCard) Disconnect() error                                { return nil }
+func (c *hangingCard) Status() (*domain.CardStatus, error)              { return &domain.CardStatus{}, nil }
+
+func (c *hangingCard) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	c.deadline, _ = ctx.Deadline()
+	<-ctx.Done()
+	return nil, domain.NewTimeoutError(ctx.Err())
+}
+
+func TestSmartCardService_CommandTimeout(t *testing.T) {
+	card := &hangingCard{}
+	service := NewSmartCardService(card, nil)
+	if err := service.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	service.SetCommandTimeout(0xA4, 20*time.Millisecond)
+
+	start := time.Now()
+	_, err := service.SelectApplication(context.Background(), testAID)
+	var transportErr *domain.TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != domain.ErrTimeout {
+		t.Fatalf("SelectApplication() error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SelectApplication() returned after %v, want the SELECT timeout", elapsed)
+	}
+}
+
+func TestSmartCardService_CommandTimeout_PerInstruction(t *testing.T) {
+	card := &hangingCard{}
+	service := NewSmartCardService(card, nil)
+	if err := service.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	// Cancel right away; only the deadline chosen for VERIFY matters here
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := service.VerifyPIN(ctx, []byte("1234"), 0x01)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("VerifyPIN() error = %v, want context.Canceled", err)
+	}
+
+	want := domain.DefaultCommandTimeouts().For(&domain.APDU{INS: 0x20})
+	if remaining := time.Until(card.deadline); remaining <= domain.DefaultCommandTimeout || remaining > want {
+		t.Errorf("VERIFY deadline in %v, want about %v", remaining, want)
+	}
+}
+
+func TestSmartCardService_RequireSupportedCard(t *testing.T) {
+	belgian := []byte{0x3B, 0x98, 0x13, 0x40, 0x0A, 0xA5, 0x03, 0x01, 0x01, 0x01, 0xAD, 0x13, 0x11}
+	cei := []byte{0x3B, 0xFF, 0x96, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x43,
+		0x80, 0x31, 0x80, 0x65, 0xB0, 0x85, 0x03, 0x00, 0xEF, 0x12, 0x0F, 0xFF, 0x82, 0x90, 0x00, 0x19}
+
+	tests := []struct {
+		name         string
+		atr          []byte
+		allowUnknown bool
+		wantErr      bool
+	}{
+		{"CEI accepted", cei, false, false},
+		{"foreign eID refused", belgian, true, true},
+		{"unknown card refused", []byte{0x3B, 0x00}, false, true},
+		{"unknown card allowed", []byte{0x3B, 0x00}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := infrastructure.NewScriptedCard()
+			card.SetStatus(domain.CardStatus{ATR: tt.atr, ActiveProtocol: "T=1", Reader: "Reader A"})
+			service := NewSmartCardService(card, nil)
+			service.RequireSupportedCard(tt.allowUnknown)
+
+			err := service.Connect(context.Background())
+			var unsupported *domain.UnsupportedCardError
+			if (err != nil) != tt.wantErr || (err != nil && !errors.As(err, &unsupported)) {
+				t.Fatalf("Connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := service.Status(); err == nil {
+					t.Error("a refused card should leave the service disconnected")
+				}
+			}
+		})
+	}
+}
+
+func TestSmartCardService_ReplayTrace(t *testing.T) {
+	// Session recorded from the simulator: SELECT, VERIFY PIN1, READ BINARY
+	replay, err := infrastructure.LoadReplayCard("../infrastructure/testdata/traces/cei-simulator-pin1.json")
+	if err != nil {
+		t.Fatalf("LoadReplayCard() error = %v", err)
+	}
+	service := NewSmartCardService(replay, nil)
+	service.ProtectLastPINTry(false) // Recorded without the retry counter query
+	ctx := context.Background()
+
+	if err := service.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := service.SelectApplication(ctx, testAID); err != nil {
+		t.Fatalf("SelectApplication() error = %v", err)
+	}
+	if err := service.VerifyPIN(ctx, []byte("1234"), 0x01); err != nil {
+		t.Fatalf("VerifyPIN() error = %v", err)
+	}
+	resp, err := service.Transmit(ctx, &domain.APDU{INS: 0xB0, P1: 0x81, Le: domain.MaxShortLe})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if !strings.HasPrefix(string(resp.Data), "SURNAME=") {
+		t.Errorf("READ BINARY = %q, want the recorded personal data", resp.Data)
+	}
+	if err := replay.Verify(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,78 @@
 package domain
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// Length limits for short and extended APDUs (ISO/IEC 7816-4 §5.1)
+const (
+	MaxShortLc    = 255   // Largest Nc encodable in one Lc byte
+	MaxShortLe    = 256   // Largest Ne encodable in one Le byte ("00")
+	MaxExtendedLc = 65535 // Largest Nc encodable in extended Lc
+	MaxExtendedLe = 65536 // Largest Ne encodable in extended Le ("0000")
+)
+
+// ErrMalformedAPDU is wrapped by ParseAPDU errors
+var ErrMalformedAPDU = errors.New("malformed command APDU")
+
+// CLAChainingBit marks all but the last command of a chain (ISO/IEC 7816-4 §5.3.3)
+const CLAChainingBit byte = 0x10
+
+// LengthPolicy selects how commands exceeding short APDU limits are sent
+type LengthPolicy int
+
+const (
+	LengthPolicyExtended LengthPolicy = iota // Extended Lc/Le (reader and card must support it)
+	LengthPolicyChaining                     // Short APDUs linked with command chaining
+)
+
+// String returns the policy name
+func (p LengthPolicy) String() string {
+	switch p {
+	case LengthPolicyExtended:
+		return "extended"
+	case LengthPolicyChaining:
+		return "chaining"
+	default:
+		return fmt.Sprintf("LengthPolicy(%d)", int(p))
+	}
+}
+
+// APDUCase identifies the command APDU structure (ISO/IEC 7816-3 §12.1)
+type APDUCase int
+
+const (
+	APDUCase1  APDUCase = iota + 1 // Header only
+	APDUCase2S                     // Header + short Le
+	APDUCase3S                     // Header + short Lc + data
+	APDUCase4S                     // Header + short Lc + data + short Le
+	APDUCase2E                     // Header + extended Le
+	APDUCase3E                     // Header + extended Lc + data
+	APDUCase4E                     // Header + extended Lc + data + extended Le
+)
+
+// String returns the conventional case name (e.g. "4E")
+func (c APDUCase) String() string {
+	switch c {
+	case APDUCase1:
+		return "1"
+	case APDUCase2S:
+		return "2S"
+	case APDUCase3S:
+		return "3S"
+	case APDUCase4S:
+		return "4S"
+	case APDUCase2E:
+		return "2E"
+	case APDUCase3E:
+		return "3E"
+	case APDUCase4E:
+		return "4E"
+	default:
+		return fmt.Sprintf("APDUCase(%d)", int(c))
+	}
+}
 
 // APDU represents a smart card command/response (ISO/IEC 7816-4)
 type APDU struct {
@@ -9,28 +81,181 @@ type APDU struct {
 	P1   byte   // Parameter 1
 	P2   byte   // Parameter 2
 	Data []byte // Command data
-	Le   byte   // Expected response length (0 = unspecified)
+	Le   int    // Expected response length Ne (0 = absent, 256 = short "00", 65536 = extended "0000")
+
+	// Extended forces extended length fields when short ones would do, so
+	// that parsed commands serialize exactly as received
+	Extended bool
+}
+
+// IsExtended reports whether the command needs extended length fields.
+// Lc and Le are always encoded in the same form, so one field exceeding
+// the short limits makes both extended.
+func (a *APDU) IsExtended() bool {
+	if a.Extended && (len(a.Data) > 0 || a.Le > 0) {
+		return true
+	}
+	return len(a.Data) > MaxShortLc || a.Le > MaxShortLe
 }
 
-// Bytes serializes APDU to ISO/IEC 7816-4 format
+// Case returns the APDU case chosen for serialization
+func (a *APDU) Case() APDUCase {
+	hasData, hasLe := len(a.Data) > 0, a.Le > 0
+	extended := a.IsExtended()
+
+	switch {
+	case !hasData && !hasLe:
+		return APDUCase1
+	case !hasData && !extended:
+		return APDUCase2S
+	case !hasData:
+		return APDUCase2E
+	case !hasLe && !extended:
+		return APDUCase3S
+	case !hasLe:
+		return APDUCase3E
+	case !extended:
+		return APDUCase4S
+	default:
+		return APDUCase4E
+	}
+}
+
+// Validate checks that Nc and Ne fit the ISO/IEC 7816-4 limits
+func (a *APDU) Validate() error {
+	if len(a.Data) > MaxExtendedLc {
+		return fmt.Errorf("command data too long: %d bytes (max %d)", len(a.Data), MaxExtendedLc)
+	}
+	if a.Le < 0 || a.Le > MaxExtendedLe {
+		return fmt.Errorf("expected length out of range: %d (0-%d)", a.Le, MaxExtendedLe)
+	}
+	return nil
+}
+
+// Bytes serializes APDU to ISO/IEC 7816-4 format, choosing the short or
+// extended encoding automatically. Call Validate first for untrusted lengths.
 func (a *APDU) Bytes() []byte {
 	cmd := []byte{a.CLA, a.INS, a.P1, a.P2}
-	if len(a.Data) == 0 {
-		// No data, just header + Le
-		if a.Le > 0 {
-			cmd = append(cmd, a.Le)
+	extended := a.IsExtended()
+
+	if len(a.Data) > 0 {
+		lc := len(a.Data)
+		if extended {
+			cmd = append(cmd, 0x00, byte(lc>>8), byte(lc))
+		} else {
+			cmd = append(cmd, byte(lc))
 		}
-		return cmd
+		cmd = append(cmd, a.Data...)
 	}
-	// With data
-	cmd = append(cmd, byte(len(a.Data)))
-	cmd = append(cmd, a.Data...)
+
 	if a.Le > 0 {
-		cmd = append(cmd, a.Le)
+		if !extended {
+			// Ne = 256 encodes as 0x00
+			return append(cmd, byte(a.Le))
+		}
+		if len(a.Data) == 0 {
+			// Case 2E: Le is preceded by a zero byte
+			cmd = append(cmd, 0x00)
+		}
+		// Ne = 65536 encodes as 0x0000
+		cmd = append(cmd, byte(a.Le>>8), byte(a.Le))
 	}
 	return cmd
 }
 
+// ParseAPDU decodes a command APDU, recognising the cases of ISO/IEC 7816-3
+// §12.1.3 strictly: the body after the header must be exactly one of Le,
+// Lc+data or Lc+data+Le in the short or extended form. The result
+// serializes back to the same bytes with Bytes.
+func ParseAPDU(data []byte) (*APDU, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: %d bytes, the header needs 4", ErrMalformedAPDU, len(data))
+	}
+	apdu := &APDU{CLA: data[0], INS: data[1], P1: data[2], P2: data[3]}
+	body := data[4:]
+
+	switch {
+	case len(body) == 0: // Case 1
+		return apdu, nil
+
+	case len(body) == 1: // Case 2S
+		apdu.Le = decodeLe(int(body[0]), MaxShortLe)
+		return apdu, nil
+
+	case body[0] != 0x00: // Cases 3S and 4S
+		lc := int(body[0])
+		switch len(body) {
+		case 1 + lc:
+		case 2 + lc:
+			apdu.Le = decodeLe(int(body[len(body)-1]), MaxShortLe)
+		default:
+			return nil, fmt.Errorf("%w: short Lc %d needs %d or %d bytes after it, got %d",
+				ErrMalformedAPDU, lc, lc, lc+1, len(body)-1)
+		}
+		apdu.Data = body[1 : 1+lc]
+		return apdu, nil
+
+	case len(body) < 3:
+		return nil, fmt.Errorf("%w: extended length field truncated (%d bytes after the header)",
+			ErrMalformedAPDU, len(body))
+
+	case len(body) == 3: // Case 2E
+		apdu.Le = decodeLe(int(body[1])<<8|int(body[2]), MaxExtendedLe)
+		apdu.Extended = true
+		return apdu, nil
+	}
+
+	// Cases 3E and 4E
+	lc := int(body[1])<<8 | int(body[2])
+	if lc == 0 {
+		return nil, fmt.Errorf("%w: extended Lc is zero", ErrMalformedAPDU)
+	}
+	switch len(body) {
+	case 3 + lc:
+	case 5 + lc:
+		apdu.Le = decodeLe(int(body[len(body)-2])<<8|int(body[len(body)-1]), MaxExtendedLe)
+	default:
+		return nil, fmt.Errorf("%w: extended Lc %d needs %d or %d bytes after it, got %d",
+			ErrMalformedAPDU, lc, lc, lc+2, len(body)-3)
+	}
+	apdu.Data = body[3 : 3+lc]
+	apdu.Extended = true
+	return apdu, nil
+}
+
+// decodeLe converts an encoded Le field to Ne (a zero field means the maximum)
+func decodeLe(encoded int, maximum int) int {
+	if encoded == 0 {
+		return maximum
+	}
+	return encoded
+}
+
+// Chain splits the command data into short APDUs of at most maxChunk bytes.
+// Every part but the last has the chaining bit set in CLA and no Le; the
+// last part carries the original CLA and Le. A command that fits in one
+// chunk is returned unchanged as a single part.
+func (a *APDU) Chain(maxChunk int) []*APDU {
+	if maxChunk <= 0 || maxChunk > MaxShortLc {
+		maxChunk = MaxShortLc
+	}
+	if len(a.Data) <= maxChunk {
+		return []*APDU{a}
+	}
+
+	var parts []*APDU
+	for offset := 0; offset < len(a.Data); offset += maxChunk {
+		end := min(offset+maxChunk, len(a.Data))
+		part := &APDU{CLA: a.CLA | CLAChainingBit, INS: a.INS, P1: a.P1, P2: a.P2, Data: a.Data[offset:end]}
+		if end == len(a.Data) {
+			part.CLA = a.CLA
+			part.Le = a.Le
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
 // Response represents a smart card response
 type Response struct {
 	Data []byte // Response data
@@ -53,7 +278,8 @@ func (r *Response) Error() string {
 	return fmt.Sprintf("APDU error: SW1=%02X SW2=%02X", r.SW1, r.SW2)
 }
 
-// NewResponse creates a Response from raw bytes (last 2 bytes are status words)
+// NewResponse creates a Response from raw bytes (last 2 bytes are status words).
+// The data field may be any length, including extended responses up to 65536 bytes.
 func NewResponse(data []byte) *Response {
 	if len(data) < 2 {
 		return &Response{Data: data, SW1: 0x6F, SW2: 0x00} // Unknown error
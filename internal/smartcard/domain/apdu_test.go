@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"bytes"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -121,6 +124,253 @@ func TestAPDU_Bytes_LeZeroNotAppended(t *testing.T) {
 	}
 }
 
+func TestAPDU_Bytes_ShortLe256(t *testing.T) {
+	// Ne = 256 is the short Le byte 00, distinct from an absent Le
+	apdu := &APDU{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x00, Le: MaxShortLe}
+
+	got := apdu.Bytes()
+	want := []byte{0x00, 0xB0, 0x00, 0x00, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Bytes() = %02X, want %02X", got, want)
+	}
+	if apdu.Case() != APDUCase2S {
+		t.Errorf("Case() = %v, want 2S", apdu.Case())
+	}
+}
+
+func TestAPDU_Bytes_Extended(t *testing.T) {
+	data300 := bytes.Repeat([]byte{0xAA}, 300)
+
+	tests := []struct {
+		name     string
+		apdu     *APDU
+		wantCase APDUCase
+		wantTail []byte // Bytes after the 4-byte header, excluding data
+		dataAt   int    // Offset of the data field after the header
+	}{
+		{
+			name:     "case 2E",
+			apdu:     &APDU{INS: 0xB0, Le: 1024},
+			wantCase: APDUCase2E,
+			wantTail: []byte{0x00, 0x04, 0x00},
+		},
+		{
+			name:     "case 2E Le 65536",
+			apdu:     &APDU{INS: 0xB0, Le: MaxExtendedLe},
+			wantCase: APDUCase2E,
+			wantTail: []byte{0x00, 0x00, 0x00},
+		},
+		{
+			name:     "case 3E",
+			apdu:     &APDU{INS: 0xD6, Data: data300},
+			wantCase: APDUCase3E,
+			wantTail: []byte{0x00, 0x01, 0x2C},
+			dataAt:   3,
+		},
+		{
+			name:     "case 4E with small Le",
+			apdu:     &APDU{INS: 0x2A, Data: data300, Le: 256},
+			wantCase: APDUCase4E,
+			wantTail: []byte{0x00, 0x01, 0x2C, 0x01, 0x00},
+			dataAt:   3,
+		},
+		{
+			name:     "case 4E with short data",
+			apdu:     &APDU{INS: 0xB1, Data: []byte{0x54, 0x02, 0x00, 0x00}, Le: 4096},
+			wantCase: APDUCase4E,
+			wantTail: []byte{0x00, 0x00, 0x04, 0x10, 0x00},
+			dataAt:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.apdu.IsExtended() {
+				t.Error("IsExtended() = false, want true")
+			}
+			if got := tt.apdu.Case(); got != tt.wantCase {
+				t.Errorf("Case() = %v, want %v", got, tt.wantCase)
+			}
+
+			got := tt.apdu.Bytes()[4:]
+			if len(tt.apdu.Data) > 0 {
+				// Strip the data field to compare the length fields only
+				data := got[tt.dataAt : tt.dataAt+len(tt.apdu.Data)]
+				if !bytes.Equal(data, tt.apdu.Data) {
+					t.Fatalf("data field not at offset %d", tt.dataAt)
+				}
+				got = append(append([]byte{}, got[:tt.dataAt]...), got[tt.dataAt+len(tt.apdu.Data):]...)
+			}
+			if !bytes.Equal(got, tt.wantTail) {
+				t.Errorf("length fields = %02X, want %02X", got, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestParseAPDU(t *testing.T) {
+	data300 := bytes.Repeat([]byte{0xAA}, 300)
+
+	tests := []struct {
+		name     string
+		raw      []byte
+		wantCase APDUCase
+		wantLc   int
+		wantLe   int
+	}{
+		{"case 1", []byte{0x00, 0x20, 0x00, 0x01}, APDUCase1, 0, 0},
+		{"case 2S", []byte{0x00, 0x84, 0x00, 0x00, 0x08}, APDUCase2S, 0, 8},
+		{"case 2S Le 256", []byte{0x00, 0xB0, 0x00, 0x00, 0x00}, APDUCase2S, 0, 256},
+		{"case 3S", []byte{0x00, 0x20, 0x00, 0x01, 0x02, 0x31, 0x32}, APDUCase3S, 2, 0},
+		{"case 4S", []byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x3F, 0x00, 0x00}, APDUCase4S, 2, 256},
+		{"case 4S data looks like Lc", []byte{0x00, 0xA4, 0x04, 0x00, 0x01, 0x01, 0x10}, APDUCase4S, 1, 16},
+		{"case 2E", []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x04, 0x00}, APDUCase2E, 0, 1024},
+		{"case 2E small Le", []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x10}, APDUCase2E, 0, 16},
+		{"case 2E Le 65536", []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x00}, APDUCase2E, 0, 65536},
+		{"case 3E", append([]byte{0x00, 0xD6, 0x00, 0x00, 0x00, 0x01, 0x2C}, data300...), APDUCase3E, 300, 0},
+		{"case 3E short data", []byte{0x00, 0xD6, 0x00, 0x00, 0x00, 0x00, 0x01, 0xAA}, APDUCase3E, 1, 0},
+		{"case 4E", append(append([]byte{0x00, 0x2A, 0x9E, 0x9A, 0x00, 0x01, 0x2C}, data300...), 0x00, 0x00), APDUCase4E, 300, 65536},
+		{"case 4E short lengths", []byte{0x00, 0x2A, 0x9E, 0x9A, 0x00, 0x00, 0x01, 0xAA, 0x01, 0x00}, APDUCase4E, 1, 256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apdu, err := ParseAPDU(tt.raw)
+			if err != nil {
+				t.Fatalf("ParseAPDU() error = %v", err)
+			}
+			if apdu.Case() != tt.wantCase || len(apdu.Data) != tt.wantLc || apdu.Le != tt.wantLe {
+				t.Errorf("ParseAPDU() = case %v, Nc %d, Ne %d, want case %v, Nc %d, Ne %d",
+					apdu.Case(), len(apdu.Data), apdu.Le, tt.wantCase, tt.wantLc, tt.wantLe)
+			}
+			if got := apdu.Bytes(); !bytes.Equal(got, tt.raw) {
+				t.Errorf("Bytes() = %02X, want the parsed %02X", got, tt.raw)
+			}
+		})
+	}
+}
+
+func TestParseAPDU_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"short header", []byte{0x00, 0xA4}, "2 bytes, the header needs 4"},
+		{"short data missing", []byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31}, "short Lc 4 needs 4 or 5 bytes after it, got 1"},
+		{"short trailing bytes", []byte{0x00, 0x20, 0x00, 0x01, 0x01, 0x31, 0x00, 0x00}, "short Lc 1 needs 1 or 2 bytes after it, got 3"},
+		{"extended truncated", []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01}, "extended length field truncated"},
+		{"extended Lc zero", []byte{0x00, 0xD6, 0x00, 0x00, 0x00, 0x00, 0x00, 0xAA}, "extended Lc is zero"},
+		{"extended data missing", []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01, 0x2C, 0x01}, "extended Lc 300 needs 300 or 302 bytes after it, got 1"},
+		{"extended one-byte Le", []byte{0x00, 0xD6, 0x00, 0x00, 0x00, 0x00, 0x01, 0xAA, 0x00}, "extended Lc 1 needs 1 or 3 bytes after it, got 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAPDU(tt.raw)
+			if !errors.Is(err, ErrMalformedAPDU) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseAPDU(%02X) error = %v, want %q", tt.raw, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPDU_Case_Short(t *testing.T) {
+	tests := []struct {
+		apdu *APDU
+		want APDUCase
+	}{
+		{&APDU{INS: 0xA4}, APDUCase1},
+		{&APDU{INS: 0x84, Le: 8}, APDUCase2S},
+		{&APDU{INS: 0x20, Data: []byte{0x31}}, APDUCase3S},
+		{&APDU{INS: 0xA4, Data: []byte{0x3F, 0x00}, Le: 256}, APDUCase4S},
+		{&APDU{INS: 0xD6, Data: bytes.Repeat([]byte{0x01}, MaxShortLc)}, APDUCase3S},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want.String(), func(t *testing.T) {
+			if got := tt.apdu.Case(); got != tt.want {
+				t.Errorf("Case() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPDU_Validate(t *testing.T) {
+	valid := &APDU{Data: bytes.Repeat([]byte{0x01}, MaxExtendedLc), Le: MaxExtendedLe}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v for maximum lengths", err)
+	}
+
+	tooLong := &APDU{Data: make([]byte, MaxExtendedLc+1)}
+	if err := tooLong.Validate(); err == nil {
+		t.Error("Validate() should reject data over 65535 bytes")
+	}
+
+	badLe := &APDU{Le: MaxExtendedLe + 1}
+	if err := badLe.Validate(); err == nil {
+		t.Error("Validate() should reject Le over 65536")
+	}
+}
+
+func TestAPDU_Chain(t *testing.T) {
+	data := make([]byte, 600)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	apdu := &APDU{CLA: 0x00, INS: 0x86, Data: data, Le: 256}
+
+	parts := apdu.Chain(MaxShortLc)
+	if len(parts) != 3 {
+		t.Fatalf("Chain() returned %d parts, want 3", len(parts))
+	}
+
+	var joined []byte
+	for i, part := range parts {
+		last := i == len(parts)-1
+		if chained := part.CLA&CLAChainingBit != 0; chained == last {
+			t.Errorf("part %d CLA = %02X, chaining bit should be set on all but the last", i, part.CLA)
+		}
+		if !last && part.Le != 0 {
+			t.Errorf("part %d Le = %d, want absent", i, part.Le)
+		}
+		if part.IsExtended() {
+			t.Errorf("part %d is extended, want short", i)
+		}
+		joined = append(joined, part.Data...)
+	}
+	if parts[2].Le != 256 {
+		t.Errorf("last part Le = %d, want 256", parts[2].Le)
+	}
+	if len(parts[0].Data) != 255 || len(parts[2].Data) != 90 {
+		t.Errorf("chunk sizes = %d/%d, want 255/90", len(parts[0].Data), len(parts[2].Data))
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("chained data does not reassemble to the original")
+	}
+}
+
+func TestAPDU_Chain_SinglePart(t *testing.T) {
+	apdu := &APDU{CLA: 0x00, INS: 0x20, Data: []byte("1234")}
+
+	parts := apdu.Chain(MaxShortLc)
+	if len(parts) != 1 || parts[0] != apdu {
+		t.Errorf("Chain() = %d parts, want the original APDU", len(parts))
+	}
+}
+
+func TestNewResponse_Extended(t *testing.T) {
+	data := append(bytes.Repeat([]byte{0x5A}, 4096), 0x90, 0x00)
+	resp := NewResponse(data)
+
+	if len(resp.Data) != 4096 {
+		t.Errorf("Data length = %d, want 4096", len(resp.Data))
+	}
+	if !resp.IsSuccess() {
+		t.Errorf("StatusCode() = %04X, want 9000", resp.StatusCode())
+	}
+}
+
 func TestNewResponse_Success(t *testing.T) {
 	// Response with data and success status
 	data := []byte{0x6F, 0x10, 0x84, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x90, 0x00}
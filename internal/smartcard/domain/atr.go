@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ATR convention bytes (ISO/IEC 7816-3 §8.1)
+const (
+	ATRDirectConvention  byte = 0x3B
+	ATRInverseConvention byte = 0x3F
+)
+
+// fiTable and fmaxTable map the TA1 high nibble to Fi and f(max) in kHz
+// (ISO/IEC 7816-3 Table 7); zero marks reserved values
+var (
+	fiTable   = [16]int{372, 372, 558, 744, 1116, 1488, 1860, 0, 0, 512, 768, 1024, 1536, 2048, 0, 0}
+	fmaxTable = [16]int{4000, 5000, 6000, 8000, 12000, 16000, 20000, 0, 0, 5000, 7500, 10000, 15000, 20000, 0, 0}
+	diTable   = [16]int{0, 1, 2, 4, 8, 16, 32, 64, 12, 20, 0, 0, 0, 0, 0, 0}
+)
+
+// ATRInterfaceBytes is one group of interface bytes (TAi, TBi, TCi, TDi);
+// absent bytes are nil
+type ATRInterfaceBytes struct {
+	TA, TB, TC, TD *byte
+}
+
+// CompactTLV is a COMPACT-TLV data object from the historical bytes
+// (ISO/IEC 7816-4 §8.1.1.2): tag in the high nibble, length in the low one
+type CompactTLV struct {
+	Tag   byte
+	Value []byte
+}
+
+// ParsedATR is a decoded Answer To Reset (ISO/IEC 7816-3 §8)
+type ParsedATR struct {
+	Raw        []byte
+	TS         byte
+	T0         byte
+	Interfaces []ATRInterfaceBytes // Groups 1..n
+	Protocols  []int               // Offered protocols (T=0, T=1, ...) in order
+
+	Fi, Di int // Clock rate conversion and baud rate adjustment factors
+	FMax   int // Maximum clock frequency in kHz
+
+	Historical        []byte
+	CategoryIndicator byte         // First historical byte (0x00, 0x80 and 0x10 are standard)
+	HistoricalTLV     []CompactTLV // Objects when the category indicator is 0x00 or 0x80
+
+	TCK      *byte // Check byte, present unless only T=0 is offered
+	TCKValid bool  // XOR of T0..TCK is zero (true when no TCK is required)
+}
+
+// ParseATR decodes an ATR. A wrong TCK is not an error (some readers
+// synthesise ATRs) but is reported through TCKValid.
+func ParseATR(raw []byte) (*ParsedATR, error) {
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("ATR too short: %d bytes", len(raw))
+	}
+	atr := &ParsedATR{Raw: raw, TS: raw[0], T0: raw[1], Fi: 372, Di: 1, FMax: 5000}
+	if atr.TS != ATRDirectConvention && atr.TS != ATRInverseConvention {
+		return nil, fmt.Errorf("invalid ATR initial character TS=%02X", atr.TS)
+	}
+
+	pos := 2
+	indicator := atr.T0 >> 4 // Presence of TAi..TDi in bits 5-8
+	needTCK := false
+	for i := 1; ; i++ {
+		group := ATRInterfaceBytes{}
+		for bit, field := range []**byte{&group.TA, &group.TB, &group.TC, &group.TD} {
+			if indicator&(1<<bit) == 0 {
+				continue
+			}
+			if pos >= len(raw) {
+				return nil, fmt.Errorf("ATR truncated in interface bytes group %d", i)
+			}
+			b := raw[pos]
+			*field = &b
+			pos++
+		}
+		atr.Interfaces = append(atr.Interfaces, group)
+
+		if group.TD == nil {
+			break
+		}
+		protocol := int(*group.TD & 0x0F)
+		if protocol != 0 {
+			needTCK = true
+		}
+		if !slices.Contains(atr.Protocols, protocol) && protocol != 15 { // T=15 is global, not a protocol
+			atr.Protocols = append(atr.Protocols, protocol)
+		}
+		indicator = *group.TD >> 4
+	}
+	if len(atr.Protocols) == 0 {
+		atr.Protocols = []int{0} // No TD1: only T=0
+	}
+
+	if ta1 := atr.Interfaces[0].TA; ta1 != nil {
+		atr.Fi = fiTable[*ta1>>4]
+		atr.FMax = fmaxTable[*ta1>>4]
+		atr.Di = diTable[*ta1&0x0F]
+	}
+
+	k := int(atr.T0 & 0x0F)
+	if pos+k > len(raw) {
+		return nil, fmt.Errorf("ATR truncated: %d historical bytes expected, %d left", k, len(raw)-pos)
+	}
+	atr.Historical = raw[pos : pos+k]
+	pos += k
+	atr.parseHistorical()
+
+	atr.TCKValid = true
+	if needTCK {
+		if pos >= len(raw) {
+			return nil, fmt.Errorf("ATR truncated: TCK missing")
+		}
+		tck := raw[pos]
+		atr.TCK = &tck
+		pos++
+
+		var check byte
+		for _, b := range raw[1:pos] {
+			check ^= b
+		}
+		atr.TCKValid = check == 0
+	}
+	if pos != len(raw) {
+		return nil, fmt.Errorf("ATR has %d unexpected trailing bytes", len(raw)-pos)
+	}
+	return atr, nil
+}
+
+// parseHistorical decodes COMPACT-TLV historical bytes (ISO/IEC 7816-4 §8.1.1)
+func (a *ParsedATR) parseHistorical() {
+	if len(a.Historical) == 0 {
+		return
+	}
+	a.CategoryIndicator = a.Historical[0]
+
+	var objects []byte
+	switch a.CategoryIndicator {
+	case 0x00:
+		// Objects followed by a mandatory 3-byte status indicator
+		if len(a.Historical) < 4 {
+			return
+		}
+		objects = a.Historical[1 : len(a.Historical)-3]
+	case 0x80:
+		objects = a.Historical[1:]
+	default:
+		return
+	}
+
+	for len(objects) > 0 {
+		tag, length := objects[0]>>4, int(objects[0]&0x0F)
+		if 1+length > len(objects) {
+			return // Malformed: keep what was decoded
+		}
+		a.HistoricalTLV = append(a.HistoricalTLV, CompactTLV{Tag: tag, Value: objects[1 : 1+length]})
+		objects = objects[1+length:]
+	}
+}
+
+// SupportsProtocol reports whether the card offers protocol T=t
+func (a *ParsedATR) SupportsProtocol(t int) bool {
+	return slices.Contains(a.Protocols, t)
+}
+
+// String summarises protocols, transmission factors and historical bytes
+func (a *ParsedATR) String() string {
+	protocols := make([]string, len(a.Protocols))
+	for i, t := range a.Protocols {
+		protocols[i] = fmt.Sprintf("T=%d", t)
+	}
+
+	summary := fmt.Sprintf("%s, Fi=%d Di=%d (fmax %d kHz)", strings.Join(protocols, ","), a.Fi, a.Di, a.FMax)
+	if a.TS == ATRInverseConvention {
+		summary += ", inverse convention"
+	}
+	if len(a.Historical) > 0 {
+		summary += fmt.Sprintf(", historical bytes %02X", a.Historical)
+	}
+	if !a.TCKValid {
+		summary += ", TCK invalid"
+	}
+	return summary
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ceiShapedATR follows the CEI prefix (ID-One Cosmo interface bytes and
+// "80 31 80 65 B0 85") with illustrative version bytes and a valid TCK
+var ceiShapedATR = []byte{
+	0x3B, 0xFF, 0x96, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x43,
+	0x80, 0x31, 0x80, 0x65, 0xB0, 0x85, 0x03, 0x00, 0xEF, 0x12, 0x0F, 0xFF, 0x82, 0x90, 0x00,
+	0x19,
+}
+
+func TestParseATR_CEI(t *testing.T) {
+	atr, err := ParseATR(ceiShapedATR)
+	if err != nil {
+		t.Fatalf("ParseATR() error = %v", err)
+	}
+
+	if len(atr.Interfaces) != 3 {
+		t.Fatalf("len(Interfaces) = %d, want 3", len(atr.Interfaces))
+	}
+	if ta1 := atr.Interfaces[0].TA; ta1 == nil || *ta1 != 0x96 {
+		t.Errorf("TA1 = %v, want 96", ta1)
+	}
+	if tb3 := atr.Interfaces[2].TB; tb3 == nil || *tb3 != 0x43 {
+		t.Errorf("TB3 = %v, want 43", tb3)
+	}
+	if !reflect.DeepEqual(atr.Protocols, []int{1}) {
+		t.Errorf("Protocols = %v, want [1]", atr.Protocols)
+	}
+	if atr.Fi != 512 || atr.Di != 32 || atr.FMax != 5000 {
+		t.Errorf("Fi/Di/fmax = %d/%d/%d, want 512/32/5000", atr.Fi, atr.Di, atr.FMax)
+	}
+	if len(atr.Historical) != 15 || atr.CategoryIndicator != 0x80 {
+		t.Errorf("Historical = %02X, want 15 bytes starting with 80", atr.Historical)
+	}
+	wantTLV := []CompactTLV{
+		{Tag: 0x3, Value: []byte{0x80}},
+		{Tag: 0x6, Value: []byte{0xB0, 0x85, 0x03, 0x00, 0xEF}},
+		{Tag: 0x1, Value: []byte{0x0F, 0xFF}},
+		{Tag: 0x8, Value: []byte{0x90, 0x00}},
+	}
+	if !reflect.DeepEqual(atr.HistoricalTLV, wantTLV) {
+		t.Errorf("HistoricalTLV = %v, want %v", atr.HistoricalTLV, wantTLV)
+	}
+	if atr.TCK == nil || *atr.TCK != 0x19 || !atr.TCKValid {
+		t.Errorf("TCK = %v valid %v, want valid 19", atr.TCK, atr.TCKValid)
+	}
+	if s := atr.String(); !strings.Contains(s, "T=1, Fi=512 Di=32") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestParseATR_T0Only(t *testing.T) {
+	// No interface bytes, two historical bytes, no TCK
+	atr, err := ParseATR([]byte{0x3B, 0x02, 0x14, 0x50})
+	if err != nil {
+		t.Fatalf("ParseATR() error = %v", err)
+	}
+	if !reflect.DeepEqual(atr.Protocols, []int{0}) || !atr.SupportsProtocol(0) || atr.SupportsProtocol(1) {
+		t.Errorf("Protocols = %v, want [0]", atr.Protocols)
+	}
+	if atr.Fi != 372 || atr.Di != 1 {
+		t.Errorf("Fi/Di = %d/%d, want defaults 372/1", atr.Fi, atr.Di)
+	}
+	if atr.TCK != nil || !atr.TCKValid {
+		t.Error("T=0 only ATR should have no TCK")
+	}
+	if !bytes.Equal(atr.Historical, []byte{0x14, 0x50}) {
+		t.Errorf("Historical = %02X, want 1450", atr.Historical)
+	}
+}
+
+func TestParseATR_StatusIndicator(t *testing.T) {
+	// Category 00: objects followed by a 3-byte status indicator
+	atr, err := ParseATR([]byte{0x3B, 0x06, 0x00, 0x31, 0xC0, 0x01, 0x90, 0x00})
+	if err != nil {
+		t.Fatalf("ParseATR() error = %v", err)
+	}
+	want := []CompactTLV{{Tag: 0x3, Value: []byte{0xC0}}}
+	if !reflect.DeepEqual(atr.HistoricalTLV, want) {
+		t.Errorf("HistoricalTLV = %v, want %v", atr.HistoricalTLV, want)
+	}
+}
+
+func TestParseATR_BadTCK(t *testing.T) {
+	raw := append([]byte{}, ceiShapedATR...)
+	raw[len(raw)-1] = 0x00
+
+	atr, err := ParseATR(raw)
+	if err != nil {
+		t.Fatalf("ParseATR() error = %v", err)
+	}
+	if atr.TCKValid {
+		t.Error("TCKValid = true for a corrupted TCK")
+	}
+	if !strings.Contains(atr.String(), "TCK invalid") {
+		t.Errorf("String() = %q, want the TCK error mentioned", atr.String())
+	}
+}
+
+func TestParseATR_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		atr     []byte
+		wantErr string
+	}{
+		{"too short", []byte{0x3B}, "too short"},
+		{"bad TS", []byte{0x3A, 0x00}, "initial character"},
+		{"truncated interface bytes", []byte{0x3B, 0xF0, 0x96}, "interface bytes"},
+		{"truncated historical bytes", []byte{0x3B, 0x8C, 0x80, 0x01}, "historical bytes"},
+		{"missing TCK", []byte{0x3B, 0x80, 0x01}, "TCK missing"},
+		{"trailing bytes", []byte{0x3B, 0x00, 0xAA}, "trailing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseATR(tt.atr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseATR(%02X) error = %v, want %q", tt.atr, err, tt.wantErr)
+			}
+		})
+	}
+}
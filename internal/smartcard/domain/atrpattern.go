@@ -0,0 +1,49 @@
+package domain
+
+// ATRPattern matches an ATR against a reference value under a bit mask
+// (the PC/SC "ATR + mask" convention). With Prefix set, only the first
+// len(ATR) bytes are compared and longer ATRs still match.
+type ATRPattern struct {
+	Name   string
+	ATR    []byte
+	Mask   []byte // Same length as ATR; nil compares every bit
+	Prefix bool
+}
+
+// Matches reports whether the ATR satisfies the pattern
+func (p ATRPattern) Matches(atr []byte) bool {
+	if len(atr) < len(p.ATR) || (!p.Prefix && len(atr) != len(p.ATR)) {
+		return false
+	}
+	for i, want := range p.ATR {
+		mask := byte(0xFF)
+		if i < len(p.Mask) {
+			mask = p.Mask[i]
+		}
+		if atr[i]&mask != want&mask {
+			return false
+		}
+	}
+	return true
+}
+
+// CEIATRPatterns is the ATR profile used to recognise Romanian CEI cards.
+// It matches the IAS-ECC ID-One Cosmo family by its interface bytes and the
+// "80 31 80 65 B0 85" historical prefix; the version bytes that follow vary
+// between chip generations and are not compared. Behind a contactless reader
+// PC/SC part 3 builds the ATR from the same historical bytes, sent in the
+// ATS. Extend it as ATRs of new card generations are collected.
+var CEIATRPatterns = []ATRPattern{
+	{
+		Name: "ID-One Cosmo (IAS-ECC)",
+		ATR: []byte{0x3B, 0xFF, 0x96, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x43,
+			0x80, 0x31, 0x80, 0x65, 0xB0, 0x85},
+		Prefix: true,
+	},
+	{
+		Name:   "ID-One Cosmo (IAS-ECC), contactless",
+		ATR:    []byte{0x3B, 0x80, 0x80, 0x01, 0x80, 0x31, 0x80, 0x65, 0xB0, 0x85},
+		Mask:   []byte{0xFF, 0xF0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		Prefix: true,
+	},
+}
@@ -1,9 +1,12 @@
 package domain
 
+import "context"
+
 // Card represents a connected smart card instance
 type Card interface {
-	// Transmit sends an APDU command and receives response
-	Transmit(apdu *APDU) (*Response, error)
+	// Transmit sends an APDU command and receives response. It gives up with
+	// an ErrTimeout TransportError when ctx is cancelled or its deadline passes.
+	Transmit(ctx context.Context, apdu *APDU) (*Response, error)
 
 	// Disconnect closes the card connection
 	Disconnect() error
@@ -12,9 +15,29 @@ type Card interface {
 	Status() (*CardStatus, error)
 }
 
+// Connector opens sessions with a card
+type Connector interface {
+	// Connect establishes a session and returns the connected card
+	Connect(ctx context.Context) (Card, error)
+}
+
 // CardStatus holds information about a connected card
 type CardStatus struct {
-	ATR            []byte // Answer To Reset
-	ActiveProtocol string // T=0, T=1, etc.
-	Reader         string // Reader name
+	ATR            []byte     // Answer To Reset
+	ActiveProtocol string     // T=0, T=1, etc.
+	Reader         string     // Reader name
+	ParsedATR      *ParsedATR // Decoded ATR, nil if it is malformed
+	Card           *KnownCard // Identified card, nil if unknown
+}
+
+// NewCardStatus creates a card status with the ATR decoded and identified
+func NewCardStatus(atr []byte, protocol string, reader string) *CardStatus {
+	parsed, _ := ParseATR(atr)
+	return &CardStatus{
+		ATR:            atr,
+		ActiveProtocol: protocol,
+		Reader:         reader,
+		ParsedATR:      parsed,
+		Card:           IdentifyCard(atr),
+	}
 }
@@ -0,0 +1,146 @@
+package domain
+
+import "fmt"
+
+// CardFamily groups cards by the document they implement
+type CardFamily int
+
+const (
+	CardFamilyUnknown     CardFamily = iota
+	CardFamilyCEI                    // Romanian electronic identity card
+	CardFamilyContactless            // ISO/IEC 14443-4 card behind a contactless reader (eMRTD passport, eID)
+	CardFamilyEUeID                  // Identity card of another EU member state
+)
+
+// String returns the family name
+func (f CardFamily) String() string {
+	switch f {
+	case CardFamilyCEI:
+		return "Romanian CEI"
+	case CardFamilyContactless:
+		return "contactless eMRTD/eID"
+	case CardFamilyEUeID:
+		return "EU eID"
+	default:
+		return "unknown"
+	}
+}
+
+// CardSupport tells whether this reader can work with a card
+type CardSupport int
+
+const (
+	SupportUnknown CardSupport = iota // Not identified well enough to decide
+	Supported
+	Unsupported
+)
+
+// KnownCard is an entry of the card identification database
+type KnownCard struct {
+	Name    string
+	Family  CardFamily
+	Support CardSupport
+	Pattern ATRPattern
+}
+
+// String returns the card name and family
+func (c *KnownCard) String() string {
+	return fmt.Sprintf("%s [%s]", c.Name, c.Family)
+}
+
+// KnownCards is the card identification database, searched in order (put
+// specific patterns before generic ones). Entries for foreign cards follow
+// the public pcsc-tools ATR list; extend it as new ATRs are collected.
+//
+// Every CEI generation issued so far (the 2021 pilot and the national issue)
+// is an IAS-ECC ID-One Cosmo whose version bytes are not compared, so one
+// entry per interface covers them. Romanian identity cards issued before the
+// CEI have no chip and never present an ATR.
+var KnownCards = []KnownCard{
+	{
+		Name:    "Romanian CEI (ID-One Cosmo, IAS-ECC)",
+		Family:  CardFamilyCEI,
+		Support: Supported,
+		Pattern: CEIATRPatterns[0],
+	},
+	{
+		Name:    "Romanian CEI, contactless interface (ID-One Cosmo, IAS-ECC)",
+		Family:  CardFamilyCEI,
+		Support: Supported,
+		Pattern: CEIATRPatterns[1],
+	},
+	{
+		Name:    "Belgian eID",
+		Family:  CardFamilyEUeID,
+		Support: Unsupported,
+		Pattern: ATRPattern{
+			Name: "Belgian eID applet",
+			ATR:  []byte{0x3B, 0x98, 0x00, 0x40, 0x00, 0xA5, 0x03, 0x01, 0x01, 0x01, 0xAD, 0x13, 0x00},
+			Mask: []byte{0xFF, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00},
+		},
+	},
+	{
+		Name:    "Estonian eID (2018)",
+		Family:  CardFamilyEUeID,
+		Support: Unsupported,
+		Pattern: ATRPattern{
+			Name: "EstEID IDEMIA",
+			ATR: []byte{0x3B, 0xDB, 0x96, 0x00, 0x80, 0xB1, 0xFE, 0x45, 0x1F, 0x83, 0x00,
+				0x12, 0x23, 0x3F, 0x53, 0x65, 0x49, 0x44, 0x0F, 0x90, 0x00, 0xF1},
+		},
+	},
+	{
+		// PC/SC part 3 synthesises "3B 8n 80 01" + ATS historical bytes (type A)
+		// or ATQB data (type B). Contactless CEI cards also look like this, so
+		// the family is known but support cannot be decided from the ATR.
+		Name:    "Contactless ISO/IEC 14443-4 card (eMRTD passport or eID)",
+		Family:  CardFamilyContactless,
+		Support: SupportUnknown,
+		Pattern: ATRPattern{
+			Name:   "PC/SC contactless",
+			ATR:    []byte{0x3B, 0x80, 0x80, 0x01},
+			Mask:   []byte{0xFF, 0xF0, 0xFF, 0xFF},
+			Prefix: true,
+		},
+	},
+}
+
+// IdentifyCard returns the first database entry matching the ATR, or nil
+func IdentifyCard(atr []byte) *KnownCard {
+	for i := range KnownCards {
+		if KnownCards[i].Pattern.Matches(atr) {
+			return &KnownCards[i]
+		}
+	}
+	return nil
+}
+
+// UnsupportedCardError reports a card refused before any APDU was sent
+type UnsupportedCardError struct {
+	ATR  []byte
+	Card *KnownCard // nil when the card was not identified
+}
+
+// Error implements the error interface
+func (e *UnsupportedCardError) Error() string {
+	if e.Card == nil {
+		return fmt.Sprintf("unsupported card: ATR %02X is not a known Romanian CEI", e.ATR)
+	}
+	return fmt.Sprintf("unsupported card: %s; insert a Romanian CEI", e.Card)
+}
+
+// CheckCardSupported refuses cards identified as unsupported and, unless
+// allowUnknown is set, cards that could not be identified
+func CheckCardSupported(status *CardStatus, allowUnknown bool) error {
+	card := status.Card
+	switch {
+	case card != nil && card.Support == Supported:
+		return nil
+	case card != nil && card.Support == Unsupported:
+		return &UnsupportedCardError{ATR: status.ATR, Card: card}
+	case allowUnknown:
+		return nil
+	default:
+		return &UnsupportedCardError{ATR: status.ATR, Card: card}
+	}
+}
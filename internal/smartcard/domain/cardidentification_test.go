@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIdentifyCard(t *testing.T) {
+	tests := []struct {
+		name       string
+		atr        []byte
+		wantFamily CardFamily
+		wantNil    bool
+	}{
+		{"CEI", ceiShapedATR, CardFamilyCEI, false},
+		{"contactless CEI", []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x31, 0x80, 0x65, 0xB0, 0x85, 0x03, 0x00, 0xEF, 0x12, 0x0F, 0xFF, 0x82, 0x90, 0x00, 0x74}, CardFamilyCEI, false},
+		{"CEI simulator is not a production card", []byte{0x3B, 0x88, 0x80, 0x01, 0x43, 0x45, 0x49, 0x2D, 0x53, 0x49, 0x4D, 0x31, 0x0D}, CardFamilyContactless, false},
+		{"Belgian eID", []byte{0x3B, 0x98, 0x13, 0x40, 0x0A, 0xA5, 0x03, 0x01, 0x01, 0x01, 0xAD, 0x13, 0x11}, CardFamilyEUeID, false},
+		{"Estonian eID", []byte{0x3B, 0xDB, 0x96, 0x00, 0x80, 0xB1, 0xFE, 0x45, 0x1F, 0x83, 0x00,
+			0x12, 0x23, 0x3F, 0x53, 0x65, 0x49, 0x44, 0x0F, 0x90, 0x00, 0xF1}, CardFamilyEUeID, false},
+		{"contactless", []byte{0x3B, 0x84, 0x80, 0x01, 0x11, 0x22, 0x33, 0x44, 0x10}, CardFamilyContactless, false},
+		{"unknown", []byte{0x3B, 0x02, 0x14, 0x50}, CardFamilyUnknown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := IdentifyCard(tt.atr)
+			if tt.wantNil {
+				if card != nil {
+					t.Errorf("IdentifyCard() = %v, want nil", card)
+				}
+				return
+			}
+			if card == nil || card.Family != tt.wantFamily {
+				t.Errorf("IdentifyCard() = %v, want family %s", card, tt.wantFamily)
+			}
+		})
+	}
+}
+
+func TestCheckCardSupported(t *testing.T) {
+	tests := []struct {
+		name         string
+		atr          []byte
+		allowUnknown bool
+		wantErr      bool
+	}{
+		{"CEI", ceiShapedATR, false, false},
+		{"foreign eID", []byte{0x3B, 0x98, 0x13, 0x40, 0x0A, 0xA5, 0x03, 0x01, 0x01, 0x01, 0xAD, 0x13, 0x11}, true, true},
+		{"unknown allowed", []byte{0x3B, 0x02, 0x14, 0x50}, true, false},
+		{"unknown refused", []byte{0x3B, 0x02, 0x14, 0x50}, false, true},
+		{"contactless allowed", []byte{0x3B, 0x84, 0x80, 0x01, 0x11, 0x22, 0x33, 0x44, 0x10}, true, false},
+		{"contactless refused", []byte{0x3B, 0x84, 0x80, 0x01, 0x11, 0x22, 0x33, 0x44, 0x10}, false, true},
+		{"contactless CEI", []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x31, 0x80, 0x65, 0xB0, 0x85, 0x03, 0x00, 0xEF, 0x12, 0x0F, 0xFF, 0x82, 0x90, 0x00, 0x74}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCardSupported(NewCardStatus(tt.atr, "T=1", "Reader"), tt.allowUnknown)
+			var unsupported *UnsupportedCardError
+			if (err != nil) != tt.wantErr || (err != nil && !errors.As(err, &unsupported)) {
+				t.Errorf("CheckCardSupported() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewCardStatus(t *testing.T) {
+	status := NewCardStatus(ceiShapedATR, "T=1", "Reader A")
+	if status.ParsedATR == nil || !status.ParsedATR.SupportsProtocol(1) {
+		t.Errorf("ParsedATR = %v, want a decoded T=1 ATR", status.ParsedATR)
+	}
+	if status.Card == nil || status.Card.Support != Supported {
+		t.Errorf("Card = %v, want a supported CEI", status.Card)
+	}
+
+	malformed := NewCardStatus([]byte{0x3B}, "T=1", "Reader A")
+	if malformed.ParsedATR != nil || malformed.Card != nil {
+		t.Error("a malformed ATR should be neither decoded nor identified")
+	}
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrChannelClosedByReset is the cause of errors on logical channels that a
+// card reset closed; they have to be opened again
+var ErrChannelClosedByReset = errors.New("the card was reset, which closes logical channels")
+
+// Logical channel numbers (ISO/IEC 7816-4 §5.4.2)
+const (
+	BasicChannel      = 0  // Always open, cannot be closed
+	MaxFirstChannel   = 3  // Highest channel of the first interindustry class (CLA b2-b1)
+	MaxLogicalChannel = 19 // Highest channel of the further interindustry class (CLA b4-b1 + 4)
+)
+
+// Secure messaging indications of a first interindustry CLA (b4-b3)
+const (
+	CLASMProprietary   byte = 0x04 // Proprietary secure messaging
+	CLASMNoHeader      byte = 0x08 // ISO secure messaging, header not processed
+	CLASMAuthenticated byte = 0x0C // ISO secure messaging, header authenticated
+)
+
+// claFurtherInterindustry marks the further interindustry class (b8-b7 = 01)
+const claFurtherInterindustry byte = 0x40
+
+// ChannelCLA returns cla addressed to a logical channel, keeping its command
+// chaining and secure messaging indications. Channels 0-3 use the first
+// interindustry class; channels 4-19 use the further interindustry class,
+// which has no room for proprietary SM or an authenticated header.
+// Proprietary classes keep b8 and can only address channels 0-3.
+func ChannelCLA(cla byte, channel int) (byte, error) {
+	if channel < BasicChannel || channel > MaxLogicalChannel {
+		return 0, fmt.Errorf("logical channel out of range: %d (0-%d)", channel, MaxLogicalChannel)
+	}
+	if cla&0x80 != 0 {
+		if channel > MaxFirstChannel {
+			return 0, fmt.Errorf("proprietary class %02X cannot address logical channel %d", cla, channel)
+		}
+		return cla&^0x03 | byte(channel), nil
+	}
+
+	chaining := cla & CLAChainingBit
+	sm := CLASecureMessaging(cla)
+	if channel <= MaxFirstChannel {
+		return chaining | sm | byte(channel), nil
+	}
+
+	further := claFurtherInterindustry | chaining | byte(channel-MaxFirstChannel-1)
+	switch sm {
+	case 0:
+		return further, nil
+	case CLASMNoHeader:
+		return further | 0x20, nil
+	default:
+		return 0, fmt.Errorf("secure messaging indication %02X cannot be encoded for logical channel %d", sm, channel)
+	}
+}
+
+// CLAChannel returns the logical channel a command is addressed to
+func CLAChannel(cla byte) int {
+	if cla&0xC0 == claFurtherInterindustry {
+		return MaxFirstChannel + 1 + int(cla&0x0F)
+	}
+	return int(cla & 0x03)
+}
+
+// CLASecureMessaging returns the secure messaging indication of an
+// interindustry CLA in the first interindustry form (CLASM* or 0 for none)
+func CLASecureMessaging(cla byte) byte {
+	if cla&0xC0 == claFurtherInterindustry {
+		if cla&0x20 != 0 {
+			return CLASMNoHeader
+		}
+		return 0
+	}
+	return cla & 0x0C
+}
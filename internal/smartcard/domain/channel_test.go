@@ -0,0 +1,48 @@
+package domain
+
+import "testing"
+
+func TestChannelCLA(t *testing.T) {
+	tests := []struct {
+		name    string
+		cla     byte
+		channel int
+		want    byte
+		wantErr bool
+	}{
+		{"basic channel", 0x00, 0, 0x00, false},
+		{"first class channel", 0x00, 2, 0x02, false},
+		{"chaining kept", 0x10, 3, 0x13, false},
+		{"authenticated SM kept", 0x0C, 1, 0x0D, false},
+		{"re-addressed", 0x0D, 2, 0x0E, false},
+		{"further class", 0x00, 4, 0x40, false},
+		{"further class with chaining", 0x10, 19, 0x5F, false},
+		{"further class SM", 0x08, 5, 0x61, false},
+		{"further class back to first", 0x61, 1, 0x09, false},
+		{"proprietary class", 0x80, 1, 0x81, false},
+		{"proprietary class beyond 3", 0x80, 4, 0, true},
+		{"authenticated SM beyond 3", 0x0C, 4, 0, true},
+		{"negative channel", 0x00, -1, 0, true},
+		{"channel out of range", 0x00, 20, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ChannelCLA(tt.cla, tt.channel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChannelCLA(%02X, %d) error = %v, wantErr %v", tt.cla, tt.channel, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ChannelCLA(%02X, %d) = %02X, want %02X", tt.cla, tt.channel, got, tt.want)
+			}
+			if !tt.wantErr && tt.cla&0x80 == 0 {
+				if ch := CLAChannel(got); ch != tt.channel {
+					t.Errorf("CLAChannel(%02X) = %d, want %d", got, ch, tt.channel)
+				}
+				if sm := CLASecureMessaging(got); sm != CLASecureMessaging(tt.cla) {
+					t.Errorf("CLASecureMessaging(%02X) = %02X, want %02X", got, sm, CLASecureMessaging(tt.cla))
+				}
+			}
+		})
+	}
+}
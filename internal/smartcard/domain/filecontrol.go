@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MasterFileID is the file identifier of the MF (ISO/IEC 7816-4 §7.1.1)
+const MasterFileID uint16 = 0x3F00
+
+// Templates and tags of the file control parameters (ISO/IEC 7816-4 §7.4)
+const (
+	TagFCP               uint32 = 0x62 // File control parameters template
+	TagFCI               uint32 = 0x6F // File control information template
+	TagFileSize          uint32 = 0x80 // Data bytes in a transparent EF
+	TagTotalFileSize     uint32 = 0x81 // Bytes allocated, structural information included
+	TagFileDescriptor    uint32 = 0x82
+	TagFileIdentifier    uint32 = 0x83
+	TagDFName            uint32 = 0x84
+	TagShortEFIdentifier uint32 = 0x88
+	TagLifeCycleStatus   uint32 = 0x8A
+)
+
+// SelectResponse is what SELECT returns, encoded in P2 (ISO/IEC 7816-4
+// §11.2.2, table 63)
+type SelectResponse byte
+
+const (
+	SelectReturnFCI  SelectResponse = 0x00 // File control information (tag 6F)
+	SelectReturnFCP  SelectResponse = 0x04 // File control parameters (tag 62)
+	SelectReturnNone SelectResponse = 0x0C // No response data
+)
+
+// FileControlParameters is the decoded FCP, or the FCP part of an FCI,
+// returned by SELECT
+type FileControlParameters struct {
+	Size       int    // Data bytes of a transparent EF, -1 when not given
+	TotalSize  int    // Bytes allocated to the file, -1 when not given
+	Descriptor byte   // File descriptor byte (0x38 for a DF, 0x01 for a transparent EF)
+	FID        uint16 // File identifier, 0 when not given
+	DFName     []byte // DF name (AID of an application)
+	SFI        byte   // Short EF identifier, 0 when not given
+	LifeCycle  byte   // Life cycle status byte, 0 when not given
+	Raw        []byte // Encoded template
+}
+
+// ParseFileControlParameters decodes an FCP (62) or FCI (6F) template. An
+// FCI may carry the parameters directly or in a nested FCP.
+func ParseFileControlParameters(data []byte) (*FileControlParameters, error) {
+	objects, err := ParseBERTLV(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file control parameters: %w", err)
+	}
+	template, ok := FindTLV(objects, TagFCP)
+	if !ok {
+		if template, ok = FindTLV(objects, TagFCI); !ok {
+			return nil, fmt.Errorf("invalid file control parameters: no FCP or FCI template")
+		}
+	}
+	children, err := template.Children()
+	if err != nil {
+		return nil, fmt.Errorf("invalid file control parameters: %w", err)
+	}
+	if nested, ok := FindTLV(children, TagFCP); ok && template.Tag == TagFCI {
+		nestedChildren, err := nested.Children()
+		if err != nil {
+			return nil, fmt.Errorf("invalid file control parameters: %w", err)
+		}
+		children = append(nestedChildren, children...)
+	}
+
+	fcp := &FileControlParameters{Size: -1, TotalSize: -1, Raw: data}
+	for _, child := range children {
+		switch child.Tag {
+		case TagFileSize:
+			fcp.Size, err = parseFileSize(child)
+		case TagTotalFileSize:
+			fcp.TotalSize, err = parseFileSize(child)
+		case TagFileDescriptor:
+			if len(child.Value) > 0 {
+				fcp.Descriptor = child.Value[0]
+			}
+		case TagFileIdentifier:
+			if len(child.Value) != 2 {
+				err = fmt.Errorf("file identifier of %d bytes", len(child.Value))
+			} else {
+				fcp.FID = uint16(child.Value[0])<<8 | uint16(child.Value[1])
+			}
+		case TagDFName:
+			fcp.DFName = child.Value
+		case TagShortEFIdentifier:
+			if len(child.Value) > 0 {
+				fcp.SFI = child.Value[0] >> 3 // b8-b4
+			}
+		case TagLifeCycleStatus:
+			if len(child.Value) > 0 {
+				fcp.LifeCycle = child.Value[0]
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid file control parameters: %w", err)
+		}
+	}
+	return fcp, nil
+}
+
+// parseFileSize decodes a big-endian size of up to four bytes
+func parseFileSize(object TLV) (int, error) {
+	if len(object.Value) == 0 || len(object.Value) > 4 {
+		return 0, fmt.Errorf("file size of %d bytes", len(object.Value))
+	}
+	size := 0
+	for _, b := range object.Value {
+		size = size<<8 | int(b)
+	}
+	return size, nil
+}
+
+// IsDF reports whether the parameters describe a DF
+func (f *FileControlParameters) IsDF() bool {
+	return f.Descriptor&0xBF == 0x38
+}
+
+// IsTransparent reports whether the parameters describe a transparent EF,
+// which is read with READ BINARY
+func (f *FileControlParameters) IsTransparent() bool {
+	return !f.IsDF() && f.Descriptor&0x07 == 0x01
+}
+
+// FilePath is a path of file identifiers (ISO/IEC 7816-4 §7.1.2): absolute
+// paths start at the MF, whose identifier they do not include
+type FilePath struct {
+	FromMF bool
+	FIDs   []uint16
+}
+
+// ParseFilePath parses file identifiers in hex separated by slashes. A
+// leading "3F00" makes the path absolute, e.g. "3F00/DF01/0101"; "DF01/0101"
+// starts at the current DF.
+func ParseFilePath(s string) (FilePath, error) {
+	var path FilePath
+	for i, part := range strings.Split(s, "/") {
+		fid, err := strconv.ParseUint(part, 16, 16)
+		if err != nil || len(part) != 4 {
+			return FilePath{}, fmt.Errorf("invalid file identifier %q in path %q", part, s)
+		}
+		if i == 0 && uint16(fid) == MasterFileID {
+			path.FromMF = true
+			continue
+		}
+		path.FIDs = append(path.FIDs, uint16(fid))
+	}
+	return path, nil
+}
+
+// Bytes returns the concatenated file identifiers, as sent by SELECT
+func (p FilePath) Bytes() []byte {
+	data := make([]byte, 0, 2*len(p.FIDs))
+	for _, fid := range p.FIDs {
+		data = append(data, byte(fid>>8), byte(fid))
+	}
+	return data
+}
+
+// String implements fmt.Stringer
+func (p FilePath) String() string {
+	var parts []string
+	if p.FromMF {
+		parts = append(parts, fmt.Sprintf("%04X", MasterFileID))
+	}
+	for _, fid := range p.FIDs {
+		parts = append(parts, fmt.Sprintf("%04X", fid))
+	}
+	return strings.Join(parts, "/")
+}
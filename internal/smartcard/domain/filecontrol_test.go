@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseFileControlParameters(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		size        int
+		fid         uint16
+		sfi         byte
+		df          bool
+		transparent bool
+		dfName      []byte
+	}{
+		{
+			name:        "EF FCP",
+			data:        []byte{0x62, 0x11, 0x80, 0x02, 0x05, 0x0D, 0x82, 0x01, 0x01, 0x83, 0x02, 0x01, 0x1C, 0x88, 0x01, 0xE0, 0x8A, 0x01, 0x05},
+			size:        1293,
+			fid:         0x011C,
+			sfi:         28,
+			transparent: true,
+		},
+		{
+			name:   "DF FCI",
+			data:   []byte{0x6F, 0x0B, 0x82, 0x01, 0x38, 0x84, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01},
+			size:   -1,
+			df:     true,
+			dfName: []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01},
+		},
+		{
+			name:        "FCI with nested FCP",
+			data:        []byte{0x6F, 0x0A, 0x62, 0x06, 0x80, 0x01, 0x20, 0x82, 0x01, 0x01, 0x85, 0x00},
+			size:        0x20,
+			transparent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fcp, err := ParseFileControlParameters(tt.data)
+			if err != nil {
+				t.Fatalf("ParseFileControlParameters() error = %v", err)
+			}
+			if fcp.Size != tt.size || fcp.FID != tt.fid || fcp.SFI != tt.sfi {
+				t.Errorf("Size, FID, SFI = %d, %04X, %d, want %d, %04X, %d", fcp.Size, fcp.FID, fcp.SFI, tt.size, tt.fid, tt.sfi)
+			}
+			if fcp.IsDF() != tt.df || fcp.IsTransparent() != tt.transparent {
+				t.Errorf("IsDF() = %v, IsTransparent() = %v, want %v, %v", fcp.IsDF(), fcp.IsTransparent(), tt.df, tt.transparent)
+			}
+			if !bytes.Equal(fcp.DFName, tt.dfName) {
+				t.Errorf("DFName = %02X, want %02X", fcp.DFName, tt.dfName)
+			}
+		})
+	}
+}
+
+func TestParseFileControlParameters_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no template", []byte{0x80, 0x01, 0x20}},
+		{"truncated", []byte{0x62, 0x05, 0x80, 0x02}},
+		{"short file identifier", []byte{0x62, 0x03, 0x83, 0x01, 0x01}},
+		{"oversized file size", []byte{0x62, 0x07, 0x80, 0x05, 0x00, 0x00, 0x00, 0x00, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFileControlParameters(tt.data); err == nil {
+				t.Error("ParseFileControlParameters() expected error")
+			}
+		})
+	}
+}
+
+func TestParseFilePath(t *testing.T) {
+	tests := []struct {
+		input  string
+		fromMF bool
+		bytes  []byte
+	}{
+		{"3F00/011C", true, []byte{0x01, 0x1C}},
+		{"3F00", true, []byte{}},
+		{"DF01/0101", false, []byte{0xDF, 0x01, 0x01, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			path, err := ParseFilePath(tt.input)
+			if err != nil {
+				t.Fatalf("ParseFilePath() error = %v", err)
+			}
+			if path.FromMF != tt.fromMF || !bytes.Equal(path.Bytes(), tt.bytes) {
+				t.Errorf("ParseFilePath() = %+v, want FromMF %v and %02X", path, tt.fromMF, tt.bytes)
+			}
+			if path.String() != tt.input {
+				t.Errorf("String() = %q, want %q", path.String(), tt.input)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "3F0", "3F00//0101", "XY01"} {
+		if _, err := ParseFilePath(invalid); err == nil {
+			t.Errorf("ParseFilePath(%q) expected error", invalid)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "errors"
+
+// CEI PIN references (P2 of VERIFY, CHANGE REFERENCE DATA and RESET RETRY COUNTER)
+const (
+	PIN1Reference byte = 0x01 // Authentication PIN, 4 digits
+	PIN2Reference byte = 0x02 // Signature PIN, 6 digits
+)
+
+// ErrLastPINTry is returned instead of spending the last try of a PIN
+var ErrLastPINTry = errors.New("only one PIN try left")
+
+// PINStatus is the state of a PIN reported by a VERIFY without command data
+// (ISO/IEC 7816-4 §11.5.6), which does not spend a try
+type PINStatus struct {
+	Reference   byte
+	Verified    bool // Already verified on this channel; RetriesLeft is then unknown
+	RetriesLeft int
+}
+
+// NewPINStatus interprets the response to a retry counter query: 9000 for a
+// verified PIN, 63Cx for x retries left and 6983 for a blocked PIN. Any other
+// status word is returned as an error.
+func NewPINStatus(apdu *APDU, resp *Response) (*PINStatus, error) {
+	status := &PINStatus{Reference: apdu.P2}
+	switch code := resp.StatusCode(); {
+	case code == StatusSuccess:
+		status.Verified = true
+	case code&0xFFF0 == StatusPINRetryMask:
+		status.RetriesLeft = int(code & 0x000F)
+	case code == StatusIncorrectPIN:
+		status.RetriesLeft = 0
+	default:
+		return nil, NewCommandError(apdu, resp)
+	}
+	return status, nil
+}
+
+// Blocked reports whether the PIN must be unblocked before it can be verified
+func (s *PINStatus) Blocked() bool {
+	return !s.Verified && s.RetriesLeft == 0
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPIN is wrapped by PINFormat errors; their message is meant for
+// the user
+var ErrInvalidPIN = errors.New("invalid PIN")
+
+// PINEncoding is how PIN digits are laid out in the command data
+type PINEncoding int
+
+const (
+	PINEncodingASCII   PINEncoding = iota // One character per byte
+	PINEncodingBCD                        // Two digits per byte, left justified
+	PINEncodingFormat2                    // ISO 9564-1 format 2 block: 2L, digits, F fill (8 bytes)
+)
+
+// format2BlockLength is the size of an ISO 9564-1 format 2 PIN block
+const format2BlockLength = 8
+
+// Digits is the charset of numeric PINs
+const Digits = "0123456789"
+
+// String implements fmt.Stringer
+func (e PINEncoding) String() string {
+	switch e {
+	case PINEncodingASCII:
+		return "ASCII"
+	case PINEncodingBCD:
+		return "BCD"
+	case PINEncodingFormat2:
+		return "ISO 9564 format 2"
+	default:
+		return fmt.Sprintf("PINEncoding(%d)", int(e))
+	}
+}
+
+// PINFormat describes what a card accepts as a PIN and how it is encoded in
+// VERIFY, CHANGE REFERENCE DATA and RESET RETRY COUNTER, and what a PIN pad
+// must be told to build the same block
+type PINFormat struct {
+	Name      string      // Shown in errors (e.g. "PIN1")
+	Encoding  PINEncoding // Layout of the digits
+	MinLength int         // Minimum number of characters
+	MaxLength int         // Maximum number of characters
+	Charset   string      // Allowed characters, empty for any
+	BlockSize int         // Padded length in bytes, 0 for no padding (ignored by format 2)
+	PadByte   byte        // Fills the block up to BlockSize
+}
+
+// CEI PIN profiles: the PINs are sent as ASCII digits without padding
+var (
+	CEIPIN1Format = PINFormat{Name: "PIN1", Encoding: PINEncodingASCII, MinLength: 4, MaxLength: 4, Charset: Digits}
+	CEIPIN2Format = PINFormat{Name: "PIN2", Encoding: PINEncodingASCII, MinLength: 6, MaxLength: 6, Charset: Digits}
+)
+
+// GenericPINFormat accepts the 4 to 12 digit PINs of ISO 9564-1 as ASCII; it
+// is used for references without a profile
+var GenericPINFormat = PINFormat{Name: "PIN", Encoding: PINEncodingASCII, MinLength: 4, MaxLength: 12, Charset: Digits}
+
+// CEIPINFormats returns the CEI profiles by PIN reference
+func CEIPINFormats() map[byte]PINFormat {
+	return map[byte]PINFormat{
+		PIN1Reference: CEIPIN1Format,
+		PIN2Reference: CEIPIN2Format,
+	}
+}
+
+// Validate checks the length and characters of a PIN before it is sent
+func (f PINFormat) Validate(pin []byte) error {
+	name := f.Name
+	if name == "" {
+		name = "PIN"
+	}
+
+	if len(pin) < f.MinLength || (f.MaxLength > 0 && len(pin) > f.MaxLength) {
+		switch {
+		case f.MinLength == f.MaxLength:
+			return fmt.Errorf("%w: %s must have %d %s", ErrInvalidPIN, name, f.MinLength, f.unit())
+		case f.MaxLength == 0:
+			return fmt.Errorf("%w: %s must have at least %d %s", ErrInvalidPIN, name, f.MinLength, f.unit())
+		default:
+			return fmt.Errorf("%w: %s must have %d to %d %s", ErrInvalidPIN, name, f.MinLength, f.MaxLength, f.unit())
+		}
+	}
+
+	for _, c := range pin {
+		if f.Charset != "" && strings.IndexByte(f.Charset, c) < 0 {
+			if f.Charset == Digits {
+				return fmt.Errorf("%w: %s must contain only digits", ErrInvalidPIN, name)
+			}
+			return fmt.Errorf("%w: %s contains a character outside %q", ErrInvalidPIN, name, f.Charset)
+		}
+		if f.Encoding != PINEncodingASCII && (c < '0' || c > '9') {
+			return fmt.Errorf("%w: %s must contain only digits for %s encoding", ErrInvalidPIN, name, f.Encoding)
+		}
+	}
+
+	if f.Encoding == PINEncodingFormat2 && len(pin) > 2*(format2BlockLength-1) {
+		return fmt.Errorf("%w: %s too long for %s", ErrInvalidPIN, name, f.Encoding)
+	}
+	if f.BlockSize > 0 && f.Encoding != PINEncodingFormat2 && f.encodedLength(len(pin)) > f.BlockSize {
+		return fmt.Errorf("%w: %s does not fit in %d bytes", ErrInvalidPIN, name, f.BlockSize)
+	}
+	return nil
+}
+
+// Encode validates a PIN and returns it as command data. The block is
+// allocated once at its final size and the PIN is written into it in place,
+// so clearing the result wipes every copy of the PIN.
+func (f PINFormat) Encode(pin []byte) ([]byte, error) {
+	if err := f.Validate(pin); err != nil {
+		return nil, err
+	}
+
+	if f.Encoding == PINEncodingFormat2 {
+		// Control field 2 and PIN length, then the digits and F fill
+		block := make([]byte, format2BlockLength)
+		block[0] = 0x20 | byte(len(pin))
+		n := 1 + packDigits(block[1:], pin, 0x0F)
+		for i := n; i < len(block); i++ {
+			block[i] = 0xFF
+		}
+		return block, nil
+	}
+
+	block := make([]byte, max(f.encodedLength(len(pin)), f.BlockSize))
+	var n int
+	if f.Encoding == PINEncodingBCD {
+		n = packDigits(block, pin, f.PadByte&0x0F)
+	} else {
+		n = copy(block, pin)
+	}
+	for i := n; i < len(block); i++ {
+		block[i] = f.PadByte
+	}
+	return block, nil
+}
+
+// encodedLength returns the unpadded encoded size of a PIN of n characters
+func (f PINFormat) encodedLength(n int) int {
+	if f.Encoding == PINEncodingBCD {
+		return (n + 1) / 2
+	}
+	return n
+}
+
+// unit names what the length of the PIN counts
+func (f PINFormat) unit() string {
+	if f.Charset == Digits {
+		return "digits"
+	}
+	return "characters"
+}
+
+// packDigits packs ASCII digits two per byte into dst, filling an odd last
+// nibble, and returns the number of bytes written
+func packDigits(dst, digits []byte, fill byte) int {
+	n := 0
+	for i := 0; i < len(digits); i += 2 {
+		high := (digits[i] - '0') & 0x0F
+		low := fill
+		if i+1 < len(digits) {
+			low = (digits[i+1] - '0') & 0x0F
+		}
+		dst[n] = high<<4 | low
+		n++
+	}
+	return n
+}
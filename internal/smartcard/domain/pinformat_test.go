@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPINFormat_Encode(t *testing.T) {
+	tests := []struct {
+		name   string
+		format PINFormat
+		pin    string
+		want   []byte
+	}{
+		{"CEI PIN1", CEIPIN1Format, "1234", []byte("1234")},
+		{"CEI PIN2", CEIPIN2Format, "123456", []byte("123456")},
+		{
+			name:   "ASCII padded",
+			format: PINFormat{Encoding: PINEncodingASCII, MinLength: 4, MaxLength: 8, BlockSize: 8, PadByte: 0xFF},
+			pin:    "1234",
+			want:   []byte{0x31, 0x32, 0x33, 0x34, 0xFF, 0xFF, 0xFF, 0xFF},
+		},
+		{
+			name:   "BCD odd length",
+			format: PINFormat{Encoding: PINEncodingBCD, MinLength: 4, MaxLength: 8, BlockSize: 4, PadByte: 0xFF},
+			pin:    "12345",
+			want:   []byte{0x12, 0x34, 0x5F, 0xFF},
+		},
+		{
+			name:   "ISO 9564 format 2",
+			format: PINFormat{Encoding: PINEncodingFormat2, MinLength: 4, MaxLength: 12},
+			pin:    "123456",
+			want:   []byte{0x26, 0x12, 0x34, 0x56, 0xFF, 0xFF, 0xFF, 0xFF},
+		},
+		{
+			name:   "ISO 9564 format 2 odd length",
+			format: PINFormat{Encoding: PINEncodingFormat2, MinLength: 4, MaxLength: 12},
+			pin:    "12345",
+			want:   []byte{0x25, 0x12, 0x34, 0x5F, 0xFF, 0xFF, 0xFF, 0xFF},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.format.Encode([]byte(tt.pin))
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Encode() = %X, want %X", got, tt.want)
+			}
+			// Clearing the block must wipe the only copy of the PIN
+			if cap(got) != len(got) {
+				t.Errorf("Encode() capacity = %d, want the block allocated at its length %d", cap(got), len(got))
+			}
+		})
+	}
+}
+
+func TestPINFormat_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		format PINFormat
+		pin    string
+		want   string
+	}{
+		{"PIN1 too short", CEIPIN1Format, "123", "invalid PIN: PIN1 must have 4 digits"},
+		{"PIN1 too long", CEIPIN1Format, "12345", "invalid PIN: PIN1 must have 4 digits"},
+		{"PIN2 letters", CEIPIN2Format, "12345a", "invalid PIN: PIN2 must contain only digits"},
+		{"generic range", GenericPINFormat, "", "invalid PIN: PIN must have 4 to 12 digits"},
+		{
+			name:   "charset",
+			format: PINFormat{Name: "PIN", MinLength: 1, Charset: "ABC"},
+			pin:    "AD",
+			want:   `invalid PIN: PIN contains a character outside "ABC"`,
+		},
+		{
+			name:   "BCD needs digits",
+			format: PINFormat{Name: "PIN", Encoding: PINEncodingBCD, MinLength: 1, MaxLength: 8},
+			pin:    "12A4",
+			want:   "invalid PIN: PIN must contain only digits for BCD encoding",
+		},
+		{
+			name:   "block too small",
+			format: PINFormat{Name: "PIN", MinLength: 1, MaxLength: 12, BlockSize: 4},
+			pin:    "123456",
+			want:   "invalid PIN: PIN does not fit in 4 bytes",
+		},
+		{"valid", CEIPIN2Format, "123456", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.format.Validate([]byte(tt.pin))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidPIN) || err.Error() != tt.want {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package domain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrPINPadUnsupported is returned when a PIN format cannot be described
+	// to a PIN pad; the PIN is then entered on the host
+	ErrPINPadUnsupported = errors.New("PIN format not supported by the PIN pad")
+
+	// ErrPINPadTimeout is returned when nothing was entered on the PIN pad in time
+	ErrPINPadTimeout = errors.New("PIN pad entry timed out")
+
+	// ErrPINPadEntryRequired is returned when a PIN is passed from the host
+	// while it is to be typed on the reader's PIN pad
+	ErrPINPadEntryRequired = errors.New("the PIN must be typed on the reader's PIN pad")
+)
+
+// PIN pad entry parameters (PC/SC part 10, PIN_VERIFY_STRUCTURE)
+const (
+	pinPadUnitsBytes      = 0x80   // bmFormatString: PIN position counted in bytes
+	pinPadFormatBCD       = 0x01   // bmFormatString: two digits per byte
+	pinPadFormatASCII     = 0x02   // bmFormatString: one character per byte
+	pinPadValidationKey   = 0x02   // bEntryValidationCondition: OK key pressed
+	pinPadConfirmAndOld   = 0x03   // bConfirmPIN: enter the current PIN, confirm the new one
+	pinPadLanguageEnglish = 0x0409 // wLangId of the reader's messages
+)
+
+// pinPadBlock is how a PIN format is laid out for a PIN pad: the block of
+// the command data the reader fills and the bmFormatString,
+// bmPINBlockString and bmPINLengthFormat that describe it
+type pinPadBlock struct {
+	template     []byte
+	formatString byte
+	blockString  byte
+	lengthFormat byte
+}
+
+// newPINPadBlock describes f for a PIN pad. A block of 0 bytes means that the
+// reader sends the PIN with its entered length.
+func newPINPadBlock(f PINFormat) (pinPadBlock, error) {
+	if f.Charset != Digits || f.MinLength == 0 || f.MaxLength == 0 || f.MaxLength > 0xFF {
+		return pinPadBlock{}, ErrPINPadUnsupported
+	}
+
+	switch f.Encoding {
+	case PINEncodingFormat2:
+		// Digits as BCD from the second byte (8 bits in), length in the low
+		// nibble of the first byte
+		template := []byte{0x20}
+		for len(template) < format2BlockLength {
+			template = append(template, 0xFF)
+		}
+		return pinPadBlock{
+			template:     template,
+			formatString: 8<<3 | pinPadFormatBCD,
+			blockString:  4<<4 | format2BlockLength,
+			lengthFormat: 4,
+		}, nil
+	case PINEncodingBCD:
+		size := f.BlockSize
+		if size == 0 && f.MinLength == f.MaxLength {
+			size = f.encodedLength(f.MaxLength)
+		}
+		if size == 0 {
+			return pinPadBlock{}, ErrPINPadUnsupported
+		}
+		return pinPadBlock{
+			template:     padding(size, f.PadByte),
+			formatString: pinPadUnitsBytes | pinPadFormatBCD,
+			blockString:  byte(size),
+		}, nil
+	default:
+		size := f.BlockSize
+		if size == 0 && f.MinLength == f.MaxLength {
+			size = f.MaxLength
+		}
+		return pinPadBlock{
+			template:     padding(size, f.PadByte),
+			formatString: pinPadUnitsBytes | pinPadFormatASCII,
+			blockString:  byte(size),
+		}, nil
+	}
+}
+
+// NewVerifyPINStructure builds the input of FEATURE_VERIFY_PIN_DIRECT for the
+// VERIFY command apdu (its data is ignored): the reader collects the PIN on
+// its keypad and sends the command with the PIN inserted
+func NewVerifyPINStructure(apdu *APDU, f PINFormat) ([]byte, error) {
+	block, err := newPINPadBlock(f)
+	if err != nil {
+		return nil, err
+	}
+
+	structure := []byte{
+		0x00, // bTimerOut: reader default
+		0x00, // bTimerOut2: reader default
+		block.formatString,
+		block.blockString,
+		block.lengthFormat,
+		byte(f.MaxLength), byte(f.MinLength), // wPINMaxExtraDigit
+		pinPadValidationKey,
+		0x01, // bNumberMessage
+	}
+	structure = binary.LittleEndian.AppendUint16(structure, pinPadLanguageEnglish)
+	structure = append(structure,
+		0x00,             // bMsgIndex
+		0x00, 0x00, 0x00, // bTeoPrologue
+	)
+	return appendPINPadAPDU(structure, apdu, block.template), nil
+}
+
+// NewModifyPINStructure builds the input of FEATURE_MODIFY_PIN_DIRECT for the
+// CHANGE REFERENCE DATA command apdu: the reader asks for the current PIN and
+// twice for the new one, and sends both in blocks of the same format. PINs
+// without a fixed block size cannot be changed this way.
+func NewModifyPINStructure(apdu *APDU, f PINFormat) ([]byte, error) {
+	block, err := newPINPadBlock(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.template) == 0 {
+		return nil, ErrPINPadUnsupported
+	}
+
+	structure := []byte{
+		0x00, // bTimerOut: reader default
+		0x00, // bTimerOut2: reader default
+		block.formatString,
+		block.blockString,
+		block.lengthFormat,
+		0x00,                                 // bInsertionOffsetOld
+		byte(len(block.template)),            // bInsertionOffsetNew
+		byte(f.MaxLength), byte(f.MinLength), // wPINMaxExtraDigit
+		pinPadConfirmAndOld,
+		pinPadValidationKey,
+		0x03, // bNumberMessage: current PIN, new PIN, confirmation
+	}
+	structure = binary.LittleEndian.AppendUint16(structure, pinPadLanguageEnglish)
+	structure = append(structure,
+		0x00, 0x01, 0x02, // bMsgIndex1, bMsgIndex2, bMsgIndex3
+		0x00, 0x00, 0x00, // bTeoPrologue
+	)
+	template := append(append([]byte{}, block.template...), block.template...)
+	return appendPINPadAPDU(structure, apdu, template), nil
+}
+
+// appendPINPadAPDU appends ulDataLength and abData: the command header, Lc
+// and the block template
+func appendPINPadAPDU(structure []byte, apdu *APDU, template []byte) []byte {
+	command := append([]byte{apdu.CLA, apdu.INS, apdu.P1, apdu.P2, byte(len(template))}, template...)
+	structure = binary.LittleEndian.AppendUint32(structure, uint32(len(command)))
+	return append(structure, command...)
+}
+
+// NewPINPadError converts the result of a PIN pad entry. The reader reports
+// entry problems with its own status words (64xx, 6B80); anything else comes
+// from the card and is converted by NewCommandError.
+func NewPINPadError(apdu *APDU, resp *Response) error {
+	switch resp.StatusCode() {
+	case 0x9000:
+		return nil
+	case 0x6400:
+		return ErrPINPadTimeout
+	case 0x6401:
+		return ErrPINEntryCancelled
+	case 0x6402:
+		return ErrNewPINMismatch
+	case 0x6403:
+		return fmt.Errorf("%w: the PIN pad rejected the PIN length", ErrInvalidPIN)
+	case 0x6B80:
+		return fmt.Errorf("%w: the reader rejected the entry parameters", ErrPINPadUnsupported)
+	}
+	return NewCommandError(apdu, resp)
+}
+
+// padding returns n copies of b
+func padding(n int, b byte) []byte {
+	block := make([]byte, n)
+	for i := range block {
+		block[i] = b
+	}
+	return block
+}
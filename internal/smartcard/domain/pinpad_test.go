@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewVerifyPINStructure(t *testing.T) {
+	tests := []struct {
+		name   string
+		format PINFormat
+		pinRef byte
+		want   []byte
+	}{
+		{
+			name:   "CEI PIN1",
+			format: CEIPIN1Format,
+			pinRef: PIN1Reference,
+			want: []byte{
+				0x00, 0x00, // Timeouts
+				0x82, 0x04, 0x00, // ASCII in bytes from 0, 4-byte block, no length field
+				0x04, 0x04, // 4 to 4 digits
+				0x02, 0x01, 0x09, 0x04, 0x00, 0x00, 0x00, 0x00,
+				0x09, 0x00, 0x00, 0x00, // 9-byte command
+				0x00, 0x20, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			name:   "variable length ASCII",
+			format: GenericPINFormat,
+			pinRef: 0x81,
+			want: []byte{
+				0x00, 0x00,
+				0x82, 0x00, 0x00, // Block sent with the entered length
+				0x0C, 0x04,
+				0x02, 0x01, 0x09, 0x04, 0x00, 0x00, 0x00, 0x00,
+				0x05, 0x00, 0x00, 0x00,
+				0x00, 0x20, 0x00, 0x81, 0x00,
+			},
+		},
+		{
+			name:   "ISO 9564 format 2",
+			format: PINFormat{Encoding: PINEncodingFormat2, MinLength: 4, MaxLength: 12, Charset: Digits},
+			pinRef: 0x81,
+			want: []byte{
+				0x00, 0x00,
+				0x41, 0x48, 0x04, // BCD from bit 8, 4-bit length field in an 8-byte block, length at bit 4
+				0x0C, 0x04,
+				0x02, 0x01, 0x09, 0x04, 0x00, 0x00, 0x00, 0x00,
+				0x0D, 0x00, 0x00, 0x00,
+				0x00, 0x20, 0x00, 0x81, 0x08, 0x20, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apdu := &APDU{CLA: 0x00, INS: 0x20, P1: 0x00, P2: tt.pinRef}
+			got, err := NewVerifyPINStructure(apdu, tt.format)
+			if err != nil {
+				t.Fatalf("NewVerifyPINStructure() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("NewVerifyPINStructure() = %02X, want %02X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewModifyPINStructure(t *testing.T) {
+	apdu := &APDU{CLA: 0x00, INS: 0x24, P1: 0x00, P2: PIN2Reference}
+	got, err := NewModifyPINStructure(apdu, CEIPIN2Format)
+	if err != nil {
+		t.Fatalf("NewModifyPINStructure() error = %v", err)
+	}
+	want := []byte{
+		0x00, 0x00,
+		0x82, 0x06, 0x00,
+		0x00, 0x06, // Current PIN at 0, new PIN at 6
+		0x06, 0x06,
+		0x03, 0x02, 0x03, 0x09, 0x04, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00,
+		0x11, 0x00, 0x00, 0x00,
+		0x00, 0x24, 0x00, 0x02, 0x0C, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("NewModifyPINStructure() = %02X, want %02X", got, want)
+	}
+}
+
+func TestNewPINPadStructure_Unsupported(t *testing.T) {
+	apdu := &APDU{CLA: 0x00, INS: 0x20, P1: 0x00, P2: 0x01}
+	alphanumeric := PINFormat{Encoding: PINEncodingASCII, MinLength: 4, MaxLength: 8}
+	if _, err := NewVerifyPINStructure(apdu, alphanumeric); !errors.Is(err, ErrPINPadUnsupported) {
+		t.Errorf("NewVerifyPINStructure() error = %v, want ErrPINPadUnsupported", err)
+	}
+	if _, err := NewModifyPINStructure(apdu, GenericPINFormat); !errors.Is(err, ErrPINPadUnsupported) {
+		t.Errorf("NewModifyPINStructure() of a variable length PIN error = %v, want ErrPINPadUnsupported", err)
+	}
+}
+
+func TestNewPINPadError(t *testing.T) {
+	apdu := &APDU{CLA: 0x00, INS: 0x20, P1: 0x00, P2: 0x01}
+	tests := []struct {
+		sw   uint16
+		want error
+	}{
+		{0x6400, ErrPINPadTimeout},
+		{0x6401, ErrPINEntryCancelled},
+		{0x6402, ErrNewPINMismatch},
+		{0x6403, ErrInvalidPIN},
+		{0x6B80, ErrPINPadUnsupported},
+		{0x63C2, ErrVerificationFailed},
+		{0x6983, ErrAuthenticationMethodBlocked},
+	}
+
+	for _, tt := range tests {
+		resp := NewResponse([]byte{byte(tt.sw >> 8), byte(tt.sw)})
+		if err := NewPINPadError(apdu, resp); !errors.Is(err, tt.want) {
+			t.Errorf("NewPINPadError(%04X) = %v, want %v", tt.sw, err, tt.want)
+		}
+	}
+	if err := NewPINPadError(apdu, NewResponse([]byte{0x90, 0x00})); err != nil {
+		t.Errorf("NewPINPadError(9000) = %v, want nil", err)
+	}
+
+	var pinErr *PINError
+	err := NewPINPadError(apdu, NewResponse([]byte{0x63, 0xC2}))
+	if !errors.As(err, &pinErr) || pinErr.RetriesLeft != 2 {
+		t.Errorf("NewPINPadError(63C2) = %v, want a PINError with 2 retries left", err)
+	}
+}
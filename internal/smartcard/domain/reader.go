@@ -7,7 +7,23 @@ type Reader interface {
 
 	// IsConnected checks if a card is present
 	IsConnected() bool
+
+	// ATR returns the Answer To Reset of the present card (nil when empty)
+	ATR() []byte
+
+	// Features returns the PC/SC part 10 features of the reader (PIN pad,
+	// PACE), empty when unknown
+	Features() ReaderFeatures
 }
 
 // ReaderList holds multiple readers
 type ReaderList []Reader
+
+// Names returns the reader names in order
+func (l ReaderList) Names() []string {
+	names := make([]string, len(l))
+	for i, r := range l {
+		names[i] = r.Name()
+	}
+	return names
+}
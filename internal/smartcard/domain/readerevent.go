@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReaderEventType identifies a reader or card state transition
+type ReaderEventType int
+
+const (
+	EventReaderAttached ReaderEventType = iota + 1
+	EventReaderDetached
+	EventCardInserted
+	EventCardRemoved
+	EventCardMuted // Card present but not answering to reset
+)
+
+// String returns a human-readable event name
+func (t ReaderEventType) String() string {
+	switch t {
+	case EventReaderAttached:
+		return "reader attached"
+	case EventReaderDetached:
+		return "reader detached"
+	case EventCardInserted:
+		return "card inserted"
+	case EventCardRemoved:
+		return "card removed"
+	case EventCardMuted:
+		return "card muted"
+	default:
+		return fmt.Sprintf("ReaderEventType(%d)", int(t))
+	}
+}
+
+// ReaderEvent is emitted by a card monitor when a reader or card changes state
+type ReaderEvent struct {
+	Type   ReaderEventType
+	Reader string
+	ATR    []byte // Set for EventCardInserted
+	Time   time.Time
+}
+
+// String returns a one-line description of the event
+func (e ReaderEvent) String() string {
+	if e.Type == EventCardInserted {
+		return fmt.Sprintf("%s: %s (ATR: %02X)", e.Reader, e.Type, e.ATR)
+	}
+	return fmt.Sprintf("%s: %s", e.Reader, e.Type)
+}
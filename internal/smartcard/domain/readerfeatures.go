@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// FeatureRequestFunction is the SCardControl function number of
+// GET_FEATURE_REQUEST (PC/SC part 10); the control code is derived from it
+// per platform (SCARD_CTL_CODE)
+const FeatureRequestFunction = 3400
+
+// ReaderFeature is a PC/SC part 10 feature tag returned by GET_FEATURE_REQUEST
+type ReaderFeature byte
+
+const (
+	FeatureVerifyPINStart       ReaderFeature = 0x01
+	FeatureVerifyPINFinish      ReaderFeature = 0x02
+	FeatureModifyPINStart       ReaderFeature = 0x03
+	FeatureModifyPINFinish      ReaderFeature = 0x04
+	FeatureGetKeyPressed        ReaderFeature = 0x05
+	FeatureVerifyPINDirect      ReaderFeature = 0x06 // PIN entry on the PIN pad, then VERIFY
+	FeatureModifyPINDirect      ReaderFeature = 0x07 // PIN entry on the PIN pad, then CHANGE REFERENCE DATA
+	FeatureMCTReaderDirect      ReaderFeature = 0x08
+	FeatureMCTUniversal         ReaderFeature = 0x09
+	FeatureIFDPINProperties     ReaderFeature = 0x0A
+	FeatureAbort                ReaderFeature = 0x0B
+	FeatureSetSPEMessage        ReaderFeature = 0x0C
+	FeatureWriteDisplay         ReaderFeature = 0x0F
+	FeatureGetKey               ReaderFeature = 0x10
+	FeatureIFDDisplayProperties ReaderFeature = 0x11
+	FeatureGetTLVProperties     ReaderFeature = 0x12
+	FeatureCCIDEscCommand       ReaderFeature = 0x13
+	FeatureExecutePACE          ReaderFeature = 0x20 // PACE run by the reader (BSI TR-03119)
+)
+
+// readerFeatureNames are the names used by PC/SC part 10, without FEATURE_
+var readerFeatureNames = map[ReaderFeature]string{
+	FeatureVerifyPINStart:       "VERIFY_PIN_START",
+	FeatureVerifyPINFinish:      "VERIFY_PIN_FINISH",
+	FeatureModifyPINStart:       "MODIFY_PIN_START",
+	FeatureModifyPINFinish:      "MODIFY_PIN_FINISH",
+	FeatureGetKeyPressed:        "GET_KEY_PRESSED",
+	FeatureVerifyPINDirect:      "VERIFY_PIN_DIRECT",
+	FeatureModifyPINDirect:      "MODIFY_PIN_DIRECT",
+	FeatureMCTReaderDirect:      "MCT_READER_DIRECT",
+	FeatureMCTUniversal:         "MCT_UNIVERSAL",
+	FeatureIFDPINProperties:     "IFD_PIN_PROPERTIES",
+	FeatureAbort:                "ABORT",
+	FeatureSetSPEMessage:        "SET_SPE_MESSAGE",
+	FeatureWriteDisplay:         "WRITE_DISPLAY",
+	FeatureGetKey:               "GET_KEY",
+	FeatureIFDDisplayProperties: "IFD_DISPLAY_PROPERTIES",
+	FeatureGetTLVProperties:     "GET_TLV_PROPERTIES",
+	FeatureCCIDEscCommand:       "CCID_ESC_COMMAND",
+	FeatureExecutePACE:          "EXECUTE_PACE",
+}
+
+// String implements fmt.Stringer
+func (f ReaderFeature) String() string {
+	if name, ok := readerFeatureNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("FEATURE_%02X", byte(f))
+}
+
+// ReaderFeatures maps the features of a reader to their control codes
+type ReaderFeatures map[ReaderFeature]uint32
+
+// ParseReaderFeatures decodes the response to GET_FEATURE_REQUEST: a list of
+// tag, length 4 and big-endian control code
+func ParseReaderFeatures(data []byte) (ReaderFeatures, error) {
+	features := make(ReaderFeatures)
+	for offset := 0; offset < len(data); {
+		if offset+2 > len(data) {
+			return nil, fmt.Errorf("feature TLV truncated at offset %d", offset)
+		}
+		tag, length := ReaderFeature(data[offset]), int(data[offset+1])
+		if length != 4 {
+			return nil, fmt.Errorf("feature %s has a %d-byte control code", tag, length)
+		}
+		if offset+6 > len(data) {
+			return nil, fmt.Errorf("feature %s truncated", tag)
+		}
+		value := data[offset+2 : offset+6]
+		features[tag] = uint32(value[0])<<24 | uint32(value[1])<<16 | uint32(value[2])<<8 | uint32(value[3])
+		offset += 6
+	}
+	return features, nil
+}
+
+// Has reports whether the reader supports a feature
+func (f ReaderFeatures) Has(feature ReaderFeature) bool {
+	_, ok := f[feature]
+	return ok
+}
+
+// ControlCode returns the SCardControl code of a feature
+func (f ReaderFeatures) ControlCode(feature ReaderFeature) (uint32, bool) {
+	code, ok := f[feature]
+	return code, ok
+}
+
+// PINPad reports whether PINs can be entered on the reader (secure PIN entry)
+func (f ReaderFeatures) PINPad() bool {
+	return f.Has(FeatureVerifyPINDirect)
+}
+
+// PACE reports whether the reader can run PACE itself
+func (f ReaderFeatures) PACE() bool {
+	return f.Has(FeatureExecutePACE)
+}
+
+// List returns the features in tag order
+func (f ReaderFeatures) List() []ReaderFeature {
+	list := make([]ReaderFeature, 0, len(f))
+	for feature := range f {
+		list = append(list, feature)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// Controller is implemented by cards whose reader accepts control commands
+// (PC/SC SCardControl), such as PIN pads and PACE-capable readers
+type Controller interface {
+	// ReaderFeatures returns the PC/SC part 10 features of the reader, empty
+	// when it has none
+	ReaderFeatures(ctx context.Context) (ReaderFeatures, error)
+
+	// Control sends a control command to the reader and returns its output.
+	// It gives up with an ErrTimeout TransportError when ctx is done.
+	Control(ctx context.Context, code uint32, in []byte) ([]byte, error)
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReaderFeatures(t *testing.T) {
+	data := []byte{
+		0x06, 0x04, 0x42, 0x33, 0x00, 0x06, // VERIFY_PIN_DIRECT
+		0x07, 0x04, 0x42, 0x33, 0x00, 0x07, // MODIFY_PIN_DIRECT
+		0x20, 0x04, 0x42, 0x33, 0x00, 0x20, // EXECUTE_PACE
+	}
+	features, err := ParseReaderFeatures(data)
+	if err != nil {
+		t.Fatalf("ParseReaderFeatures() error = %v", err)
+	}
+	if code, ok := features.ControlCode(FeatureVerifyPINDirect); !ok || code != 0x42330006 {
+		t.Errorf("ControlCode(VERIFY_PIN_DIRECT) = %08X, %v, want 42330006", code, ok)
+	}
+	if !features.PINPad() || !features.PACE() {
+		t.Errorf("PINPad() = %v, PACE() = %v, want both true", features.PINPad(), features.PACE())
+	}
+	want := []ReaderFeature{FeatureVerifyPINDirect, FeatureModifyPINDirect, FeatureExecutePACE}
+	if got := features.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestParseReaderFeatures_Empty(t *testing.T) {
+	features, err := ParseReaderFeatures(nil)
+	if err != nil {
+		t.Fatalf("ParseReaderFeatures() error = %v", err)
+	}
+	if features.PINPad() || features.PACE() || len(features) != 0 {
+		t.Errorf("features = %v, want none", features)
+	}
+}
+
+func TestParseReaderFeatures_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"tag without length", []byte{0x06}},
+		{"wrong length", []byte{0x06, 0x02, 0x00, 0x06}},
+		{"truncated code", []byte{0x06, 0x04, 0x42, 0x33, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseReaderFeatures(tt.data); err == nil {
+				t.Error("ParseReaderFeatures() expected error")
+			}
+		})
+	}
+}
+
+func TestReaderFeature_String(t *testing.T) {
+	if got := FeatureExecutePACE.String(); got != "EXECUTE_PACE" {
+		t.Errorf("String() = %q, want EXECUTE_PACE", got)
+	}
+	if got := ReaderFeature(0x7F).String(); got != "FEATURE_7F" {
+		t.Errorf("String() = %q, want FEATURE_7F", got)
+	}
+}
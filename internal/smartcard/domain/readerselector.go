@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReaderSelector chooses which reader to connect to
+type ReaderSelector interface {
+	// Select returns one of the available readers
+	Select(readers ReaderList) (Reader, error)
+
+	// String describes the selection rule (for logs and errors)
+	String() string
+}
+
+// errNoMatchingReader reports that no reader satisfied a selector
+func errNoMatchingReader(selector ReaderSelector, readers ReaderList) error {
+	return NewTransportError(ErrNoReaders,
+		fmt.Sprintf("no reader matches %s (available: %s)",
+			selector, strings.Join(readers.Names(), ", ")), nil)
+}
+
+// ReaderNamed selects the reader with exactly this name
+func ReaderNamed(name string) ReaderSelector {
+	return readerByName(name)
+}
+
+type readerByName string
+
+func (s readerByName) Select(readers ReaderList) (Reader, error) {
+	for _, r := range readers {
+		if r.Name() == string(s) {
+			return r, nil
+		}
+	}
+	return nil, errNoMatchingReader(s, readers)
+}
+
+func (s readerByName) String() string {
+	return fmt.Sprintf("name %q", string(s))
+}
+
+// ReaderAt selects the reader at a zero-based index in the PC/SC list
+func ReaderAt(index int) ReaderSelector {
+	return readerByIndex(index)
+}
+
+type readerByIndex int
+
+func (s readerByIndex) Select(readers ReaderList) (Reader, error) {
+	if int(s) < 0 || int(s) >= len(readers) {
+		return nil, errNoMatchingReader(s, readers)
+	}
+	return readers[s], nil
+}
+
+func (s readerByIndex) String() string {
+	return fmt.Sprintf("index %d", int(s))
+}
+
+// ReaderMatching selects the first reader whose name matches the expression
+func ReaderMatching(pattern *regexp.Regexp) ReaderSelector {
+	return readerByPattern{pattern: pattern}
+}
+
+type readerByPattern struct {
+	pattern *regexp.Regexp
+}
+
+func (s readerByPattern) Select(readers ReaderList) (Reader, error) {
+	for _, r := range readers {
+		if s.pattern.MatchString(r.Name()) {
+			return r, nil
+		}
+	}
+	return nil, errNoMatchingReader(s, readers)
+}
+
+func (s readerByPattern) String() string {
+	return fmt.Sprintf("pattern /%s/", s.pattern)
+}
+
+// FirstReaderWithCard selects the first reader that has a card inserted
+func FirstReaderWithCard() ReaderSelector {
+	return readerWithCard{}
+}
+
+type readerWithCard struct{}
+
+func (s readerWithCard) Select(readers ReaderList) (Reader, error) {
+	for _, r := range readers {
+		if r.IsConnected() {
+			return r, nil
+		}
+	}
+	return nil, errNoMatchingReader(s, readers)
+}
+
+func (s readerWithCard) String() string {
+	return "card present"
+}
+
+// FirstReaderWithATR selects the first reader whose card ATR matches a pattern
+func FirstReaderWithATR(patterns ...ATRPattern) ReaderSelector {
+	return readerByATR(patterns)
+}
+
+type readerByATR []ATRPattern
+
+func (s readerByATR) Select(readers ReaderList) (Reader, error) {
+	for _, r := range readers {
+		if !r.IsConnected() {
+			continue
+		}
+		for _, p := range s {
+			if p.Matches(r.ATR()) {
+				return r, nil
+			}
+		}
+	}
+	return nil, errNoMatchingReader(s, readers)
+}
+
+func (s readerByATR) String() string {
+	names := make([]string, len(s))
+	for i, p := range s {
+		names[i] = p.Name
+	}
+	return "ATR profile " + strings.Join(names, "/")
+}
+
+// DefaultReaderSelector prefers "Generic EMV" readers and falls back to the first one
+func DefaultReaderSelector() ReaderSelector {
+	return preferredReader("Generic EMV")
+}
+
+type preferredReader string
+
+func (s preferredReader) Select(readers ReaderList) (Reader, error) {
+	if len(readers) == 0 {
+		return nil, errNoMatchingReader(s, readers)
+	}
+	for _, r := range readers {
+		if strings.Contains(r.Name(), string(s)) {
+			return r, nil
+		}
+	}
+	return readers[0], nil
+}
+
+func (s preferredReader) String() string {
+	return fmt.Sprintf("preferred %q, else first", string(s))
+}
+
+// ParseReaderSelector builds a selector from a textual rule:
+//
+//	""             default ("Generic EMV" preferred, else first reader)
+//	"index:N"      reader at zero-based index N
+//	"match:REGEX"  first reader whose name matches REGEX
+//	"card"         first reader with a card present
+//	"cei"          first reader whose card ATR matches the CEI profile
+//	"name:NAME"    reader named exactly NAME (any other text is taken as a name)
+func ParseReaderSelector(spec string) (ReaderSelector, error) {
+	switch spec {
+	case "":
+		return DefaultReaderSelector(), nil
+	case "card":
+		return FirstReaderWithCard(), nil
+	case "cei":
+		return FirstReaderWithATR(CEIATRPatterns...), nil
+	}
+
+	kind, value, found := strings.Cut(spec, ":")
+	if !found {
+		return ReaderNamed(spec), nil
+	}
+	switch kind {
+	case "index":
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid reader index %q", value)
+		}
+		return ReaderAt(index), nil
+	case "match":
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reader pattern: %w", err)
+		}
+		return ReaderMatching(pattern), nil
+	case "name":
+		return ReaderNamed(value), nil
+	default:
+		return ReaderNamed(spec), nil
+	}
+}
@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type testReader struct {
+	name string
+	atr  []byte
+}
+
+func (r testReader) Name() string      { return r.name }
+func (r testReader) IsConnected() bool { return r.atr != nil }
+func (r testReader) ATR() []byte       { return r.atr }
+
+func (r testReader) Features() ReaderFeatures { return nil }
+
+var ceiATR = []byte{0x3B, 0xFF, 0x96, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x43,
+	0x80, 0x31, 0x80, 0x65, 0xB0, 0x85, 0x05, 0x00, 0x11, 0x12, 0x0F, 0xFF, 0x82, 0x90, 0x00, 0x00}
+
+var testReaders = ReaderList{
+	testReader{name: "Laptop Internal Reader 00 00"},
+	testReader{name: "Yubico YubiKey OTP+FIDO+CCID 01 00", atr: []byte{0x3B, 0xFD, 0x13, 0x00}},
+	testReader{name: "Generic EMV Smartcard Reader 02 00", atr: ceiATR},
+}
+
+func TestReaderSelectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector ReaderSelector
+		want     string
+	}{
+		{"default prefers Generic EMV", DefaultReaderSelector(), "Generic EMV Smartcard Reader 02 00"},
+		{"exact name", ReaderNamed("Laptop Internal Reader 00 00"), "Laptop Internal Reader 00 00"},
+		{"index", ReaderAt(1), "Yubico YubiKey OTP+FIDO+CCID 01 00"},
+		{"pattern", ReaderMatching(regexp.MustCompile(`(?i)yubikey`)), "Yubico YubiKey OTP+FIDO+CCID 01 00"},
+		{"card present", FirstReaderWithCard(), "Yubico YubiKey OTP+FIDO+CCID 01 00"},
+		{"CEI ATR", FirstReaderWithATR(CEIATRPatterns...), "Generic EMV Smartcard Reader 02 00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.selector.Select(testReaders)
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			if got.Name() != tt.want {
+				t.Errorf("Select() = %q, want %q", got.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderSelectors_NoMatch(t *testing.T) {
+	selectors := []ReaderSelector{
+		ReaderNamed("Missing Reader"),
+		ReaderAt(3),
+		ReaderAt(-1),
+		ReaderMatching(regexp.MustCompile(`^ACS`)),
+		FirstReaderWithATR(ATRPattern{Name: "other", ATR: []byte{0x3B, 0x00}}),
+	}
+
+	for _, selector := range selectors {
+		t.Run(selector.String(), func(t *testing.T) {
+			_, err := selector.Select(testReaders)
+			var transportErr *TransportError
+			if !errors.As(err, &transportErr) || transportErr.Code != ErrNoReaders {
+				t.Fatalf("Select() error = %v, want ErrNoReaders", err)
+			}
+			if !strings.Contains(err.Error(), "Laptop Internal Reader") {
+				t.Errorf("error %q should list the available readers", err)
+			}
+		})
+	}
+}
+
+func TestDefaultReaderSelector_FallsBackToFirst(t *testing.T) {
+	readers := ReaderList{testReader{name: "Reader A"}, testReader{name: "Reader B"}}
+
+	got, err := DefaultReaderSelector().Select(readers)
+	if err != nil || got.Name() != "Reader A" {
+		t.Errorf("Select() = %v, %v, want Reader A", got, err)
+	}
+	if _, err := DefaultReaderSelector().Select(nil); err == nil {
+		t.Error("Select() on an empty list should fail")
+	}
+}
+
+func TestParseReaderSelector(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", `preferred "Generic EMV", else first`},
+		{"card", "card present"},
+		{"cei", "ATR profile ID-One Cosmo (IAS-ECC)/ID-One Cosmo (IAS-ECC), contactless"},
+		{"index:2", "index 2"},
+		{"match:^Gem", "pattern /^Gem/"},
+		{"name:ACS ACR38U 00 00", `name "ACS ACR38U 00 00"`},
+		{"Laptop Internal Reader 00 00", `name "Laptop Internal Reader 00 00"`},
+		{"HID Global: OMNIKEY 3x21", `name "HID Global: OMNIKEY 3x21"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			selector, err := ParseReaderSelector(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseReaderSelector() error = %v", err)
+			}
+			if selector.String() != tt.want {
+				t.Errorf("String() = %q, want %q", selector.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReaderSelector_Invalid(t *testing.T) {
+	for _, spec := range []string{"index:x", "index:-1", "match:("} {
+		if _, err := ParseReaderSelector(spec); err == nil {
+			t.Errorf("ParseReaderSelector(%q) should fail", spec)
+		}
+	}
+}
+
+func TestATRPattern_Matches(t *testing.T) {
+	exact := ATRPattern{ATR: []byte{0x3B, 0x8C, 0x80, 0x01}, Mask: []byte{0xFF, 0xFF, 0xFF, 0x00}}
+	if !exact.Matches([]byte{0x3B, 0x8C, 0x80, 0x7F}) {
+		t.Error("masked byte should be ignored")
+	}
+	if exact.Matches([]byte{0x3B, 0x8C, 0x81, 0x01}) {
+		t.Error("unmasked byte should be compared")
+	}
+	if exact.Matches([]byte{0x3B, 0x8C, 0x80, 0x01, 0x00}) {
+		t.Error("non-prefix pattern should require equal length")
+	}
+
+	prefix := ATRPattern{ATR: []byte{0x3B, 0x8C}, Prefix: true}
+	if !prefix.Matches([]byte{0x3B, 0x8C, 0x80, 0x01}) || prefix.Matches([]byte{0x3B}) {
+		t.Error("prefix pattern should match longer ATRs only")
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "context"
+
+// ResetMode is what happens to the card when a session is re-established
+type ResetMode int
+
+const (
+	ResetLeave ResetMode = iota // Keep the card powered (acknowledges a reset done elsewhere)
+	ResetWarm                   // Warm reset: the card restarts, volatile state is lost
+	ResetCold                   // Power the card down and up again
+)
+
+// String returns the reset mode name
+func (m ResetMode) String() string {
+	switch m {
+	case ResetLeave:
+		return "leave"
+	case ResetWarm:
+		return "warm reset"
+	case ResetCold:
+		return "cold reset"
+	default:
+		return "unknown"
+	}
+}
+
+// Reconnector is implemented by cards that can re-establish a broken
+// session (card reset by another application, brief contact loss) without
+// a full Connect. Security state on the card (verified PINs, secure
+// messaging keys) does not survive a reconnect.
+type Reconnector interface {
+	// Reconnect re-establishes the session with the card in the same reader
+	Reconnect(ctx context.Context, mode ResetMode) error
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrPINEntryCancelled is returned by a PINProvider when the user gives up
+	ErrPINEntryCancelled = errors.New("PIN entry cancelled")
+
+	// ErrNewPINMismatch is returned when the confirmation of a new PIN differs
+	ErrNewPINMismatch = errors.New("the new PINs do not match")
+)
+
+// Secret is a PIN, PUK or CAN held in a byte buffer that is wiped after use.
+// Secrets never go through Go strings, which cannot be cleared.
+type Secret struct {
+	value []byte
+}
+
+// NewSecret wraps value, taking ownership of it: Wipe clears it in place
+func NewSecret(value []byte) *Secret {
+	return &Secret{value: value}
+}
+
+// Bytes returns the secret; the slice is cleared by Wipe
+func (s *Secret) Bytes() []byte {
+	return s.value
+}
+
+// Len returns the length of the secret
+func (s *Secret) Len() int {
+	return len(s.value)
+}
+
+// Equal compares two secrets in constant time for equal lengths
+func (s *Secret) Equal(other *Secret) bool {
+	if len(s.value) != len(other.value) {
+		return false
+	}
+	var diff byte
+	for i := range s.value {
+		diff |= s.value[i] ^ other.value[i]
+	}
+	return diff == 0
+}
+
+// Wipe overwrites the secret with zeros
+func (s *Secret) Wipe() {
+	if s != nil {
+		clear(s.value)
+		s.value = s.value[:0]
+	}
+}
+
+// SecretKind is what a PINProvider is asked for
+type SecretKind int
+
+const (
+	SecretPIN    SecretKind = iota // PIN to verify or the current PIN to change
+	SecretNewPIN                   // Replacement PIN
+	SecretPUK                      // Resetting code of a blocked PIN
+	SecretCAN                      // Card access number for PACE
+)
+
+// String implements fmt.Stringer
+func (k SecretKind) String() string {
+	switch k {
+	case SecretPIN:
+		return "PIN"
+	case SecretNewPIN:
+		return "new PIN"
+	case SecretPUK:
+		return "PUK"
+	case SecretCAN:
+		return "CAN"
+	default:
+		return fmt.Sprintf("SecretKind(%d)", int(k))
+	}
+}
+
+// PINRequest describes the secret a PINProvider must collect
+type PINRequest struct {
+	Kind        SecretKind
+	Reference   byte      // PIN reference the secret is for
+	Name        string    // Name of the secret (e.g. "PIN1", "new PIN2", "PUK")
+	Format      PINFormat // Expected length and charset
+	RetriesLeft int       // Tries left before blocking, -1 when unknown
+	Confirm     bool      // Second entry of a new PIN
+}
+
+// Prompt returns a one-line prompt such as "Enter PIN1 (3 tries left)"
+func (r PINRequest) Prompt() string {
+	verb := "Enter"
+	if r.Confirm {
+		verb = "Repeat"
+	}
+	switch {
+	case r.RetriesLeft == 1:
+		return fmt.Sprintf("%s %s (LAST try)", verb, r.Name)
+	case r.RetriesLeft > 1:
+		return fmt.Sprintf("%s %s (%d tries left)", verb, r.Name, r.RetriesLeft)
+	default:
+		return fmt.Sprintf("%s %s", verb, r.Name)
+	}
+}
+
+// Description explains the request for PIN dialogs, including the expected
+// format
+func (r PINRequest) Description() string {
+	length := fmt.Sprintf("%d to %d", r.Format.MinLength, r.Format.MaxLength)
+	if r.Format.MinLength == r.Format.MaxLength {
+		length = fmt.Sprint(r.Format.MinLength)
+	}
+	description := fmt.Sprintf("The identity card needs the %s (%s %s).", r.Name, length, r.Format.unit())
+	if r.RetriesLeft == 1 {
+		description += " Only one try is left: a wrong value blocks it."
+	}
+	return description
+}
+
+// PINProvider collects PINs, PUKs and CANs from the user when the
+// application services need them. The caller wipes the returned secret.
+type PINProvider interface {
+	RequestSecret(ctx context.Context, req PINRequest) (*Secret, error)
+}
+
+// PINPadNotifier is implemented by PINProviders that tell the user when a
+// secret is typed on the reader's PIN pad instead of being asked for. The
+// requests of one entry are notified in the order the PIN pad asks for them.
+type PINPadNotifier interface {
+	PINPadEntry(req PINRequest)
+}
+
+// PINProviderFunc adapts a function to PINProvider, for GUIs and daemons
+type PINProviderFunc func(ctx context.Context, req PINRequest) (*Secret, error)
+
+// RequestSecret implements PINProvider
+func (f PINProviderFunc) RequestSecret(ctx context.Context, req PINRequest) (*Secret, error) {
+	return f(ctx, req)
+}
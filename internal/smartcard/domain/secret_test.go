@@ -0,0 +1,49 @@
+package domain
+
+import "testing"
+
+func TestSecret_Wipe(t *testing.T) {
+	value := []byte("1234")
+	secret := NewSecret(value)
+	secret.Wipe()
+
+	if secret.Len() != 0 {
+		t.Errorf("Len() after Wipe = %d, want 0", secret.Len())
+	}
+	for i, b := range value {
+		if b != 0 {
+			t.Errorf("byte %d after Wipe = %02X, want 00", i, b)
+		}
+	}
+
+	var missing *Secret
+	missing.Wipe() // Must not panic
+}
+
+func TestSecret_Equal(t *testing.T) {
+	a := NewSecret([]byte("1234"))
+	if !a.Equal(NewSecret([]byte("1234"))) {
+		t.Error("Equal() = false for the same value")
+	}
+	if a.Equal(NewSecret([]byte("1235"))) || a.Equal(NewSecret([]byte("123"))) {
+		t.Error("Equal() = true for different values")
+	}
+}
+
+func TestPINRequest_Prompt(t *testing.T) {
+	tests := []struct {
+		request PINRequest
+		want    string
+	}{
+		{PINRequest{Name: "PIN1", RetriesLeft: 3}, "Enter PIN1 (3 tries left)"},
+		{PINRequest{Name: "PIN2", RetriesLeft: 1}, "Enter PIN2 (LAST try)"},
+		{PINRequest{Name: "PUK", RetriesLeft: -1}, "Enter PUK"},
+		{PINRequest{Name: "new PIN1", RetriesLeft: -1, Confirm: true}, "Repeat new PIN1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.request.Prompt(); got != tt.want {
+			t.Errorf("Prompt() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"context"
+	"errors"
+)
+
 // Status code constants (ISO/IEC 7816-4)
 const (
 	// Successful execution
@@ -17,24 +22,36 @@ const (
 	StatusSecurityError   uint16 = 0x6600 // Security error
 
 	// Client errors (0x67xx, 0x68xx, 0x69xx, 0x6Axx)
-	StatusLengthError       uint16 = 0x6700 // Wrong length
-	StatusFunctionNotFound  uint16 = 0x6881 // Logical channel not supported
-	StatusLogicalChannelErr uint16 = 0x6882 // Secure messaging not supported
-	StatusKeyReferenceErr   uint16 = 0x6A86 // Incorrect parameters (P1/P2)
-	StatusFileNotFound      uint16 = 0x6A82 // File or application not found
-	StatusInstructionErr    uint16 = 0x6D00 // Instruction code not supported
-	StatusCLAErr            uint16 = 0x6E00 // Class not supported
+	StatusLengthError            uint16 = 0x6700 // Wrong length
+	StatusFunctionNotFound       uint16 = 0x6881 // Logical channel not supported
+	StatusLogicalChannelErr      uint16 = 0x6882 // Secure messaging not supported
+	StatusLastCommandExpected    uint16 = 0x6883 // Last command of the chain expected
+	StatusConditionsNotSatisfied uint16 = 0x6985 // Conditions of use not satisfied
+	StatusCommandNotAllowed      uint16 = 0x6986 // Command not allowed (no current EF)
+	StatusSMDataMissing          uint16 = 0x6987 // Expected secure messaging data objects missing
+	StatusSMDataIncorrect        uint16 = 0x6988 // Incorrect secure messaging data objects
+	StatusWrongData              uint16 = 0x6A80 // Incorrect parameters in the data field
+	StatusFuncNotSupported       uint16 = 0x6A81 // Function not supported
+	StatusKeyReferenceErr        uint16 = 0x6A86 // Incorrect parameters (P1/P2)
+	StatusFileNotFound           uint16 = 0x6A82 // File or application not found
+	StatusReferenceNotFound      uint16 = 0x6A88 // Referenced data not found
+	StatusWrongOffset            uint16 = 0x6B00 // Wrong parameters (offset outside the EF)
+	StatusInstructionErr         uint16 = 0x6D00 // Instruction code not supported
+	StatusCLAErr                 uint16 = 0x6E00 // Class not supported
 
 	// Authentication errors
-	StatusSecurityAuthFailed uint16 = 0x6982 // Security status not satisfied
-	StatusIncorrectPIN       uint16 = 0x6983 // Authentication method blocked
-	StatusIncorrectKey       uint16 = 0x6984 // Reference key in use
+	StatusAuthenticationFailed uint16 = 0x6300 // Authentication failed (e.g. PACE token)
+	StatusSecurityAuthFailed   uint16 = 0x6982 // Security status not satisfied
+	StatusIncorrectPIN         uint16 = 0x6983 // Authentication method blocked
+	StatusIncorrectKey         uint16 = 0x6984 // Reference data not usable
 
 	// PIN retry counter (0x63Cx where x = remaining tries)
 	StatusPINRetryMask uint16 = 0x63C0 // Mask for PIN retry counter
 )
 
-// TransportErrorCode represents PC/SC transport-level errors
+// TransportErrorCode represents PC/SC transport-level errors. Codes are also
+// errors.Is sentinels: errors.Is(err, ErrCardRemoved) matches any
+// TransportError with that code.
 type TransportErrorCode int
 
 const (
@@ -47,13 +64,84 @@ const (
 	ErrProtocolMismatch
 	ErrReaderBusy
 	ErrTimeout
+	ErrCardReset
+	ErrCardUnresponsive
+)
+
+// Error implements the error interface so codes can be used as sentinels
+func (c TransportErrorCode) Error() string {
+	switch c {
+	case ErrNoContext:
+		return "PC/SC service unavailable"
+	case ErrNoReaders:
+		return "no smart card reader"
+	case ErrNoCard:
+		return "no card in reader"
+	case ErrCardRemoved:
+		return "card removed"
+	case ErrConnectionLost:
+		return "connection to card lost"
+	case ErrTransmissionFailed:
+		return "transmission failed"
+	case ErrProtocolMismatch:
+		return "protocol mismatch"
+	case ErrReaderBusy:
+		return "reader in use by another application"
+	case ErrTimeout:
+		return "timeout"
+	case ErrCardReset:
+		return "card was reset"
+	case ErrCardUnresponsive:
+		return "card does not respond"
+	default:
+		return "transport error"
+	}
+}
+
+// Recovery is the action that may resolve a transport error
+type Recovery int
+
+const (
+	RecoveryNone         Recovery = iota // Permanent for this setup (service down, no reader, bug)
+	RecoveryRetry                        // Try the same operation again later
+	RecoveryReconnect                    // Reconnect to the card, then restart the operation
+	RecoveryReinsertCard                 // Ask the user to (re)insert the card
 )
 
+// String returns the recovery action name
+func (r Recovery) String() string {
+	switch r {
+	case RecoveryRetry:
+		return "retry"
+	case RecoveryReconnect:
+		return "reconnect"
+	case RecoveryReinsertCard:
+		return "reinsert card"
+	default:
+		return "none"
+	}
+}
+
+// Recovery returns the action that may resolve errors with this code
+func (c TransportErrorCode) Recovery() Recovery {
+	switch c {
+	case ErrReaderBusy:
+		return RecoveryRetry
+	case ErrConnectionLost, ErrCardReset, ErrTimeout:
+		return RecoveryReconnect
+	case ErrNoCard, ErrCardRemoved, ErrCardUnresponsive:
+		return RecoveryReinsertCard
+	default:
+		return RecoveryNone
+	}
+}
+
 // TransportError represents a PC/SC transport-level error (not APDU status)
 type TransportError struct {
-	Code    TransportErrorCode
-	Message string
-	Cause   error
+	Code     TransportErrorCode
+	Message  string
+	Cause    error
+	PCSCCode uint32 // Raw PC/SC result (e.g. 0x80100069 SCARD_W_REMOVED_CARD), 0 if none
 }
 
 // Error implements the error interface
@@ -69,6 +157,27 @@ func (e *TransportError) Unwrap() error {
 	return e.Cause
 }
 
+// Is matches a TransportErrorCode sentinel against the error code
+func (e *TransportError) Is(target error) bool {
+	code, ok := target.(TransportErrorCode)
+	return ok && code == e.Code
+}
+
+// Recovery returns the action that may resolve the error
+func (e *TransportError) Recovery() Recovery {
+	return e.Code.Recovery()
+}
+
+// NewTimeoutError converts the error of a done context (deadline exceeded or
+// cancelled) into an ErrTimeout transport error wrapping it
+func NewTimeoutError(cause error) *TransportError {
+	message := "operation timed out"
+	if errors.Is(cause, context.Canceled) {
+		message = "operation cancelled"
+	}
+	return NewTransportError(ErrTimeout, message, cause)
+}
+
 // NewTransportError creates a new transport error
 func NewTransportError(code TransportErrorCode, message string, cause error) *TransportError {
 	return &TransportError{
@@ -78,12 +187,15 @@ func NewTransportError(code TransportErrorCode, message string, cause error) *Tr
 	}
 }
 
-// StatusError wraps a status code with context
+// StatusError wraps a status code with context. It matches its
+// StatusCondition with errors.Is.
 type StatusError struct {
 	Code   uint16
 	SW1    byte
 	SW2    byte
-	Detail string
+	Detail string // Replaces the catalogue description when set
+	INS    byte   // Instruction that received the status, when known
+	Hint   string // Meaning of the status for that instruction
 }
 
 // Error implements the error interface
@@ -91,41 +203,26 @@ func (e *StatusError) Error() string {
 	if e.Detail != "" {
 		return e.Detail
 	}
+	if e.Hint != "" {
+		return e.String() + ": " + e.Hint
+	}
 	return e.String()
 }
 
-// String returns a human-readable status code
+// String returns the catalogue description of the status code
 func (e *StatusError) String() string {
-	switch e.Code {
-	case StatusSuccess:
-		return "Success"
-	case StatusMoreData:
-		return "More data available"
-	case StatusWarning:
-		return "Warning"
-	case StatusWarningCorrupted:
-		return "File corrupted"
-	case StatusWarningEOF:
-		return "End of file reached"
-	case StatusExecutionError:
-		return "Execution error"
-	case StatusPersistentError:
-		return "Persistent memory error"
-	case StatusSecurityError:
-		return "Security error"
-	case StatusLengthError:
-		return "Wrong length"
-	case StatusSecurityAuthFailed:
-		return "Security status not satisfied (incorrect PIN/CAN?)"
-	case StatusIncorrectPIN:
-		return "Authentication method blocked"
-	case StatusInstructionErr:
-		return "Instruction code not supported"
-	case StatusCLAErr:
-		return "Class not supported"
-	default:
-		return "Unknown error"
-	}
+	return LookupStatusWord(e.Code).Description
+}
+
+// Condition returns the class of the status code
+func (e *StatusError) Condition() StatusCondition {
+	return LookupStatusWord(e.Code).Condition
+}
+
+// Is matches a StatusCondition sentinel against the status code
+func (e *StatusError) Is(target error) bool {
+	condition, ok := target.(StatusCondition)
+	return ok && condition != 0 && condition == e.Condition()
 }
 
 // NewStatusError creates a StatusError from a Response
@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -29,7 +31,12 @@ func TestStatusError_Error(t *testing.T) {
 		{
 			name:   "file not found",
 			err:    &StatusError{Code: StatusFileNotFound, SW1: 0x6A, SW2: 0x82},
-			expect: "Unknown error", // Not mapped in String() yet
+			expect: "File or application not found",
+		},
+		{
+			name:   "with hint",
+			err:    &StatusError{Code: StatusFileNotFound, SW1: 0x6A, SW2: 0x82, Hint: "no such application"},
+			expect: "File or application not found: no such application",
 		},
 	}
 
@@ -56,6 +63,9 @@ func TestStatusError_String(t *testing.T) {
 		{StatusLengthError, "Wrong length"},
 		{StatusInstructionErr, "Instruction code not supported"},
 		{StatusCLAErr, "Class not supported"},
+		{0x63C2, "Verification failed, 2 retries left"},
+		{0x6C10, "Wrong Le field, 16 bytes available"},
+		{0x62FF, "Unknown warning"},
 		{0xFFFF, "Unknown error"},
 	}
 
@@ -152,6 +162,71 @@ func TestNewTransportError(t *testing.T) {
 	}
 }
 
+func TestNewTimeoutError(t *testing.T) {
+	tests := []struct {
+		cause       error
+		wantMessage string
+	}{
+		{context.DeadlineExceeded, "operation timed out"},
+		{context.Canceled, "operation cancelled"},
+	}
+
+	for _, tt := range tests {
+		err := NewTimeoutError(tt.cause)
+		if err.Code != ErrTimeout {
+			t.Errorf("Code = %d, want ErrTimeout", err.Code)
+		}
+		if err.Message != tt.wantMessage {
+			t.Errorf("Message = %q, want %q", err.Message, tt.wantMessage)
+		}
+		if !errors.Is(err, tt.cause) {
+			t.Errorf("errors.Is(%v, %v) = false", err, tt.cause)
+		}
+	}
+}
+
+func TestTransportError_Is(t *testing.T) {
+	cause := errors.New("scard: card removed")
+	err := fmt.Errorf("reading file: %w", NewTransportError(ErrCardRemoved, "APDU transmission failed", cause))
+
+	if !errors.Is(err, ErrCardRemoved) {
+		t.Error("errors.Is(err, ErrCardRemoved) = false, want true")
+	}
+	if errors.Is(err, ErrCardReset) {
+		t.Error("errors.Is(err, ErrCardReset) = true, want false")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want the cause to stay reachable")
+	}
+}
+
+func TestTransportErrorCode_Recovery(t *testing.T) {
+	tests := []struct {
+		code TransportErrorCode
+		want Recovery
+	}{
+		{ErrNoContext, RecoveryNone},
+		{ErrNoReaders, RecoveryNone},
+		{ErrNoCard, RecoveryReinsertCard},
+		{ErrCardRemoved, RecoveryReinsertCard},
+		{ErrCardUnresponsive, RecoveryReinsertCard},
+		{ErrConnectionLost, RecoveryReconnect},
+		{ErrCardReset, RecoveryReconnect},
+		{ErrTimeout, RecoveryReconnect},
+		{ErrReaderBusy, RecoveryRetry},
+		{ErrTransmissionFailed, RecoveryNone},
+		{ErrProtocolMismatch, RecoveryNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.Error(), func(t *testing.T) {
+			if got := NewTransportError(tt.code, "test", nil).Recovery(); got != tt.want {
+				t.Errorf("Recovery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestTransportErrorCode_Values(t *testing.T) {
 	// Verify error codes are unique and non-zero
 	codes := []TransportErrorCode{
@@ -164,6 +239,8 @@ func TestTransportErrorCode_Values(t *testing.T) {
 		ErrProtocolMismatch,
 		ErrReaderBusy,
 		ErrTimeout,
+		ErrCardReset,
+		ErrCardUnresponsive,
 	}
 
 	seen := make(map[TransportErrorCode]bool)
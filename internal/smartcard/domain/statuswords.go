@@ -0,0 +1,287 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatusCondition classifies status words. Conditions are errors.Is
+// sentinels: errors.Is(err, ErrSecurityStatusNotSatisfied) matches any
+// StatusError (or PINError) whose status word has that condition.
+type StatusCondition int
+
+const (
+	ErrWarning StatusCondition = iota + 1 // 62xx and 63xx other than the authentication failures 6300 and 63Cx
+	ErrAuthenticationFailed
+	ErrVerificationFailed
+	ErrExecutionError
+	ErrMemoryFailure
+	ErrWrongLength
+	ErrLogicalChannelNotSupported
+	ErrSecureMessagingNotSupported
+	ErrChainingError
+	ErrCommandNotAllowed
+	ErrIncompatibleFileStructure
+	ErrSecurityStatusNotSatisfied
+	ErrAuthenticationMethodBlocked
+	ErrReferenceDataNotUsable
+	ErrConditionsNotSatisfied
+	ErrNoCurrentEF
+	ErrSMDataObjectsMissing
+	ErrSMDataObjectsIncorrect
+	ErrWrongData
+	ErrFunctionNotSupported
+	ErrFileNotFound
+	ErrRecordNotFound
+	ErrNotEnoughMemory
+	ErrIncorrectP1P2
+	ErrReferencedDataNotFound
+	ErrFileExists
+	ErrWrongLe
+	ErrInstructionNotSupported
+	ErrClassNotSupported
+)
+
+// statusConditionNames describes each condition
+var statusConditionNames = map[StatusCondition]string{
+	ErrWarning:                     "warning",
+	ErrAuthenticationFailed:        "authentication failed",
+	ErrVerificationFailed:          "verification failed",
+	ErrExecutionError:              "execution error",
+	ErrMemoryFailure:               "memory failure",
+	ErrWrongLength:                 "wrong length",
+	ErrLogicalChannelNotSupported:  "logical channel not supported",
+	ErrSecureMessagingNotSupported: "secure messaging not supported",
+	ErrChainingError:               "command chaining error",
+	ErrCommandNotAllowed:           "command not allowed",
+	ErrIncompatibleFileStructure:   "command incompatible with file structure",
+	ErrSecurityStatusNotSatisfied:  "security status not satisfied",
+	ErrAuthenticationMethodBlocked: "authentication method blocked",
+	ErrReferenceDataNotUsable:      "reference data not usable",
+	ErrConditionsNotSatisfied:      "conditions of use not satisfied",
+	ErrNoCurrentEF:                 "no current EF",
+	ErrSMDataObjectsMissing:        "secure messaging data objects missing",
+	ErrSMDataObjectsIncorrect:      "secure messaging data objects incorrect",
+	ErrWrongData:                   "incorrect command data",
+	ErrFunctionNotSupported:        "function not supported",
+	ErrFileNotFound:                "file or application not found",
+	ErrRecordNotFound:              "record not found",
+	ErrNotEnoughMemory:             "not enough memory",
+	ErrIncorrectP1P2:               "incorrect parameters P1-P2",
+	ErrReferencedDataNotFound:      "referenced data not found",
+	ErrFileExists:                  "file already exists",
+	ErrWrongLe:                     "wrong Le",
+	ErrInstructionNotSupported:     "instruction not supported",
+	ErrClassNotSupported:           "class not supported",
+}
+
+// Error implements the error interface so conditions can be used as sentinels
+func (c StatusCondition) Error() string {
+	if name, ok := statusConditionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("StatusCondition(%d)", int(c))
+}
+
+// StatusWord describes a status word from the catalogue
+type StatusWord struct {
+	Code        uint16
+	Label       string // Short form for APDU logs (e.g. "Auth failed")
+	Description string // Full form for errors
+	Condition   StatusCondition
+	Warning     bool // 62xx/63xx: the command was processed
+}
+
+// statusEntry is a catalogue entry matching Code under Mask. Descriptions
+// and labels of masked entries take the unmasked bits as a %d argument.
+type statusEntry struct {
+	code, mask  uint16
+	label       string
+	description string
+	condition   StatusCondition
+}
+
+// statusCatalogue lists the ISO/IEC 7816-4 §5.6 status words and the
+// IAS-ECC meanings used by the CEI, most specific entries first
+var statusCatalogue = []statusEntry{
+	{0x9000, 0xFFFF, "OK", "Success", 0},
+	{StatusMoreData, 0xFF00, "More data", "More data available", 0},
+
+	{0x6200, 0xFFFF, "Warning", "Warning: non-volatile memory unchanged", ErrWarning},
+	{StatusWarningCorrupted, 0xFFFF, "Data corrupted", "Part of the returned data may be corrupted", ErrWarning},
+	{StatusWarningEOF, 0xFFFF, "EOF", "End of file reached", ErrWarning},
+	{0x6283, 0xFFFF, "File deactivated", "Selected file deactivated", ErrWarning},
+	{0x6284, 0xFFFF, "FCI not formatted", "File control information not formatted according to ISO/IEC 7816-4", ErrWarning},
+	{0x6285, 0xFFFF, "File terminated", "Selected file in termination state", ErrWarning},
+	{0x6300, 0xFFFF, "Authentication failed", "Authentication failed", ErrAuthenticationFailed},
+	{0x6381, 0xFFFF, "File filled up", "File filled up by the last write", ErrWarning},
+	{StatusPINRetryMask, 0xFFF0, "%d retries left", "Verification failed, %d retries left", ErrVerificationFailed},
+
+	{0x6400, 0xFFFF, "Execution error", "Execution error", ErrExecutionError},
+	{0x6500, 0xFFFF, "Memory changed", "Execution error, non-volatile memory changed", ErrExecutionError},
+	{0x6581, 0xFFFF, "Memory failure", "Memory failure", ErrMemoryFailure},
+	{0x6600, 0xFFFF, "Security error", "Security error", ErrExecutionError},
+
+	{StatusLengthError, 0xFFFF, "Wrong length", "Wrong length", ErrWrongLength},
+	{0x6800, 0xFFFF, "CLA function not supported", "Functions in CLA not supported", ErrClassNotSupported},
+	{StatusFunctionNotFound, 0xFFFF, "Channel not supported", "Logical channel not supported", ErrLogicalChannelNotSupported},
+	{StatusLogicalChannelErr, 0xFFFF, "SM not supported", "Secure messaging not supported", ErrSecureMessagingNotSupported},
+	{StatusLastCommandExpected, 0xFFFF, "Last command expected", "Last command of the chain expected", ErrChainingError},
+	{0x6884, 0xFFFF, "Chaining not supported", "Command chaining not supported", ErrChainingError},
+
+	{0x6900, 0xFFFF, "Command not allowed", "Command not allowed", ErrCommandNotAllowed},
+	{0x6981, 0xFFFF, "Incompatible file", "Command incompatible with file structure", ErrIncompatibleFileStructure},
+	{StatusSecurityAuthFailed, 0xFFFF, "Auth failed", "Security status not satisfied (incorrect PIN/CAN?)", ErrSecurityStatusNotSatisfied},
+	{StatusIncorrectPIN, 0xFFFF, "Auth method blocked", "Authentication method blocked", ErrAuthenticationMethodBlocked},
+	{StatusIncorrectKey, 0xFFFF, "Reference data not usable", "Reference data not usable", ErrReferenceDataNotUsable},
+	{0x6985, 0xFFFF, "Conditions not satisfied", "Conditions of use not satisfied", ErrConditionsNotSatisfied},
+	{StatusCommandNotAllowed, 0xFFFF, "No current EF", "Command not allowed (no current EF)", ErrNoCurrentEF},
+	{0x6987, 0xFFFF, "SM objects missing", "Expected secure messaging data objects missing", ErrSMDataObjectsMissing},
+	{0x6988, 0xFFFF, "SM objects incorrect", "Incorrect secure messaging data objects", ErrSMDataObjectsIncorrect},
+
+	{0x6A00, 0xFFFF, "Wrong P1/P2", "Wrong parameters P1-P2", ErrIncorrectP1P2},
+	{StatusWrongData, 0xFFFF, "Wrong data", "Incorrect parameters in the command data field", ErrWrongData},
+	{StatusFuncNotSupported, 0xFFFF, "Function not supported", "Function not supported", ErrFunctionNotSupported},
+	{StatusFileNotFound, 0xFFFF, "Not found", "File or application not found", ErrFileNotFound},
+	{0x6A83, 0xFFFF, "Record not found", "Record not found", ErrRecordNotFound},
+	{0x6A84, 0xFFFF, "Not enough memory", "Not enough memory space in the file", ErrNotEnoughMemory},
+	{0x6A85, 0xFFFF, "Nc inconsistent", "Nc inconsistent with TLV structure", ErrWrongData},
+	{StatusKeyReferenceErr, 0xFFFF, "Wrong P1/P2", "Incorrect parameters P1-P2", ErrIncorrectP1P2},
+	{0x6A87, 0xFFFF, "Nc inconsistent", "Nc inconsistent with parameters P1-P2", ErrWrongLength},
+	{StatusReferenceNotFound, 0xFFFF, "Reference not found", "Referenced data or reference data not found", ErrReferencedDataNotFound},
+	{0x6A89, 0xFFFF, "File exists", "File already exists", ErrFileExists},
+	{0x6A8A, 0xFFFF, "DF name exists", "DF name already exists", ErrFileExists},
+
+	{StatusWrongOffset, 0xFFFF, "Wrong offset", "Wrong parameters P1-P2 (offset outside the EF)", ErrIncorrectP1P2},
+	{0x6C00, 0xFF00, "Wrong Le (%d available)", "Wrong Le field, %d bytes available", ErrWrongLe},
+	{StatusInstructionErr, 0xFFFF, "INS not supported", "Instruction code not supported", ErrInstructionNotSupported},
+	{StatusCLAErr, 0xFFFF, "CLA not supported", "Class not supported", ErrClassNotSupported},
+	{0x6F00, 0xFFFF, "No diagnosis", "No precise diagnosis", ErrExecutionError},
+}
+
+// statusHintKey selects a hint by instruction and catalogue code
+type statusHintKey struct {
+	ins  byte
+	code uint16
+}
+
+// statusHints explain a status word in the context of the command that
+// received it
+var statusHints = map[statusHintKey]string{
+	{0xA4, StatusFileNotFound}:       "the application or file does not exist on this card",
+	{0xA4, StatusKeyReferenceErr}:    "selection mode not supported by the card",
+	{0x20, StatusPINRetryMask}:       "wrong PIN",
+	{0x20, StatusIncorrectPIN}:       "PIN blocked, unblock it with the PUK",
+	{0x20, StatusIncorrectKey}:       "PIN not initialised or deactivated",
+	{0x20, StatusReferenceNotFound}:  "no PIN with this reference",
+	{0x24, StatusPINRetryMask}:       "wrong current PIN",
+	{0x24, StatusIncorrectPIN}:       "PIN blocked, unblock it with the PUK",
+	{0x24, StatusWrongData}:          "the new PIN does not have the required format",
+	{0x2C, StatusPINRetryMask}:       "wrong PUK",
+	{0x2C, StatusIncorrectPIN}:       "PUK blocked, the card must be replaced",
+	{0x2C, StatusWrongData}:          "the new PIN does not have the required format",
+	{0xB0, StatusSecurityAuthFailed}: "reading this file requires PIN verification or PACE",
+	{0xB0, StatusWrongOffset}:        "offset beyond the end of the file",
+	{0xB0, StatusCommandNotAllowed}:  "no EF selected",
+	{0xB0, 0x6981}:                   "the current file is not transparent",
+	{0xB2, 0x6A83}:                   "record number beyond the last record",
+	{0x22, StatusReferenceNotFound}:  "key or password reference unknown to the card",
+	{0x22, StatusWrongData}:          "protocol or domain parameters not supported",
+	{0x86, 0x6300}:                   "PACE or terminal authentication failed (wrong CAN or PIN?)",
+	{0x86, StatusSecurityAuthFailed}: "MSE:Set AT missing or rejected",
+	{0x2A, StatusSecurityAuthFailed}: "signing requires PIN2 verification",
+	{0x70, StatusFunctionNotFound}:   "card does not support logical channels",
+	{0x70, StatusLogicalChannelErr}:  "secure messaging indicated on MANAGE CHANNEL",
+	{0x70, StatusFuncNotSupported}:   "no logical channel left to open",
+	{0xC0, StatusCommandNotAllowed}:  "no response data pending",
+}
+
+// LookupStatusWord returns the catalogue entry of a status word. Unknown
+// words get a generic description and no label.
+func LookupStatusWord(code uint16) StatusWord {
+	word := StatusWord{Code: code, Warning: code>>8 == 0x62 || code>>8 == 0x63}
+	entry, ok := findStatusEntry(code)
+	if !ok {
+		word.Description = "Unknown error"
+		if word.Warning {
+			word.Description = "Unknown warning"
+			word.Condition = ErrWarning
+		}
+		return word
+	}
+
+	word.Label = entry.format(entry.label, code)
+	word.Description = entry.format(entry.description, code)
+	word.Condition = entry.condition
+	return word
+}
+
+// findStatusEntry returns the first catalogue entry matching code
+func findStatusEntry(code uint16) (statusEntry, bool) {
+	for _, entry := range statusCatalogue {
+		if code&entry.mask == entry.code {
+			return entry, true
+		}
+	}
+	return statusEntry{}, false
+}
+
+// format fills the unmasked bits of code into text
+func (e statusEntry) format(text string, code uint16) string {
+	if !strings.Contains(text, "%d") {
+		return text
+	}
+	return fmt.Sprintf(text, code&^e.mask)
+}
+
+// statusHint returns the hint for a status word received by ins
+func statusHint(ins byte, code uint16) string {
+	entry, ok := findStatusEntry(code)
+	if !ok {
+		return ""
+	}
+	return statusHints[statusHintKey{ins, entry.code}]
+}
+
+// pinInstructions are the commands whose failures are PINErrors
+var pinInstructions = map[byte]bool{
+	0x20: true, // VERIFY
+	0x24: true, // CHANGE REFERENCE DATA
+	0x2C: true, // RESET RETRY COUNTER
+}
+
+// PINError reports a failed PIN or PUK check: a wrong value (63Cx) with
+// the retries left, or a blocked reference (6983). It wraps the StatusError.
+type PINError struct {
+	*StatusError
+	RetriesLeft int // 0 when blocked
+}
+
+// Blocked reports whether no retries are left
+func (e *PINError) Blocked() bool {
+	return e.RetriesLeft == 0
+}
+
+// Unwrap returns the status error
+func (e *PINError) Unwrap() error {
+	return e.StatusError
+}
+
+// NewCommandError creates the error for an unsuccessful response to apdu:
+// a *PINError for failed PIN checks, otherwise a *StatusError with a hint
+// for the command
+func NewCommandError(apdu *APDU, resp *Response) error {
+	statusErr := NewStatusError(resp)
+	statusErr.INS = apdu.INS
+	statusErr.Hint = statusHint(apdu.INS, statusErr.Code)
+
+	if pinInstructions[apdu.INS] {
+		switch {
+		case statusErr.Code&0xFFF0 == StatusPINRetryMask:
+			return &PINError{StatusError: statusErr, RetriesLeft: int(statusErr.Code & 0x000F)}
+		case statusErr.Code == StatusIncorrectPIN:
+			return &PINError{StatusError: statusErr}
+		}
+	}
+	return statusErr
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestLookupStatusWord(t *testing.T) {
+	tests := []struct {
+		code      uint16
+		label     string
+		condition StatusCondition
+		warning   bool
+	}{
+		{0x9000, "OK", 0, false},
+		{0x6110, "More data", 0, false},
+		{0x6282, "EOF", ErrWarning, true},
+		{0x63C0, "0 retries left", ErrVerificationFailed, true},
+		{0x63C3, "3 retries left", ErrVerificationFailed, true},
+		{0x6982, "Auth failed", ErrSecurityStatusNotSatisfied, false},
+		{0x6983, "Auth method blocked", ErrAuthenticationMethodBlocked, false},
+		{0x6985, "Conditions not satisfied", ErrConditionsNotSatisfied, false},
+		{0x6987, "SM objects missing", ErrSMDataObjectsMissing, false},
+		{0x6988, "SM objects incorrect", ErrSMDataObjectsIncorrect, false},
+		{0x6A82, "Not found", ErrFileNotFound, false},
+		{0x6C20, "Wrong Le (32 available)", ErrWrongLe, false},
+		{0x6D00, "INS not supported", ErrInstructionNotSupported, false},
+		{0x6299, "", ErrWarning, true},
+		{0x1234, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%04X", tt.code), func(t *testing.T) {
+			word := LookupStatusWord(tt.code)
+			if word.Label != tt.label {
+				t.Errorf("Label = %q, want %q", word.Label, tt.label)
+			}
+			if word.Condition != tt.condition {
+				t.Errorf("Condition = %v, want %v", word.Condition, tt.condition)
+			}
+			if word.Warning != tt.warning {
+				t.Errorf("Warning = %v, want %v", word.Warning, tt.warning)
+			}
+		})
+	}
+}
+
+func TestStatusError_Is(t *testing.T) {
+	err := fmt.Errorf("reading file: %w", NewStatusError(&Response{SW1: 0x69, SW2: 0x82}))
+
+	if !errors.Is(err, ErrSecurityStatusNotSatisfied) {
+		t.Error("errors.Is(err, ErrSecurityStatusNotSatisfied) = false, want true")
+	}
+	if errors.Is(err, ErrFileNotFound) {
+		t.Error("errors.Is(err, ErrFileNotFound) = true, want false")
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != StatusSecurityAuthFailed {
+		t.Errorf("errors.As should reach the StatusError, got %v", statusErr)
+	}
+}
+
+// ErrWarning covers the 62xx and 63xx warnings but not the authentication
+// failures, which have conditions of their own
+func TestStatusError_IsWarning(t *testing.T) {
+	tests := []struct {
+		code      uint16
+		condition StatusCondition
+		warning   bool
+	}{
+		{0x6282, ErrWarning, true},
+		{0x6299, ErrWarning, true},
+		{0x6381, ErrWarning, true},
+		{0x6300, ErrAuthenticationFailed, false},
+		{0x63C2, ErrVerificationFailed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%04X", tt.code), func(t *testing.T) {
+			err := NewStatusError(&Response{SW1: byte(tt.code >> 8), SW2: byte(tt.code)})
+			if got := errors.Is(err, ErrWarning); got != tt.warning {
+				t.Errorf("errors.Is(%v, ErrWarning) = %v, want %v", err, got, tt.warning)
+			}
+			if !errors.Is(err, tt.condition) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.condition)
+			}
+		})
+	}
+}
+
+func TestNewCommandError(t *testing.T) {
+	tests := []struct {
+		name      string
+		ins       byte
+		sw1, sw2  byte
+		condition StatusCondition
+		hint      string
+		pin       bool
+		retries   int
+	}{
+		{"wrong PIN", 0x20, 0x63, 0xC2, ErrVerificationFailed, "wrong PIN", true, 2},
+		{"PIN blocked", 0x20, 0x69, 0x83, ErrAuthenticationMethodBlocked, "PIN blocked, unblock it with the PUK", true, 0},
+		{"wrong PUK", 0x2C, 0x63, 0xC9, ErrVerificationFailed, "wrong PUK", true, 9},
+		{"PIN not found", 0x20, 0x6A, 0x88, ErrReferencedDataNotFound, "no PIN with this reference", false, 0},
+		{"SELECT not found", 0xA4, 0x6A, 0x82, ErrFileNotFound, "the application or file does not exist on this card", false, 0},
+		{"READ BINARY security", 0xB0, 0x69, 0x82, ErrSecurityStatusNotSatisfied, "reading this file requires PIN verification or PACE", false, 0},
+		{"no hint", 0xB0, 0x6D, 0x00, ErrInstructionNotSupported, "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewCommandError(&APDU{INS: tt.ins}, &Response{SW1: tt.sw1, SW2: tt.sw2})
+
+			if !errors.Is(err, tt.condition) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.condition)
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("errors.As(%v, *StatusError) = false", err)
+			}
+			if statusErr.INS != tt.ins || statusErr.Hint != tt.hint {
+				t.Errorf("INS, Hint = %02X, %q, want %02X, %q", statusErr.INS, statusErr.Hint, tt.ins, tt.hint)
+			}
+
+			var pinErr *PINError
+			if errors.As(err, &pinErr) != tt.pin {
+				t.Fatalf("errors.As(%v, *PINError) = %v, want %v", err, !tt.pin, tt.pin)
+			}
+			if tt.pin && pinErr.RetriesLeft != tt.retries {
+				t.Errorf("RetriesLeft = %d, want %d", pinErr.RetriesLeft, tt.retries)
+			}
+		})
+	}
+}
+
+func TestPINError_Blocked(t *testing.T) {
+	blocked := NewCommandError(&APDU{INS: 0x20}, &Response{SW1: 0x69, SW2: 0x83}).(*PINError)
+	if !blocked.Blocked() {
+		t.Error("6983 should report a blocked PIN")
+	}
+	wrong := NewCommandError(&APDU{INS: 0x20}, &Response{SW1: 0x63, SW2: 0xC1}).(*PINError)
+	if wrong.Blocked() {
+		t.Error("63C1 should not report a blocked PIN")
+	}
+}
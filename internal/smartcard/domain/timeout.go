@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// DefaultCommandTimeout applies to instructions without a specific timeout
+const DefaultCommandTimeout = 5 * time.Second
+
+// CommandTimeouts holds the time allowed for one command exchange, per
+// instruction. Operations that compute on the card (signatures, key
+// agreement, PIN checks with anti-hammering delays) get more time than
+// SELECT or READ BINARY. A zero duration means no limit.
+type CommandTimeouts struct {
+	Default time.Duration
+	ByINS   map[byte]time.Duration
+}
+
+// DefaultCommandTimeouts returns the timeouts used by SmartCardService
+func DefaultCommandTimeouts() CommandTimeouts {
+	return CommandTimeouts{
+		Default: DefaultCommandTimeout,
+		ByINS: map[byte]time.Duration{
+			0x20: 15 * time.Second, // VERIFY
+			0x24: 15 * time.Second, // CHANGE REFERENCE DATA
+			0x2C: 15 * time.Second, // RESET RETRY COUNTER
+			0x2A: 30 * time.Second, // PERFORM SECURITY OPERATION (signature with PIN2)
+			0x46: 60 * time.Second, // GENERATE ASYMMETRIC KEY PAIR
+			0x86: 15 * time.Second, // GENERAL AUTHENTICATE (PACE)
+			0x88: 15 * time.Second, // INTERNAL AUTHENTICATE
+		},
+	}
+}
+
+// For returns the timeout for an APDU
+func (t CommandTimeouts) For(apdu *APDU) time.Duration {
+	if timeout, ok := t.ByINS[apdu.INS]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// Set overrides the timeout of one instruction
+func (t *CommandTimeouts) Set(ins byte, timeout time.Duration) {
+	if t.ByINS == nil {
+		t.ByINS = make(map[byte]time.Duration)
+	}
+	t.ByINS[ins] = timeout
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommandTimeouts_For(t *testing.T) {
+	timeouts := DefaultCommandTimeouts()
+
+	tests := []struct {
+		name string
+		ins  byte
+		want time.Duration
+	}{
+		{"SELECT uses default", 0xA4, DefaultCommandTimeout},
+		{"READ BINARY uses default", 0xB0, DefaultCommandTimeout},
+		{"VERIFY", 0x20, 15 * time.Second},
+		{"PSO signature", 0x2A, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeouts.For(&APDU{INS: tt.ins}); got != tt.want {
+				t.Errorf("For(INS %02X) = %v, want %v", tt.ins, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandTimeouts_Set(t *testing.T) {
+	var timeouts CommandTimeouts
+	timeouts.Set(0xA4, time.Second)
+
+	if got := timeouts.For(&APDU{INS: 0xA4}); got != time.Second {
+		t.Errorf("For(SELECT) = %v, want 1s", got)
+	}
+	if got := timeouts.For(&APDU{INS: 0xB0}); got != 0 {
+		t.Errorf("For(READ BINARY) = %v, want no limit", got)
+	}
+}
@@ -0,0 +1,112 @@
+package domain
+
+import "fmt"
+
+// TLV is a BER-TLV data object (ISO/IEC 7816-4 §6.3, ISO/IEC 8825-1)
+type TLV struct {
+	Tag    uint32 // Tag bytes big-endian, e.g. 0x7C or 0x5F1F
+	Value  []byte
+	Offset int // Offset of the tag in the parsed data
+	Header int // Length of the tag and length fields
+}
+
+// ValueOffset returns the offset of the value in the parsed data
+func (t TLV) ValueOffset() int {
+	return t.Offset + t.Header
+}
+
+// Constructed reports whether the value is itself a sequence of data objects
+func (t TLV) Constructed() bool {
+	first := t.Tag
+	for first > 0xFF {
+		first >>= 8
+	}
+	return first&0x20 != 0
+}
+
+// Children parses the value of a constructed data object. Offsets are
+// relative to the data the parent was parsed from.
+func (t TLV) Children() ([]TLV, error) {
+	children, err := ParseBERTLV(t.Value)
+	if err != nil {
+		return nil, fmt.Errorf("in tag %X: %w", t.Tag, err)
+	}
+	for i := range children {
+		children[i].Offset += t.ValueOffset()
+	}
+	return children, nil
+}
+
+// FindTLV returns the first data object with tag
+func FindTLV(objects []TLV, tag uint32) (TLV, bool) {
+	for _, object := range objects {
+		if object.Tag == tag {
+			return object, true
+		}
+	}
+	return TLV{}, false
+}
+
+// ParseBERTLV parses a sequence of BER-TLV data objects. Bytes 00 and FF
+// between objects are padding (ISO/IEC 7816-4 §6.3); tags are limited to
+// three bytes and lengths to four bytes, and the indefinite form is refused.
+func ParseBERTLV(data []byte) ([]TLV, error) {
+	var objects []TLV
+	for pos := 0; pos < len(data); {
+		if data[pos] == 0x00 || data[pos] == 0xFF {
+			pos++
+			continue
+		}
+		start := pos
+
+		tag := uint32(data[pos])
+		pos++
+		if tag&0x1F == 0x1F {
+			for {
+				if pos >= len(data) {
+					return nil, fmt.Errorf("truncated tag at offset %d", start)
+				}
+				if pos-start == 3 {
+					return nil, fmt.Errorf("tag longer than 3 bytes at offset %d", start)
+				}
+				tag = tag<<8 | uint32(data[pos])
+				pos++
+				if data[pos-1]&0x80 == 0 {
+					break
+				}
+			}
+		}
+
+		if pos >= len(data) {
+			return nil, fmt.Errorf("missing length of tag %X at offset %d", tag, start)
+		}
+		length := int(data[pos])
+		pos++
+		if length > 0x7F {
+			n := length & 0x7F
+			if n == 0 || n > 4 {
+				return nil, fmt.Errorf("invalid length field %02X of tag %X", length, tag)
+			}
+			if pos+n > len(data) {
+				return nil, fmt.Errorf("truncated length of tag %X", tag)
+			}
+			length = 0
+			for _, b := range data[pos : pos+n] {
+				length = length<<8 | int(b)
+			}
+			pos += n
+		}
+
+		if length < 0 || length > len(data)-pos {
+			return nil, fmt.Errorf("tag %X: length %d exceeds the %d remaining bytes", tag, length, len(data)-pos)
+		}
+		objects = append(objects, TLV{
+			Tag:    tag,
+			Value:  data[pos : pos+length],
+			Offset: start,
+			Header: pos - start,
+		})
+		pos += length
+	}
+	return objects, nil
+}
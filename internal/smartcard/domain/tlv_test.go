@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseBERTLV(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []TLV
+	}{
+		{
+			name: "primitive",
+			data: []byte{0x80, 0x02, 0x01, 0x02},
+			want: []TLV{{Tag: 0x80, Value: []byte{0x01, 0x02}, Offset: 0, Header: 2}},
+		},
+		{
+			name: "two-byte tag and long length",
+			data: append([]byte{0x5F, 0x1F, 0x81, 0x80}, make([]byte, 0x80)...),
+			want: []TLV{{Tag: 0x5F1F, Value: make([]byte, 0x80), Offset: 0, Header: 4}},
+		},
+		{
+			name: "padding between objects",
+			data: []byte{0x00, 0x81, 0x00, 0xFF, 0x82, 0x01, 0x38},
+			want: []TLV{
+				{Tag: 0x81, Value: []byte{}, Offset: 1, Header: 2},
+				{Tag: 0x82, Value: []byte{0x38}, Offset: 4, Header: 2},
+			},
+		},
+		{
+			name: "empty",
+			data: nil,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBERTLV(tt.data)
+			if err != nil {
+				t.Fatalf("ParseBERTLV() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseBERTLV() = %d objects, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.Tag != w.Tag || !bytes.Equal(g.Value, w.Value) || g.Offset != w.Offset || g.Header != w.Header {
+					t.Errorf("object %d = %X %X @%d+%d, want %X %X @%d+%d",
+						i, g.Tag, g.Value, g.Offset, g.Header, w.Tag, w.Value, w.Offset, w.Header)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBERTLV_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"truncated tag", []byte{0x5F}, "truncated tag"},
+		{"tag too long", []byte{0x1F, 0x81, 0x82, 0x03, 0x00}, "longer than 3 bytes"},
+		{"missing length", []byte{0x80}, "missing length"},
+		{"indefinite length", []byte{0x7C, 0x80, 0x00, 0x00}, "invalid length field 80"},
+		{"truncated length", []byte{0x80, 0x82, 0x01}, "truncated length"},
+		{"value overrun", []byte{0x80, 0x03, 0x01}, "exceeds the 1 remaining bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBERTLV(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseBERTLV(%X) error = %v, want %q", tt.data, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLV_Children(t *testing.T) {
+	// GENERAL AUTHENTICATE response: 7C { 80 encrypted nonce }
+	data := []byte{0x7C, 0x04, 0x80, 0x02, 0xAA, 0xBB}
+	objects, err := ParseBERTLV(data)
+	if err != nil {
+		t.Fatalf("ParseBERTLV() error = %v", err)
+	}
+	template, ok := FindTLV(objects, 0x7C)
+	if !ok || !template.Constructed() {
+		t.Fatalf("FindTLV(7C) = %v, %v, want a constructed object", template, ok)
+	}
+
+	children, err := template.Children()
+	if err != nil {
+		t.Fatalf("Children() error = %v", err)
+	}
+	nonce, ok := FindTLV(children, 0x80)
+	if !ok || nonce.Constructed() {
+		t.Fatalf("FindTLV(80) = %v, %v, want a primitive object", nonce, ok)
+	}
+	if got := data[nonce.ValueOffset() : nonce.ValueOffset()+len(nonce.Value)]; !bytes.Equal(got, []byte{0xAA, 0xBB}) {
+		t.Errorf("value at ValueOffset() = %X, want AABB", got)
+	}
+}
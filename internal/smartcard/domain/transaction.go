@@ -0,0 +1,35 @@
+package domain
+
+import "context"
+
+// Transactor is implemented by cards that can lock the reader for exclusive
+// access (PC/SC SCardBeginTransaction/SCardEndTransaction). Transactions nest:
+// only the outermost Begin/End pair reaches the reader.
+type Transactor interface {
+	// BeginTransaction acquires exclusive access to the card, waiting at most
+	// until ctx is done while another application holds it
+	BeginTransaction(ctx context.Context) error
+
+	// EndTransaction releases exclusive access to the card
+	EndTransaction() error
+}
+
+// WithTransaction runs fn with exclusive access to the card so that no other
+// application can interleave APDUs. Cards without transaction support run fn
+// directly. An error from fn takes precedence over an error ending the
+// transaction.
+func WithTransaction(ctx context.Context, card Card, fn func(Card) error) error {
+	transactor, ok := card.(Transactor)
+	if !ok {
+		return fn(card)
+	}
+
+	if err := transactor.BeginTransaction(ctx); err != nil {
+		return err
+	}
+	err := fn(card)
+	if endErr := transactor.EndTransaction(); err == nil {
+		err = endErr
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// transactionCard records transaction calls
+type transactionCard struct {
+	calls    []string
+	beginErr error
+	endErr   error
+}
+
+func (c *transactionCard) Transmit(ctx context.Context, apdu *APDU) (*Response, error) {
+	c.calls = append(c.calls, "transmit")
+	return NewResponse([]byte{0x90, 0x00}), nil
+}
+
+func (c *transactionCard) Disconnect() error            { return nil }
+func (c *transactionCard) Status() (*CardStatus, error) { return &CardStatus{}, nil }
+
+func (c *transactionCard) BeginTransaction(ctx context.Context) error {
+	c.calls = append(c.calls, "begin")
+	return c.beginErr
+}
+
+func (c *transactionCard) EndTransaction() error {
+	c.calls = append(c.calls, "end")
+	return c.endErr
+}
+
+func TestWithTransaction(t *testing.T) {
+	errFn := errors.New("fn failed")
+	errBegin := errors.New("begin failed")
+	errEnd := errors.New("end failed")
+
+	tests := []struct {
+		name      string
+		beginErr  error
+		endErr    error
+		fnErr     error
+		wantErr   error
+		wantCalls []string
+	}{
+		{"success", nil, nil, nil, nil, []string{"begin", "transmit", "end"}},
+		{"fn error", nil, nil, errFn, errFn, []string{"begin", "transmit", "end"}},
+		{"begin error", errBegin, nil, nil, errBegin, []string{"begin"}},
+		{"end error", nil, errEnd, nil, errEnd, []string{"begin", "transmit", "end"}},
+		{"fn error wins over end error", nil, errEnd, errFn, errFn, []string{"begin", "transmit", "end"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &transactionCard{beginErr: tt.beginErr, endErr: tt.endErr}
+			err := WithTransaction(context.Background(), card, func(c Card) error {
+				c.Transmit(context.Background(), &APDU{INS: 0x20})
+				return tt.fnErr
+			})
+			if err != tt.wantErr {
+				t.Errorf("WithTransaction() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(card.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", card.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithTransaction_NoTransactor(t *testing.T) {
+	inner := &transactionCard{}
+	card := struct{ Card }{inner} // Hides the Transactor methods
+	err := WithTransaction(context.Background(), card, func(c Card) error {
+		c.Transmit(context.Background(), &APDU{INS: 0x20})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+	if !reflect.DeepEqual(inner.calls, []string{"transmit"}) {
+		t.Errorf("calls = %v, want fn run without a transaction", inner.calls)
+	}
+}
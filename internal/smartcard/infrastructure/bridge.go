@@ -0,0 +1,202 @@
+package infrastructure
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// Card bridge protocol: JSON over HTTP/2 with mutual TLS. A BridgeServer
+// exposes one local connector; a RemoteTransport drives it from another
+// machine. Requests after /connect carry the session in bridgeSessionHeader.
+const (
+	bridgeSessionHeader = "Roeid-Session"
+
+	bridgePathConnect     = "/v1/connect"
+	bridgePathDisconnect  = "/v1/disconnect"
+	bridgePathTransmit    = "/v1/transmit"
+	bridgePathBegin       = "/v1/transaction/begin"
+	bridgePathEnd         = "/v1/transaction/end"
+	bridgePathReconnect   = "/v1/reconnect"
+	bridgePathFeatures    = "/v1/features"
+	bridgePathControl     = "/v1/control"
+	bridgePathEvents      = "/v1/events"
+	bridgeContentTypeJSON = "application/json"
+
+	// bridgeMaxRequestSize bounds request bodies: the hex data of the largest
+	// extended command APDU plus room for the other JSON fields
+	bridgeMaxRequestSize = 2*domain.MaxExtendedLc + 1024
+)
+
+// bridgeStatus is the wire form of domain.CardStatus
+type bridgeStatus struct {
+	Session  string `json:"session,omitempty"` // Set in the /connect response
+	ATR      string `json:"atr"`               // Hex
+	Protocol string `json:"protocol"`
+	Reader   string `json:"reader"`
+}
+
+// bridgeCommand is the wire form of a command APDU
+type bridgeCommand struct {
+	CLA  byte   `json:"cla"`
+	INS  byte   `json:"ins"`
+	P1   byte   `json:"p1"`
+	P2   byte   `json:"p2"`
+	Data string `json:"data,omitempty"` // Hex
+	Le   int    `json:"le,omitempty"`   // Ne, as in domain.APDU
+	// Extended length fields forced, as in domain.APDU
+	Extended bool `json:"extended,omitempty"`
+}
+
+// bridgeResponse is the wire form of a response APDU
+type bridgeResponse struct {
+	Data string `json:"data,omitempty"` // Hex
+	SW1  byte   `json:"sw1"`
+	SW2  byte   `json:"sw2"`
+}
+
+// bridgeReconnect selects the reset mode of /reconnect
+type bridgeReconnect struct {
+	Mode domain.ResetMode `json:"mode"`
+}
+
+// bridgeFeatures is the wire form of domain.ReaderFeatures
+type bridgeFeatures struct {
+	Features domain.ReaderFeatures `json:"features"`
+}
+
+// bridgeControl is a reader control command (input) or its output
+type bridgeControl struct {
+	Code uint32 `json:"code,omitempty"` // Control code, only in requests
+	Data string `json:"data,omitempty"` // Hex
+}
+
+// bridgeEvent is the wire form of domain.ReaderEvent (one JSON object per line)
+type bridgeEvent struct {
+	Type   domain.ReaderEventType `json:"type"`
+	Reader string                 `json:"reader"`
+	ATR    string                 `json:"atr,omitempty"`
+	Time   time.Time              `json:"time"`
+}
+
+// bridgeError is the body of failed requests; Code 0 means not a transport error
+type bridgeError struct {
+	Code     domain.TransportErrorCode `json:"code,omitempty"`
+	Message  string                    `json:"message"`
+	PCSCCode uint32                    `json:"pcscCode,omitempty"`
+}
+
+// newBridgeCommand converts a command APDU to its wire form
+func newBridgeCommand(apdu *domain.APDU) bridgeCommand {
+	return bridgeCommand{
+		CLA:      apdu.CLA,
+		INS:      apdu.INS,
+		P1:       apdu.P1,
+		P2:       apdu.P2,
+		Data:     hex.EncodeToString(apdu.Data),
+		Le:       apdu.Le,
+		Extended: apdu.Extended,
+	}
+}
+
+// toDomain converts the wire command back to a validated APDU
+func (c *bridgeCommand) toDomain() (*domain.APDU, error) {
+	data, err := hex.DecodeString(c.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APDU data: %w", err)
+	}
+	apdu := &domain.APDU{CLA: c.CLA, INS: c.INS, P1: c.P1, P2: c.P2, Le: c.Le, Extended: c.Extended}
+	if len(data) > 0 {
+		apdu.Data = data
+	}
+	if err := apdu.Validate(); err != nil {
+		return nil, err
+	}
+	return apdu, nil
+}
+
+// newBridgeResponse converts a response APDU to its wire form
+func newBridgeResponse(resp *domain.Response) bridgeResponse {
+	return bridgeResponse{Data: hex.EncodeToString(resp.Data), SW1: resp.SW1, SW2: resp.SW2}
+}
+
+// toDomain converts the wire response back to a Response
+func (r *bridgeResponse) toDomain() (*domain.Response, error) {
+	data, err := hex.DecodeString(r.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response data from bridge: %w", err)
+	}
+	return &domain.Response{Data: data, SW1: r.SW1, SW2: r.SW2}, nil
+}
+
+// toDomain converts the wire status to a decoded CardStatus
+func (s *bridgeStatus) toDomain() (*domain.CardStatus, error) {
+	atr, err := hex.DecodeString(s.ATR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATR from bridge: %w", err)
+	}
+	return domain.NewCardStatus(atr, s.Protocol, s.Reader), nil
+}
+
+// newBridgeEvent converts a reader event to its wire form
+func newBridgeEvent(event domain.ReaderEvent) bridgeEvent {
+	return bridgeEvent{Type: event.Type, Reader: event.Reader, ATR: hex.EncodeToString(event.ATR), Time: event.Time}
+}
+
+// toDomain converts the wire event back to a reader event
+func (e *bridgeEvent) toDomain() (domain.ReaderEvent, error) {
+	atr, err := hex.DecodeString(e.ATR)
+	if err != nil {
+		return domain.ReaderEvent{}, fmt.Errorf("invalid ATR in bridge event: %w", err)
+	}
+	if len(atr) == 0 {
+		atr = nil
+	}
+	return domain.ReaderEvent{Type: e.Type, Reader: e.Reader, ATR: atr, Time: e.Time}, nil
+}
+
+// toDomain rebuilds the error so errors.Is and Recovery work on the client
+func (e *bridgeError) toDomain() error {
+	if e.Code == 0 {
+		return domain.NewTransportError(domain.ErrTransmissionFailed,
+			"card bridge: "+e.Message, nil)
+	}
+	return &domain.TransportError{Code: e.Code, Message: e.Message, PCSCCode: e.PCSCCode}
+}
+
+// NewBridgeTLSConfig loads this end's certificate and the CA that issues the
+// peer certificates. A server config requires and verifies client
+// certificates, so only terminals holding a certificate from caFile may use
+// the reader.
+func NewBridgeTLSConfig(certFile, keyFile, caFile string, server bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bridge certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bridge CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates in bridge CA file %s", caFile)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+		NextProtos:   []string{"h2"},
+	}
+	if server {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = pool
+	} else {
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,430 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// bridgePKI holds PEM files for a test CA, server and client certificate
+type bridgePKI struct {
+	dir string
+	ca  *x509.Certificate
+	key *ecdsa.PrivateKey
+}
+
+// newBridgePKI creates a CA in a temporary directory
+func newBridgePKI(t *testing.T) *bridgePKI {
+	t.Helper()
+	pki := &bridgePKI{dir: t.TempDir()}
+	pki.key, pki.ca = pki.issue(t, "ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Bridge test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return pki
+}
+
+// issue signs a certificate (self-signed when the CA does not exist yet)
+// and writes <name>.pem and <name>-key.pem
+func (p *bridgePKI) issue(t *testing.T, name string, template *x509.Certificate) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Minute)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := template, key
+	if p.ca != nil {
+		parent, signer = p.ca, p.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	writePEM(t, filepath.Join(p.dir, name+".pem"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(p.dir, name+"-key.pem"), "EC PRIVATE KEY", keyDER)
+	return key, cert
+}
+
+// config issues a certificate and loads it with NewBridgeTLSConfig
+func (p *bridgePKI) config(t *testing.T, name string, server bool) *tls.Config {
+	t.Helper()
+	template := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+	if server {
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	p.issue(t, name, template)
+
+	config, err := NewBridgeTLSConfig(filepath.Join(p.dir, name+".pem"),
+		filepath.Join(p.dir, name+"-key.pem"), filepath.Join(p.dir, "ca.pem"), server)
+	if err != nil {
+		t.Fatalf("NewBridgeTLSConfig() error = %v", err)
+	}
+	return config
+}
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startBridge serves connector over HTTP/2 with mTLS and returns a client
+func startBridge(t *testing.T, connector domain.Connector) (*BridgeServer, *httptest.Server, *RemoteTransport) {
+	t.Helper()
+	pki := newBridgePKI(t)
+	bridge := NewBridgeServer(connector)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "HTTP/2 required", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		bridge.Handler().ServeHTTP(w, r)
+	}))
+	server.EnableHTTP2 = true
+	server.TLS = pki.config(t, "kiosk", true)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return bridge, server, NewRemoteTransport(server.URL, pki.config(t, "terminal", false))
+}
+
+func TestRemoteTransport_Simulator(t *testing.T) {
+	sim, err := LoadCardSimulator("testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	_, _, remote := startBridge(t, sim)
+	ctx := context.Background()
+
+	card, err := remote.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer card.Disconnect()
+
+	status, err := card.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Reader != DefaultSimulatorReader || status.ParsedATR == nil {
+		t.Errorf("Status() = %+v, want the decoded simulator status", status)
+	}
+
+	resp, err := card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x04, Data: simulatorCEIAID, Le: domain.MaxShortLe})
+	if err != nil {
+		t.Fatalf("Transmit(SELECT) error = %v", err)
+	}
+	if !resp.IsSuccess() || len(resp.Data) == 0 {
+		t.Errorf("SELECT = %02X %02X%02X, want FCP and 9000", resp.Data, resp.SW1, resp.SW2)
+	}
+
+	// Security state lives on the serving side across requests
+	card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")})
+	resp, _ = card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x81, Le: 0x07})
+	if string(resp.Data) != "SURNAME" {
+		t.Errorf("READ BINARY = %q, want SURNAME", resp.Data)
+	}
+
+	// A warm reset through the bridge clears it
+	if err := remote.Reconnect(ctx, domain.ResetWarm); err != nil {
+		t.Fatalf("Reconnect() error = %v", err)
+	}
+	resp, _ = card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01})
+	if resp.StatusCode() != 0x63C3 {
+		t.Errorf("VERIFY status after reset = %04X, want 63C3", resp.StatusCode())
+	}
+}
+
+func TestRemoteTransport_TransactionsAndErrors(t *testing.T) {
+	scripted := NewScriptedCard().
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x01}, []byte{0x42}, 0x9000).
+		ExpectError([]byte{0x00, 0xB0, 0x00, 0x01, 0x01},
+			&domain.TransportError{Code: domain.ErrCardRemoved, Message: "card removed", PCSCCode: 0x80100069})
+	_, _, remote := startBridge(t, scripted)
+	ctx := context.Background()
+
+	card, err := remote.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	err = domain.WithTransaction(ctx, card, func(card domain.Card) error {
+		_, err := card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 1})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+	if scripted.Transactions() != 1 {
+		t.Errorf("Transactions() = %d, want 1 on the serving card", scripted.Transactions())
+	}
+
+	_, err = card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xB0, P2: 0x01, Le: 1})
+	var transportErr *domain.TransportError
+	if !errors.Is(err, domain.ErrCardRemoved) || !errors.As(err, &transportErr) || transportErr.PCSCCode != 0x80100069 {
+		t.Errorf("Transmit() error = %v, want ErrCardRemoved with its PC/SC code", err)
+	}
+
+	if err := card.Disconnect(); err != nil {
+		t.Errorf("Disconnect() error = %v", err)
+	}
+	if err := scripted.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBridgeServer_RejectsOversizedRequest(t *testing.T) {
+	_, server, remote := startBridge(t, NewScriptedCard())
+
+	body := `{"data":"` + strings.Repeat("00", bridgeMaxRequestSize) + `"}`
+	resp, err := remote.client.Post(server.URL+bridgePathTransmit, bridgeContentTypeJSON, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized /transmit status = %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// stallingCard answers a command only once released, recording whether the
+// context of the exchange was cancelled meanwhile
+type stallingCard struct {
+	started  chan struct{}
+	release  chan struct{}
+	finished chan error
+}
+
+func (c *stallingCard) Connect(ctx context.Context) (domain.Card, error) { return c, nil }
+func (c *stallingCard) Disconnect() error                                { return nil }
+func (c *stallingCard) Status() (*domain.CardStatus, error)              { return &domain.CardStatus{}, nil }
+
+func (c *stallingCard) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	close(c.started)
+	select {
+	case <-c.release:
+		c.finished <- ctx.Err()
+		return &domain.Response{SW1: 0x90}, nil
+	case <-ctx.Done():
+		c.finished <- ctx.Err()
+		return nil, domain.NewTimeoutError(ctx.Err())
+	}
+}
+
+func TestBridgeServer_ClientDisconnectKeepsCardExchange(t *testing.T) {
+	card := &stallingCard{started: make(chan struct{}), release: make(chan struct{}), finished: make(chan error, 1)}
+	_, _, remote := startBridge(t, card)
+
+	if _, err := remote.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-card.started
+		cancel()
+	}()
+	if _, err := remote.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Transmit() error = %v, want context.Canceled", err)
+	}
+
+	// The card exchange outlives the dropped request and completes normally
+	close(card.release)
+	if err := <-card.finished; err != nil {
+		t.Errorf("card exchange context error = %v, want none after the client left", err)
+	}
+}
+
+// watchedCard reports when the bridge disconnects it
+type watchedCard struct {
+	*ScriptedCard
+	disconnected chan struct{}
+}
+
+func (c *watchedCard) Connect(ctx context.Context) (domain.Card, error) {
+	if _, err := c.ScriptedCard.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *watchedCard) Disconnect() error {
+	close(c.disconnected)
+	return c.ScriptedCard.Disconnect()
+}
+
+func TestBridgeServer_ClosesIdleSession(t *testing.T) {
+	card := &watchedCard{ScriptedCard: NewScriptedCard(), disconnected: make(chan struct{})}
+	bridge, _, remote := startBridge(t, card)
+	bridge.SetIdleTimeout(50 * time.Millisecond)
+	ctx := context.Background()
+
+	// A terminal that vanishes inside a transaction must not keep the reader
+	if _, err := remote.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if err := remote.BeginTransaction(ctx); err != nil {
+		t.Fatalf("BeginTransaction() error = %v", err)
+	}
+	select {
+	case <-card.disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle session was not closed")
+	}
+
+	_, err := remote.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x84, Le: 8})
+	if !errors.Is(err, domain.ErrConnectionLost) {
+		t.Errorf("Transmit() on an expired session error = %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestRemoteTransport_ReaderControl(t *testing.T) {
+	const verifyPINDirect = 0x42330006
+	structure := []byte{0x1E, 0x1E, 0x02, 0x47, 0x04}
+	scripted := NewScriptedCard().ExpectControl(verifyPINDirect, structure, []byte{0x90, 0x00})
+	scripted.SetReaderFeatures(domain.ReaderFeatures{domain.FeatureVerifyPINDirect: verifyPINDirect})
+	_, _, remote := startBridge(t, scripted)
+	ctx := context.Background()
+
+	card, err := remote.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	controller, ok := card.(domain.Controller)
+	if !ok {
+		t.Fatal("remote card should accept control commands")
+	}
+	features, err := controller.ReaderFeatures(ctx)
+	if err != nil || !features.PINPad() {
+		t.Fatalf("ReaderFeatures() = %v, %v, want the remote PIN pad", features, err)
+	}
+	out, err := controller.Control(ctx, verifyPINDirect, structure)
+	if err != nil || !bytes.Equal(out, []byte{0x90, 0x00}) {
+		t.Errorf("Control() = %X, %v, want 9000", out, err)
+	}
+	if err := scripted.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRemoteTransport_ReaderControlUnsupported(t *testing.T) {
+	sim, err := LoadCardSimulator("testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	_, _, remote := startBridge(t, sim)
+	ctx := context.Background()
+
+	if _, err := remote.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	features, err := remote.ReaderFeatures(ctx)
+	if err != nil || len(features) != 0 {
+		t.Errorf("ReaderFeatures() = %v, %v, want none for the simulator", features, err)
+	}
+	if _, err := remote.Control(ctx, 0x42330006, nil); !errors.Is(err, domain.ErrTransmissionFailed) {
+		t.Errorf("Control() error = %v, want ErrTransmissionFailed", err)
+	}
+}
+
+func TestRemoteTransport_SessionTakeover(t *testing.T) {
+	sim, err := LoadCardSimulator("testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	bridge, server, first := startBridge(t, sim)
+	second := NewRemoteTransport(server.URL, first.client.Transport.(*http.Transport).TLSClientConfig)
+	ctx := context.Background()
+
+	if _, err := first.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := second.Connect(ctx); !errors.Is(err, domain.ErrReaderBusy) {
+		t.Fatalf("second Connect() error = %v, want ErrReaderBusy", err)
+	}
+
+	// Once the first session is idle, the second terminal takes the card
+	bridge.SetIdleTimeout(0)
+	if _, err := second.Connect(ctx); err != nil {
+		t.Fatalf("second Connect() after idle error = %v", err)
+	}
+	_, err = first.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x84, Le: 8})
+	if !errors.Is(err, domain.ErrConnectionLost) {
+		t.Errorf("Transmit() on a taken-over session error = %v, want ErrConnectionLost", err)
+	}
+	if err := first.Reconnect(ctx, domain.ResetLeave); err != nil {
+		t.Errorf("Reconnect() after takeover error = %v, want a new session", err)
+	}
+}
+
+func TestRemoteTransport_Events(t *testing.T) {
+	bridge, _, remote := startBridge(t, NewScriptedCard())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := remote.Events(ctx)
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+
+	source := make(chan domain.ReaderEvent)
+	go bridge.Publish(source)
+	source <- domain.ReaderEvent{Type: domain.EventCardInserted, Reader: "Kiosk Reader", ATR: []byte{0x3B, 0x00}, Time: time.Now()}
+	close(source)
+
+	select {
+	case event := <-events:
+		if event.Type != domain.EventCardInserted || event.Reader != "Kiosk Reader" || len(event.ATR) != 2 {
+			t.Errorf("event = %v, want card inserted in Kiosk Reader", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	if _, ok := <-events; ok {
+		t.Error("event stream should close when the server stops publishing")
+	}
+}
+
+func TestRemoteTransport_RequiresClientCertificate(t *testing.T) {
+	_, server, authenticated := startBridge(t, NewScriptedCard())
+
+	// Trusts the server but presents no certificate
+	config := authenticated.client.Transport.(*http.Transport).TLSClientConfig.Clone()
+	config.Certificates = nil
+	remote := NewRemoteTransport(server.URL, config)
+
+	if _, err := remote.Connect(context.Background()); !errors.Is(err, domain.ErrConnectionLost) {
+		t.Errorf("Connect() without client certificate error = %v, want ErrConnectionLost", err)
+	}
+}
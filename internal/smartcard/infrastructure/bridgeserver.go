@@ -0,0 +1,533 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// DefaultBridgeIdleTimeout is how long an idle remote session keeps the card
+// before another terminal may take it over
+const DefaultBridgeIdleTimeout = 2 * time.Minute
+
+// bridgeControlTimeout bounds a reader control command. PIN pad entry waits
+// for the user; readers give up on their own well before.
+const bridgeControlTimeout = 2 * time.Minute
+
+// bridgeReadHeaderTimeout bounds how long a client may take to send the
+// headers of a request
+const bridgeReadHeaderTimeout = 10 * time.Second
+
+// BridgeServer exposes a local connector (PC/SC reader or simulator) to one
+// RemoteTransport at a time over HTTP/2 with mutual TLS. Transactions opened
+// by the remote end are held until it ends them, disconnects, or its session
+// is closed after the idle timeout. Card operations are bounded by the
+// server's command timeouts rather than by the HTTP request, so a remote end
+// dropping its connection mid-exchange does not cancel (and reset) the card.
+// Reader events fed to Publish are streamed to every remote end listening on
+// /v1/events.
+type BridgeServer struct {
+	connector   domain.Connector
+	logger      *APDULogger
+	idleTimeout time.Duration
+	timeouts    domain.CommandTimeouts
+
+	mu        sync.Mutex // Serializes card access
+	card      domain.Card
+	session   string
+	lastSeen  time.Time
+	idleTimer *time.Timer // Closes the session once idle
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan domain.ReaderEvent]struct{}
+	eventsClosed  bool
+}
+
+// NewBridgeServer creates a bridge serving connector
+func NewBridgeServer(connector domain.Connector) *BridgeServer {
+	return &BridgeServer{
+		connector:   connector,
+		idleTimeout: DefaultBridgeIdleTimeout,
+		timeouts:    domain.DefaultCommandTimeouts(),
+		subscribers: make(map[chan domain.ReaderEvent]struct{}),
+	}
+}
+
+// SetLogger sets the logger for remote session events
+func (b *BridgeServer) SetLogger(logger *APDULogger) {
+	b.logger = logger
+}
+
+// SetIdleTimeout sets how long an idle session keeps the card, and its
+// transactions, before it is closed. A zero timeout lets another terminal
+// take the card at any time and never closes sessions on its own.
+func (b *BridgeServer) SetIdleTimeout(timeout time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.idleTimeout = timeout
+}
+
+// SetCommandTimeout sets the time allowed for one exchange of an instruction.
+// A zero timeout means no limit.
+func (b *BridgeServer) SetCommandTimeout(ins byte, timeout time.Duration) {
+	b.timeouts.Set(ins, timeout)
+}
+
+// Handler returns the HTTP handler of the bridge protocol. It relies on the
+// TLS layer for authentication; serve it with a NewBridgeTLSConfig server config.
+func (b *BridgeServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+bridgePathConnect, b.handleConnect)
+	mux.HandleFunc("POST "+bridgePathDisconnect, b.handleDisconnect)
+	mux.HandleFunc("POST "+bridgePathTransmit, b.handleTransmit)
+	mux.HandleFunc("POST "+bridgePathBegin, b.handleBegin)
+	mux.HandleFunc("POST "+bridgePathEnd, b.handleEnd)
+	mux.HandleFunc("POST "+bridgePathReconnect, b.handleReconnect)
+	mux.HandleFunc("POST "+bridgePathFeatures, b.handleFeatures)
+	mux.HandleFunc("POST "+bridgePathControl, b.handleControl)
+	mux.HandleFunc("GET "+bridgePathEvents, b.handleEvents)
+	return mux
+}
+
+// Serve accepts bridge connections on listener until ctx is done
+func (b *BridgeServer) Serve(ctx context.Context, listener net.Listener, tlsConfig *tls.Config) error {
+	server := &http.Server{
+		Handler:           b.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: bridgeReadHeaderTimeout,
+		IdleTimeout:       DefaultBridgeIdleTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	err := server.ServeTLS(listener, "", "")
+	b.mu.Lock()
+	b.closeSession("bridge stopped")
+	b.mu.Unlock()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Publish streams events (e.g. from a CardMonitor) to remote listeners until
+// the channel is closed
+func (b *BridgeServer) Publish(events <-chan domain.ReaderEvent) {
+	for event := range events {
+		b.subscribersMu.Lock()
+		for subscriber := range b.subscribers {
+			select {
+			case subscriber <- event:
+			default:
+				// A listener that stopped reading must not stall the others
+			}
+		}
+		b.subscribersMu.Unlock()
+	}
+
+	b.subscribersMu.Lock()
+	defer b.subscribersMu.Unlock()
+	for subscriber := range b.subscribers {
+		close(subscriber)
+		delete(b.subscribers, subscriber)
+	}
+	b.eventsClosed = true
+}
+
+// handleConnect opens the session, refusing while another one is active
+func (b *BridgeServer) handleConnect(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.card != nil {
+		if time.Since(b.lastSeen) < b.idleTimeout {
+			writeBridgeError(w, http.StatusConflict, domain.NewTransportError(domain.ErrReaderBusy,
+				"card bridge in use by another terminal", nil))
+			return
+		}
+		b.closeSession("idle session taken over")
+	}
+
+	ctx, cancel := b.cardContext(b.timeouts.Default)
+	defer cancel()
+	card, err := b.connector.Connect(ctx)
+	if err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	status, err := card.Status()
+	if err != nil {
+		card.Disconnect()
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	session := make([]byte, 16)
+	if _, err := rand.Read(session); err != nil {
+		card.Disconnect()
+		writeBridgeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	b.card = card
+	b.session = hex.EncodeToString(session)
+	b.touch()
+	if b.logger != nil {
+		b.logger.LogInfo("Bridge session opened by %s", bridgePeer(r))
+	}
+
+	writeBridgeJSON(w, newBridgeStatus(b.session, status))
+}
+
+// handleDisconnect closes the session
+func (b *BridgeServer) handleDisconnect(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sessionCard(w, r) == nil {
+		return
+	}
+	if err := b.closeSession("closed by " + bridgePeer(r)); err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTransmit forwards one command within the timeout of its instruction
+func (b *BridgeServer) handleTransmit(w http.ResponseWriter, r *http.Request) {
+	var command bridgeCommand
+	if !decodeBridgeRequest(w, r, &command) {
+		return
+	}
+	apdu, err := command.toDomain()
+	if err != nil {
+		writeBridgeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	card := b.sessionCard(w, r)
+	if card == nil {
+		return
+	}
+	// Idle time counts from the end of the card operation
+	defer b.touch()
+	ctx, cancel := b.cardContext(b.timeouts.For(apdu))
+	defer cancel()
+	resp, err := card.Transmit(ctx, apdu)
+	if err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeBridgeJSON(w, newBridgeResponse(resp))
+}
+
+// handleBegin starts a transaction on the card
+func (b *BridgeServer) handleBegin(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	card := b.sessionCard(w, r)
+	if card == nil {
+		return
+	}
+	// Idle time counts from the end of the card operation
+	defer b.touch()
+	if transactor, ok := card.(domain.Transactor); ok {
+		ctx, cancel := b.cardContext(b.timeouts.Default)
+		defer cancel()
+		if err := transactor.BeginTransaction(ctx); err != nil {
+			writeBridgeError(w, http.StatusBadGateway, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEnd ends the innermost transaction
+func (b *BridgeServer) handleEnd(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	card := b.sessionCard(w, r)
+	if card == nil {
+		return
+	}
+	if transactor, ok := card.(domain.Transactor); ok {
+		if err := transactor.EndTransaction(); err != nil {
+			writeBridgeError(w, http.StatusBadGateway, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReconnect re-establishes the card session and returns the new status
+func (b *BridgeServer) handleReconnect(w http.ResponseWriter, r *http.Request) {
+	var request bridgeReconnect
+	if !decodeBridgeRequest(w, r, &request) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	card := b.sessionCard(w, r)
+	if card == nil {
+		return
+	}
+	// Idle time counts from the end of the card operation
+	defer b.touch()
+	reconnector, ok := card.(domain.Reconnector)
+	if !ok {
+		writeBridgeError(w, http.StatusNotImplemented, domain.NewTransportError(domain.ErrConnectionLost,
+			"card does not support reconnecting", nil))
+		return
+	}
+	ctx, cancel := b.cardContext(b.timeouts.Default)
+	defer cancel()
+	if err := reconnector.Reconnect(ctx, request.Mode); err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	status, err := card.Status()
+	if err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeBridgeJSON(w, newBridgeStatus("", status))
+}
+
+// handleFeatures returns the PC/SC part 10 features of the reader, empty
+// when the served card does not accept control commands
+func (b *BridgeServer) handleFeatures(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	card := b.sessionCard(w, r)
+	if card == nil {
+		return
+	}
+	features := domain.ReaderFeatures{}
+	if controller, ok := card.(domain.Controller); ok {
+		ctx, cancel := b.cardContext(b.timeouts.Default)
+		defer cancel()
+		var err error
+		if features, err = controller.ReaderFeatures(ctx); err != nil {
+			writeBridgeError(w, http.StatusBadGateway, err)
+			return
+		}
+	}
+	writeBridgeJSON(w, bridgeFeatures{Features: features})
+}
+
+// handleControl forwards a reader control command, such as PIN pad entry
+func (b *BridgeServer) handleControl(w http.ResponseWriter, r *http.Request) {
+	var command bridgeControl
+	if !decodeBridgeRequest(w, r, &command) {
+		return
+	}
+	in, err := hex.DecodeString(command.Data)
+	if err != nil {
+		writeBridgeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	card := b.sessionCard(w, r)
+	if card == nil {
+		return
+	}
+	// Idle time counts from the end of the card operation
+	defer b.touch()
+	controller, ok := card.(domain.Controller)
+	if !ok {
+		writeBridgeError(w, http.StatusNotImplemented, errNoReaderControl())
+		return
+	}
+	ctx, cancel := b.cardContext(bridgeControlTimeout)
+	defer cancel()
+	out, err := controller.Control(ctx, command.Code, in)
+	if err != nil {
+		writeBridgeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeBridgeJSON(w, bridgeControl{Data: hex.EncodeToString(out)})
+}
+
+// handleEvents streams reader events as JSON lines until the client leaves
+func (b *BridgeServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	events := make(chan domain.ReaderEvent, 16)
+	b.subscribersMu.Lock()
+	if b.eventsClosed {
+		b.subscribersMu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	b.subscribers[events] = struct{}{}
+	b.subscribersMu.Unlock()
+
+	defer func() {
+		b.subscribersMu.Lock()
+		delete(b.subscribers, events)
+		b.subscribersMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	controller.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if encoder.Encode(newBridgeEvent(event)) != nil || controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// cardContext bounds one card operation by timeout (zero means no limit),
+// independently of the HTTP request that asked for it
+func (b *BridgeServer) cardContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// decodeBridgeRequest decodes a size-limited JSON request body, or writes an
+// error and returns false
+func decodeBridgeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, bridgeMaxRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeBridgeError(w, status, err)
+		return false
+	}
+	return true
+}
+
+// sessionCard returns the card of the request's session, or writes an
+// ErrConnectionLost error when the session is unknown. Callers hold b.mu.
+func (b *BridgeServer) sessionCard(w http.ResponseWriter, r *http.Request) domain.Card {
+	session := r.Header.Get(bridgeSessionHeader)
+	if b.card == nil || subtle.ConstantTimeCompare([]byte(session), []byte(b.session)) != 1 {
+		writeBridgeError(w, http.StatusGone, domain.NewTransportError(domain.ErrConnectionLost,
+			"card bridge session expired", nil))
+		return nil
+	}
+	b.touch()
+	return b.card
+}
+
+// touch marks the session as used and (re)arms its idle timer. Callers hold b.mu.
+func (b *BridgeServer) touch() {
+	b.lastSeen = time.Now()
+	if b.idleTimeout <= 0 {
+		return
+	}
+	if b.idleTimer == nil {
+		b.idleTimer = time.AfterFunc(b.idleTimeout, b.expireIdle)
+	} else {
+		b.idleTimer.Reset(b.idleTimeout)
+	}
+}
+
+// expireIdle closes the session when it has not been used for the idle
+// timeout, so a remote end that vanished mid-transaction releases the reader
+func (b *BridgeServer) expireIdle() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.card == nil || b.idleTimeout <= 0 {
+		return
+	}
+	// The timer may fire while a long exchange held the lock
+	if remaining := b.idleTimeout - time.Since(b.lastSeen); remaining > 0 {
+		b.idleTimer.Reset(remaining)
+		return
+	}
+	b.closeSession("idle")
+}
+
+// closeSession disconnects the card (ending its transactions). Callers hold b.mu.
+func (b *BridgeServer) closeSession(reason string) error {
+	if b.card == nil {
+		return nil
+	}
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+	}
+	err := b.card.Disconnect()
+	b.card = nil
+	b.session = ""
+	if b.logger != nil {
+		b.logger.LogInfo("Bridge session ended: %s", reason)
+	}
+	return err
+}
+
+// bridgePeer names the remote end by its certificate subject and address
+func bridgePeer(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0].Subject.CommonName + " (" + r.RemoteAddr + ")"
+	}
+	return r.RemoteAddr
+}
+
+// newBridgeStatus converts a card status to its wire form
+func newBridgeStatus(session string, status *domain.CardStatus) bridgeStatus {
+	return bridgeStatus{
+		Session:  session,
+		ATR:      hex.EncodeToString(status.ATR),
+		Protocol: status.ActiveProtocol,
+		Reader:   status.Reader,
+	}
+}
+
+// writeBridgeJSON writes a successful JSON response
+func writeBridgeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", bridgeContentTypeJSON)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeBridgeError writes a failed response, keeping the transport error code
+func writeBridgeError(w http.ResponseWriter, status int, err error) {
+	body := bridgeError{Message: err.Error()}
+	var transportErr *domain.TransportError
+	if errors.As(err, &transportErr) {
+		body.Code = transportErr.Code
+		body.PCSCCode = transportErr.PCSCCode
+	}
+	w.Header().Set("Content-Type", bridgeContentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
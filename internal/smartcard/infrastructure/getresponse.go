@@ -0,0 +1,87 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// maxGetResponseRounds bounds the GET RESPONSE loop against misbehaving cards
+const maxGetResponseRounds = 256
+
+// transmitFunc sends one APDU without response post-processing
+type transmitFunc func(ctx context.Context, apdu *domain.APDU) (*domain.Response, error)
+
+// ResponseCollector decorates a Card with automatic T=0 response handling:
+// 6Cxx re-issues the command with the corrected Le and 61xx is followed by
+// GET RESPONSE until all data has been collected. Every intermediate
+// exchange goes through the wrapped card, so it still shows in its log.
+type ResponseCollector struct {
+	domain.Card
+}
+
+// NewResponseCollector wraps a card with GET RESPONSE/6Cxx handling
+func NewResponseCollector(card domain.Card) *ResponseCollector {
+	return &ResponseCollector{Card: card}
+}
+
+// Transmit sends an APDU and returns the fully assembled response
+func (c *ResponseCollector) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	return collectResponse(ctx, c.Card.Transmit, apdu)
+}
+
+// collectResponse runs the GET RESPONSE/6Cxx pipeline (ISO/IEC 7816-3 §10.3.4, 7816-4 §5.3)
+func collectResponse(ctx context.Context, transmit transmitFunc, apdu *domain.APDU) (*domain.Response, error) {
+	resp, err := transmit(ctx, apdu)
+	if err != nil {
+		return nil, err
+	}
+
+	// 6Cxx: wrong Le, SW2 carries the exact length available
+	if resp.SW1 == 0x6C {
+		retry := *apdu
+		retry.Le = shortNe(resp.SW2)
+		if resp, err = transmit(ctx, &retry); err != nil {
+			return nil, err
+		}
+	}
+
+	// 61xx: SW2 more bytes are waiting to be fetched
+	data := resp.Data
+	for rounds := 0; resp.SW1 == 0x61; rounds++ {
+		if rounds == maxGetResponseRounds {
+			return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+				"card kept answering 61xx to GET RESPONSE", nil)
+		}
+
+		getResponse := &domain.APDU{
+			CLA: getResponseCLA(apdu.CLA),
+			INS: 0xC0, // GET RESPONSE
+			P1:  0x00,
+			P2:  0x00,
+			Le:  shortNe(resp.SW2),
+		}
+		if resp, err = transmit(ctx, getResponse); err != nil {
+			return nil, err
+		}
+		data = append(data, resp.Data...)
+	}
+
+	return &domain.Response{Data: data, SW1: resp.SW1, SW2: resp.SW2}, nil
+}
+
+// getResponseCLA keeps the logical channel of the original command but
+// drops the chaining and secure messaging indications
+func getResponseCLA(cla byte) byte {
+	// CLAChannel only returns valid channels, which ChannelCLA always encodes
+	plain, _ := domain.ChannelCLA(0x00, domain.CLAChannel(cla))
+	return plain
+}
+
+// shortNe converts a one-byte length from SW2 to Ne (0x00 means 256)
+func shortNe(b byte) int {
+	if b == 0 {
+		return domain.MaxShortLe
+	}
+	return int(b)
+}
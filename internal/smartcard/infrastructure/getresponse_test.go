@@ -0,0 +1,134 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+func newCollectorCard(t *testing.T, script *ScriptedCard) *ResponseCollector {
+	t.Helper()
+	if _, err := script.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return NewResponseCollector(script)
+}
+
+func TestResponseCollector_GetResponse(t *testing.T) {
+	script := NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x3F, 0x00}, nil, 0x6104).
+		Expect([]byte{0x00, 0xC0, 0x00, 0x00, 0x04}, []byte{0x6F, 0x02, 0x84, 0x00}, 0x9000)
+	card := newCollectorCard(t, script)
+
+	resp, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, Data: []byte{0x3F, 0x00}})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if !resp.IsSuccess() || !bytes.Equal(resp.Data, []byte{0x6F, 0x02, 0x84, 0x00}) {
+		t.Errorf("Transmit() = %02X %04X, want 6F028400 9000", resp.Data, resp.StatusCode())
+	}
+	if err := script.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResponseCollector_MultipleGetResponse(t *testing.T) {
+	first := bytes.Repeat([]byte{0x01}, 256)
+	script := NewScriptedCard().
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, nil, 0x6100).
+		Expect([]byte{0x00, 0xC0, 0x00, 0x00, 0x00}, first, 0x6110).
+		Expect([]byte{0x00, 0xC0, 0x00, 0x00, 0x10}, bytes.Repeat([]byte{0x02}, 16), 0x9000)
+	card := newCollectorCard(t, script)
+
+	resp, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 256})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if len(resp.Data) != 272 {
+		t.Errorf("Data length = %d, want 272", len(resp.Data))
+	}
+}
+
+func TestResponseCollector_WrongLe(t *testing.T) {
+	script := NewScriptedCard().
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, nil, 0x6C05).
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x05}, []byte{1, 2, 3, 4, 5}, 0x9000)
+	card := newCollectorCard(t, script)
+
+	resp, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 256})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if len(resp.Data) != 5 || !resp.IsSuccess() {
+		t.Errorf("Transmit() = %02X %04X, want 5 bytes 9000", resp.Data, resp.StatusCode())
+	}
+}
+
+func TestResponseCollector_WrongLeThenMoreData(t *testing.T) {
+	script := NewScriptedCard().
+		Expect([]byte{0x00, 0xCA, 0x01, 0x01, 0x01}, nil, 0x6C02).
+		Expect([]byte{0x00, 0xCA, 0x01, 0x01, 0x02}, []byte{0xAA}, 0x6101).
+		Expect([]byte{0x00, 0xC0, 0x00, 0x00, 0x01}, []byte{0xBB}, 0x9000)
+	card := newCollectorCard(t, script)
+
+	resp, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xCA, P1: 0x01, P2: 0x01, Le: 1})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if !bytes.Equal(resp.Data, []byte{0xAA, 0xBB}) {
+		t.Errorf("Data = %02X, want AABB", resp.Data)
+	}
+}
+
+func TestResponseCollector_PassesErrors(t *testing.T) {
+	script := NewScriptedCard().
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C2)
+	card := newCollectorCard(t, script)
+
+	resp, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if resp.StatusCode() != 0x63C2 {
+		t.Errorf("StatusCode() = %04X, want 63C2", resp.StatusCode())
+	}
+}
+
+func TestResponseCollector_LogsIntermediateExchanges(t *testing.T) {
+	buf := &bytes.Buffer{}
+	script := NewScriptedCard().
+		Expect([]byte{0x00, 0x84, 0x00, 0x00, 0x08}, nil, 0x6108).
+		Expect([]byte{0x00, 0xC0, 0x00, 0x00, 0x08}, make([]byte, 8), 0x9000)
+	script.SetLogger(NewAPDULogger(buf))
+	card := newCollectorCard(t, script)
+
+	if _, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0x84, Le: 8}); err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "SW=6108") || !strings.Contains(output, "GET RESPONSE") {
+		t.Errorf("log should show the 61xx status and the GET RESPONSE command:\n%s", output)
+	}
+}
+
+func TestGetResponseCLA(t *testing.T) {
+	tests := []struct {
+		cla  byte
+		want byte
+	}{
+		{0x00, 0x00},
+		{0x0C, 0x00}, // SM indication dropped
+		{0x13, 0x03}, // Chaining dropped, channel 3 kept
+		{0x80, 0x00}, // Proprietary class answered with interindustry
+		{0x61, 0x41}, // Further interindustry, channel 5, SM dropped
+	}
+
+	for _, tt := range tests {
+		if got := getResponseCLA(tt.cla); got != tt.want {
+			t.Errorf("getResponseCLA(%02X) = %02X, want %02X", tt.cla, got, tt.want)
+		}
+	}
+}
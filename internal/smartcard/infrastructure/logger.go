@@ -1,22 +1,114 @@
 package infrastructure
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
 )
 
+// LogFormat selects how APDULogger renders events
+type LogFormat string
+
+// Log formats
+const (
+	LogFormatHuman LogFormat = "human" // Timestamped lines with glyphs
+	LogFormatText  LogFormat = "text"  // slog key=value lines
+	LogFormatJSON  LogFormat = "json"  // One JSON object per event
+)
+
+// ParseLogFormat parses a -log-format value
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch format := LogFormat(s); format {
+	case LogFormatHuman, LogFormatText, LogFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q (want human, text or json)", s)
+	}
+}
+
+// Values of the "event" attribute
+const (
+	LogEventConnect        = "connect"
+	LogEventDisconnect     = "disconnect"
+	LogEventReaderSelected = "reader_selected"
+	LogEventATR            = "atr"
+	LogEventAPDU           = "apdu"
+	LogEventError          = "error"
+	LogEventInfo           = "info"
+	LogEventUnsafeTrace    = "unsafe_trace"
+)
+
+// Values of the "direction" attribute of APDU events
+const (
+	LogDirectionCommand  = "out" // Command sent to the card
+	LogDirectionResponse = "in"  // Response received from the card
+)
+
+// LoggerSetter is implemented by connectors that emit APDU and lifecycle logs
+type LoggerSetter interface {
+	SetLogger(logger *APDULogger)
+}
+
 // APDULogger logs APDU commands, responses, and connection lifecycle events
+// as log/slog records with typed attributes (event, direction, cla, ins,
+// p1, p2, sw, duration, reader, session). APDUs are logged at debug level,
+// lifecycle events at info and errors at error.
+//
+// PINs, PACE password-derived objects and personal data read from or written
+// to the card are masked (see redaction.go) unless SetUnsafeFullTrace is
+// enabled.
 type APDULogger struct {
-	out     io.Writer
-	enabled bool
+	logger   *slog.Logger
+	level    *slog.LevelVar // Nil when the handler was supplied by the caller
+	enabled  bool
+	unsafe   bool
+	annotate bool
+
+	mu         sync.Mutex
+	session    string    // Identifies the current connection
+	reader     string    // Reader of the current connection
+	commandAt  time.Time // When the last command was logged
+	commandCLA byte      // Header of the last command other than GET RESPONSE,
+	commandINS byte      // which decides what its response reveals
 }
 
-// NewAPDULogger creates a new APDU logger
+// NewAPDULogger creates a new APDU logger writing human-readable lines
 func NewAPDULogger(out io.Writer) *APDULogger {
-	return &APDULogger{out: out, enabled: true}
+	return NewAPDULoggerFormat(out, LogFormatHuman)
+}
+
+// NewAPDULoggerFormat creates a new APDU logger writing in format. It logs
+// every level until SetLevel is called.
+func NewAPDULoggerFormat(out io.Writer, format LogFormat) *APDULogger {
+	level := &slog.LevelVar{}
+	level.Set(slog.LevelDebug)
+
+	var handler slog.Handler
+	switch format {
+	case LogFormatText:
+		handler = slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
+	case LogFormatJSON:
+		handler = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level})
+	default:
+		handler = newHumanHandler(out, level)
+	}
+	l := NewSlogAPDULogger(handler)
+	l.level = level
+	return l
+}
+
+// NewSlogAPDULogger creates a new APDU logger emitting to handler, e.g. to
+// feed card traces into an existing log pipeline
+func NewSlogAPDULogger(handler slog.Handler) *APDULogger {
+	return &APDULogger{logger: slog.New(handler), enabled: true}
 }
 
 // SetEnabled enables or disables logging
@@ -24,25 +116,64 @@ func (l *APDULogger) SetEnabled(enabled bool) {
 	l.enabled = enabled
 }
 
-func (l *APDULogger) timestamp() string {
-	return time.Now().Format("15:04:05.000")
+// SetUnsafeFullTrace disables redaction, logging PINs, PACE secrets and
+// personal data in clear. It is meant for lab debugging with test cards
+// only; enabling it logs a warning, and so does every later connection.
+func (l *APDULogger) SetUnsafeFullTrace(unsafe bool) {
+	l.unsafe = unsafe
+	if unsafe && l.enabled {
+		l.warnUnsafe()
+	}
+}
+
+// SetAnnotateTLV enables decoding BER-TLV payloads of commands and
+// responses into a tree of named data objects (the "tlv" attribute)
+func (l *APDULogger) SetAnnotateTLV(annotate bool) {
+	l.annotate = annotate
+}
+
+// SetLevel sets the minimum level logged (e.g. slog.LevelInfo hides APDUs).
+// It has no effect on a logger created with NewSlogAPDULogger, whose handler
+// decides.
+func (l *APDULogger) SetLevel(level slog.Level) {
+	if l.level != nil {
+		l.level.Set(level)
+	}
+}
+
+// Logger returns the underlying slog logger
+func (l *APDULogger) Logger() *slog.Logger {
+	return l.logger
 }
 
-// LogConnect logs a successful card connection
+// LogConnect logs a successful card connection and starts a new session
 func (l *APDULogger) LogConnect(reader string, protocol string) {
 	if !l.enabled {
 		return
 	}
-	fmt.Fprintf(l.out, "[%s] ● Connected to reader: %s (protocol: %s)\n",
-		l.timestamp(), reader, protocol)
+	l.mu.Lock()
+	l.session = newLogSession()
+	l.reader = reader
+	l.mu.Unlock()
+
+	l.log(slog.LevelInfo, LogEventConnect,
+		fmt.Sprintf("Connected to reader: %s (protocol: %s)", reader, protocol),
+		slog.String("protocol", protocol))
+	if l.unsafe {
+		l.warnUnsafe()
+	}
 }
 
-// LogDisconnect logs a card disconnection
+// LogDisconnect logs a card disconnection and ends the session
 func (l *APDULogger) LogDisconnect() {
 	if !l.enabled {
 		return
 	}
-	fmt.Fprintf(l.out, "[%s] ○ Disconnected from card\n", l.timestamp())
+	l.log(slog.LevelInfo, LogEventDisconnect, "Disconnected from card")
+
+	l.mu.Lock()
+	l.session, l.reader = "", ""
+	l.mu.Unlock()
 }
 
 // LogReaderSelected logs reader selection
@@ -50,16 +181,28 @@ func (l *APDULogger) LogReaderSelected(reader string, available []string) {
 	if !l.enabled {
 		return
 	}
-	fmt.Fprintf(l.out, "[%s] ◆ Selected reader: %s (from %d available)\n",
-		l.timestamp(), reader, len(available))
+	l.log(slog.LevelInfo, LogEventReaderSelected,
+		fmt.Sprintf("Selected reader: %s (from %d available)", reader, len(available)),
+		slog.String("selected", reader), slog.Int("available", len(available)))
 }
 
-// LogATR logs the Answer To Reset
+// LogATR logs the Answer To Reset with its decoding and the identified card
 func (l *APDULogger) LogATR(atr []byte) {
 	if !l.enabled {
 		return
 	}
-	fmt.Fprintf(l.out, "[%s] ◇ Card ATR: %02X\n", l.timestamp(), atr)
+	attrs := []slog.Attr{slog.String("atr", fmt.Sprintf("%02X", atr))}
+	if parsed, err := domain.ParseATR(atr); err != nil {
+		attrs = append(attrs, slog.String("atr_error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.String("atr_decoded", parsed.String()))
+	}
+	if card := domain.IdentifyCard(atr); card != nil {
+		attrs = append(attrs, slog.String("card", card.String()))
+	} else {
+		attrs = append(attrs, slog.String("card", "not identified"))
+	}
+	l.log(slog.LevelInfo, LogEventATR, fmt.Sprintf("Card ATR: %02X", atr), attrs...)
 }
 
 // LogCommand logs an outgoing APDU command with parsed header
@@ -67,42 +210,130 @@ func (l *APDULogger) LogCommand(data []byte) {
 	if !l.enabled {
 		return
 	}
-	if len(data) >= 4 {
-		// Parse APDU header for readability
-		cla, ins, p1, p2 := data[0], data[1], data[2], data[3]
-		insName := l.instructionName(ins)
-		fmt.Fprintf(l.out, "[%s] → APDU %s (CLA=%02X INS=%02X P1=%02X P2=%02X, %d bytes): %02X\n",
-			l.timestamp(), insName, cla, ins, p1, p2, len(data), data)
-	} else {
-		fmt.Fprintf(l.out, "[%s] → APDU Command (%d bytes): %02X\n",
-			l.timestamp(), len(data), data)
+	l.mu.Lock()
+	l.commandAt = time.Now()
+	if len(data) >= 4 && data[1] != insGetResponse {
+		l.commandCLA, l.commandINS = data[0], data[1]
 	}
+	l.mu.Unlock()
+
+	var redactions []span
+	if !l.unsafe {
+		redactions = commandRedactions(data)
+	}
+	payload := maskedHex(data, redactions)
+	attrs := []slog.Attr{
+		slog.String("direction", LogDirectionCommand),
+		slog.Int("length", len(data)),
+		slog.String("data", payload),
+	}
+	if len(redactions) > 0 {
+		attrs = append(attrs, slog.Int("redacted", maskedCount(redactions)))
+	}
+	if l.annotate && len(data) >= 4 {
+		scope := tlvScopeTop
+		switch {
+		case domain.CLASecureMessaging(data[0]) != 0:
+			scope = tlvScopeSM
+		case data[1] == insManageSecurityEnvironment:
+			scope = tlvScopeMSE
+		}
+		start, end := commandDataRange(data)
+		if tree := l.annotateTLV(data[start:end], start, redactions, scope); tree != nil {
+			attrs = append(attrs, slog.Any("tlv", tree))
+		}
+	}
+	if len(data) < 4 {
+		l.log(slog.LevelDebug, LogEventAPDU,
+			fmt.Sprintf("APDU Command (%d bytes): %s", len(data), payload), attrs...)
+		return
+	}
+
+	// Parse APDU header for readability
+	cla, ins, p1, p2 := data[0], data[1], data[2], data[3]
+	insName := l.instructionName(ins)
+	attrs = append(attrs,
+		slog.String("cla", fmt.Sprintf("%02X", cla)),
+		slog.String("ins", fmt.Sprintf("%02X", ins)),
+		slog.String("p1", fmt.Sprintf("%02X", p1)),
+		slog.String("p2", fmt.Sprintf("%02X", p2)),
+		slog.String("instruction", insName))
+	l.log(slog.LevelDebug, LogEventAPDU,
+		fmt.Sprintf("APDU %s (CLA=%02X INS=%02X P1=%02X P2=%02X, %d bytes): %s",
+			insName, cla, ins, p1, p2, len(data), payload), attrs...)
 }
 
-// LogResponse logs an incoming APDU response with status interpretation
+// LogResponse logs an incoming APDU response with status interpretation and
+// the time since the command
 func (l *APDULogger) LogResponse(data []byte) {
 	if !l.enabled {
 		return
 	}
-	if len(data) >= 2 {
-		sw1, sw2 := data[len(data)-2], data[len(data)-1]
-		statusCode := (uint16(sw1) << 8) | uint16(sw2)
-		statusDesc := l.statusDescription(statusCode)
-		dataLen := len(data) - 2
-		fmt.Fprintf(l.out, "[%s] ← APDU Response (%d data bytes, SW=%04X %s): %02X\n",
-			l.timestamp(), dataLen, statusCode, statusDesc, data)
-	} else {
-		fmt.Fprintf(l.out, "[%s] ← APDU Response (%d bytes): %02X\n",
-			l.timestamp(), len(data), data)
+	l.mu.Lock()
+	commandAt, cla, ins := l.commandAt, l.commandCLA, l.commandINS
+	l.commandAt = time.Time{}
+	l.mu.Unlock()
+
+	var redactions []span
+	if !l.unsafe {
+		redactions = responseRedactions(cla, ins, data)
+	}
+	payload := maskedHex(data, redactions)
+	attrs := []slog.Attr{
+		slog.String("direction", LogDirectionResponse),
+		slog.String("data", payload),
+	}
+	if len(redactions) > 0 {
+		attrs = append(attrs, slog.Int("redacted", maskedCount(redactions)))
 	}
+	if !commandAt.IsZero() {
+		attrs = append(attrs, slog.Duration("duration", time.Since(commandAt)))
+	}
+
+	if len(data) < 2 {
+		attrs = append(attrs, slog.Int("length", len(data)))
+		l.log(slog.LevelDebug, LogEventAPDU,
+			fmt.Sprintf("APDU Response (%d bytes): %s", len(data), payload), attrs...)
+		return
+	}
+
+	sw1, sw2 := data[len(data)-2], data[len(data)-1]
+	statusCode := (uint16(sw1) << 8) | uint16(sw2)
+	statusDesc := l.statusDescription(statusCode)
+	dataLen := len(data) - 2
+	attrs = append(attrs,
+		slog.Int("length", dataLen),
+		slog.String("sw", fmt.Sprintf("%04X", statusCode)),
+		slog.String("status", statusDesc))
+	if l.annotate {
+		scope := tlvScopeTop
+		if domain.CLASecureMessaging(cla) != 0 {
+			scope = tlvScopeSM
+		}
+		if tree := l.annotateTLV(data[:dataLen], 0, redactions, scope); tree != nil {
+			attrs = append(attrs, slog.Any("tlv", tree))
+		}
+	}
+	l.log(slog.LevelDebug, LogEventAPDU,
+		fmt.Sprintf("APDU Response (%d data bytes, SW=%04X %s): %s",
+			dataLen, statusCode, statusDesc, payload), attrs...)
 }
 
-// LogError logs an error
+// LogError logs an error, with its transport error code when it has one
 func (l *APDULogger) LogError(err error) {
 	if !l.enabled {
 		return
 	}
-	fmt.Fprintf(l.out, "[%s] ✗ Error: %v\n", l.timestamp(), err)
+	attrs := []slog.Attr{slog.String("error", err.Error())}
+	var transportErr *domain.TransportError
+	if errors.As(err, &transportErr) {
+		attrs = append(attrs, slog.String("code", transportErr.Code.Error()))
+	}
+	var statusErr *domain.StatusError
+	if errors.As(err, &statusErr) {
+		attrs = append(attrs, slog.String("sw", fmt.Sprintf("%04X", statusErr.Code)))
+	}
+	l.log(slog.LevelError, LogEventError, fmt.Sprintf("Error: %v", err), attrs...)
 }
 
 // LogInfo logs an informational message
@@ -110,8 +341,42 @@ func (l *APDULogger) LogInfo(format string, args ...any) {
 	if !l.enabled {
 		return
 	}
-	msg := fmt.Sprintf(format, args...)
-	fmt.Fprintf(l.out, "[%s] ℹ %s\n", l.timestamp(), msg)
+	l.log(slog.LevelInfo, LogEventInfo, fmt.Sprintf(format, args...))
+}
+
+// warnUnsafe logs that redaction is disabled
+func (l *APDULogger) warnUnsafe() {
+	l.log(slog.LevelWarn, LogEventUnsafeTrace,
+		"UNSAFE FULL TRACE: PINs, PACE secrets and personal data are logged in clear; "+
+			"use only with test cards, never with citizens' cards")
+}
+
+// log emits a record with the event and the current session and reader
+func (l *APDULogger) log(level slog.Level, event string, msg string, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.mu.Lock()
+	session, reader := l.session, l.reader
+	l.mu.Unlock()
+
+	common := make([]slog.Attr, 0, len(attrs)+3)
+	common = append(common, slog.String("event", event))
+	if session != "" {
+		common = append(common, slog.String("session", session))
+	}
+	if reader != "" {
+		common = append(common, slog.String("reader", reader))
+	}
+	l.logger.LogAttrs(ctx, level, msg, append(common, attrs...)...)
+}
+
+// newLogSession returns a random identifier for a connection
+func newLogSession() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
 
 // instructionName returns a human-readable name for common INS bytes (ISO/IEC 7816-4)
@@ -121,6 +386,10 @@ func (l *APDULogger) instructionName(ins byte) string {
 		return "SELECT"
 	case 0x20:
 		return "VERIFY"
+	case 0x24:
+		return "CHANGE REFERENCE DATA"
+	case 0x2C:
+		return "RESET RETRY COUNTER"
 	case 0xB0:
 		return "READ BINARY"
 	case 0xB2:
@@ -141,38 +410,16 @@ func (l *APDULogger) instructionName(ins byte) string {
 		return "MANAGE SECURITY ENVIRONMENT"
 	case 0x2A:
 		return "PERFORM SECURITY OPERATION"
+	case 0xC0:
+		return "GET RESPONSE"
+	case 0x70:
+		return "MANAGE CHANNEL"
 	default:
 		return fmt.Sprintf("INS_%02X", ins)
 	}
 }
 
-// statusDescription returns a human-readable status description
+// statusDescription returns the short catalogue label of a status word
 func (l *APDULogger) statusDescription(status uint16) string {
-	switch status {
-	case domain.StatusSuccess:
-		return "OK"
-	case domain.StatusMoreData:
-		return "More data"
-	case domain.StatusWarningEOF:
-		return "EOF"
-	case domain.StatusSecurityAuthFailed:
-		return "Auth failed"
-	case domain.StatusIncorrectPIN:
-		return "PIN blocked"
-	case domain.StatusFileNotFound:
-		return "Not found"
-	case domain.StatusLengthError:
-		return "Wrong length"
-	case domain.StatusInstructionErr:
-		return "INS not supported"
-	case domain.StatusCLAErr:
-		return "CLA not supported"
-	default:
-		// Check for PIN retry counter (0x63Cx)
-		if status&0xFFF0 == domain.StatusPINRetryMask {
-			retries := status & 0x000F
-			return fmt.Sprintf("%d retries left", retries)
-		}
-		return ""
-	}
+	return domain.LookupStatusWord(status).Label
 }
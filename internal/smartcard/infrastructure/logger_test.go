@@ -2,9 +2,13 @@ package infrastructure
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"strings"
 	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
 )
 
 func TestNewAPDULogger(t *testing.T) {
@@ -104,6 +108,25 @@ func TestAPDULogger_LogATR(t *testing.T) {
 	if !strings.Contains(output, "3B8C8001") {
 		t.Error("LogATR should contain hex-encoded ATR")
 	}
+	if !strings.Contains(output, "ATR not decodable") {
+		t.Error("LogATR should report a truncated ATR")
+	}
+}
+
+func TestAPDULogger_LogATR_Decoded(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULogger(buf)
+
+	logger.LogATR([]byte{0x3B, 0xFF, 0x96, 0x00, 0x00, 0x81, 0x31, 0xFE, 0x43,
+		0x80, 0x31, 0x80, 0x65, 0xB0, 0x85, 0x03, 0x00, 0xEF, 0x12, 0x0F, 0xFF, 0x82, 0x90, 0x00, 0x19})
+
+	output := buf.String()
+	if !strings.Contains(output, "ATR: T=1, Fi=512 Di=32") {
+		t.Errorf("LogATR should decode the ATR, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Card: Romanian CEI (ID-One Cosmo, IAS-ECC) [Romanian CEI]") {
+		t.Errorf("LogATR should identify the card, got:\n%s", output)
+	}
 }
 
 func TestAPDULogger_LogCommand_SELECT(t *testing.T) {
@@ -297,6 +320,8 @@ func TestAPDULogger_InstructionName(t *testing.T) {
 	}{
 		{0xA4, "SELECT"},
 		{0x20, "VERIFY"},
+		{0x24, "CHANGE REFERENCE DATA"},
+		{0x2C, "RESET RETRY COUNTER"},
 		{0xB0, "READ BINARY"},
 		{0xB2, "READ RECORD"},
 		{0xCA, "GET DATA"},
@@ -307,6 +332,8 @@ func TestAPDULogger_InstructionName(t *testing.T) {
 		{0x88, "INTERNAL AUTHENTICATE"},
 		{0x22, "MANAGE SECURITY ENVIRONMENT"},
 		{0x2A, "PERFORM SECURITY OPERATION"},
+		{0xC0, "GET RESPONSE"},
+		{0x70, "MANAGE CHANNEL"},
 		{0xFF, "INS_FF"},
 	}
 
@@ -339,3 +366,80 @@ func TestAPDULogger_Disabled(t *testing.T) {
 		t.Errorf("Disabled logger should not produce output, got %d bytes", buf.Len())
 	}
 }
+
+func TestAPDULogger_JSONFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULoggerFormat(buf, LogFormatJSON)
+
+	logger.LogConnect("Test Reader", "T=1")
+	logger.LogCommand([]byte{0x00, 0xA4, 0x04, 0x00, 0x02, 0x3F, 0x00})
+	logger.LogResponse([]byte{0x63, 0xC2})
+	logger.LogError(domain.NewTransportError(domain.ErrCardRemoved, "card removed", nil))
+	logger.LogDisconnect()
+
+	var records []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		record := map[string]any{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("output is not JSON: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+
+	session := records[0]["session"]
+	if session == nil || session == "" {
+		t.Fatal("connect should start a session")
+	}
+	tests := []struct {
+		record int
+		want   map[string]any
+	}{
+		{0, map[string]any{"level": "INFO", "event": "connect", "reader": "Test Reader", "protocol": "T=1"}},
+		{1, map[string]any{"level": "DEBUG", "event": "apdu", "direction": "out", "cla": "00", "ins": "A4", "p1": "04", "p2": "00", "instruction": "SELECT", "session": session}},
+		{2, map[string]any{"level": "DEBUG", "event": "apdu", "direction": "in", "sw": "63C2", "status": "2 retries left", "reader": "Test Reader"}},
+		{3, map[string]any{"level": "ERROR", "event": "error", "code": "card removed"}},
+		{4, map[string]any{"level": "INFO", "event": "disconnect", "session": session}},
+	}
+	for _, tt := range tests {
+		for key, want := range tt.want {
+			if got := records[tt.record][key]; got != want {
+				t.Errorf("record %d %s = %v, want %v", tt.record, key, got, want)
+			}
+		}
+	}
+	if _, ok := records[2]["duration"]; !ok {
+		t.Error("response should carry the duration since the command")
+	}
+}
+
+func TestAPDULogger_SetLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULoggerFormat(buf, LogFormatText)
+	logger.SetLevel(slog.LevelInfo)
+
+	logger.LogCommand([]byte{0x00, 0xA4, 0x04, 0x00})
+	logger.LogResponse([]byte{0x90, 0x00})
+	if buf.Len() != 0 {
+		t.Errorf("APDUs should be logged at debug level, got %q", buf.String())
+	}
+
+	logger.LogInfo("test")
+	if !strings.Contains(buf.String(), "level=INFO") || !strings.Contains(buf.String(), "event=info") {
+		t.Errorf("info should be logged as text, got %q", buf.String())
+	}
+}
+
+func TestParseLogFormat(t *testing.T) {
+	for _, s := range []string{"human", "text", "json"} {
+		if format, err := ParseLogFormat(s); err != nil || string(format) != s {
+			t.Errorf("ParseLogFormat(%q) = %q, %v", s, format, err)
+		}
+	}
+	if _, err := ParseLogFormat("xml"); err == nil {
+		t.Error("ParseLogFormat(xml) should fail")
+	}
+}
@@ -0,0 +1,106 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// humanGlyphs marks each event in the human format
+var humanGlyphs = map[string]string{
+	LogEventConnect:        "●",
+	LogEventDisconnect:     "○",
+	LogEventReaderSelected: "◆",
+	LogEventATR:            "◇",
+	LogEventError:          "✗",
+	LogEventInfo:           "ℹ",
+	LogEventUnsafeTrace:    "⚠",
+}
+
+// humanDetails are attributes printed on indented lines below the message
+var humanDetails = []struct {
+	key    string
+	format string
+}{
+	{"atr_error", "ATR not decodable: %s"},
+	{"atr_decoded", "ATR: %s"},
+	{"card", "Card: %s"},
+	{"tlv", "%s"},
+}
+
+// humanHandler is the slog handler behind LogFormatHuman: one timestamped
+// line per record with a glyph for the event. Attributes other than the
+// details are already part of the message and are not printed.
+type humanHandler struct {
+	mu    *sync.Mutex // Shared with handlers derived by WithAttrs
+	out   io.Writer
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+// newHumanHandler creates a human format handler
+func newHumanHandler(out io.Writer, level slog.Leveler) *humanHandler {
+	return &humanHandler{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+// Enabled implements slog.Handler
+func (h *humanHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle implements slog.Handler
+func (h *humanHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]string, r.NumAttrs()+len(h.attrs))
+	for _, a := range h.attrs {
+		attrs[a.Key] = a.Value.String()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.String()
+		return true
+	})
+
+	timestamp := r.Time.Format("15:04:05.000")
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s %s\n", timestamp, humanGlyph(attrs), r.Message)
+	for _, detail := range humanDetails {
+		if value, ok := attrs[detail.key]; ok {
+			for _, line := range strings.Split(fmt.Sprintf(detail.format, value), "\n") {
+				fmt.Fprintf(&b, "[%s]   %s\n", timestamp, line)
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (h *humanHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &derived
+}
+
+// WithGroup implements slog.Handler; groups are not rendered
+func (h *humanHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// humanGlyph returns the glyph of a record's event
+func humanGlyph(attrs map[string]string) string {
+	switch event := attrs["event"]; {
+	case event == LogEventAPDU && attrs["direction"] == LogDirectionCommand:
+		return "→"
+	case event == LogEventAPDU:
+		return "←"
+	case humanGlyphs[event] != "":
+		return humanGlyphs[event]
+	default:
+		return "ℹ"
+	}
+}
@@ -0,0 +1,303 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/ebfe/scard"
+)
+
+// pnpNotification is the PC/SC pseudo-reader signalling reader hot-plug
+const pnpNotification = `\\?PnP?\Notification`
+
+// monitorPollInterval bounds each GetStatusChange wait so that readers are
+// re-listed even on platforms without PnP notifications
+const monitorPollInterval = 2 * time.Second
+
+// statusWatcher is the part of a PC/SC context used by the monitor
+type statusWatcher interface {
+	ListReaders() ([]string, error)
+	GetStatusChange(states []scard.ReaderState, timeout time.Duration) error
+	Cancel() error
+	Release() error
+}
+
+// SessionInvalidator is notified when the card behind a session is gone
+type SessionInvalidator interface {
+	InvalidateSession(reader string, cause *domain.TransportError)
+}
+
+// readerWatch is the last known state of a monitored reader
+type readerWatch struct {
+	flags scard.StateFlag
+	atr   []byte
+}
+
+// CardMonitor watches readers with SCardGetStatusChange and emits events for
+// reader hot-plug and card insertion/removal on a channel. It runs on its own
+// PC/SC context because GetStatusChange blocks.
+type CardMonitor struct {
+	ctx    statusWatcher
+	events chan domain.ReaderEvent
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu           sync.Mutex
+	readers      map[string]*readerWatch
+	invalidators []SessionInvalidator
+	pnp          bool // PnP notifications supported
+	started      bool
+	closeOnce    sync.Once
+}
+
+// NewCardMonitor establishes a dedicated PC/SC context for monitoring
+func NewCardMonitor() (*CardMonitor, error) {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, pcscError(err, domain.ErrNoContext,
+			"failed to establish PC/SC context")
+	}
+	return newCardMonitor(ctx), nil
+}
+
+// newCardMonitor creates a monitor on top of any status watcher
+func newCardMonitor(ctx statusWatcher) *CardMonitor {
+	return &CardMonitor{
+		ctx:     ctx,
+		events:  make(chan domain.ReaderEvent, 16),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		readers: make(map[string]*readerWatch),
+		pnp:     true,
+	}
+}
+
+// AddInvalidator registers a session to invalidate when its card is removed
+// (e.g. a PCSCTransport)
+func (m *CardMonitor) AddInvalidator(invalidator SessionInvalidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.invalidators = append(m.invalidators, invalidator)
+}
+
+// Events returns the event stream; it is closed when the monitor stops
+func (m *CardMonitor) Events() <-chan domain.ReaderEvent {
+	return m.events
+}
+
+// Start launches the monitoring loop
+func (m *CardMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return
+	}
+	m.started = true
+	go m.run()
+}
+
+// Close stops the monitoring loop and releases the PC/SC context
+func (m *CardMonitor) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		m.ctx.Cancel()
+
+		m.mu.Lock()
+		started := m.started
+		m.mu.Unlock()
+		if started {
+			<-m.done
+		}
+		err = m.ctx.Release()
+	})
+	return err
+}
+
+// Readers returns a snapshot of the monitored readers
+func (m *CardMonitor) Readers() domain.ReaderList {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	readers := make(domain.ReaderList, 0, len(m.readers))
+	for name, watch := range m.readers {
+		reader := &pcscReader{name: name}
+		if watch.flags&scard.StatePresent != 0 && watch.flags&scard.StateMute == 0 {
+			reader.cardPresent = true
+			reader.atr = watch.atr
+		}
+		readers = append(readers, reader)
+	}
+	return readers
+}
+
+// run is the monitoring loop
+func (m *CardMonitor) run() {
+	defer close(m.done)
+	defer close(m.events)
+
+	for !m.stopped() {
+		names, err := m.ctx.ListReaders()
+		if err != nil && err != scard.ErrNoReadersAvailable {
+			if err == scard.ErrCancelled || !m.sleep(monitorPollInterval) {
+				return
+			}
+			continue
+		}
+		if !m.syncReaders(names) {
+			return
+		}
+
+		states := make([]scard.ReaderState, 0, len(names)+1)
+		for _, name := range names {
+			states = append(states, scard.ReaderState{
+				Reader:       name,
+				CurrentState: m.readers[name].flags,
+			})
+		}
+		if m.pnp {
+			states = append(states, scard.ReaderState{
+				Reader:       pnpNotification,
+				CurrentState: scard.StateFlag(len(names) << 16),
+			})
+		}
+
+		err = m.ctx.GetStatusChange(states, monitorPollInterval)
+		switch err {
+		case nil:
+		case scard.ErrTimeout, scard.ErrUnknownReader, scard.ErrReaderUnavailable:
+			continue
+		case scard.ErrCancelled:
+			return
+		default:
+			if !m.sleep(monitorPollInterval) {
+				return
+			}
+			continue
+		}
+
+		if m.pnp && states[len(states)-1].EventState&scard.StateUnknown != 0 {
+			// No PnP support: fall back to re-listing on every poll
+			m.pnp = false
+		}
+		for _, state := range states[:len(names)] {
+			if !m.update(state.Reader, state.EventState, state.Atr) {
+				return
+			}
+		}
+	}
+}
+
+// syncReaders emits attach/detach events for changes in the reader list
+func (m *CardMonitor) syncReaders(names []string) bool {
+	current := make(map[string]bool, len(names))
+	for _, name := range names {
+		current[name] = true
+		if _, known := m.readers[name]; !known {
+			m.mu.Lock()
+			m.readers[name] = &readerWatch{flags: scard.StateUnaware}
+			m.mu.Unlock()
+			if !m.emit(domain.EventReaderAttached, name, nil) {
+				return false
+			}
+		}
+	}
+
+	for name, watch := range m.readers {
+		if current[name] {
+			continue
+		}
+		m.mu.Lock()
+		delete(m.readers, name)
+		m.mu.Unlock()
+		if watch.flags&scard.StatePresent != 0 {
+			m.invalidate(name, "card reader detached")
+			if !m.emit(domain.EventCardRemoved, name, nil) {
+				return false
+			}
+		}
+		if !m.emit(domain.EventReaderDetached, name, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// update compares a new reader state with the previous one and emits events
+func (m *CardMonitor) update(name string, flags scard.StateFlag, atr []byte) bool {
+	m.mu.Lock()
+	watch := m.readers[name]
+	previous := watch.flags
+	watch.flags = flags &^ scard.StateChanged
+	watch.atr = atr
+	m.mu.Unlock()
+
+	wasPresent := previous&scard.StatePresent != 0
+	isPresent := flags&scard.StatePresent != 0
+	wasMute := previous&scard.StateMute != 0
+	isMute := flags&scard.StateMute != 0
+
+	// The upper 16 bits count card events: a change while the card stays
+	// present means it was swapped between two polls
+	swapped := wasPresent && isPresent && previous>>16 != flags>>16
+
+	if wasPresent && (!isPresent || swapped) {
+		m.invalidate(name, "card removed from reader")
+		if !m.emit(domain.EventCardRemoved, name, nil) {
+			return false
+		}
+		wasMute = false
+	}
+
+	switch {
+	case isPresent && isMute && !wasMute:
+		return m.emit(domain.EventCardMuted, name, nil)
+	case isPresent && !isMute && (!wasPresent || swapped || wasMute):
+		return m.emit(domain.EventCardInserted, name, atr)
+	}
+	return true
+}
+
+// invalidate notifies registered sessions that the card is gone
+func (m *CardMonitor) invalidate(reader string, message string) {
+	m.mu.Lock()
+	invalidators := append([]SessionInvalidator{}, m.invalidators...)
+	m.mu.Unlock()
+
+	cause := domain.NewTransportError(domain.ErrCardRemoved, message, nil)
+	for _, invalidator := range invalidators {
+		invalidator.InvalidateSession(reader, cause)
+	}
+}
+
+// emit delivers an event unless the monitor is stopping
+func (m *CardMonitor) emit(typ domain.ReaderEventType, reader string, atr []byte) bool {
+	event := domain.ReaderEvent{Type: typ, Reader: reader, ATR: atr, Time: time.Now()}
+	select {
+	case m.events <- event:
+		return true
+	case <-m.stop:
+		return false
+	}
+}
+
+// stopped reports whether Close was called
+func (m *CardMonitor) stopped() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, returning false if the monitor is stopping
+func (m *CardMonitor) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-m.stop:
+		return false
+	}
+}
@@ -0,0 +1,205 @@
+package infrastructure
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/ebfe/scard"
+)
+
+// fakeWatcherStep is the reader list and states returned by one poll
+type fakeWatcherStep struct {
+	readers []string
+	states  map[string]scard.StateFlag
+	atrs    map[string][]byte
+}
+
+// fakeWatcher replays reader states, then blocks until cancelled
+type fakeWatcher struct {
+	mu        sync.Mutex
+	steps     []fakeWatcherStep
+	position  int
+	cancelled chan struct{}
+	released  bool
+}
+
+func newFakeWatcher(steps ...fakeWatcherStep) *fakeWatcher {
+	return &fakeWatcher{steps: steps, cancelled: make(chan struct{})}
+}
+
+func (w *fakeWatcher) ListReaders() ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	step := w.steps[min(w.position, len(w.steps)-1)]
+	if len(step.readers) == 0 {
+		return nil, scard.ErrNoReadersAvailable
+	}
+	return step.readers, nil
+}
+
+func (w *fakeWatcher) GetStatusChange(states []scard.ReaderState, timeout time.Duration) error {
+	w.mu.Lock()
+	if w.position >= len(w.steps) {
+		w.mu.Unlock()
+		<-w.cancelled
+		return scard.ErrCancelled
+	}
+	step := w.steps[w.position]
+	w.position++
+	w.mu.Unlock()
+
+	for i := range states {
+		states[i].EventState = step.states[states[i].Reader] | scard.StateChanged
+		states[i].Atr = step.atrs[states[i].Reader]
+	}
+	return nil
+}
+
+func (w *fakeWatcher) Cancel() error {
+	close(w.cancelled)
+	return nil
+}
+
+func (w *fakeWatcher) Release() error {
+	w.released = true
+	return nil
+}
+
+// recordingInvalidator records InvalidateSession calls
+type recordingInvalidator struct {
+	mu      sync.Mutex
+	readers []string
+}
+
+func (r *recordingInvalidator) InvalidateSession(reader string, cause *domain.TransportError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cause.Code == domain.ErrCardRemoved {
+		r.readers = append(r.readers, reader)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan domain.ReaderEvent) domain.ReaderEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for reader event")
+		return domain.ReaderEvent{}
+	}
+}
+
+func TestCardMonitor_Events(t *testing.T) {
+	atr := []byte{0x3B, 0x88, 0x80, 0x01}
+	const readerA, readerB = "Reader A 00 00", "Reader B 01 00"
+
+	watcher := newFakeWatcher(
+		fakeWatcherStep{
+			readers: []string{readerA},
+			states:  map[string]scard.StateFlag{readerA: scard.StatePresent},
+			atrs:    map[string][]byte{readerA: atr},
+		},
+		fakeWatcherStep{
+			readers: []string{readerA, readerB},
+			states:  map[string]scard.StateFlag{readerA: scard.StatePresent, readerB: scard.StateEmpty},
+			atrs:    map[string][]byte{readerA: atr},
+		},
+		fakeWatcherStep{
+			readers: []string{readerA, readerB},
+			states:  map[string]scard.StateFlag{readerA: scard.StateEmpty, readerB: scard.StateEmpty},
+		},
+		fakeWatcherStep{
+			readers: []string{readerB},
+			states:  map[string]scard.StateFlag{readerB: scard.StatePresent | scard.StateMute},
+		},
+	)
+	invalidator := &recordingInvalidator{}
+	monitor := newCardMonitor(watcher)
+	monitor.AddInvalidator(invalidator)
+	monitor.Start()
+
+	want := []struct {
+		typ    domain.ReaderEventType
+		reader string
+	}{
+		{domain.EventReaderAttached, readerA},
+		{domain.EventCardInserted, readerA},
+		{domain.EventReaderAttached, readerB},
+		{domain.EventCardRemoved, readerA},
+		{domain.EventReaderDetached, readerA},
+		{domain.EventCardMuted, readerB},
+	}
+	for i, w := range want {
+		event := nextEvent(t, monitor.Events())
+		if event.Type != w.typ || event.Reader != w.reader {
+			t.Fatalf("event %d = %v, want %s: %s", i, event, w.reader, w.typ)
+		}
+		if event.Type == domain.EventCardInserted && string(event.ATR) != string(atr) {
+			t.Errorf("inserted event ATR = %02X, want %02X", event.ATR, atr)
+		}
+	}
+
+	if err := monitor.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, open := <-monitor.Events(); open {
+		t.Error("Events() should be closed after Close")
+	}
+	if !watcher.released {
+		t.Error("Close() should release the PC/SC context")
+	}
+
+	invalidator.mu.Lock()
+	defer invalidator.mu.Unlock()
+	if len(invalidator.readers) != 1 || invalidator.readers[0] != readerA {
+		t.Errorf("invalidated readers = %v, want [%s]", invalidator.readers, readerA)
+	}
+}
+
+func TestCardMonitor_CardSwap(t *testing.T) {
+	const reader = "Reader A 00 00"
+	watcher := newFakeWatcher(
+		fakeWatcherStep{
+			readers: []string{reader},
+			states:  map[string]scard.StateFlag{reader: scard.StatePresent | 1<<16},
+		},
+		fakeWatcherStep{
+			readers: []string{reader},
+			states:  map[string]scard.StateFlag{reader: scard.StatePresent | 3<<16},
+		},
+	)
+	monitor := newCardMonitor(watcher)
+	monitor.Start()
+	defer monitor.Close()
+
+	for _, want := range []domain.ReaderEventType{
+		domain.EventReaderAttached,
+		domain.EventCardInserted,
+		domain.EventCardRemoved,
+		domain.EventCardInserted,
+	} {
+		if event := nextEvent(t, monitor.Events()); event.Type != want {
+			t.Fatalf("event = %v, want %s", event, want)
+		}
+	}
+
+	readers := monitor.Readers()
+	if len(readers) != 1 || !readers[0].IsConnected() {
+		t.Errorf("Readers() = %v, want one reader with a card", readers.Names())
+	}
+}
+
+func TestCardMonitor_CloseWithoutStart(t *testing.T) {
+	watcher := newFakeWatcher(fakeWatcherStep{})
+	monitor := newCardMonitor(watcher)
+
+	if err := monitor.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := monitor.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+	"errors"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/ebfe/scard"
+)
+
+// pcscErrorCodes translates PC/SC results into domain transport codes.
+// Results not listed keep the fallback code chosen by the failing call.
+var pcscErrorCodes = map[scard.Error]domain.TransportErrorCode{
+	scard.ErrNoService:          domain.ErrNoContext,
+	scard.ErrServiceStopped:     domain.ErrNoContext,
+	scard.ErrNoReadersAvailable: domain.ErrNoReaders,
+	scard.ErrUnknownReader:      domain.ErrNoReaders,
+	scard.ErrReaderUnavailable:  domain.ErrConnectionLost,
+	scard.ErrNoSmartcard:        domain.ErrNoCard,
+	scard.ErrRemovedCard:        domain.ErrCardRemoved,
+	scard.ErrResetCard:          domain.ErrCardReset,
+	scard.ErrUnresponsiveCard:   domain.ErrCardUnresponsive,
+	scard.ErrUnpoweredCard:      domain.ErrCardUnresponsive,
+	scard.ErrUnsupportedCard:    domain.ErrProtocolMismatch,
+	scard.ErrProtoMismatch:      domain.ErrProtocolMismatch,
+	scard.ErrSharingViolation:   domain.ErrReaderBusy,
+	scard.ErrServerTooBusy:      domain.ErrReaderBusy,
+	scard.ErrInvalidHandle:      domain.ErrConnectionLost,
+	scard.ErrCommError:          domain.ErrConnectionLost,
+	scard.ErrCommDataLost:       domain.ErrConnectionLost,
+	scard.ErrNotTransacted:      domain.ErrTransmissionFailed,
+	scard.ErrTimeout:            domain.ErrTimeout,
+	scard.ErrCancelled:          domain.ErrTimeout,
+}
+
+// pcscError converts an error returned by scard into a TransportError,
+// keeping the raw PC/SC code
+func pcscError(err error, fallback domain.TransportErrorCode, message string) *domain.TransportError {
+	transportErr := domain.NewTransportError(fallback, message, err)
+
+	var pcscErr scard.Error
+	if errors.As(err, &pcscErr) {
+		transportErr.PCSCCode = uint32(pcscErr)
+		if code, ok := pcscErrorCodes[pcscErr]; ok {
+			transportErr.Code = code
+		}
+	}
+	return transportErr
+}
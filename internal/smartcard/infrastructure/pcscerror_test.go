@@ -0,0 +1,49 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/ebfe/scard"
+)
+
+func TestPCSCError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		fallback     domain.TransportErrorCode
+		wantCode     domain.TransportErrorCode
+		wantPCSCCode uint32
+	}{
+		{"no service", scard.ErrNoService, domain.ErrNoReaders, domain.ErrNoContext, 0x8010001D},
+		{"removed card", scard.ErrRemovedCard, domain.ErrTransmissionFailed, domain.ErrCardRemoved, 0x80100069},
+		{"reset card", scard.ErrResetCard, domain.ErrTransmissionFailed, domain.ErrCardReset, 0x80100068},
+		{"unresponsive card", scard.ErrUnresponsiveCard, domain.ErrNoCard, domain.ErrCardUnresponsive, 0x80100066},
+		{"sharing violation", scard.ErrSharingViolation, domain.ErrNoCard, domain.ErrReaderBusy, 0x8010000B},
+		{"protocol mismatch", scard.ErrProtoMismatch, domain.ErrNoCard, domain.ErrProtocolMismatch, 0x8010000F},
+		{"reader unplugged", scard.ErrReaderUnavailable, domain.ErrTransmissionFailed, domain.ErrConnectionLost, 0x80100017},
+		{"unmapped keeps fallback", scard.ErrInternalError, domain.ErrTransmissionFailed, domain.ErrTransmissionFailed, 0x80100001},
+		{"wrapped", fmt.Errorf("transmit: %w", scard.ErrRemovedCard), domain.ErrTransmissionFailed, domain.ErrCardRemoved, 0x80100069},
+		{"not a PC/SC error", errors.New("boom"), domain.ErrTransmissionFailed, domain.ErrTransmissionFailed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pcscError(tt.err, tt.fallback, "operation failed")
+			if err.Code != tt.wantCode {
+				t.Errorf("Code = %d (%v), want %d (%v)", err.Code, err.Code, tt.wantCode, tt.wantCode)
+			}
+			if err.PCSCCode != tt.wantPCSCCode {
+				t.Errorf("PCSCCode = %08X, want %08X", err.PCSCCode, tt.wantPCSCCode)
+			}
+			if !errors.Is(err, tt.wantCode) {
+				t.Errorf("errors.Is(err, %v) = false", tt.wantCode)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("errors.Is(err, cause) = false, want the cause kept")
+			}
+		})
+	}
+}
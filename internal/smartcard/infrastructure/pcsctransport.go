@@ -1,7 +1,8 @@
 package infrastructure
 
 import (
-	"strings"
+	"context"
+	"sync"
 
 	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
 	"github.com/ebfe/scard"
@@ -14,12 +15,24 @@ type PCSCTransport struct {
 	context        *scard.Context
 	card           *scard.Card
 	logger         *APDULogger
+	selector       domain.ReaderSelector
 	selectedReader string
+	txDepth        int                   // Nesting depth of BeginTransaction calls
+	features       domain.ReaderFeatures // Features of the selected reader, nil until queried
+
+	// Sessions invalidated by a CardMonitor, keyed by reader name
+	invalidatedMu sync.Mutex
+	invalidated   map[string]*domain.TransportError
 }
 
-// NewPCSCTransport creates a new PC/SC transport
+// NewPCSCTransport creates a new PC/SC transport using the default reader selector
 func NewPCSCTransport() *PCSCTransport {
-	return &PCSCTransport{}
+	return &PCSCTransport{selector: domain.DefaultReaderSelector()}
+}
+
+// SetReaderSelector sets the rule used by Connect to pick a reader
+func (t *PCSCTransport) SetReaderSelector(selector domain.ReaderSelector) {
+	t.selector = selector
 }
 
 // SetLogger sets the logger for transport events
@@ -28,45 +41,55 @@ func (t *PCSCTransport) SetLogger(logger *APDULogger) {
 }
 
 // Connect establishes a PC/SC context and connects to a card
-func (t *PCSCTransport) Connect() error {
-	ctx, err := scard.EstablishContext()
+func (t *PCSCTransport) Connect(ctx context.Context) (domain.Card, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+
+	pcsc, err := scard.EstablishContext()
 	if err != nil {
-		return domain.NewTransportError(domain.ErrNoContext,
-			"failed to establish PC/SC context", err)
+		return nil, pcscError(err, domain.ErrNoContext,
+			"failed to establish PC/SC context")
 	}
-	t.context = ctx
+	t.context = pcsc
 
-	// List available readers
-	readers, err := ctx.ListReaders()
+	// List available readers with card presence and ATR
+	readers, err := readerStates(pcsc, false)
 	if err != nil {
 		t.context.Release()
 		t.context = nil
-		return domain.NewTransportError(domain.ErrNoReaders,
-			"failed to list readers", err)
+		return nil, err
 	}
 
-	if len(readers) == 0 {
+	// Select reader according to the configured policy
+	reader, err := t.selector.Select(readers)
+	if err != nil {
 		t.context.Release()
 		t.context = nil
-		return domain.NewTransportError(domain.ErrNoReaders,
-			"no smart card readers found", nil)
+		return nil, err
 	}
-
-	// Select reader (prefer "Generic EMV" readers)
-	t.selectedReader = t.selectReader(readers)
+	t.selectedReader = reader.Name()
+	t.clearInvalidation()
 	if t.logger != nil {
-		t.logger.LogReaderSelected(t.selectedReader, readers)
+		t.logger.LogReaderSelected(t.selectedReader, readers.Names())
 	}
 
 	// Connect to card
-	card, err := ctx.Connect(t.selectedReader, scard.ShareShared, scard.ProtocolAny)
+	if err := ctx.Err(); err != nil {
+		t.context.Release()
+		t.context = nil
+		return nil, domain.NewTimeoutError(err)
+	}
+	card, err := pcsc.Connect(t.selectedReader, scard.ShareShared, scard.ProtocolAny)
 	if err != nil {
 		t.context.Release()
 		t.context = nil
-		return domain.NewTransportError(domain.ErrNoCard,
-			"failed to connect to card", err)
+		return nil, pcscError(err, domain.ErrNoCard,
+			"failed to connect to card")
 	}
 	t.card = card
+	t.txDepth = 0
+	t.features = nil
 
 	// Log successful connection with protocol info
 	if t.logger != nil {
@@ -77,11 +100,20 @@ func (t *PCSCTransport) Connect() error {
 		}
 	}
 
-	return nil
+	return t, nil
+}
+
+// Transmit sends an APDU and receives the complete response, transparently
+// handling GET RESPONSE (61xx) and Le correction (6Cxx) for T=0 cards
+func (t *PCSCTransport) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	return collectResponse(ctx, t.transmitRaw, apdu)
 }
 
-// Transmit sends an APDU and receives a response
-func (t *PCSCTransport) Transmit(apdu *domain.APDU) (*domain.Response, error) {
+// transmitRaw sends a single APDU exchange and logs it
+func (t *PCSCTransport) transmitRaw(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if err := t.invalidation(); err != nil {
+		return nil, err
+	}
 	if t.card == nil {
 		return nil, domain.NewTransportError(domain.ErrNoCard,
 			"not connected to card", nil)
@@ -93,10 +125,15 @@ func (t *PCSCTransport) Transmit(apdu *domain.APDU) (*domain.Response, error) {
 		t.logger.LogCommand(apduBytes)
 	}
 
-	responseData, err := t.card.Transmit(apduBytes)
+	card := t.card
+	var responseData []byte
+	err := t.await(ctx, func() (err error) {
+		responseData, err = card.Transmit(apduBytes)
+		return err
+	})
 	if err != nil {
-		transportErr := domain.NewTransportError(domain.ErrTransmissionFailed,
-			"APDU transmission failed", err)
+		transportErr := t.failure(err, domain.ErrTransmissionFailed,
+			"APDU transmission failed")
 		if t.logger != nil {
 			t.logger.LogError(transportErr)
 		}
@@ -111,12 +148,284 @@ func (t *PCSCTransport) Transmit(apdu *domain.APDU) (*domain.Response, error) {
 	return domain.NewResponse(responseData), nil
 }
 
+// Reconnect re-establishes the session with the card in the selected reader.
+// A live handle is reconnected in place (acknowledging a reset done by another
+// application); a dead or abandoned one is replaced by a fresh connection.
+func (t *PCSCTransport) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	if t.selectedReader == "" {
+		return domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+
+	if t.card != nil {
+		card := t.card
+		err := t.await(ctx, func() error {
+			return card.Reconnect(scard.ShareShared, scard.ProtocolAny, resetDisposition(mode))
+		})
+		if err == nil {
+			t.reconnected(mode)
+			return nil
+		}
+		if _, ok := err.(*domain.TransportError); ok {
+			return err // Timed out: the handle was abandoned
+		}
+		card.Disconnect(LeaveCard)
+		t.card = nil
+	}
+
+	if t.context == nil {
+		pcsc, err := scard.EstablishContext()
+		if err != nil {
+			return pcscError(err, domain.ErrNoContext,
+				"failed to establish PC/SC context")
+		}
+		t.context = pcsc
+	}
+	card, err := t.context.Connect(t.selectedReader, scard.ShareShared, scard.ProtocolAny)
+	if err != nil {
+		return pcscError(err, domain.ErrNoCard, "failed to reconnect to card")
+	}
+	t.card = card
+	t.reconnected(mode)
+	return nil
+}
+
+// reconnected resets the session bookkeeping after a successful reconnect
+func (t *PCSCTransport) reconnected(mode domain.ResetMode) {
+	t.txDepth = 0
+	t.clearInvalidation()
+	if t.logger != nil {
+		t.logger.LogInfo("Reconnected to %s (%s)", t.selectedReader, mode)
+	}
+}
+
+// resetDisposition maps a reset mode to the PC/SC disposition
+func resetDisposition(mode domain.ResetMode) scard.Disposition {
+	switch mode {
+	case domain.ResetWarm:
+		return scard.ResetCard
+	case domain.ResetCold:
+		return scard.UnpowerCard
+	default:
+		return scard.LeaveCard
+	}
+}
+
+// BeginTransaction acquires exclusive access to the card so that other
+// applications (browser middleware, vendor tools) cannot interleave APDUs.
+// Nested calls only increment a counter.
+func (t *PCSCTransport) BeginTransaction(ctx context.Context) error {
+	if err := t.invalidation(); err != nil {
+		return err
+	}
+	if t.card == nil {
+		return domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if t.txDepth > 0 {
+		t.txDepth++
+		return nil
+	}
+
+	// SCardBeginTransaction blocks while another application holds the card
+	if err := t.await(ctx, t.card.BeginTransaction); err != nil {
+		return t.failure(err, domain.ErrReaderBusy, "failed to begin transaction")
+	}
+	t.txDepth = 1
+	return nil
+}
+
+// EndTransaction releases exclusive access once the outermost transaction ends.
+// A reset reported here means another application reset the card while we
+// held it, so the session is aborted.
+func (t *PCSCTransport) EndTransaction() error {
+	if t.card == nil || t.txDepth == 0 {
+		return nil
+	}
+	t.txDepth--
+	if t.txDepth > 0 {
+		return nil
+	}
+
+	if err := t.card.EndTransaction(LeaveCard); err != nil {
+		return t.failure(err, domain.ErrTransmissionFailed, "failed to end transaction")
+	}
+	return nil
+}
+
+// Control sends a control command to the reader (SCardControl)
+func (t *PCSCTransport) Control(ctx context.Context, code uint32, in []byte) ([]byte, error) {
+	if err := t.invalidation(); err != nil {
+		return nil, err
+	}
+	if t.card == nil {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+
+	card := t.card
+	var out []byte
+	err := t.await(ctx, func() (err error) {
+		out, err = card.Control(code, in)
+		return err
+	})
+	if err != nil {
+		transportErr := t.failure(err, domain.ErrTransmissionFailed,
+			"reader control command failed")
+		if t.logger != nil {
+			t.logger.LogError(transportErr)
+		}
+		return nil, transportErr
+	}
+	return out, nil
+}
+
+// ReaderFeatures returns the PC/SC part 10 features of the selected reader,
+// queried once per connection
+func (t *PCSCTransport) ReaderFeatures(ctx context.Context) (domain.ReaderFeatures, error) {
+	if t.features != nil {
+		return t.features, nil
+	}
+	if t.card == nil {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+
+	card := t.card
+	var features domain.ReaderFeatures
+	err := t.await(ctx, func() error {
+		features = cardFeatures(card)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.features = features
+	if t.logger != nil && len(features) > 0 {
+		t.logger.LogInfo("Reader features: %v", features.List())
+	}
+	return features, nil
+}
+
+// cardFeatures sends GET_FEATURE_REQUEST. Readers without part 10 support
+// fail it with various errors, which all mean no features.
+func cardFeatures(card *scard.Card) domain.ReaderFeatures {
+	out, err := card.Control(scard.CtlCode(domain.FeatureRequestFunction), nil)
+	if err != nil {
+		return domain.ReaderFeatures{}
+	}
+	features, err := domain.ParseReaderFeatures(out)
+	if err != nil {
+		return domain.ReaderFeatures{}
+	}
+	return features
+}
+
+// await runs a blocking PC/SC call. If ctx ends first, the session is
+// abandoned: the call is cancelled where PC/SC allows it, the card handle is
+// reset and released in the background once the call returns, and an
+// ErrTimeout TransportError is returned. The reader is never left locked by a
+// stuck request.
+func (t *PCSCTransport) await(ctx context.Context, call func() error) error {
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	if ctx.Done() == nil {
+		return call() // Context can never be cancelled
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- call() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		timeoutErr := domain.NewTimeoutError(ctx.Err())
+		t.abandon(done, timeoutErr)
+		return timeoutErr
+	}
+}
+
+// abandon detaches the current card handle and context from the transport
+// and cleans them up after the pending call completes
+func (t *PCSCTransport) abandon(pending <-chan error, cause *domain.TransportError) {
+	card, pcsc := t.card, t.context
+	t.card, t.context, t.txDepth = nil, nil, 0
+	t.InvalidateSession(t.selectedReader, cause)
+
+	if pcsc != nil {
+		pcsc.Cancel()
+	}
+	go func() {
+		<-pending
+		if card != nil {
+			// The card state is unknown mid-command: reset it
+			card.Disconnect(scard.ResetCard)
+		}
+		if pcsc != nil {
+			pcsc.Release()
+		}
+	}()
+}
+
+// failure translates an error from a card call and aborts the session when
+// PC/SC reports that the card was reset or removed. Security state (verified
+// PINs, secure messaging keys) is gone in both cases, so every later
+// operation fails until Connect is called again.
+func (t *PCSCTransport) failure(err error, fallback domain.TransportErrorCode, message string) *domain.TransportError {
+	if transportErr, ok := err.(*domain.TransportError); ok {
+		return transportErr // Already translated (timeout in await)
+	}
+
+	transportErr := pcscError(err, fallback, message)
+	switch transportErr.Code {
+	case domain.ErrCardReset, domain.ErrCardRemoved, domain.ErrNoCard:
+		t.txDepth = 0
+		t.InvalidateSession(t.selectedReader, transportErr)
+	}
+	return transportErr
+}
+
+// InvalidateSession marks the session on a reader as dead (card removed or
+// reset, command abandoned after a timeout).
+// It is safe to call from a CardMonitor goroutine; the next Transmit on that
+// reader fails with the given cause until Connect is called again.
+func (t *PCSCTransport) InvalidateSession(reader string, cause *domain.TransportError) {
+	t.invalidatedMu.Lock()
+	defer t.invalidatedMu.Unlock()
+	if t.invalidated == nil {
+		t.invalidated = make(map[string]*domain.TransportError)
+	}
+	t.invalidated[reader] = cause
+}
+
+// invalidation returns the error recorded for the selected reader, if any
+func (t *PCSCTransport) invalidation() *domain.TransportError {
+	t.invalidatedMu.Lock()
+	defer t.invalidatedMu.Unlock()
+	return t.invalidated[t.selectedReader]
+}
+
+// clearInvalidation forgets a previous invalidation of the selected reader
+func (t *PCSCTransport) clearInvalidation() {
+	t.invalidatedMu.Lock()
+	defer t.invalidatedMu.Unlock()
+	delete(t.invalidated, t.selectedReader)
+}
+
 // Disconnect closes the card connection
 func (t *PCSCTransport) Disconnect() error {
 	if t.logger != nil {
 		t.logger.LogDisconnect()
 	}
 	if t.card != nil {
+		if t.txDepth > 0 {
+			t.card.EndTransaction(LeaveCard)
+			t.txDepth = 0
+		}
 		t.card.Disconnect(LeaveCard)
 		t.card = nil
 	}
@@ -137,8 +446,8 @@ func (t *PCSCTransport) Status() (*domain.CardStatus, error) {
 
 	status, err := t.card.Status()
 	if err != nil {
-		return nil, domain.NewTransportError(domain.ErrConnectionLost,
-			"failed to get card status", err)
+		return nil, pcscError(err, domain.ErrConnectionLost,
+			"failed to get card status")
 	}
 
 	protocol := "T=0"
@@ -146,29 +455,115 @@ func (t *PCSCTransport) Status() (*domain.CardStatus, error) {
 		protocol = "T=1"
 	}
 
-	return &domain.CardStatus{
-		ATR:            status.Atr,
-		ActiveProtocol: protocol,
-		Reader:         t.selectedReader,
-	}, nil
+	return domain.NewCardStatus(status.Atr, protocol, t.selectedReader), nil
+}
+
+// ListReaders returns available smart card readers. It works before Connect
+// by opening a short-lived PC/SC context.
+func (t *PCSCTransport) ListReaders() ([]string, error) {
+	readers, err := t.readers(false)
+	if err != nil {
+		return nil, err
+	}
+	return readers.Names(), nil
+}
+
+// Readers returns available readers with card presence, ATR and features.
+// The features are queried through a direct connection to each reader.
+func (t *PCSCTransport) Readers() (domain.ReaderList, error) {
+	return t.readers(true)
+}
+
+// readers lists the readers with the connection's context or a short-lived one
+func (t *PCSCTransport) readers(withFeatures bool) (domain.ReaderList, error) {
+	if t.context != nil {
+		return readerStates(t.context, withFeatures)
+	}
+
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, pcscError(err, domain.ErrNoContext,
+			"failed to establish PC/SC context")
+	}
+	defer ctx.Release()
+	return readerStates(ctx, withFeatures)
+}
+
+// pcscReader is a snapshot of a PC/SC reader state
+type pcscReader struct {
+	name        string
+	cardPresent bool
+	atr         []byte
+	features    domain.ReaderFeatures
 }
 
-// selectReader chooses a reader, preferring "Generic EMV" readers
-func (t *PCSCTransport) selectReader(readers []string) string {
-	for _, reader := range readers {
-		if strings.Contains(reader, "Generic EMV") {
-			return reader
+// Name returns the reader name
+func (r *pcscReader) Name() string {
+	return r.name
+}
+
+// IsConnected checks if a card is present
+func (r *pcscReader) IsConnected() bool {
+	return r.cardPresent
+}
+
+// ATR returns the ATR of the present card
+func (r *pcscReader) ATR() []byte {
+	return r.atr
+}
+
+// Features returns the PC/SC part 10 features of the reader
+func (r *pcscReader) Features() domain.ReaderFeatures {
+	return r.features
+}
+
+// readerStates lists readers and queries their current state without
+// blocking, and with withFeatures their part 10 features
+func readerStates(ctx *scard.Context, withFeatures bool) (domain.ReaderList, error) {
+	names, err := ctx.ListReaders()
+	if err != nil {
+		if err == scard.ErrNoReadersAvailable {
+			return nil, domain.NewTransportError(domain.ErrNoReaders,
+				"no smart card readers found", nil)
 		}
+		return nil, pcscError(err, domain.ErrNoReaders,
+			"failed to list readers")
+	}
+	if len(names) == 0 {
+		return nil, domain.NewTransportError(domain.ErrNoReaders,
+			"no smart card readers found", nil)
 	}
-	// Fallback to first reader
-	return readers[0]
+
+	states := make([]scard.ReaderState, len(names))
+	for i, name := range names {
+		states[i] = scard.ReaderState{Reader: name, CurrentState: scard.StateUnaware}
+	}
+	// Without state information every reader is reported empty; name and
+	// index based selection still work
+	statesKnown := ctx.GetStatusChange(states, 0) == nil
+
+	readers := make(domain.ReaderList, len(names))
+	for i, name := range names {
+		reader := &pcscReader{name: name}
+		if statesKnown && states[i].EventState&scard.StatePresent != 0 {
+			reader.cardPresent = true
+			reader.atr = states[i].Atr
+		}
+		if withFeatures {
+			reader.features = readerFeatures(ctx, name)
+		}
+		readers[i] = reader
+	}
+	return readers, nil
 }
 
-// ListReaders returns available smart card readers
-func (t *PCSCTransport) ListReaders() ([]string, error) {
-	if t.context == nil {
-		return nil, domain.NewTransportError(domain.ErrNoContext,
-			"not connected to PC/SC", nil)
+// readerFeatures queries the features of a reader through a direct
+// connection, which works with or without a card
+func readerFeatures(ctx *scard.Context, name string) domain.ReaderFeatures {
+	card, err := ctx.Connect(name, scard.ShareDirect, 0)
+	if err != nil {
+		return nil
 	}
-	return t.context.ListReaders()
+	defer card.Disconnect(LeaveCard)
+	return cardFeatures(card)
 }
@@ -0,0 +1,157 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+	"github.com/ebfe/scard"
+)
+
+func TestPCSCTransport_InvalidateSession(t *testing.T) {
+	transport := NewPCSCTransport()
+	transport.selectedReader = "Reader A 00 00"
+
+	transport.InvalidateSession("Reader B 01 00",
+		domain.NewTransportError(domain.ErrCardRemoved, "card removed from reader", nil))
+	if err := transport.invalidation(); err != nil {
+		t.Errorf("invalidation() = %v, want nil for another reader", err)
+	}
+
+	transport.InvalidateSession("Reader A 00 00",
+		domain.NewTransportError(domain.ErrCardRemoved, "card removed from reader", nil))
+	if err := transport.invalidation(); err == nil || err.Code != domain.ErrCardRemoved {
+		t.Errorf("invalidation() = %v, want ErrCardRemoved", err)
+	}
+
+	transport.clearInvalidation()
+	if err := transport.invalidation(); err != nil {
+		t.Errorf("invalidation() after clear = %v, want nil", err)
+	}
+}
+
+func TestPCSCTransport_ReconnectNotConnected(t *testing.T) {
+	transport := NewPCSCTransport()
+
+	err := transport.Reconnect(context.Background(), domain.ResetWarm)
+	if !errors.Is(err, domain.ErrNoCard) {
+		t.Errorf("Reconnect() error = %v, want ErrNoCard", err)
+	}
+}
+
+func TestResetDisposition(t *testing.T) {
+	tests := []struct {
+		mode domain.ResetMode
+		want scard.Disposition
+	}{
+		{domain.ResetLeave, scard.LeaveCard},
+		{domain.ResetWarm, scard.ResetCard},
+		{domain.ResetCold, scard.UnpowerCard},
+	}
+	for _, tt := range tests {
+		if got := resetDisposition(tt.mode); got != tt.want {
+			t.Errorf("resetDisposition(%s) = %d, want %d", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestPCSCTransport_Failure(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCode  domain.TransportErrorCode
+		wantAbort bool
+	}{
+		{"card reset", scard.ErrResetCard, domain.ErrCardReset, true},
+		{"card removed", scard.ErrRemovedCard, domain.ErrCardRemoved, true},
+		{"no card", scard.ErrNoSmartcard, domain.ErrNoCard, true},
+		{"sharing violation", scard.ErrSharingViolation, domain.ErrReaderBusy, false},
+		{"unmapped", scard.ErrInternalError, domain.ErrTransmissionFailed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewPCSCTransport()
+			transport.selectedReader = "Reader A 00 00"
+			transport.txDepth = 2
+
+			err := transport.failure(tt.err, domain.ErrTransmissionFailed, "APDU transmission failed")
+			if err.Code != tt.wantCode || !errors.Is(err, tt.err) {
+				t.Fatalf("failure() = %v (code %d), want code %d wrapping %v", err, err.Code, tt.wantCode, tt.err)
+			}
+
+			if !tt.wantAbort {
+				if transport.txDepth != 2 || transport.invalidation() != nil {
+					t.Errorf("failure(%v) should keep the session", tt.err)
+				}
+				return
+			}
+			if transport.txDepth != 0 {
+				t.Errorf("txDepth = %d, want 0 after abort", transport.txDepth)
+			}
+			if transport.invalidation() != err {
+				t.Errorf("invalidation() = %v, want the session aborted", transport.invalidation())
+			}
+		})
+	}
+}
+
+func TestPCSCTransport_TransactionNotConnected(t *testing.T) {
+	transport := NewPCSCTransport()
+
+	var transportErr *domain.TransportError
+	if err := transport.BeginTransaction(context.Background()); !errors.As(err, &transportErr) || transportErr.Code != domain.ErrNoCard {
+		t.Errorf("BeginTransaction() error = %v, want ErrNoCard", err)
+	}
+	if err := transport.EndTransaction(); err != nil {
+		t.Errorf("EndTransaction() error = %v, want nil", err)
+	}
+}
+
+func TestPCSCTransport_AwaitTimeout(t *testing.T) {
+	transport := NewPCSCTransport()
+	transport.selectedReader = "Reader A 00 00"
+	transport.txDepth = 1
+
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := transport.await(ctx, func() error {
+		<-release // A reader that never answers
+		return nil
+	})
+
+	var transportErr *domain.TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != domain.ErrTimeout {
+		t.Fatalf("await() error = %v, want ErrTimeout", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("await() error = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+	if transport.txDepth != 0 {
+		t.Errorf("txDepth = %d, want 0 after abandoning the session", transport.txDepth)
+	}
+	if transport.invalidation() != transportErr {
+		t.Errorf("invalidation() = %v, want the abandoned session to stay failed", transport.invalidation())
+	}
+}
+
+func TestPCSCTransport_AwaitCancelledBeforeCall(t *testing.T) {
+	transport := NewPCSCTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err := transport.await(ctx, func() error {
+		called = true
+		return nil
+	})
+	var transportErr *domain.TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != domain.ErrTimeout || called {
+		t.Errorf("await() error = %v, called = %v; want ErrTimeout without calling PC/SC", err, called)
+	}
+}
@@ -0,0 +1,212 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// DefaultPinentryProgram is the GnuPG PIN dialog started by PinentryProvider
+const DefaultPinentryProgram = "pinentry"
+
+// Assuan protocol limits and error codes (libassuan, libgpg-error)
+const (
+	assuanLineLength  = 1000
+	gpgErrCanceled    = 99
+	gpgErrCodeMask    = 0xFFFF
+	pinentryTitleText = "Romanian eID"
+)
+
+// PinentryProvider asks for secrets through a GnuPG pinentry program, which
+// speaks the Assuan protocol on its standard input and output. One pinentry
+// process is started per request.
+type PinentryProvider struct {
+	program string
+}
+
+// NewPinentryProvider creates a provider running program (DefaultPinentryProgram
+// when empty)
+func NewPinentryProvider(program string) *PinentryProvider {
+	if program == "" {
+		program = DefaultPinentryProgram
+	}
+	return &PinentryProvider{program: program}
+}
+
+// RequestSecret implements domain.PINProvider; cancelling ctx kills pinentry
+func (p *PinentryProvider) RequestSecret(ctx context.Context, req domain.PINRequest) (*domain.Secret, error) {
+	cmd := exec.CommandContext(ctx, p.program)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", p.program, err)
+	}
+
+	secret, err := pinentrySession(stdout, stdin, req)
+	stdin.Close()
+	waitErr := cmd.Wait()
+	switch {
+	case ctx.Err() != nil:
+		secret.Wipe()
+		return nil, ctx.Err()
+	case err != nil:
+		return nil, err
+	case waitErr != nil:
+		secret.Wipe()
+		return nil, fmt.Errorf("%s failed: %w", p.program, waitErr)
+	}
+	return secret, nil
+}
+
+// pinentrySession runs the Assuan dialog of one PIN request: set the texts,
+// GETPIN, BYE
+func pinentrySession(r io.Reader, w io.Writer, req domain.PINRequest) (*domain.Secret, error) {
+	session := &assuanSession{r: r, w: w}
+	if _, err := session.response(); err != nil {
+		return nil, fmt.Errorf("pinentry greeting: %w", err)
+	}
+
+	commands := []string{
+		"SETTITLE " + assuanEscape(pinentryTitleText),
+		"SETDESC " + assuanEscape(req.Description()),
+		"SETPROMPT " + assuanEscape(req.Name+":"),
+	}
+	if req.RetriesLeft == 1 {
+		commands = append(commands, "SETERROR "+assuanEscape("Last try"))
+	}
+	for _, command := range commands {
+		if _, err := session.command(command); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := session.command("GETPIN")
+	if err != nil {
+		return nil, err
+	}
+	session.command("BYE")
+	if len(data) == 0 {
+		return nil, domain.ErrPINEntryCancelled
+	}
+	return domain.NewSecret(data), nil
+}
+
+// assuanSession is the client side of an Assuan connection
+type assuanSession struct {
+	r io.Reader
+	w io.Writer
+}
+
+// command sends a command and returns the data lines of its response
+func (s *assuanSession) command(line string) ([]byte, error) {
+	if _, err := io.WriteString(s.w, line+"\n"); err != nil {
+		return nil, err
+	}
+	return s.response()
+}
+
+// response reads lines up to OK or ERR, decoding D lines into one buffer.
+// Lines are read byte by byte so that only the returned buffer holds the PIN.
+func (s *assuanSession) response() ([]byte, error) {
+	data := make([]byte, 0, assuanLineLength) // Not reallocated for one D line
+	for {
+		line, err := readAssuanLine(s.r)
+		if err != nil {
+			clear(line)
+			clear(data)
+			return nil, err
+		}
+		switch {
+		case bytes.Equal(line, []byte("OK")) || bytes.HasPrefix(line, []byte("OK ")):
+			return data, nil
+		case bytes.HasPrefix(line, []byte("D ")):
+			data = assuanUnescape(data, line[2:])
+			clear(line)
+		case bytes.HasPrefix(line, []byte("ERR ")):
+			clear(data)
+			return nil, assuanError(string(line[4:]))
+		}
+		// Status (S) and comment (#) lines are ignored
+	}
+}
+
+// readAssuanLine reads one line without its newline
+func readAssuanLine(r io.Reader) ([]byte, error) {
+	line := make([]byte, 0, 64)
+	var b [1]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return line, err
+		}
+		if b[0] == '\n' {
+			return line, nil
+		}
+		if len(line) == assuanLineLength {
+			return line, fmt.Errorf("Assuan line longer than %d bytes", assuanLineLength)
+		}
+		line = append(line, b[0])
+		b[0] = 0
+	}
+}
+
+// assuanError converts "ERR <code> <description>"; cancelling the dialog
+// gives domain.ErrPINEntryCancelled
+func assuanError(text string) error {
+	codeText, description, _ := strings.Cut(text, " ")
+	code, err := strconv.ParseUint(codeText, 10, 32)
+	if err == nil && code&gpgErrCodeMask == gpgErrCanceled {
+		return domain.ErrPINEntryCancelled
+	}
+	return fmt.Errorf("pinentry error %s: %s", codeText, description)
+}
+
+// assuanEscape percent-encodes the characters Assuan does not allow in lines
+func assuanEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// assuanUnescape appends the percent-decoded encoded to dst
+func assuanUnescape(dst, encoded []byte) []byte {
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] == '%' && i+2 < len(encoded) {
+			high, okHigh := unhex(encoded[i+1])
+			low, okLow := unhex(encoded[i+2])
+			if okHigh && okLow {
+				dst = append(dst, high<<4|low)
+				i += 2
+				continue
+			}
+		}
+		dst = append(dst, encoded[i])
+	}
+	return dst
+}
+
+// unhex decodes one hex digit
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
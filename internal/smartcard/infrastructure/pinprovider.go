@@ -0,0 +1,123 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// maxSecretLine bounds the length of a line read as a secret
+const maxSecretLine = 256
+
+// TerminalPINProvider reads secrets from a terminal without echoing them,
+// so they stay out of the screen and the scrollback. An empty entry cancels.
+type TerminalPINProvider struct {
+	in  *os.File
+	out io.Writer
+}
+
+// NewTerminalPINProvider creates a provider reading from in (a terminal) and
+// writing the prompts to out
+func NewTerminalPINProvider(in *os.File, out io.Writer) *TerminalPINProvider {
+	return &TerminalPINProvider{in: in, out: out}
+}
+
+// RequestSecret implements domain.PINProvider. The read cannot be interrupted
+// by ctx; it is only checked before prompting.
+func (p *TerminalPINProvider) RequestSecret(ctx context.Context, req domain.PINRequest) (*domain.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fd := int(p.in.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("%s is not a terminal; read the %s from a file descriptor instead", p.in.Name(), req.Name)
+	}
+
+	fmt.Fprintf(p.out, "%s: ", req.Prompt())
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(p.out)
+	if err != nil {
+		clear(value)
+		return nil, fmt.Errorf("failed to read the %s: %w", req.Name, err)
+	}
+	if len(value) == 0 {
+		return nil, domain.ErrPINEntryCancelled
+	}
+	return domain.NewSecret(value), nil
+}
+
+// PINPadEntry implements domain.PINPadNotifier
+func (p *TerminalPINProvider) PINPadEntry(req domain.PINRequest) {
+	fmt.Fprintf(p.out, "%s on the reader's PIN pad\n", req.Prompt())
+}
+
+// ReaderPINProvider reads one secret per line from a pre-opened file
+// descriptor or pipe, for automation. Lines are read byte by byte so that no
+// buffer keeps a copy of the secrets; end of input cancels.
+type ReaderPINProvider struct {
+	in io.Reader
+}
+
+// NewReaderPINProvider creates a provider reading lines from in
+func NewReaderPINProvider(in io.Reader) *ReaderPINProvider {
+	return &ReaderPINProvider{in: in}
+}
+
+// NewFDPINProvider creates a provider reading lines from file descriptor fd
+// (e.g. 3 for "3< pins.txt" in a shell)
+func NewFDPINProvider(fd uintptr) *ReaderPINProvider {
+	return NewReaderPINProvider(os.NewFile(fd, fmt.Sprintf("fd %d", fd)))
+}
+
+// RequestSecret implements domain.PINProvider
+func (p *ReaderPINProvider) RequestSecret(ctx context.Context, req domain.PINRequest) (*domain.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	line, err := readSecretLine(p.in)
+	if errors.Is(err, io.EOF) && len(line) == 0 {
+		return nil, domain.ErrPINEntryCancelled
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		clear(line)
+		return nil, fmt.Errorf("failed to read the %s: %w", req.Name, err)
+	}
+	return domain.NewSecret(line), nil
+}
+
+// readSecretLine reads up to a newline, dropping it and a carriage return.
+// On error the bytes read so far are returned with it.
+func readSecretLine(in io.Reader) ([]byte, error) {
+	line := make([]byte, 0, maxSecretLine)
+	var b [1]byte
+	for {
+		n, err := in.Read(b[:])
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			if len(line) == maxSecretLine {
+				clear(line)
+				b[0] = 0
+				return nil, fmt.Errorf("line longer than %d bytes", maxSecretLine)
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			b[0] = 0
+			return line, err
+		}
+	}
+	b[0] = 0
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line[n-1] = 0
+		line = line[:n-1]
+	}
+	return line, nil
+}
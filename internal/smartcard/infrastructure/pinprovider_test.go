@@ -0,0 +1,98 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+func TestReaderPINProvider(t *testing.T) {
+	provider := NewReaderPINProvider(strings.NewReader("1234\r\n123456\n"))
+	ctx := context.Background()
+
+	for _, want := range []string{"1234", "123456"} {
+		secret, err := provider.RequestSecret(ctx, domain.PINRequest{Name: "PIN1"})
+		if err != nil {
+			t.Fatalf("RequestSecret() error = %v", err)
+		}
+		if string(secret.Bytes()) != want {
+			t.Errorf("RequestSecret() = %q, want %q", secret.Bytes(), want)
+		}
+		secret.Wipe()
+	}
+
+	if _, err := provider.RequestSecret(ctx, domain.PINRequest{Name: "PIN1"}); !errors.Is(err, domain.ErrPINEntryCancelled) {
+		t.Errorf("RequestSecret() at end of input error = %v, want ErrPINEntryCancelled", err)
+	}
+}
+
+func TestReaderPINProvider_LastLineWithoutNewline(t *testing.T) {
+	secret, err := NewReaderPINProvider(strings.NewReader("9999")).RequestSecret(context.Background(), domain.PINRequest{})
+	if err != nil || string(secret.Bytes()) != "9999" {
+		t.Errorf("RequestSecret() = %q, %v, want 9999", secret.Bytes(), err)
+	}
+}
+
+func TestPinentrySession(t *testing.T) {
+	pinentry := strings.NewReader(strings.Join([]string{
+		"OK Pleased to meet you",
+		"OK", "OK", "OK", "OK",
+		"# comment",
+		"S PASSPHRASE_QUALITY 0",
+		"D 12%2534",
+		"OK",
+		"OK closing connection",
+	}, "\n") + "\n")
+	commands := &bytes.Buffer{}
+	request := domain.PINRequest{Kind: domain.SecretPIN, Name: "PIN1", Format: domain.CEIPIN1Format, RetriesLeft: 1}
+
+	secret, err := pinentrySession(pinentry, commands, request)
+	if err != nil {
+		t.Fatalf("pinentrySession() error = %v", err)
+	}
+	if string(secret.Bytes()) != "12%34" {
+		t.Errorf("secret = %q, want %q", secret.Bytes(), "12%34")
+	}
+
+	want := []string{
+		"SETTITLE Romanian eID",
+		"SETDESC The identity card needs the PIN1 (4 digits). Only one try is left: a wrong value blocks it.",
+		"SETPROMPT PIN1:",
+		"SETERROR Last try",
+		"GETPIN",
+		"BYE",
+	}
+	if got := strings.Split(strings.TrimSuffix(commands.String(), "\n"), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("commands =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPinentrySession_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		pinentry string
+		want     error
+	}{
+		{"cancelled", "OK\nOK\nOK\nOK\nERR 83886179 Operation cancelled <Pinentry>\n", domain.ErrPINEntryCancelled},
+		{"empty PIN", "OK\nOK\nOK\nOK\nOK\nOK\n", domain.ErrPINEntryCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pinentrySession(strings.NewReader(tt.pinentry), &bytes.Buffer{},
+				domain.PINRequest{Name: "PIN1", Format: domain.CEIPIN1Format, RetriesLeft: -1})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("pinentrySession() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	_, err := pinentrySession(strings.NewReader("OK\nERR 1 failure\n"), &bytes.Buffer{}, domain.PINRequest{})
+	if err == nil || !strings.Contains(err.Error(), "pinentry error 1: failure") {
+		t.Errorf("pinentrySession() error = %v, want pinentry error", err)
+	}
+}
@@ -0,0 +1,234 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// RecordingCard decorates a card and records every operation into a
+// CardTrace that a ReplayCard can serve back. Transactions and reconnects
+// are passed to the card when it supports them and recorded either way.
+type RecordingCard struct {
+	connector domain.Connector // Set when recording from Connect
+	card      domain.Card
+	started   time.Time
+
+	mu    sync.Mutex // Random reads may come from another goroutine
+	trace CardTrace
+}
+
+// NewRecordingCard records an already connected card
+func NewRecordingCard(card domain.Card) *RecordingCard {
+	r := &RecordingCard{card: card}
+	r.begin()
+	return r
+}
+
+// NewRecordingConnector records the session opened by the next Connect
+func NewRecordingConnector(connector domain.Connector) *RecordingCard {
+	return &RecordingCard{connector: connector}
+}
+
+// SetLogger forwards the logger to the recorded connector
+func (r *RecordingCard) SetLogger(logger *APDULogger) {
+	if l, ok := r.connector.(LoggerSetter); ok {
+		l.SetLogger(logger)
+	}
+}
+
+// Connect connects the recorded connector and starts a new trace
+func (r *RecordingCard) Connect(ctx context.Context) (domain.Card, error) {
+	if r.connector == nil {
+		return r, nil
+	}
+	card, err := r.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.card = card
+	r.begin()
+	return r, nil
+}
+
+// begin resets the trace for the connected card
+func (r *RecordingCard) begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.started = time.Now()
+	r.trace = CardTrace{Version: CardTraceVersion, Recorded: r.started}
+	if status, err := r.card.Status(); err == nil {
+		r.trace.Status = newTraceStatus(status)
+	}
+}
+
+// Transmit forwards the command and records it with the response or error
+func (r *RecordingCard) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if r.card == nil {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	start := time.Now()
+	resp, err := r.card.Transmit(ctx, apdu)
+
+	entry := TraceEntry{
+		Kind:    TraceExchange,
+		Elapsed: time.Since(start),
+		Command: hex.EncodeToString(apdu.Bytes()),
+	}
+	if err != nil {
+		entry.Error = newTraceError(err)
+	} else {
+		entry.Response = hex.EncodeToString(append(append([]byte{}, resp.Data...), resp.SW1, resp.SW2))
+	}
+	r.record(start, entry)
+	return resp, err
+}
+
+// BeginTransaction records and forwards the transaction start
+func (r *RecordingCard) BeginTransaction(ctx context.Context) error {
+	r.record(time.Now(), TraceEntry{Kind: TraceBeginTransaction})
+	if transactor, ok := r.card.(domain.Transactor); ok {
+		return transactor.BeginTransaction(ctx)
+	}
+	return nil
+}
+
+// EndTransaction records and forwards the transaction end
+func (r *RecordingCard) EndTransaction() error {
+	r.record(time.Now(), TraceEntry{Kind: TraceEndTransaction})
+	if transactor, ok := r.card.(domain.Transactor); ok {
+		return transactor.EndTransaction()
+	}
+	return nil
+}
+
+// Reconnect records and forwards a reconnect
+func (r *RecordingCard) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	r.record(time.Now(), TraceEntry{Kind: TraceReconnect, Mode: mode})
+	reconnector, ok := r.card.(domain.Reconnector)
+	if !ok {
+		return domain.NewTransportError(domain.ErrConnectionLost,
+			"card does not support reconnecting", nil)
+	}
+	return reconnector.Reconnect(ctx, mode)
+}
+
+// ReaderFeatures returns the features of the recorded card's reader, empty
+// when it does not accept control commands, and records them in the trace
+// status
+func (r *RecordingCard) ReaderFeatures(ctx context.Context) (domain.ReaderFeatures, error) {
+	controller, ok := r.card.(domain.Controller)
+	if !ok {
+		return domain.ReaderFeatures{}, nil
+	}
+	features, err := controller.ReaderFeatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Status.Features = features
+	return features, nil
+}
+
+// Control forwards a reader control command (e.g. PIN pad entry) and records
+// it with its output or error
+func (r *RecordingCard) Control(ctx context.Context, code uint32, in []byte) ([]byte, error) {
+	controller, ok := r.card.(domain.Controller)
+	if !ok {
+		return nil, errNoReaderControl()
+	}
+	start := time.Now()
+	out, err := controller.Control(ctx, code, in)
+
+	entry := TraceEntry{
+		Kind:    TraceControl,
+		Elapsed: time.Since(start),
+		Control: code,
+		Command: hex.EncodeToString(in),
+	}
+	if err != nil {
+		entry.Error = newTraceError(err)
+	} else {
+		entry.Response = hex.EncodeToString(out)
+	}
+	r.record(start, entry)
+	return out, err
+}
+
+// Random wraps a random source (e.g. crypto/rand.Reader) so that the bytes
+// drawn for PACE nonces and ephemeral keys are recorded in order with the
+// exchanges. A replay serves them back from ReplayCard.Random.
+func (r *RecordingCard) Random(source io.Reader) io.Reader {
+	return &recordingReader{recorder: r, source: source}
+}
+
+// Disconnect disconnects the recorded card
+func (r *RecordingCard) Disconnect() error {
+	if r.card == nil {
+		return nil
+	}
+	return r.card.Disconnect()
+}
+
+// Status returns the recorded card's status
+func (r *RecordingCard) Status() (*domain.CardStatus, error) {
+	if r.card == nil {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	return r.card.Status()
+}
+
+// Trace returns a copy of the trace recorded so far
+func (r *RecordingCard) Trace() *CardTrace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trace := r.trace
+	trace.Entries = append([]TraceEntry(nil), r.trace.Entries...)
+	return &trace
+}
+
+// Save writes the trace recorded so far
+func (r *RecordingCard) Save(path string) error {
+	return r.Trace().Save(path)
+}
+
+// record appends an entry stamped with its offset in the session
+func (r *RecordingCard) record(at time.Time, entry TraceEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.Offset = at.Sub(r.started)
+	r.trace.Entries = append(r.trace.Entries, entry)
+}
+
+// recordingReader records the bytes read from a random source
+type recordingReader struct {
+	recorder *RecordingCard
+	source   io.Reader
+}
+
+// Read reads from the source and records what was read
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.source.Read(p)
+	if n > 0 {
+		rr.recorder.record(time.Now(), TraceEntry{Kind: TraceRandom, Random: hex.EncodeToString(p[:n])})
+	}
+	return n, err
+}
+
+// errNoReaderControl reports a control command for a card whose reader does
+// not accept them
+func errNoReaderControl() error {
+	return domain.NewTransportError(domain.ErrTransmissionFailed,
+		"reader does not accept control commands", nil)
+}
@@ -0,0 +1,175 @@
+package infrastructure
+
+import (
+	"strings"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// Instructions whose command data is a PIN, PUK or new reference data
+var secretInstructions = map[byte]bool{
+	0x20: true, // VERIFY
+	0x24: true, // CHANGE REFERENCE DATA
+	0x2C: true, // RESET RETRY COUNTER
+}
+
+// Instructions whose plain response data is personal data read from files
+var personalDataInstructions = map[byte]bool{
+	0xB0: true, 0xB1: true, // READ BINARY
+	0xB2: true, 0xB3: true, // READ RECORD
+	0xCA: true, 0xCB: true, // GET DATA
+}
+
+// Instructions whose plain command data is personal data written to files
+var personalDataWriteInstructions = map[byte]bool{
+	0xD0: true, 0xD1: true, // WRITE BINARY
+	0xD2: true, 0xE2: true, // WRITE RECORD, APPEND RECORD
+	0xD6: true, 0xD7: true, // UPDATE BINARY
+	0xDC: true, 0xDD: true, // UPDATE RECORD
+	0xDA: true, 0xDB: true, // PUT DATA
+}
+
+// Dynamic authentication data objects of PACE GENERAL AUTHENTICATE
+// (BSI TR-03110-3 §B.1) from which the password can be brute-forced offline
+var pacePasswordObjects = map[uint32]bool{
+	0x80: true, // Encrypted nonce
+	0x85: true, // Authentication token of the terminal
+	0x86: true, // Authentication token of the card
+}
+
+const (
+	insManageSecurityEnvironment byte = 0x22
+	insGeneralAuthenticate       byte = 0x86
+	insGetResponse               byte = 0xC0
+	tagDynamicAuthData                = 0x7C
+)
+
+// span is a half-open range of masked bytes
+type span struct {
+	start, end int
+}
+
+// commandRedactions returns the bytes of a command APDU that must not be
+// logged: the data field of PIN commands and of plain writes, and the PACE
+// password objects. Writes under secure messaging are cryptograms and stay
+// visible.
+func commandRedactions(apdu []byte) []span {
+	if len(apdu) < 4 {
+		return nil
+	}
+	start, end := commandDataRange(apdu)
+	if start == end {
+		return nil
+	}
+	switch ins := apdu[1]; {
+	case secretInstructions[ins]:
+		return []span{{start, end}}
+	case personalDataWriteInstructions[ins] && domain.CLASecureMessaging(apdu[0]) == 0:
+		return []span{{start, end}}
+	case ins == insGeneralAuthenticate:
+		return paceRedactions(apdu[start:end], start)
+	}
+	return nil
+}
+
+// redactedCommand renders a command APDU as hex with its secrets masked, for
+// errors that quote commands and may end up in logs
+func redactedCommand(apdu []byte) string {
+	return maskedHex(apdu, commandRedactions(apdu))
+}
+
+// responseRedactions returns the bytes of a response that must not be
+// logged, given the header of the command it answers. Responses under
+// secure messaging are cryptograms and stay visible.
+func responseRedactions(cla, ins byte, response []byte) []span {
+	if len(response) <= 2 {
+		return nil
+	}
+	data := response[:len(response)-2]
+	switch {
+	case ins == insGeneralAuthenticate:
+		return paceRedactions(data, 0)
+	case personalDataInstructions[ins] && domain.CLASecureMessaging(cla) == 0:
+		return []span{{0, len(data)}}
+	}
+	return nil
+}
+
+// paceRedactions masks the values of the password objects in a dynamic
+// authentication data template at offset in the APDU. A template that does
+// not parse is masked entirely.
+func paceRedactions(data []byte, offset int) []span {
+	objects, err := domain.ParseBERTLV(data)
+	if err != nil {
+		return []span{{offset, offset + len(data)}}
+	}
+	template, ok := domain.FindTLV(objects, tagDynamicAuthData)
+	if !ok {
+		return nil
+	}
+	children, err := template.Children()
+	if err != nil {
+		return []span{{offset + template.ValueOffset(), offset + template.ValueOffset() + len(template.Value)}}
+	}
+
+	var spans []span
+	for _, child := range children {
+		if pacePasswordObjects[child.Tag] {
+			start := offset + child.ValueOffset()
+			spans = append(spans, span{start, start + len(child.Value)})
+		}
+	}
+	return spans
+}
+
+// commandDataRange returns where the data field of a command APDU lies,
+// with start == end when it has none (ISO/IEC 7816-4 §5.1). An Lc beyond
+// the APDU extends the range to its end.
+func commandDataRange(apdu []byte) (start, end int) {
+	switch {
+	case len(apdu) <= 5:
+		return 0, 0 // Cases 1 and 2S
+	case apdu[4] != 0x00:
+		start, end = 5, 5+int(apdu[4])
+	case len(apdu) <= 7:
+		return 0, 0 // Case 2E
+	default:
+		start, end = 7, 7+(int(apdu[5])<<8|int(apdu[6]))
+	}
+	return start, min(end, len(apdu))
+}
+
+// maskedHex renders data as hex with the masked bytes shown as "**"
+func maskedHex(data []byte, spans []span) string {
+	const digits = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(2 * len(data))
+	for i, c := range data {
+		if masked(i, spans) {
+			b.WriteString("**")
+			continue
+		}
+		b.WriteByte(digits[c>>4])
+		b.WriteByte(digits[c&0x0F])
+	}
+	return b.String()
+}
+
+// masked reports whether byte i is in one of spans
+func masked(i int, spans []span) bool {
+	for _, s := range spans {
+		if i >= s.start && i < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// maskedCount returns the number of bytes in spans
+func maskedCount(spans []span) int {
+	n := 0
+	for _, s := range spans {
+		n += s.end - s.start
+	}
+	return n
+}
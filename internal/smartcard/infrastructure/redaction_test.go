@@ -0,0 +1,237 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+func TestCommandDataRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		apdu       []byte
+		start, end int
+	}{
+		{"case 1", []byte{0x00, 0x20, 0x00, 0x01}, 0, 0},
+		{"case 2S", []byte{0x00, 0xB0, 0x00, 0x00, 0x10}, 0, 0},
+		{"case 3S", []byte{0x00, 0x20, 0x00, 0x01, 0x02, 0x31, 0x32}, 5, 7},
+		{"case 4S", []byte{0x00, 0xA4, 0x04, 0x00, 0x01, 0xD2, 0x00}, 5, 6},
+		{"case 2E", []byte{0x00, 0xB0, 0x00, 0x00, 0x00, 0x01, 0x00}, 0, 0},
+		{"case 3E", []byte{0x00, 0x2A, 0x9E, 0x9A, 0x00, 0x00, 0x02, 0xAA, 0xBB}, 7, 9},
+		{"Lc beyond APDU", []byte{0x00, 0x20, 0x00, 0x01, 0x08, 0x31}, 5, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := commandDataRange(tt.apdu)
+			if start != tt.start || end != tt.end {
+				t.Errorf("commandDataRange(%X) = %d, %d, want %d, %d", tt.apdu, start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestAPDULogger_Redaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  []byte
+		response []byte
+		want     []string // Rendered in the log
+		hidden   []string // Must not appear in the log
+	}{
+		{
+			name:     "VERIFY PIN",
+			command:  []byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34},
+			response: []byte{0x90, 0x00},
+			want:     []string{"0020000104********"},
+			hidden:   []string{"31323334"},
+		},
+		{
+			name:     "VERIFY retry counter query",
+			command:  []byte{0x00, 0x20, 0x00, 0x01},
+			response: []byte{0x63, 0xC3},
+			want:     []string{"00200001", "63C3"},
+		},
+		{
+			name:     "CHANGE REFERENCE DATA",
+			command:  []byte{0x00, 0x24, 0x00, 0x01, 0x08, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38},
+			response: []byte{0x90, 0x00},
+			want:     []string{"0024000108****************"},
+			hidden:   []string{"3132333435363738"},
+		},
+		{
+			name:     "RESET RETRY COUNTER",
+			command:  []byte{0x00, 0x2C, 0x00, 0x01, 0x02, 0x39, 0x39},
+			response: []byte{0x90, 0x00},
+			hidden:   []string{"3939"},
+		},
+		{
+			name: "PACE authentication tokens",
+			command: []byte{0x00, 0x86, 0x00, 0x00, 0x0C, 0x7C, 0x0A, 0x85, 0x08,
+				0xA1, 0xA2, 0xA3, 0xA4, 0xA5, 0xA6, 0xA7, 0xA8, 0x00},
+			response: []byte{0x7C, 0x0A, 0x86, 0x08, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7, 0xB8, 0x90, 0x00},
+			want:     []string{"008600000C7C0A8508****************00", "7C0A8608****************9000"},
+			hidden:   []string{"A1A2", "B1B2"},
+		},
+		{
+			name:     "PACE encrypted nonce",
+			command:  []byte{0x10, 0x86, 0x00, 0x00, 0x02, 0x7C, 0x00, 0x00},
+			response: []byte{0x7C, 0x04, 0x80, 0x02, 0xC1, 0xC2, 0x90, 0x00},
+			want:     []string{"7C048002****9000"},
+			hidden:   []string{"C1C2"},
+		},
+		{
+			name:     "PACE ephemeral public key stays visible",
+			command:  []byte{0x10, 0x86, 0x00, 0x00, 0x06, 0x7C, 0x04, 0x81, 0x02, 0xD1, 0xD2, 0x00},
+			response: []byte{0x7C, 0x04, 0x82, 0x02, 0xE1, 0xE2, 0x90, 0x00},
+			want:     []string{"D1D2", "E1E2"},
+		},
+		{
+			name:     "READ BINARY personal data",
+			command:  []byte{0x00, 0xB0, 0x81, 0x00, 0x07},
+			response: []byte{0x53, 0x55, 0x52, 0x4E, 0x41, 0x4D, 0x45, 0x90, 0x00}, // "SURNAME"
+			hidden:   []string{"5355524E414D45"},
+		},
+		{
+			name:     "READ BINARY under secure messaging",
+			command:  []byte{0x0C, 0xB0, 0x81, 0x00, 0x00},
+			response: []byte{0x87, 0x02, 0x01, 0xF1, 0x90, 0x00},
+			want:     []string{"870201F19000"},
+		},
+		{
+			name:     "SELECT",
+			command:  []byte{0x00, 0xA4, 0x04, 0x0C, 0x02, 0x3F, 0x00},
+			response: []byte{0x6F, 0x01, 0x00, 0x90, 0x00},
+			want:     []string{"00A4040C023F00", "6F01009000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			logger := NewAPDULoggerFormat(buf, LogFormatJSON)
+			logger.LogCommand(tt.command)
+			logger.LogResponse(tt.response)
+
+			output := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("log should contain %s:\n%s", want, output)
+				}
+			}
+			for _, hidden := range tt.hidden {
+				if strings.Contains(output, hidden) {
+					t.Errorf("log must not contain %s:\n%s", hidden, output)
+				}
+			}
+		})
+	}
+}
+
+func TestAPDULogger_RedactionAfterGetResponse(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULogger(buf)
+
+	logger.LogCommand([]byte{0x00, 0xB0, 0x81, 0x00, 0x00})
+	logger.LogResponse([]byte{0x61, 0x07})
+	logger.LogCommand([]byte{0x00, 0xC0, 0x00, 0x00, 0x07})
+	logger.LogResponse(append([]byte("SURNAME"), 0x90, 0x00))
+
+	if output := buf.String(); strings.Contains(output, "5355524E414D45") {
+		t.Errorf("GET RESPONSE data of a READ BINARY must be masked:\n%s", output)
+	}
+}
+
+func TestAPDULogger_UnsafeFullTrace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULogger(buf)
+	logger.SetUnsafeFullTrace(true)
+
+	if !strings.Contains(buf.String(), "UNSAFE FULL TRACE") {
+		t.Errorf("enabling the unsafe trace should warn, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	logger.LogConnect("Test Reader", "T=1")
+	logger.LogCommand([]byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34})
+
+	output := buf.String()
+	if !strings.Contains(output, "⚠ UNSAFE FULL TRACE") {
+		t.Errorf("every connection should repeat the warning, got:\n%s", output)
+	}
+	if !strings.Contains(output, "002000010431323334") {
+		t.Errorf("the unsafe trace should log the PIN, got:\n%s", output)
+	}
+}
+
+func TestRedaction_DivergingVERIFY(t *testing.T) {
+	recorded := []byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x31, 0x32, 0x33, 0x34}
+	sent := &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("9999")}
+	ctx := context.Background()
+
+	t.Run("scripted card", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		scripted := NewScriptedCard().Expect(recorded, nil, 0x9000)
+		scripted.SetLogger(NewAPDULogger(buf))
+		card, _ := scripted.Connect(ctx)
+
+		_, err := card.Transmit(ctx, sent)
+		if err == nil {
+			t.Fatal("Transmit() should fail on a diverging VERIFY")
+		}
+		checkVERIFYRedacted(t, err.Error()+scripted.Verify().Error()+buf.String())
+	})
+
+	t.Run("replay card", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		replay, err := NewReplayCard(&CardTrace{
+			Version: CardTraceVersion,
+			Entries: []TraceEntry{{Kind: TraceExchange, Command: "00200001043132333400", Response: "9000"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		replay.SetLogger(NewAPDULogger(buf))
+		card, _ := replay.Connect(ctx)
+
+		_, err = card.Transmit(ctx, sent)
+		if err == nil {
+			t.Fatal("Transmit() should fail on a diverging VERIFY")
+		}
+		checkVERIFYRedacted(t, err.Error()+replay.Verify().Error()+buf.String())
+	})
+}
+
+// checkVERIFYRedacted checks that neither PIN of TestRedaction_DivergingVERIFY
+// appears in output while the VERIFY header does
+func checkVERIFYRedacted(t *testing.T, output string) {
+	t.Helper()
+	if !strings.Contains(output, "0020000104********") {
+		t.Errorf("output should show the masked VERIFY:\n%s", output)
+	}
+	for _, pin := range []string{"31323334", "39393939"} {
+		if strings.Contains(strings.ToUpper(output), pin) {
+			t.Errorf("output must not contain %s:\n%s", pin, output)
+		}
+	}
+}
+
+func TestRedaction_PersonalDataWrites(t *testing.T) {
+	surname := []byte("SURNAME")
+	for _, ins := range []byte{0xD6, 0xD7, 0xDC, 0xDD, 0xDA, 0xDB} {
+		command := append([]byte{0x00, ins, 0x81, 0x00, byte(len(surname))}, surname...)
+		if output := redactedCommand(command); output != fmt.Sprintf("00%02X810007", ins)+strings.Repeat("**", len(surname)) {
+			t.Errorf("redactedCommand(%X) = %s, want the written data masked", command, output)
+		}
+	}
+
+	// Under secure messaging the data field is a cryptogram
+	command := []byte{0x0C, 0xD6, 0x81, 0x00, 0x04, 0x87, 0x02, 0x01, 0xF1}
+	if output := redactedCommand(command); output != "0CD6810004870201F1" {
+		t.Errorf("redactedCommand(%X) = %s, want it unmasked", command, output)
+	}
+}
@@ -0,0 +1,300 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// RemoteTransport implements domain.Card against a BridgeServer on another
+// machine, so a terminal without a reader can use one attached to a kiosk.
+// Transactions, reconnects and transport error codes are preserved across
+// the bridge; Events streams the server's reader events.
+type RemoteTransport struct {
+	baseURL string
+	client  *http.Client
+	logger  *APDULogger
+	session string
+	status  *domain.CardStatus
+}
+
+// NewRemoteTransport creates a transport for the bridge at baseURL
+// (e.g. "https://kiosk:7443") using a NewBridgeTLSConfig client config
+func NewRemoteTransport(baseURL string, tlsConfig *tls.Config) *RemoteTransport {
+	return &RemoteTransport{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			ForceAttemptHTTP2: true,
+		}},
+	}
+}
+
+// SetLogger sets the logger for transport events
+func (t *RemoteTransport) SetLogger(logger *APDULogger) {
+	t.logger = logger
+}
+
+// Connect opens a bridge session; it fails with ErrReaderBusy while another
+// terminal holds the remote card
+func (t *RemoteTransport) Connect(ctx context.Context) (domain.Card, error) {
+	var status bridgeStatus
+	if err := t.call(ctx, bridgePathConnect, nil, &status); err != nil {
+		return nil, err
+	}
+	cardStatus, err := status.toDomain()
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid card bridge response", err)
+	}
+	t.session = status.Session
+	t.status = cardStatus
+
+	if t.logger != nil {
+		t.logger.LogConnect(t.baseURL+" → "+cardStatus.Reader, cardStatus.ActiveProtocol)
+		t.logger.LogATR(cardStatus.ATR)
+	}
+	return t, nil
+}
+
+// Transmit sends an APDU through the bridge. GET RESPONSE and Le correction
+// are handled by the serving transport.
+func (t *RemoteTransport) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if t.logger != nil {
+		t.logger.LogCommand(apdu.Bytes())
+	}
+
+	var wire bridgeResponse
+	if err := t.call(ctx, bridgePathTransmit, newBridgeCommand(apdu), &wire); err != nil {
+		if t.logger != nil {
+			t.logger.LogError(err)
+		}
+		return nil, err
+	}
+	resp, err := wire.toDomain()
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid card bridge response", err)
+	}
+
+	if t.logger != nil {
+		t.logger.LogResponse(append(append([]byte{}, resp.Data...), resp.SW1, resp.SW2))
+	}
+	return resp, nil
+}
+
+// Disconnect closes the bridge session, ending its remote transactions
+func (t *RemoteTransport) Disconnect() error {
+	if t.session == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), domain.DefaultCommandTimeout)
+	defer cancel()
+
+	err := t.call(ctx, bridgePathDisconnect, nil, nil)
+	t.session = ""
+	if t.logger != nil {
+		t.logger.LogDisconnect()
+	}
+	if errors.Is(err, domain.ErrConnectionLost) {
+		// The session is already gone on the server
+		return nil
+	}
+	return err
+}
+
+// Status returns the card status reported when the session was opened
+func (t *RemoteTransport) Status() (*domain.CardStatus, error) {
+	if t.status == nil || t.session == "" {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	return t.status, nil
+}
+
+// BeginTransaction starts an exclusive transaction on the remote card
+func (t *RemoteTransport) BeginTransaction(ctx context.Context) error {
+	return t.call(ctx, bridgePathBegin, nil, nil)
+}
+
+// EndTransaction ends the innermost remote transaction
+func (t *RemoteTransport) EndTransaction() error {
+	ctx, cancel := context.WithTimeout(context.Background(), domain.DefaultCommandTimeout)
+	defer cancel()
+	return t.call(ctx, bridgePathEnd, nil, nil)
+}
+
+// Reconnect re-establishes the remote card session, opening a new bridge
+// session when the previous one expired
+func (t *RemoteTransport) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	if t.session != "" {
+		var status bridgeStatus
+		err := t.call(ctx, bridgePathReconnect, bridgeReconnect{Mode: mode}, &status)
+		if err == nil {
+			cardStatus, err := status.toDomain()
+			if err != nil {
+				return domain.NewTransportError(domain.ErrTransmissionFailed,
+					"invalid card bridge response", err)
+			}
+			t.status = cardStatus
+			if t.logger != nil {
+				t.logger.LogInfo("Reconnected to %s (%s)", cardStatus.Reader, mode)
+			}
+			return nil
+		}
+		if t.session != "" {
+			return err
+		}
+	}
+	_, err := t.Connect(ctx)
+	return err
+}
+
+// ReaderFeatures returns the PC/SC part 10 features of the remote reader
+func (t *RemoteTransport) ReaderFeatures(ctx context.Context) (domain.ReaderFeatures, error) {
+	var wire bridgeFeatures
+	if err := t.call(ctx, bridgePathFeatures, nil, &wire); err != nil {
+		return nil, err
+	}
+	if wire.Features == nil {
+		return domain.ReaderFeatures{}, nil
+	}
+	return wire.Features, nil
+}
+
+// Control sends a control command, such as PIN pad entry, to the remote reader
+func (t *RemoteTransport) Control(ctx context.Context, code uint32, in []byte) ([]byte, error) {
+	var wire bridgeControl
+	err := t.call(ctx, bridgePathControl, bridgeControl{Code: code, Data: hex.EncodeToString(in)}, &wire)
+	if err != nil {
+		return nil, err
+	}
+	out, err := hex.DecodeString(wire.Data)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid card bridge response", err)
+	}
+	return out, nil
+}
+
+// Events streams the bridge server's reader events until ctx is done or the
+// server stops publishing; the channel is then closed
+func (t *RemoteTransport) Events(ctx context.Context) (<-chan domain.ReaderEvent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.baseURL+bridgePathEvents, nil)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid card bridge URL", err)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, t.requestError(ctx, err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		defer resp.Body.Close()
+		return nil, t.responseError(resp)
+	}
+
+	events := make(chan domain.ReaderEvent, 16)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var wire bridgeEvent
+			if json.Unmarshal(scanner.Bytes(), &wire) != nil {
+				continue
+			}
+			event, err := wire.toDomain()
+			if err != nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// call POSTs one session request to the bridge and decodes the result
+func (t *RemoteTransport) call(ctx context.Context, path string, in any, out any) error {
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	if path != bridgePathConnect && t.session == "" {
+		return domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return domain.NewTransportError(domain.ErrTransmissionFailed,
+				"failed to encode card bridge request", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+path, body)
+	if err != nil {
+		return domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid card bridge URL", err)
+	}
+	req.Header.Set("Content-Type", bridgeContentTypeJSON)
+	if t.session != "" {
+		req.Header.Set(bridgeSessionHeader, t.session)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return t.requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		if resp.StatusCode == http.StatusGone {
+			// Taken over by another terminal or the server restarted
+			t.session = ""
+		}
+		return t.responseError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return domain.NewTransportError(domain.ErrTransmissionFailed,
+				"invalid card bridge response", err)
+		}
+	}
+	return nil
+}
+
+// requestError maps a failed HTTP round trip to a transport error
+func (t *RemoteTransport) requestError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return domain.NewTimeoutError(ctxErr)
+	}
+	return domain.NewTransportError(domain.ErrConnectionLost,
+		"card bridge unreachable", err)
+}
+
+// responseError decodes the error body of a failed bridge request
+func (t *RemoteTransport) responseError(resp *http.Response) error {
+	var wire bridgeError
+	if err := json.NewDecoder(resp.Body).Decode(&wire); err != nil || wire.Message == "" {
+		return domain.NewTransportError(domain.ErrTransmissionFailed,
+			fmt.Sprintf("card bridge answered %s", resp.Status), nil)
+	}
+	return wire.toDomain()
+}
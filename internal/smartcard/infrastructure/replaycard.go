@@ -0,0 +1,305 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// ReplayCard serves a recorded CardTrace back as a card. Every operation
+// must match the next trace entry; the first divergence fails the operation
+// with a TraceDivergenceError and every later one as well, so that a
+// regression cannot go unnoticed when an error is swallowed.
+type ReplayCard struct {
+	trace  *CardTrace
+	status domain.CardStatus
+	logger *APDULogger
+	paced  bool
+
+	mu         sync.Mutex
+	position   int
+	divergence *TraceDivergenceError
+	connected  bool
+}
+
+// NewReplayCard creates a replay of trace
+func NewReplayCard(trace *CardTrace) (*ReplayCard, error) {
+	atr, err := hex.DecodeString(trace.Status.ATR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATR in card trace: %w", err)
+	}
+	return &ReplayCard{
+		trace: trace,
+		status: domain.CardStatus{
+			ATR:            atr,
+			ActiveProtocol: trace.Status.Protocol,
+			Reader:         trace.Status.Reader,
+		},
+	}, nil
+}
+
+// LoadReplayCard creates a replay of a trace file
+func LoadReplayCard(path string) (*ReplayCard, error) {
+	trace, err := LoadCardTrace(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayCard(trace)
+}
+
+// SetLogger sets the logger for transport events
+func (c *ReplayCard) SetLogger(logger *APDULogger) {
+	c.logger = logger
+}
+
+// SetPaced makes each exchange take as long as it did when recorded, for
+// tests of timeouts and progress reporting
+func (c *ReplayCard) SetPaced(paced bool) {
+	c.paced = paced
+}
+
+// Connect opens a session with the replayed card
+func (c *ReplayCard) Connect(ctx context.Context) (domain.Card, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+	c.connected = true
+	if c.logger != nil {
+		c.logger.LogConnect(c.status.Reader, c.status.ActiveProtocol)
+		c.logger.LogATR(c.status.ATR)
+	}
+	return c, nil
+}
+
+// Transmit answers a command with the recorded response or transport error
+func (c *ReplayCard) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if !c.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+
+	command := hex.EncodeToString(apdu.Bytes())
+	if c.logger != nil {
+		c.logger.LogCommand(apdu.Bytes())
+	}
+	entry, err := c.next(TraceEntry{Kind: TraceExchange, Command: command})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.paced && entry.Elapsed > 0 {
+		select {
+		case <-time.After(entry.Elapsed):
+		case <-ctx.Done():
+			return nil, domain.NewTimeoutError(ctx.Err())
+		}
+	}
+
+	if entry.Error != nil {
+		err := entry.Error.toDomain()
+		if c.logger != nil {
+			c.logger.LogError(err)
+		}
+		return nil, err
+	}
+	response, err := hex.DecodeString(entry.Response)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid response in card trace", err)
+	}
+	if c.logger != nil {
+		c.logger.LogResponse(response)
+	}
+	return domain.NewResponse(response), nil
+}
+
+// BeginTransaction checks the transaction start against the trace
+func (c *ReplayCard) BeginTransaction(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	_, err := c.next(TraceEntry{Kind: TraceBeginTransaction})
+	return err
+}
+
+// EndTransaction checks the transaction end against the trace
+func (c *ReplayCard) EndTransaction() error {
+	_, err := c.next(TraceEntry{Kind: TraceEndTransaction})
+	return err
+}
+
+// Reconnect checks the reconnect and its reset mode against the trace
+func (c *ReplayCard) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	if _, err := c.next(TraceEntry{Kind: TraceReconnect, Mode: mode}); err != nil {
+		return err
+	}
+	c.connected = true
+	return nil
+}
+
+// ReaderFeatures returns the recorded features of the reader, empty when
+// none were recorded
+func (c *ReplayCard) ReaderFeatures(ctx context.Context) (domain.ReaderFeatures, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+	if c.trace.Status.Features == nil {
+		return domain.ReaderFeatures{}, nil
+	}
+	return c.trace.Status.Features, nil
+}
+
+// Control answers a reader control command with the recorded output or
+// transport error
+func (c *ReplayCard) Control(ctx context.Context, code uint32, in []byte) ([]byte, error) {
+	if !c.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+	entry, err := c.next(TraceEntry{Kind: TraceControl, Control: code, Command: hex.EncodeToString(in)})
+	if err != nil {
+		return nil, err
+	}
+	if entry.Error != nil {
+		err := entry.Error.toDomain()
+		if c.logger != nil {
+			c.logger.LogError(err)
+		}
+		return nil, err
+	}
+	out, err := hex.DecodeString(entry.Response)
+	if err != nil {
+		return nil, domain.NewTransportError(domain.ErrTransmissionFailed,
+			"invalid control output in card trace", err)
+	}
+	return out, nil
+}
+
+// Random returns a reader serving the recorded random bytes, so that PACE
+// derives the same ephemeral keys as in the recorded session. Each Read
+// returns the bytes of one recorded read and must ask for exactly as many;
+// another length is a divergence.
+func (c *ReplayCard) Random() io.Reader {
+	return replayReader{card: c}
+}
+
+// Disconnect closes the replayed session
+func (c *ReplayCard) Disconnect() error {
+	if c.logger != nil {
+		c.logger.LogDisconnect()
+	}
+	c.connected = false
+	return nil
+}
+
+// Status returns the recorded card status
+func (c *ReplayCard) Status() (*domain.CardStatus, error) {
+	if !c.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	return domain.NewCardStatus(c.status.ATR, c.status.ActiveProtocol, c.status.Reader), nil
+}
+
+// Remaining returns the number of trace entries not yet replayed
+func (c *ReplayCard) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.trace.Entries) - c.position
+}
+
+// Verify reports the first divergence, or an error if the trace was not
+// replayed completely
+func (c *ReplayCard) Verify() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.divergence != nil {
+		return c.divergence
+	}
+	if remaining := len(c.trace.Entries) - c.position; remaining > 0 {
+		return fmt.Errorf("card trace: %d entries not replayed, next expected: %s",
+			remaining, c.trace.Entries[c.position].describe())
+	}
+	return nil
+}
+
+// next consumes the trace entry matching the operation, or records and
+// returns a divergence
+func (c *ReplayCard) next(actual TraceEntry) (*TraceEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.divergence == nil {
+		c.divergence = c.match(actual)
+	}
+	if c.divergence != nil {
+		err := domain.NewTransportError(domain.ErrTransmissionFailed,
+			"card trace diverged", c.divergence)
+		if c.logger != nil {
+			c.logger.LogError(err)
+		}
+		return nil, err
+	}
+
+	entry := &c.trace.Entries[c.position]
+	c.position++
+	return entry, nil
+}
+
+// match compares an operation with the next trace entry
+func (c *ReplayCard) match(actual TraceEntry) *TraceDivergenceError {
+	divergence := &TraceDivergenceError{Entry: c.position, Actual: actual.describe()}
+	if c.position >= len(c.trace.Entries) {
+		return divergence
+	}
+
+	expected := c.trace.Entries[c.position]
+	divergence.Expected = expected.describe()
+	switch {
+	case expected.Kind != actual.Kind:
+		return divergence
+	case expected.Kind == TraceExchange && expected.Command != actual.Command:
+		return divergence
+	case expected.Kind == TraceControl && (expected.Control != actual.Control || expected.Command != actual.Command):
+		return divergence
+	case expected.Kind == TraceReconnect && expected.Mode != actual.Mode:
+		return divergence
+	case expected.Kind == TraceRandom && len(actual.Random) != len(expected.Random):
+		return divergence
+	}
+	return nil
+}
+
+// replayReader serves recorded random bytes
+type replayReader struct {
+	card *ReplayCard
+}
+
+// Read fills p with the next recorded random bytes
+func (r replayReader) Read(p []byte) (int, error) {
+	// Only the length takes part in matching
+	entry, err := r.card.next(TraceEntry{Kind: TraceRandom, Random: hex.EncodeToString(make([]byte, len(p)))})
+	if err != nil {
+		return 0, err
+	}
+	random, err := hex.DecodeString(entry.Random)
+	if err != nil {
+		return 0, fmt.Errorf("invalid random bytes in card trace: %w", err)
+	}
+	return copy(p, random), nil
+}
@@ -0,0 +1,185 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// recordSimulatorSession records a short CEI session and returns the
+// trace file and the random bytes drawn during it
+func recordSimulatorSession(t *testing.T) (string, []byte) {
+	t.Helper()
+	sim, err := LoadCardSimulator("testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	recorder := NewRecordingConnector(sim)
+	ctx := context.Background()
+
+	card, err := recorder.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(recorder.Random(rand.Reader), nonce); err != nil {
+		t.Fatal(err)
+	}
+	err = domain.WithTransaction(ctx, card, func(card domain.Card) error {
+		card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID})
+		_, err := card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("recorded session error = %v", err)
+	}
+	card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x81, Le: 0x07})
+	card.Disconnect()
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	return path, nonce
+}
+
+func TestReplayCard_ReplaysRecording(t *testing.T) {
+	path, nonce := recordSimulatorSession(t)
+	replay, err := LoadReplayCard(path)
+	if err != nil {
+		t.Fatalf("LoadReplayCard() error = %v", err)
+	}
+	ctx := context.Background()
+
+	card, _ := replay.Connect(ctx)
+	status, err := card.Status()
+	if err != nil || status.Reader != DefaultSimulatorReader || status.ParsedATR == nil {
+		t.Errorf("Status() = %+v, %v, want the recorded simulator status", status, err)
+	}
+
+	replayed := make([]byte, len(nonce))
+	if _, err := io.ReadFull(replay.Random(), replayed); err != nil || !bytes.Equal(replayed, nonce) {
+		t.Errorf("Random() = %02X, %v, want recorded %02X", replayed, err, nonce)
+	}
+	err = domain.WithTransaction(ctx, card, func(card domain.Card) error {
+		card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID})
+		_, err := card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("replayed session error = %v", err)
+	}
+	resp, err := card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x81, Le: 0x07})
+	if err != nil || string(resp.Data) != "SURNAME" {
+		t.Errorf("READ BINARY = %v, %v, want recorded SURNAME", resp, err)
+	}
+
+	if err := replay.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayCard_Divergence(t *testing.T) {
+	path, _ := recordSimulatorSession(t)
+	replay, err := LoadReplayCard(path)
+	if err != nil {
+		t.Fatalf("LoadReplayCard() error = %v", err)
+	}
+	ctx := context.Background()
+	card, _ := replay.Connect(ctx)
+
+	// The session starts with a random read, not a command
+	_, err = card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0x84, Le: 8})
+	var divergence *TraceDivergenceError
+	if !errors.As(err, &divergence) || divergence.Entry != 0 {
+		t.Fatalf("Transmit() error = %v, want divergence at entry 0", err)
+	}
+
+	// Later operations keep failing and Verify reports the first divergence
+	io.ReadFull(replay.Random(), make([]byte, 16))
+	if err := replay.Verify(); !errors.As(err, &divergence) || !strings.Contains(err.Error(), "random read of 16 bytes") {
+		t.Errorf("Verify() error = %v, want the first divergence", err)
+	}
+}
+
+func TestReplayCard_Random(t *testing.T) {
+	scripted := NewScriptedCard()
+	scripted.Connect(context.Background())
+	recorder := NewRecordingCard(scripted)
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(recorder.Random(rand.Reader), nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	trace := recorder.Trace()
+	if len(trace.Entries) != 1 || trace.Entries[0].Kind != TraceRandom || trace.Entries[0].Random != hex.EncodeToString(nonce) {
+		t.Fatalf("Entries = %+v, want one random entry with %02X", trace.Entries, nonce)
+	}
+
+	tests := []struct {
+		name   string
+		length int
+		want   []byte // nil for a divergence
+	}{
+		{"recorded length", 16, nonce},
+		{"shorter read", 8, nil},
+		{"longer read", 32, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay, err := NewReplayCard(trace)
+			if err != nil {
+				t.Fatalf("NewReplayCard() error = %v", err)
+			}
+			replay.Connect(context.Background())
+
+			got := make([]byte, tt.length)
+			_, err = io.ReadFull(replay.Random(), got)
+			var divergence *TraceDivergenceError
+			switch {
+			case tt.want == nil && !errors.As(err, &divergence):
+				t.Errorf("ReadFull(%d bytes) error = %v, want a divergence", tt.length, err)
+			case tt.want != nil && (err != nil || !bytes.Equal(got, tt.want)):
+				t.Errorf("ReadFull(%d bytes) = %02X, %v, want %02X", tt.length, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayCard_RecordedTransportError(t *testing.T) {
+	scripted := NewScriptedCard().
+		ExpectError([]byte{0x00, 0xB0, 0x00, 0x00, 0x01},
+			&domain.TransportError{Code: domain.ErrCardReset, Message: "card was reset", PCSCCode: 0x80100068})
+	scripted.Connect(context.Background())
+	recorder := NewRecordingCard(scripted)
+	recorder.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 1})
+
+	replay, err := NewReplayCard(recorder.Trace())
+	if err != nil {
+		t.Fatalf("NewReplayCard() error = %v", err)
+	}
+	replay.Connect(context.Background())
+	_, err = replay.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 1})
+	var transportErr *domain.TransportError
+	if !errors.Is(err, domain.ErrCardReset) || !errors.As(err, &transportErr) || transportErr.PCSCCode != 0x80100068 {
+		t.Errorf("Transmit() error = %v, want the recorded ErrCardReset", err)
+	}
+}
+
+func TestLoadCardTrace_UnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.json")
+	os.WriteFile(path, []byte(`{"version": 99, "entries": []}`), 0o600)
+
+	if _, err := LoadCardTrace(path); err == nil || !strings.Contains(err.Error(), "unsupported card trace version 99") {
+		t.Errorf("LoadCardTrace() error = %v, want unsupported version", err)
+	}
+}
@@ -0,0 +1,310 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// ScriptedExchange is one expected command APDU and the canned response to it
+type ScriptedExchange struct {
+	Control  uint32 // Reader control code of a control command, 0 for an APDU
+	Command  []byte // Expected command APDU bytes (control input for a control command)
+	Response []byte // Response data followed by SW1 SW2 (control output for a control command)
+	Err      error  // Transport failure returned instead of a response
+}
+
+// ScriptMismatchError describes a command that diverged from the script
+type ScriptMismatchError struct {
+	Step            int    // Zero-based index of the exchange in the script
+	Expected        []byte // Command the script expected (nil when the script is exhausted)
+	Actual          []byte // Command that was actually sent
+	ExpectedControl uint32 // Control code the script expected, 0 for an APDU
+	ActualControl   uint32 // Control code actually used, 0 for an APDU
+}
+
+// Error implements the error interface. Commands are shown with their
+// secrets masked, as the error is logged.
+func (e *ScriptMismatchError) Error() string {
+	if e.Expected == nil {
+		return fmt.Sprintf("exchange %d: unexpected command %s (script exhausted)",
+			e.Step, redactedCommand(e.Actual))
+	}
+	if e.ExpectedControl != e.ActualControl {
+		return fmt.Sprintf("exchange %d: expected %s, got %s",
+			e.Step, describeExchange(e.ExpectedControl), describeExchange(e.ActualControl))
+	}
+	return fmt.Sprintf("exchange %d: command mismatch at byte %d: expected %s, got %s",
+		e.Step, firstDifference(e.Expected, e.Actual), redactedCommand(e.Expected), redactedCommand(e.Actual))
+}
+
+// ScriptedCard is an in-memory card that replays a fixed APDU script.
+// It implements domain.Card and domain.Connector so it can stand in for
+// PCSCTransport in tests and on machines without a reader.
+type ScriptedCard struct {
+	exchanges []ScriptedExchange
+	position  int
+	status    domain.CardStatus
+	logger    *APDULogger
+	connected bool
+
+	txDepth      int // Nesting depth of open transactions
+	transactions int // Completed outermost transactions
+
+	reconnects []domain.ResetMode // Modes of the Reconnect calls, in order
+
+	features domain.ReaderFeatures // Reported reader features (PIN pad, PACE)
+}
+
+// NewScriptedCard creates a scripted card with the given exchanges
+func NewScriptedCard(exchanges ...ScriptedExchange) *ScriptedCard {
+	return &ScriptedCard{
+		exchanges: exchanges,
+		status: domain.CardStatus{
+			ATR:            []byte{0x3B, 0x00},
+			ActiveProtocol: "T=1",
+			Reader:         "Scripted Card Reader",
+		},
+	}
+}
+
+// Expect appends an exchange returning response data and status word
+func (c *ScriptedCard) Expect(command []byte, data []byte, sw uint16) *ScriptedCard {
+	response := append(append([]byte{}, data...), byte(sw>>8), byte(sw))
+	c.exchanges = append(c.exchanges, ScriptedExchange{Command: command, Response: response})
+	return c
+}
+
+// ExpectError appends an exchange that fails with a transport error (e.g.
+// ErrCardReset) instead of answering
+func (c *ScriptedCard) ExpectError(command []byte, err error) *ScriptedCard {
+	c.exchanges = append(c.exchanges, ScriptedExchange{Command: command, Err: err})
+	return c
+}
+
+// ExpectAPDU appends an exchange for a command built from an APDU
+func (c *ScriptedCard) ExpectAPDU(apdu *domain.APDU, data []byte, sw uint16) *ScriptedCard {
+	return c.Expect(apdu.Bytes(), data, sw)
+}
+
+// ExpectControl appends a reader control command (SCardControl) returning
+// out, such as a PIN pad entry answered with the card's status word
+func (c *ScriptedCard) ExpectControl(code uint32, in []byte, out []byte) *ScriptedCard {
+	c.exchanges = append(c.exchanges, ScriptedExchange{Control: code, Command: in, Response: out})
+	return c
+}
+
+// SetReaderFeatures sets the PC/SC part 10 features reported by ReaderFeatures
+func (c *ScriptedCard) SetReaderFeatures(features domain.ReaderFeatures) {
+	c.features = features
+}
+
+// SetStatus overrides the reported card status (ATR, protocol, reader)
+func (c *ScriptedCard) SetStatus(status domain.CardStatus) {
+	c.status = status
+}
+
+// SetLogger sets the logger for transport events
+func (c *ScriptedCard) SetLogger(logger *APDULogger) {
+	c.logger = logger
+}
+
+// Connect opens a session with the scripted card
+func (c *ScriptedCard) Connect(ctx context.Context) (domain.Card, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+	c.connected = true
+	if c.logger != nil {
+		c.logger.LogConnect(c.status.Reader, c.status.ActiveProtocol)
+		c.logger.LogATR(c.status.ATR)
+	}
+	return c, nil
+}
+
+// Transmit checks the command against the next scripted exchange
+func (c *ScriptedCard) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if !c.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+
+	command := apdu.Bytes()
+	if c.logger != nil {
+		c.logger.LogCommand(command)
+	}
+
+	exchange, err := c.next(0, command)
+	if err != nil {
+		return nil, err
+	}
+	if c.logger != nil {
+		c.logger.LogResponse(exchange.Response)
+	}
+	return domain.NewResponse(append([]byte{}, exchange.Response...)), nil
+}
+
+// Control checks a reader control command against the next scripted exchange
+func (c *ScriptedCard) Control(ctx context.Context, code uint32, in []byte) ([]byte, error) {
+	if !c.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+
+	exchange, err := c.next(code, in)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, exchange.Response...), nil
+}
+
+// ReaderFeatures returns the features set with SetReaderFeatures
+func (c *ScriptedCard) ReaderFeatures(ctx context.Context) (domain.ReaderFeatures, error) {
+	if c.features == nil {
+		return domain.ReaderFeatures{}, nil
+	}
+	return c.features, nil
+}
+
+// next consumes the scripted exchange matching a command, or fails with a
+// ScriptMismatchError. A scripted transport failure is returned as the error.
+func (c *ScriptedCard) next(code uint32, command []byte) (ScriptedExchange, error) {
+	mismatch := &ScriptMismatchError{Step: c.position, Actual: command, ActualControl: code}
+	if c.position >= len(c.exchanges) {
+		return ScriptedExchange{}, c.fail(mismatch)
+	}
+
+	exchange := c.exchanges[c.position]
+	if exchange.Control != code || !bytes.Equal(exchange.Command, command) {
+		mismatch.Expected = exchange.Command
+		mismatch.ExpectedControl = exchange.Control
+		return ScriptedExchange{}, c.fail(mismatch)
+	}
+	c.position++
+
+	if exchange.Err != nil {
+		if c.logger != nil {
+			c.logger.LogError(exchange.Err)
+		}
+		return ScriptedExchange{}, exchange.Err
+	}
+	return exchange, nil
+}
+
+// BeginTransaction opens a (possibly nested) transaction
+func (c *ScriptedCard) BeginTransaction(ctx context.Context) error {
+	if !c.connected {
+		return domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	c.txDepth++
+	return nil
+}
+
+// EndTransaction closes the innermost open transaction
+func (c *ScriptedCard) EndTransaction() error {
+	if c.txDepth == 0 {
+		return fmt.Errorf("scripted card: EndTransaction without BeginTransaction")
+	}
+	c.txDepth--
+	if c.txDepth == 0 {
+		c.transactions++
+	}
+	return nil
+}
+
+// Transactions returns the number of completed outermost transactions
+func (c *ScriptedCard) Transactions() int {
+	return c.transactions
+}
+
+// Reconnect re-establishes the scripted session and records the reset mode
+func (c *ScriptedCard) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	c.connected = true
+	c.reconnects = append(c.reconnects, mode)
+	return nil
+}
+
+// Reconnects returns the reset modes of all Reconnect calls
+func (c *ScriptedCard) Reconnects() []domain.ResetMode {
+	return c.reconnects
+}
+
+// Disconnect closes the scripted session
+func (c *ScriptedCard) Disconnect() error {
+	if c.logger != nil {
+		c.logger.LogDisconnect()
+	}
+	c.connected = false
+	return nil
+}
+
+// Status returns the scripted card status
+func (c *ScriptedCard) Status() (*domain.CardStatus, error) {
+	if !c.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	return domain.NewCardStatus(c.status.ATR, c.status.ActiveProtocol, c.status.Reader), nil
+}
+
+// Remaining returns the number of exchanges not yet performed
+func (c *ScriptedCard) Remaining() int {
+	return len(c.exchanges) - c.position
+}
+
+// Verify reports an error if any scripted exchange was not performed or a
+// transaction was left open
+func (c *ScriptedCard) Verify() error {
+	if c.txDepth > 0 {
+		return fmt.Errorf("scripted card: %d transaction(s) not ended", c.txDepth)
+	}
+	if c.Remaining() == 0 {
+		return nil
+	}
+	return fmt.Errorf("scripted card: %d exchange(s) not performed, next expected command: %s",
+		c.Remaining(), redactedCommand(c.exchanges[c.position].Command))
+}
+
+// fail logs and wraps a script mismatch as a transport error
+func (c *ScriptedCard) fail(mismatch *ScriptMismatchError) error {
+	err := domain.NewTransportError(domain.ErrTransmissionFailed,
+		"scripted card", mismatch)
+	if c.logger != nil {
+		c.logger.LogError(err)
+	}
+	return err
+}
+
+// describeExchange names the kind of exchange for mismatch errors
+func describeExchange(control uint32) string {
+	if control == 0 {
+		return "an APDU"
+	}
+	return fmt.Sprintf("control command %08X", control)
+}
+
+// firstDifference returns the index of the first differing byte
+func firstDifference(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,195 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+func TestScriptedCard_Transmit_Match(t *testing.T) {
+	card := NewScriptedCard().
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x02}, []byte{0xCA, 0xFE}, 0x9000)
+	if _, err := card.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	resp, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 0x02})
+	if err != nil {
+		t.Fatalf("Transmit() error = %v", err)
+	}
+	if !resp.IsSuccess() {
+		t.Errorf("StatusCode() = %04X, want 9000", resp.StatusCode())
+	}
+	if !bytes.Equal(resp.Data, []byte{0xCA, 0xFE}) {
+		t.Errorf("Data = %02X, want CAFE", resp.Data)
+	}
+	if err := card.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestScriptedCard_Transmit_Mismatch(t *testing.T) {
+	card := NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x04, 0x00}, nil, 0x9000)
+	card.Connect(context.Background())
+
+	_, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x08, P2: 0x00})
+	if err == nil {
+		t.Fatal("Transmit() expected mismatch error")
+	}
+
+	var mismatch *ScriptMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("error %v should wrap ScriptMismatchError", err)
+	}
+	if mismatch.Step != 0 {
+		t.Errorf("Step = %d, want 0", mismatch.Step)
+	}
+	if !strings.Contains(err.Error(), "mismatch at byte 2") {
+		t.Errorf("error %q should point at the first differing byte", err)
+	}
+	if !strings.Contains(err.Error(), "00A40400") || !strings.Contains(err.Error(), "00A40800") {
+		t.Errorf("error %q should show expected and actual commands", err)
+	}
+}
+
+func TestScriptedCard_Transmit_Exhausted(t *testing.T) {
+	card := NewScriptedCard()
+	card.Connect(context.Background())
+
+	_, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0x84, Le: 0x08})
+	if err == nil || !strings.Contains(err.Error(), "script exhausted") {
+		t.Errorf("Transmit() error = %v, want script exhausted", err)
+	}
+}
+
+func TestScriptedCard_Transmit_NotConnected(t *testing.T) {
+	card := NewScriptedCard()
+
+	_, err := card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xA4})
+	var transportErr *domain.TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != domain.ErrNoCard {
+		t.Errorf("Transmit() error = %v, want ErrNoCard", err)
+	}
+}
+
+func TestScriptedCard_Transmit_Cancelled(t *testing.T) {
+	card := NewScriptedCard().Expect([]byte{0x00, 0xA4, 0x04, 0x00}, nil, 0x9000)
+	card.Connect(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := card.Transmit(ctx, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04})
+	var transportErr *domain.TransportError
+	if !errors.As(err, &transportErr) || transportErr.Code != domain.ErrTimeout {
+		t.Errorf("Transmit() error = %v, want ErrTimeout", err)
+	}
+	if card.Remaining() != 1 {
+		t.Errorf("Remaining() = %d, want the exchange not consumed", card.Remaining())
+	}
+}
+
+func TestScriptedCard_ExpectError(t *testing.T) {
+	reset := domain.NewTransportError(domain.ErrCardReset, "APDU transmission failed", nil)
+	card := NewScriptedCard().
+		ExpectError([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, reset).
+		Expect([]byte{0x00, 0xB0, 0x00, 0x00, 0x00}, []byte{0x01}, 0x9000)
+	card.Connect(context.Background())
+
+	apdu := &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 256}
+	if _, err := card.Transmit(context.Background(), apdu); err != reset {
+		t.Fatalf("Transmit() error = %v, want the scripted error", err)
+	}
+	if err := card.Reconnect(context.Background(), domain.ResetWarm); err != nil {
+		t.Fatalf("Reconnect() error = %v", err)
+	}
+	if _, err := card.Transmit(context.Background(), apdu); err != nil {
+		t.Fatalf("Transmit() after reconnect error = %v", err)
+	}
+	if modes := card.Reconnects(); len(modes) != 1 || modes[0] != domain.ResetWarm {
+		t.Errorf("Reconnects() = %v, want [warm reset]", modes)
+	}
+}
+
+func TestScriptedCard_Verify_Remaining(t *testing.T) {
+	card := NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x04, 0x00}, nil, 0x9000).
+		Expect([]byte{0x00, 0x20, 0x00, 0x01}, nil, 0x63C3)
+
+	if card.Remaining() != 2 {
+		t.Errorf("Remaining() = %d, want 2", card.Remaining())
+	}
+	err := card.Verify()
+	if err == nil || !strings.Contains(err.Error(), "2 exchange(s) not performed") {
+		t.Errorf("Verify() error = %v, want 2 exchanges not performed", err)
+	}
+}
+
+func TestScriptedCard_Transactions(t *testing.T) {
+	card := NewScriptedCard()
+	card.Connect(context.Background())
+
+	card.BeginTransaction(context.Background())
+	card.BeginTransaction(context.Background())
+	card.EndTransaction()
+	if err := card.Verify(); err == nil || !strings.Contains(err.Error(), "1 transaction(s) not ended") {
+		t.Errorf("Verify() error = %v, want open transaction reported", err)
+	}
+
+	card.EndTransaction()
+	if card.Transactions() != 1 {
+		t.Errorf("Transactions() = %d, want 1", card.Transactions())
+	}
+	if err := card.EndTransaction(); err == nil {
+		t.Error("EndTransaction() without BeginTransaction should fail")
+	}
+	if err := card.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestScriptedCard_Logging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	card := NewScriptedCard().
+		Expect([]byte{0x00, 0xA4, 0x04, 0x00}, nil, 0x6A82)
+	card.SetLogger(NewAPDULogger(buf))
+	card.Connect(context.Background())
+	card.Transmit(context.Background(), &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04})
+
+	output := buf.String()
+	if !strings.Contains(output, "Scripted Card Reader") {
+		t.Error("Connect should log the scripted reader name")
+	}
+	if !strings.Contains(output, "SELECT") || !strings.Contains(output, "SW=6A82") {
+		t.Error("Transmit should log command and response")
+	}
+}
+
+func TestScriptedCard_Control(t *testing.T) {
+	card := NewScriptedCard().
+		ExpectControl(0x42330006, []byte{0x01, 0x02}, []byte{0x90, 0x00}).
+		Expect([]byte{0x00, 0x84, 0x00, 0x00, 0x08}, nil, 0x9000)
+	card.Connect(context.Background())
+
+	out, err := card.Control(context.Background(), 0x42330006, []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatalf("Control() error = %v", err)
+	}
+	if !bytes.Equal(out, []byte{0x90, 0x00}) {
+		t.Errorf("Control() = %02X, want 9000", out)
+	}
+
+	// A control command where an APDU is expected diverges from the script
+	_, err = card.Control(context.Background(), 0x42330006, []byte{0x00, 0x84, 0x00, 0x00, 0x08})
+	var mismatch *ScriptMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Step != 1 {
+		t.Fatalf("Control() error = %v, want a mismatch at step 1", err)
+	}
+	if !strings.Contains(err.Error(), "expected an APDU, got control command 42330006") {
+		t.Errorf("error %q should name the kinds of exchange", err)
+	}
+}
@@ -0,0 +1,642 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// DefaultSimulatorReader is the reader name reported when the fixture sets none
+const DefaultSimulatorReader = "Virtual CEI Simulator"
+
+// simChannel is the selection, security and chaining state of one logical
+// channel
+type simChannel struct {
+	currentDF *simFile
+	currentEF *simFile
+	verified  map[byte]bool
+	chain     []byte // Data received so far in a command chain
+	chainINS  byte   // Instruction of the chain in progress
+}
+
+// simPIN is the card-side state of a PIN object
+type simPIN struct {
+	value      []byte
+	maxRetries int
+	retries    int
+	puk        *simPIN // Resetting code, nil when the PIN cannot be unblocked
+}
+
+// CardSimulator is a stateful virtual Romanian CEI chip. It implements
+// domain.Card and domain.Connector on top of a file system loaded from a
+// SimulatorFixture: SELECT (MF, FID, path, parent, DF name), READ BINARY
+// (offset and SFI), VERIFY with retry counters, CHANGE REFERENCE DATA, RESET
+// RETRY COUNTER with a PUK, GET CHALLENGE, command chaining and MANAGE
+// CHANNEL. Each logical channel has its own current DF/EF, verified PINs and
+// command chain; retry counters are shared.
+//
+// PACE with the CAN (MSE:SET AT, GENERAL AUTHENTICATE with Generic Mapping on
+// brainpoolP256r1, AES-128) starts a card-wide secure messaging session.
+// Protected commands are unwrapped and their responses wrapped; files with
+// the "pace" access condition can only be read under secure messaging. As on
+// an eMRTD, a plain command or an SM error ends the session.
+type CardSimulator struct {
+	atr    []byte
+	card   *domain.KnownCard // Identification reported by Status
+	reader string
+	can    string    // PACE password, empty when PACE is not offered
+	random io.Reader // Nonces, challenges and ephemeral keys
+	logger *APDULogger
+
+	mf *simFile
+
+	pins        map[byte]*simPIN
+	maxChannels int           // Logical channels besides the basic one (0 = MANAGE CHANNEL unsupported)
+	channels    []*simChannel // Open channels by number, nil when closed
+	channel     *simChannel   // Channel of the command being processed
+	secured     bool          // Whether the command being processed came under secure messaging
+	connected   bool
+
+	pace *simPACE // PACE run in progress
+	sm   *simSM   // Secure messaging session established by PACE
+}
+
+// NewCardSimulator creates a simulator from a fixture
+func NewCardSimulator(fixture *SimulatorFixture) (*CardSimulator, error) {
+	atr, err := hex.DecodeString(fixture.ATR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid simulator ATR: %w", err)
+	}
+
+	mf, err := fixture.buildTree()
+	if err != nil {
+		return nil, err
+	}
+
+	if fixture.Channels < 0 || fixture.Channels > domain.MaxLogicalChannel {
+		return nil, fmt.Errorf("simulator logical channels out of range: %d (0-%d)",
+			fixture.Channels, domain.MaxLogicalChannel)
+	}
+
+	reader := fixture.Reader
+	if reader == "" {
+		reader = DefaultSimulatorReader
+	}
+
+	pins := make(map[byte]*simPIN, len(fixture.PINs))
+	for _, pin := range fixture.PINs {
+		pins[pin.Reference] = &simPIN{
+			value:      []byte(pin.Value),
+			maxRetries: pin.Retries,
+			retries:    pin.Retries,
+		}
+	}
+	for _, pin := range fixture.PINs {
+		if pin.PUK == 0 {
+			continue
+		}
+		puk, ok := pins[pin.PUK]
+		if !ok {
+			return nil, fmt.Errorf("simulator PIN %02X: unknown PUK reference %02X", pin.Reference, pin.PUK)
+		}
+		pins[pin.Reference].puk = puk
+	}
+
+	sim := &CardSimulator{
+		atr:         atr,
+		card:        simulatorCard(atr),
+		reader:      reader,
+		can:         fixture.CAN,
+		random:      rand.Reader,
+		mf:          mf,
+		pins:        pins,
+		maxChannels: fixture.Channels,
+	}
+	sim.reset()
+	return sim, nil
+}
+
+// LoadCardSimulator creates a simulator from a fixture directory
+func LoadCardSimulator(dir string) (*CardSimulator, error) {
+	fixture, err := LoadSimulatorFixture(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewCardSimulator(fixture)
+}
+
+// SetLogger sets the logger for transport events
+func (s *CardSimulator) SetLogger(logger *APDULogger) {
+	s.logger = logger
+}
+
+// Connect powers up the virtual card (resets the volatile security state)
+func (s *CardSimulator) Connect(ctx context.Context) (domain.Card, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+	s.reset()
+	s.connected = true
+	if s.logger != nil {
+		s.logger.LogConnect(s.reader, "T=1")
+		s.logger.LogATR(s.atr)
+	}
+	return s, nil
+}
+
+// Reconnect re-establishes the session; a warm or cold reset clears the
+// volatile security state like a power cycle
+func (s *CardSimulator) Reconnect(ctx context.Context, mode domain.ResetMode) error {
+	if err := ctx.Err(); err != nil {
+		return domain.NewTimeoutError(err)
+	}
+	s.connected = true
+	if mode != domain.ResetLeave {
+		s.reset()
+	}
+	if s.logger != nil {
+		s.logger.LogInfo("Reconnected to %s (%s)", s.reader, mode)
+	}
+	return nil
+}
+
+// Disconnect powers down the virtual card
+func (s *CardSimulator) Disconnect() error {
+	if s.logger != nil {
+		s.logger.LogDisconnect()
+	}
+	s.connected = false
+	return nil
+}
+
+// Status returns the virtual card status
+func (s *CardSimulator) Status() (*domain.CardStatus, error) {
+	if !s.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	status := domain.NewCardStatus(s.atr, "T=1", s.reader)
+	status.Card = s.card
+	return status, nil
+}
+
+// simulatorCard identifies the virtual card as a supported CEI whatever its
+// fixture ATR, which is not in domain.KnownCards
+func simulatorCard(atr []byte) *domain.KnownCard {
+	return &domain.KnownCard{
+		Name:    "Romanian CEI (virtual simulator)",
+		Family:  domain.CardFamilyCEI,
+		Support: domain.Supported,
+		Pattern: domain.ATRPattern{Name: "CEI simulator", ATR: atr},
+	}
+}
+
+// Transmit processes a command APDU against the card state
+func (s *CardSimulator) Transmit(ctx context.Context, apdu *domain.APDU) (*domain.Response, error) {
+	if !s.connected {
+		return nil, domain.NewTransportError(domain.ErrNoCard,
+			"not connected to card", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, domain.NewTimeoutError(err)
+	}
+
+	if s.logger != nil {
+		s.logger.LogCommand(apdu.Bytes())
+	}
+	resp := s.process(apdu)
+	if s.logger != nil {
+		s.logger.LogResponse(append(append([]byte{}, resp.Data...), resp.SW1, resp.SW2))
+	}
+	return resp, nil
+}
+
+// reset restores the state after power-up (only the basic channel open,
+// MF selected, no PIN verified)
+func (s *CardSimulator) reset() {
+	s.channels = make([]*simChannel, 1+s.maxChannels)
+	s.channels[domain.BasicChannel] = &simChannel{currentDF: s.mf, verified: make(map[byte]bool)}
+	s.channel = s.channels[domain.BasicChannel]
+	s.pace = nil
+	s.sm = nil
+}
+
+// process unwraps a command protected by secure messaging, dispatches it and
+// wraps the response. A plain command ends the secure messaging session.
+func (s *CardSimulator) process(apdu *domain.APDU) *domain.Response {
+	if apdu.CLA&0x80 != 0 {
+		return simStatus(domain.StatusCLAErr)
+	}
+
+	indication := domain.CLASecureMessaging(apdu.CLA)
+	if indication == 0 {
+		s.sm = nil
+		s.secured = false
+		return s.dispatch(apdu)
+	}
+	if s.sm == nil || indication == domain.CLASMProprietary {
+		return simStatus(domain.StatusLogicalChannelErr) // No session to protect the command
+	}
+
+	plain, sw := s.sm.unwrap(apdu)
+	if plain == nil {
+		s.sm = nil
+		return simStatus(sw)
+	}
+	s.secured = true
+	resp := s.dispatch(plain)
+	s.secured = false
+	if s.sm == nil {
+		return resp // The command ended the session (MSE:SET AT)
+	}
+	return s.sm.wrap(resp)
+}
+
+// dispatch processes a plain command by instruction byte
+func (s *CardSimulator) dispatch(apdu *domain.APDU) *domain.Response {
+	number := domain.CLAChannel(apdu.CLA)
+	if number >= len(s.channels) || s.channels[number] == nil {
+		return simStatus(domain.StatusFunctionNotFound)
+	}
+	s.channel = s.channels[number]
+
+	// Command chaining: buffer parts until the command without the chaining
+	// bit, separately on each channel. GENERAL AUTHENTICATE uses the bit to
+	// announce further steps of the protocol, each processed on its own.
+	channel := s.channel
+	if channel.chain != nil && apdu.INS != channel.chainINS {
+		channel.chain = nil
+		return simStatus(domain.StatusLastCommandExpected)
+	}
+	if apdu.CLA&domain.CLAChainingBit != 0 && apdu.INS != 0x86 {
+		channel.chain = append(channel.chain, apdu.Data...)
+		channel.chainINS = apdu.INS
+		return simStatus(domain.StatusSuccess)
+	}
+	if channel.chain != nil {
+		full := *apdu
+		full.Data = append(channel.chain, apdu.Data...)
+		channel.chain = nil
+		apdu = &full
+	}
+
+	switch apdu.INS {
+	case 0xA4:
+		return s.selectFile(apdu)
+	case 0xB0:
+		return s.readBinary(apdu)
+	case 0x20:
+		return s.verify(apdu)
+	case 0x24:
+		return s.changeReferenceData(apdu)
+	case 0x2C:
+		return s.resetRetryCounter(apdu)
+	case 0x84:
+		return s.getChallenge(apdu)
+	case 0x70:
+		return s.manageChannel(apdu)
+	case 0x22:
+		return s.mseSetAT(apdu)
+	case 0x86:
+		return s.generalAuthenticate(apdu)
+	default:
+		return simStatus(domain.StatusInstructionErr)
+	}
+}
+
+// selectFile implements SELECT (ISO/IEC 7816-4 §11.2.2)
+func (s *CardSimulator) selectFile(apdu *domain.APDU) *domain.Response {
+	var target *simFile
+
+	switch apdu.P1 {
+	case 0x00: // MF, DF or EF by FID
+		if len(apdu.Data) == 0 {
+			target = s.mf
+		} else if len(apdu.Data) == 2 {
+			target = s.resolveFID(simFID(apdu.Data))
+		} else {
+			return simStatus(domain.StatusLengthError)
+		}
+	case 0x01, 0x02: // Child DF / EF under the current DF
+		if len(apdu.Data) != 2 {
+			return simStatus(domain.StatusLengthError)
+		}
+		target = s.channel.currentDF.child(simFID(apdu.Data))
+		if target != nil && target.isDF != (apdu.P1 == 0x01) {
+			target = nil
+		}
+	case 0x03: // Parent DF of the current DF
+		target = s.channel.currentDF.parent
+	case 0x04: // DF name (AID)
+		target = s.mf.findByName(apdu.Data)
+	case 0x08, 0x09: // Path from MF / from current DF
+		start := s.mf
+		if apdu.P1 == 0x09 {
+			start = s.channel.currentDF
+		}
+		target = s.resolvePath(start, apdu.Data)
+	default:
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+
+	if target == nil {
+		return simStatus(domain.StatusFileNotFound)
+	}
+
+	if target.isDF {
+		if target != s.channel.currentDF && len(target.aid) > 0 {
+			// Leaving an application clears its security state
+			s.channel.verified = make(map[byte]bool)
+		}
+		s.channel.currentDF = target
+		s.channel.currentEF = nil
+	} else {
+		s.channel.currentDF = target.parent
+		s.channel.currentEF = target
+	}
+
+	switch apdu.P2 & 0x0C {
+	case 0x00:
+		return simResponse(target.fci(), domain.StatusSuccess)
+	case 0x04:
+		return simResponse(target.fcp(), domain.StatusSuccess)
+	case 0x0C:
+		return simStatus(domain.StatusSuccess)
+	default:
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+}
+
+// resolveFID finds a file by FID relative to the current DF
+func (s *CardSimulator) resolveFID(fid uint16) *simFile {
+	if fid == fidMF {
+		return s.mf
+	}
+	if s.channel.currentDF.fid == fid {
+		return s.channel.currentDF
+	}
+	if child := s.channel.currentDF.child(fid); child != nil {
+		return child
+	}
+	if parent := s.channel.currentDF.parent; parent != nil {
+		if parent.fid == fid {
+			return parent
+		}
+		if sibling := parent.child(fid); sibling != nil && sibling.isDF {
+			return sibling
+		}
+	}
+	return nil
+}
+
+// resolvePath walks a concatenation of FIDs starting at a DF
+func (s *CardSimulator) resolvePath(start *simFile, path []byte) *simFile {
+	if len(path) == 0 || len(path)%2 != 0 {
+		return nil
+	}
+	current := start
+	for i := 0; i < len(path); i += 2 {
+		fid := simFID(path[i : i+2])
+		if i == 0 && fid == fidMF {
+			current = s.mf
+			continue
+		}
+		if !current.isDF {
+			return nil
+		}
+		if current = current.child(fid); current == nil {
+			return nil
+		}
+	}
+	return current
+}
+
+// readBinary implements READ BINARY with offset or short EF identifier
+func (s *CardSimulator) readBinary(apdu *domain.APDU) *domain.Response {
+	ef := s.channel.currentEF
+	offset := int(apdu.P1&0x7F)<<8 | int(apdu.P2)
+
+	if apdu.P1&0x80 != 0 {
+		// b8=1: P1 b5-b1 carry an SFI and P2 the offset
+		ef = s.channel.currentDF.childBySFI(apdu.P1 & 0x1F)
+		if ef == nil {
+			return simStatus(domain.StatusFileNotFound)
+		}
+		offset = int(apdu.P2)
+		s.channel.currentEF = ef
+	}
+
+	if ef == nil {
+		return simStatus(domain.StatusCommandNotAllowed)
+	}
+	if !s.accessGranted(ef.read) {
+		return simStatus(domain.StatusSecurityAuthFailed)
+	}
+	if offset > len(ef.data) {
+		return simStatus(domain.StatusWrongOffset)
+	}
+
+	length := apdu.Le
+	if length == 0 {
+		length = domain.MaxShortLe
+	}
+	end := offset + length
+	if end > len(ef.data) {
+		return simResponse(ef.data[offset:], domain.StatusWarningEOF)
+	}
+	return simResponse(ef.data[offset:end], domain.StatusSuccess)
+}
+
+// verify implements VERIFY, including the empty-data retry counter query
+func (s *CardSimulator) verify(apdu *domain.APDU) *domain.Response {
+	if apdu.P1 != 0x00 {
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+	pin, ok := s.pins[apdu.P2]
+	if !ok {
+		return simStatus(domain.StatusReferenceNotFound)
+	}
+
+	if len(apdu.Data) == 0 {
+		if s.channel.verified[apdu.P2] {
+			return simStatus(domain.StatusSuccess)
+		}
+		return pin.retryStatus()
+	}
+
+	if pin.retries == 0 {
+		return simStatus(domain.StatusIncorrectPIN)
+	}
+	if string(apdu.Data) != string(pin.value) {
+		pin.retries--
+		s.channel.verified[apdu.P2] = false
+		return pin.retryStatus()
+	}
+
+	pin.retries = pin.maxRetries
+	s.channel.verified[apdu.P2] = true
+	return simStatus(domain.StatusSuccess)
+}
+
+// changeReferenceData implements CHANGE REFERENCE DATA with the current and
+// new PIN (ISO/IEC 7816-4 §11.5.7). The card splits the data at the length of
+// the current PIN.
+func (s *CardSimulator) changeReferenceData(apdu *domain.APDU) *domain.Response {
+	if apdu.P1 != 0x00 {
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+	pin, ok := s.pins[apdu.P2]
+	if !ok {
+		return simStatus(domain.StatusReferenceNotFound)
+	}
+	if len(apdu.Data) <= len(pin.value) {
+		return simStatus(domain.StatusWrongData)
+	}
+
+	if pin.retries == 0 {
+		return simStatus(domain.StatusIncorrectPIN)
+	}
+	current, replacement := apdu.Data[:len(pin.value)], apdu.Data[len(pin.value):]
+	if string(current) != string(pin.value) {
+		pin.retries--
+		s.channel.verified[apdu.P2] = false
+		return pin.retryStatus()
+	}
+
+	pin.value = slices.Clone(replacement)
+	pin.retries = pin.maxRetries
+	s.channel.verified[apdu.P2] = true
+	return simStatus(domain.StatusSuccess)
+}
+
+// resetRetryCounter implements RESET RETRY COUNTER with the PUK, followed by
+// a new PIN (P1 00) or alone (P1 01) (ISO/IEC 7816-4 §11.5.10)
+func (s *CardSimulator) resetRetryCounter(apdu *domain.APDU) *domain.Response {
+	if apdu.P1 != 0x00 && apdu.P1 != 0x01 {
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+	pin, ok := s.pins[apdu.P2]
+	if !ok || pin.puk == nil {
+		return simStatus(domain.StatusReferenceNotFound)
+	}
+	puk := pin.puk
+	if len(apdu.Data) < len(puk.value) || (apdu.P1 == 0x00) == (len(apdu.Data) == len(puk.value)) {
+		return simStatus(domain.StatusWrongData)
+	}
+
+	if puk.retries == 0 {
+		return simStatus(domain.StatusIncorrectPIN)
+	}
+	if string(apdu.Data[:len(puk.value)]) != string(puk.value) {
+		puk.retries--
+		return puk.retryStatus()
+	}
+
+	puk.retries = puk.maxRetries
+	if apdu.P1 == 0x00 {
+		pin.value = slices.Clone(apdu.Data[len(puk.value):])
+	}
+	pin.retries = pin.maxRetries
+	return simStatus(domain.StatusSuccess)
+}
+
+// manageChannel implements MANAGE CHANNEL (ISO/IEC 7816-4 §11.1.2). A channel
+// opened from the basic channel starts at the MF; one opened from another
+// channel starts at that channel's current DF. Either starts unverified.
+func (s *CardSimulator) manageChannel(apdu *domain.APDU) *domain.Response {
+	if s.maxChannels == 0 {
+		return simStatus(domain.StatusFunctionNotFound)
+	}
+
+	number := int(apdu.P2)
+	switch apdu.P1 {
+	case 0x00: // Open
+		if apdu.P2 == 0x00 {
+			// The card assigns the lowest free channel
+			number = slices.Index(s.channels[1:], nil) + 1
+			if number == 0 {
+				return simStatus(domain.StatusFuncNotSupported)
+			}
+		} else if number >= len(s.channels) || s.channels[number] != nil {
+			return simStatus(domain.StatusKeyReferenceErr)
+		}
+
+		opened := &simChannel{currentDF: s.mf, verified: make(map[byte]bool)}
+		if s.channel != s.channels[domain.BasicChannel] {
+			opened.currentDF = s.channel.currentDF
+		}
+		s.channels[number] = opened
+
+		if apdu.P2 == 0x00 {
+			return simResponse([]byte{byte(number)}, domain.StatusSuccess)
+		}
+		return simStatus(domain.StatusSuccess)
+	case 0x80: // Close
+		if number == domain.BasicChannel || number >= len(s.channels) || s.channels[number] == nil {
+			return simStatus(domain.StatusKeyReferenceErr)
+		}
+		s.channels[number] = nil
+		return simStatus(domain.StatusSuccess)
+	default:
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+}
+
+// getChallenge returns Le random bytes (8 when Le is absent)
+func (s *CardSimulator) getChallenge(apdu *domain.APDU) *domain.Response {
+	length := apdu.Le
+	if length == 0 {
+		length = 8
+	}
+	challenge := make([]byte, length)
+	if _, err := io.ReadFull(s.random, challenge); err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	return simResponse(challenge, domain.StatusSuccess)
+}
+
+// accessGranted evaluates an access condition against the security state
+func (s *CardSimulator) accessGranted(access simAccess) bool {
+	switch access.kind {
+	case simAccessAlways:
+		return true
+	case simAccessPIN:
+		return s.channel.verified[access.pinRef]
+	case simAccessPACE:
+		return s.secured
+	default:
+		return false
+	}
+}
+
+// retryStatus reports the remaining tries (63Cx) or a blocked PIN (6983)
+func (p *simPIN) retryStatus() *domain.Response {
+	if p.retries == 0 {
+		return simStatus(domain.StatusIncorrectPIN)
+	}
+	return simStatus(domain.StatusPINRetryMask | uint16(min(p.retries, 0x0F)))
+}
+
+// simFID decodes a 2-byte file identifier
+func simFID(data []byte) uint16 {
+	return uint16(data[0])<<8 | uint16(data[1])
+}
+
+// simResponse builds a response with data and status word
+func simResponse(data []byte, sw uint16) *domain.Response {
+	return &domain.Response{
+		Data: append([]byte{}, data...),
+		SW1:  byte(sw >> 8),
+		SW2:  byte(sw),
+	}
+}
+
+// simStatus builds a response carrying only a status word
+func simStatus(sw uint16) *domain.Response {
+	return simResponse(nil, sw)
+}
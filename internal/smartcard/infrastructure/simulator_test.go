@@ -0,0 +1,332 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+var simulatorCEIAID = []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01}
+
+func newTestSimulator(t *testing.T) *CardSimulator {
+	t.Helper()
+	sim, err := LoadCardSimulator("testdata/cei")
+	if err != nil {
+		t.Fatalf("LoadCardSimulator() error = %v", err)
+	}
+	if _, err := sim.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return sim
+}
+
+func transmitOK(t *testing.T, sim *CardSimulator, apdu *domain.APDU, wantSW uint16) *domain.Response {
+	t.Helper()
+	resp, err := sim.Transmit(context.Background(), apdu)
+	if err != nil {
+		t.Fatalf("Transmit(%02X) error = %v", apdu.Bytes(), err)
+	}
+	if resp.StatusCode() != wantSW {
+		t.Fatalf("Transmit(%02X) SW = %04X, want %04X", apdu.Bytes(), resp.StatusCode(), wantSW)
+	}
+	return resp
+}
+
+func TestCardSimulator_Status(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	status, err := sim.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Reader != DefaultSimulatorReader {
+		t.Errorf("Reader = %q, want %q", status.Reader, DefaultSimulatorReader)
+	}
+	if len(status.ATR) == 0 || status.ATR[0] != 0x3B {
+		t.Errorf("ATR = %02X, want direct convention ATR", status.ATR)
+	}
+	if status.Card == nil || status.Card.Support != domain.Supported || status.Card.Family != domain.CardFamilyCEI {
+		t.Errorf("Card = %v, want a supported CEI", status.Card)
+	}
+	if card := domain.IdentifyCard(status.ATR); card != nil && card.Support == domain.Supported {
+		t.Errorf("the simulator ATR should not be a supported card in the database, got %v", card)
+	}
+}
+
+func TestCardSimulator_SelectByAID(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	resp := transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x04, Data: simulatorCEIAID}, 0x9000)
+	if resp.Data[0] != 0x62 {
+		t.Errorf("FCP tag = %02X, want 62", resp.Data[0])
+	}
+	if !bytes.Contains(resp.Data, simulatorCEIAID) {
+		t.Errorf("FCP %02X should contain the DF name", resp.Data)
+	}
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: []byte{0xA0, 0x00}}, domain.StatusFileNotFound)
+}
+
+func TestCardSimulator_SelectByFIDAndPath(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	// EF.CardAccess under the MF
+	resp := transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x02, P2: 0x04, Data: []byte{0x01, 0x1C}}, 0x9000)
+	if !bytes.Contains(resp.Data, []byte{0x80, 0x02, 0x00, 0x16}) {
+		t.Errorf("FCP %02X should report a 22-byte file size", resp.Data)
+	}
+
+	// Select MF, then the certificate EF by path from MF through the application
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x00, P2: 0x0C}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x09, P2: 0x0C, Data: []byte{0xC0, 0x00}}, 0x9000)
+	resp = transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 0x10}, 0x9000)
+	if !strings.HasPrefix(string(resp.Data), "-----BEGIN") {
+		t.Errorf("READ BINARY = %q, want certificate content", resp.Data)
+	}
+
+	// Parent of the application is the MF
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x03, P2: 0x0C}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x08, P2: 0x0C, Data: []byte{0x2F, 0x00}}, 0x9000)
+}
+
+func TestCardSimulator_ReadBinary_SFIAndEOF(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	// SFI 0x1C = EF.CardAccess, read past the end
+	resp := transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x80 | 0x1C, P2: 0x10, Le: 0x20}, domain.StatusWarningEOF)
+	if len(resp.Data) != 6 {
+		t.Errorf("Data length = %d, want 6 remaining bytes", len(resp.Data))
+	}
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x00, P2: 0x40, Le: 0x01}, domain.StatusWrongOffset)
+}
+
+func TestCardSimulator_ReadBinary_NoCurrentEF(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 0x01}, domain.StatusCommandNotAllowed)
+}
+
+func TestCardSimulator_AccessConditions(t *testing.T) {
+	sim := newTestSimulator(t)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID}, 0x9000)
+
+	readPersonal := &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x81, Le: 0x07}
+	transmitOK(t, sim, readPersonal, domain.StatusSecurityAuthFailed)
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")}, 0x9000)
+	resp := transmitOK(t, sim, readPersonal, 0x9000)
+	if string(resp.Data) != "SURNAME" {
+		t.Errorf("READ BINARY = %q, want SURNAME", resp.Data)
+	}
+
+	// PACE-protected files are only readable under secure messaging
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x82, Le: 0x04}, domain.StatusSecurityAuthFailed)
+}
+
+func TestCardSimulator_VerifyRetryCounter(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	query := &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02}
+	wrong := &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02, Data: []byte("000000")}
+
+	transmitOK(t, sim, query, 0x63C3)
+	transmitOK(t, sim, wrong, 0x63C2)
+	transmitOK(t, sim, query, 0x63C2)
+	transmitOK(t, sim, wrong, 0x63C1)
+	transmitOK(t, sim, wrong, domain.StatusIncorrectPIN)
+
+	// Blocked: even the correct PIN is refused
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02, Data: []byte("123456")}, domain.StatusIncorrectPIN)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x09}, domain.StatusReferenceNotFound)
+}
+
+func TestCardSimulator_VerifyResetsCounter(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("9999")}, 0x63C2)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01}, 0x9000)
+
+	// Reconnecting clears the verified state but keeps the counter
+	sim.Disconnect()
+	sim.Connect(context.Background())
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01}, 0x63C3)
+}
+
+func TestCardSimulator_ChangeReferenceData(t *testing.T) {
+	sim := newTestSimulator(t)
+	query := &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01}
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x24, P2: 0x01, Data: []byte("99995678")}, 0x63C2)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x24, P2: 0x01, Data: []byte("1234")}, domain.StatusWrongData)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x24, P2: 0x01, Data: []byte("12345678")}, 0x9000)
+	transmitOK(t, sim, query, 0x9000)
+
+	// The new PIN replaces the old one and the counter is back to its maximum
+	sim.Reconnect(context.Background(), domain.ResetWarm)
+	transmitOK(t, sim, query, 0x63C3)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")}, 0x63C2)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("5678")}, 0x9000)
+}
+
+func TestCardSimulator_ResetRetryCounter(t *testing.T) {
+	sim := newTestSimulator(t)
+	wrong := &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02, Data: []byte("000000")}
+	for range 3 {
+		sim.Transmit(context.Background(), wrong)
+	}
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02}, domain.StatusIncorrectPIN)
+
+	// A wrong PUK spends a PUK try, the right one unblocks
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x2C, P1: 0x01, P2: 0x02, Data: []byte("00000000")}, 0x63C9)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x2C, P1: 0x01, P2: 0x02, Data: []byte("12345678")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02}, 0x63C3)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x03}, 0x63CA)
+
+	// With P1 00 the PIN is replaced as well
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x2C, P1: 0x00, P2: 0x02, Data: []byte("12345678")}, domain.StatusWrongData)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x2C, P1: 0x00, P2: 0x02, Data: []byte("12345678654321")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x02, Data: []byte("654321")}, 0x9000)
+
+	// The PUK itself cannot be unblocked
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x2C, P1: 0x01, P2: 0x03, Data: []byte("12345678")}, domain.StatusReferenceNotFound)
+}
+
+func TestCardSimulator_Reconnect(t *testing.T) {
+	sim := newTestSimulator(t)
+	query := &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01}
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")}, 0x9000)
+
+	// Leaving the card keeps the security state, a warm reset clears it
+	sim.Reconnect(context.Background(), domain.ResetLeave)
+	transmitOK(t, sim, query, 0x9000)
+	sim.Reconnect(context.Background(), domain.ResetWarm)
+	transmitOK(t, sim, query, 0x63C3)
+}
+
+func TestCardSimulator_CommandChaining(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x10, INS: 0x20, P2: 0x01, Data: []byte("12")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("34")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01}, 0x9000)
+
+	// A different instruction in the middle of a chain is refused
+	transmitOK(t, sim, &domain.APDU{CLA: 0x10, INS: 0x20, P2: 0x02, Data: []byte("12")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 1}, domain.StatusLastCommandExpected)
+}
+
+func TestCardSimulator_LogicalChannels(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	resp := transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x70, Le: 1}, 0x9000)
+	if len(resp.Data) != 1 || resp.Data[0] != 0x01 {
+		t.Fatalf("MANAGE CHANNEL open = %02X, want channel 01", resp.Data)
+	}
+
+	// Channel 1 reads personal data while the basic channel reads EF.CardAccess
+	transmitOK(t, sim, &domain.APDU{CLA: 0x01, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x01, INS: 0x20, P2: 0x01, Data: []byte("1234")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x02, P2: 0x0C, Data: []byte{0x01, 0x1C}}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x01, INS: 0xB0, P1: 0x81, Le: 0x07}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 0x04}, 0x9000)
+
+	// Verification is per channel
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01}, 0x63C3)
+
+	// So is command chaining: a command on the basic channel does not break
+	// a chain in progress on channel 1
+	transmitOK(t, sim, &domain.APDU{CLA: 0x11, INS: 0x20, P2: 0x01, Data: []byte("12")}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xB0, Le: 0x04}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x01, INS: 0x20, P2: 0x01, Data: []byte("34")}, 0x9000)
+
+	// Explicit open, closing, and commands on a closed channel
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x70, P2: 0x03}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x70, P2: 0x03}, domain.StatusKeyReferenceErr)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x01, INS: 0x70, P1: 0x80, P2: 0x01}, 0x9000)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x01, INS: 0xB0, Le: 0x01}, domain.StatusFunctionNotFound)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x70, P1: 0x80, P2: 0x00}, domain.StatusKeyReferenceErr)
+
+	// A reset closes every channel but the basic one
+	sim.Reconnect(context.Background(), domain.ResetWarm)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x03, INS: 0xB0, Le: 0x01}, domain.StatusFunctionNotFound)
+}
+
+func TestCardSimulator_LogicalChannelsUnsupported(t *testing.T) {
+	sim, err := NewCardSimulator(&SimulatorFixture{ATR: "3B00"})
+	if err != nil {
+		t.Fatalf("NewCardSimulator() error = %v", err)
+	}
+	sim.Connect(context.Background())
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x70, Le: 1}, domain.StatusFunctionNotFound)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x41, INS: 0xA4, P1: 0x00, P2: 0x0C}, domain.StatusFunctionNotFound)
+}
+
+func TestCardSimulator_UnsupportedCommands(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xFE}, domain.StatusInstructionErr)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x80, INS: 0xA4}, domain.StatusCLAErr)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x0C, INS: 0xB0}, domain.StatusLogicalChannelErr)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x86, Data: []byte{0x7C, 0x00}}, domain.StatusConditionsNotSatisfied)
+
+	resp := transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x84, Le: 0x08}, 0x9000)
+	if len(resp.Data) != 8 {
+		t.Errorf("GET CHALLENGE length = %d, want 8", len(resp.Data))
+	}
+}
+
+func TestNewCardSimulator_InvalidFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture *SimulatorFixture
+		want    string
+	}{
+		{
+			name:    "bad ATR",
+			fixture: &SimulatorFixture{ATR: "3G"},
+			want:    "invalid simulator ATR",
+		},
+		{
+			name: "bad FID",
+			fixture: &SimulatorFixture{ATR: "3B00", Files: []SimulatorFileSpec{
+				{Name: "EF.X", FID: "XYZ"},
+			}},
+			want: "invalid FID",
+		},
+		{
+			name: "bad access condition",
+			fixture: &SimulatorFixture{ATR: "3B00", Files: []SimulatorFileSpec{
+				{Name: "EF.X", FID: "0101", Read: "sometimes"},
+			}},
+			want: "unknown access condition",
+		},
+		{
+			name:    "too many logical channels",
+			fixture: &SimulatorFixture{ATR: "3B00", Channels: 20},
+			want:    "logical channels out of range",
+		},
+		{
+			name: "SFI out of range",
+			fixture: &SimulatorFixture{ATR: "3B00", Files: []SimulatorFileSpec{
+				{Name: "EF.X", FID: "0101", SFI: 31},
+			}},
+			want: "out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCardSimulator(tt.fixture)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewCardSimulator() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
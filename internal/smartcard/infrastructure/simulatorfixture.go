@@ -0,0 +1,140 @@
+package infrastructure
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SimulatorFixtureFile is the name of the card description inside a fixture directory
+const SimulatorFixtureFile = "card.json"
+
+// SimulatorFixture describes the content of a virtual CEI card
+type SimulatorFixture struct {
+	Description string              `json:"description,omitempty"`
+	ATR         string              `json:"atr"`              // Hex-encoded ATR
+	Reader      string              `json:"reader,omitempty"` // Reported reader name
+	CAN         string              `json:"can,omitempty"`    // PACE password (card access number); empty disables PACE
+	PINs        []SimulatorPIN      `json:"pins"`
+	Channels    int                 `json:"channels,omitempty"` // Logical channels besides the basic one (0 = no MANAGE CHANNEL)
+	Files       []SimulatorFileSpec `json:"files"`              // Children of the MF (3F00)
+
+	dir string // Directory used to resolve SimulatorFileSpec.Path
+}
+
+// SimulatorPIN describes a PIN object and its retry counter
+type SimulatorPIN struct {
+	Reference byte   `json:"reference"`     // P2 of VERIFY (e.g. 0x01 for PIN1)
+	Value     string `json:"value"`         // PIN as sent by the terminal (ASCII)
+	Retries   int    `json:"retries"`       // Maximum and initial retry counter
+	PUK       byte   `json:"puk,omitempty"` // Reference of the PIN object that unblocks this one (0 = none)
+}
+
+// SimulatorFileSpec describes a DF or EF of the virtual file system.
+// A spec with an AID or children is a DF; anything else is a transparent EF
+// whose content comes from Hex, Text or Path (relative to the fixture directory).
+type SimulatorFileSpec struct {
+	Name     string              `json:"name,omitempty"` // Informational label
+	FID      string              `json:"fid,omitempty"`  // 4 hex digits
+	AID      string              `json:"aid,omitempty"`  // DF name, hex
+	SFI      byte                `json:"sfi,omitempty"`  // Short EF identifier (1-30)
+	Read     string              `json:"read,omitempty"` // always, never, pace, pin:<ref>
+	Hex      string              `json:"hex,omitempty"`
+	Text     string              `json:"text,omitempty"`
+	Path     string              `json:"path,omitempty"`
+	Children []SimulatorFileSpec `json:"children,omitempty"`
+}
+
+// LoadSimulatorFixture reads card.json from a fixture directory
+func LoadSimulatorFixture(dir string) (*SimulatorFixture, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, SimulatorFixtureFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read simulator fixture: %w", err)
+	}
+
+	fixture := &SimulatorFixture{}
+	if err := json.Unmarshal(raw, fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SimulatorFixtureFile, err)
+	}
+	fixture.dir = dir
+	return fixture, nil
+}
+
+// buildTree converts the fixture file specs into a simulated MF
+func (f *SimulatorFixture) buildTree() (*simFile, error) {
+	mf := &simFile{fid: fidMF, isDF: true}
+	for _, spec := range f.Files {
+		child, err := f.buildFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		mf.addChild(child)
+	}
+	return mf, nil
+}
+
+// buildFile converts one spec (recursively for DFs)
+func (f *SimulatorFixture) buildFile(spec SimulatorFileSpec) (*simFile, error) {
+	label := spec.Name
+	if label == "" {
+		label = spec.FID
+	}
+
+	file := &simFile{sfi: spec.SFI}
+	if spec.FID != "" {
+		fid, err := strconv.ParseUint(spec.FID, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: invalid FID %q", label, spec.FID)
+		}
+		file.fid = uint16(fid)
+	}
+
+	access, err := parseSimAccess(spec.Read)
+	if err != nil {
+		return nil, fmt.Errorf("file %s: %w", label, err)
+	}
+	file.read = access
+
+	if spec.AID != "" || len(spec.Children) > 0 {
+		file.isDF = true
+		if file.aid, err = decodeFixtureHex(spec.AID); err != nil {
+			return nil, fmt.Errorf("file %s: invalid AID: %w", label, err)
+		}
+		for _, childSpec := range spec.Children {
+			child, err := f.buildFile(childSpec)
+			if err != nil {
+				return nil, err
+			}
+			file.addChild(child)
+		}
+		return file, nil
+	}
+
+	if spec.SFI > 30 {
+		return nil, fmt.Errorf("file %s: SFI %d out of range 1-30", label, spec.SFI)
+	}
+
+	switch {
+	case spec.Path != "":
+		file.data, err = os.ReadFile(filepath.Join(f.dir, spec.Path))
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", label, err)
+		}
+	case spec.Text != "":
+		file.data = []byte(spec.Text)
+	default:
+		if file.data, err = decodeFixtureHex(spec.Hex); err != nil {
+			return nil, fmt.Errorf("file %s: invalid content: %w", label, err)
+		}
+	}
+	return file, nil
+}
+
+// decodeFixtureHex decodes hex allowing spaces between bytes
+func decodeFixtureHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+}
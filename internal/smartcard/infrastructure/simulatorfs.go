@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fidMF is the reserved file identifier of the master file (ISO/IEC 7816-4)
+const fidMF uint16 = 0x3F00
+
+// simAccessKind enumerates the access conditions understood by the simulator
+type simAccessKind int
+
+const (
+	simAccessAlways simAccessKind = iota
+	simAccessNever
+	simAccessPIN
+	simAccessPACE
+)
+
+// simAccess is the access condition guarding reads of a simulated file
+type simAccess struct {
+	kind   simAccessKind
+	pinRef byte // PIN reference for simAccessPIN
+}
+
+// parseSimAccess parses "always", "never", "pace" or "pin:<hex reference>"
+func parseSimAccess(s string) (simAccess, error) {
+	switch s {
+	case "", "always":
+		return simAccess{kind: simAccessAlways}, nil
+	case "never":
+		return simAccess{kind: simAccessNever}, nil
+	case "pace":
+		return simAccess{kind: simAccessPACE}, nil
+	}
+	if ref, ok := strings.CutPrefix(s, "pin:"); ok {
+		value, err := strconv.ParseUint(ref, 16, 8)
+		if err != nil {
+			return simAccess{}, fmt.Errorf("invalid PIN reference in access condition %q", s)
+		}
+		return simAccess{kind: simAccessPIN, pinRef: byte(value)}, nil
+	}
+	return simAccess{}, fmt.Errorf("unknown access condition %q", s)
+}
+
+// simFile is a node (DF or EF) in the simulated file system
+type simFile struct {
+	fid      uint16
+	aid      []byte // DF name, DFs only
+	sfi      byte   // Short EF identifier, EFs only (0 = none)
+	isDF     bool
+	read     simAccess
+	data     []byte
+	parent   *simFile
+	children []*simFile
+}
+
+// addChild attaches a file below this DF
+func (f *simFile) addChild(child *simFile) {
+	child.parent = f
+	f.children = append(f.children, child)
+}
+
+// child returns the direct child with the given FID
+func (f *simFile) child(fid uint16) *simFile {
+	for _, c := range f.children {
+		if c.fid == fid {
+			return c
+		}
+	}
+	return nil
+}
+
+// childBySFI returns the EF below this DF with the given short identifier
+func (f *simFile) childBySFI(sfi byte) *simFile {
+	for _, c := range f.children {
+		if !c.isDF && c.sfi == sfi {
+			return c
+		}
+	}
+	return nil
+}
+
+// findByName searches the tree below this DF for a DF name (AID)
+func (f *simFile) findByName(name []byte) *simFile {
+	if f.isDF && len(f.aid) > 0 && bytes.Equal(f.aid, name) {
+		return f
+	}
+	for _, c := range f.children {
+		if found := c.findByName(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// fcp builds the File Control Parameters template (tag 62)
+func (f *simFile) fcp() []byte {
+	body := f.controlParameters()
+	return append([]byte{0x62, byte(len(body))}, body...)
+}
+
+// fci builds the File Control Information template (tag 6F)
+func (f *simFile) fci() []byte {
+	body := f.controlParameters()
+	return append([]byte{0x6F, byte(len(body))}, body...)
+}
+
+// controlParameters encodes size, descriptor, identifiers and life cycle
+func (f *simFile) controlParameters() []byte {
+	var body []byte
+	if !f.isDF {
+		size := len(f.data)
+		body = append(body, 0x80, 0x02, byte(size>>8), byte(size))
+		body = append(body, 0x82, 0x01, 0x01) // Transparent working EF
+	} else {
+		body = append(body, 0x82, 0x01, 0x38) // DF
+	}
+	if f.fid != 0 {
+		body = append(body, 0x83, 0x02, byte(f.fid>>8), byte(f.fid))
+	}
+	if len(f.aid) > 0 {
+		body = append(body, 0x84, byte(len(f.aid)))
+		body = append(body, f.aid...)
+	}
+	if f.sfi != 0 {
+		body = append(body, 0x88, 0x01, f.sfi<<3)
+	}
+	return append(body, 0x8A, 0x01, 0x05) // Life cycle: operational, activated
+}
@@ -0,0 +1,272 @@
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/subtle"
+	"io"
+	"math/big"
+
+	cryptoDomain "github.com/andrei-dascalu/roeid-reader/internal/crypto/domain"
+	cryptoInfra "github.com/andrei-dascalu/roeid-reader/internal/crypto/infrastructure"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// paceECDHGMAES128 is id-PACE-ECDH-GM-AES-CBC-CMAC-128 (BSI TR-03110-3
+// §A.1.1), the only PACE protocol the simulator offers in EF.CardAccess
+var paceECDHGMAES128 = []byte{0x04, 0x00, 0x7F, 0x00, 0x07, 0x02, 0x02, 0x04, 0x02, 0x02}
+
+const (
+	pacePasswordCAN     byte = 0x02 // MSE:SET AT password reference of the CAN
+	paceBrainpoolP256r1 byte = 0x0D // Standardized domain parameter ID
+	paceNonceLength          = 16   // Nonce s, one AES block
+	paceKeyLength            = 16   // AES-128 session and password keys
+	paceTokenLength          = 8    // Authentication tokens are truncated CMACs
+	paceTagDynamicAuth       = 0x7C // Dynamic authentication data template
+	paceTagPublicKey         = 0x7F49
+	paceTagPublicKeyOID      = 0x06
+	paceTagECPoint           = 0x86
+)
+
+// simPACEStep is the GENERAL AUTHENTICATE step the card expects next
+type simPACEStep int
+
+const (
+	paceStepNonce        simPACEStep = iota // Encrypted nonce (7C 00)
+	paceStepMapping                         // Mapping data (81)
+	paceStepKeyAgreement                    // Ephemeral public key (83)
+	paceStepMutualAuth                      // Authentication token (85)
+)
+
+// simPACE is the card side of a PACE run in progress (ICAO 9303-11 §4.4)
+type simPACE struct {
+	step     simPACEStep
+	password []byte                      // K_π
+	nonce    *big.Int                    // s
+	curve    *cryptoInfra.BrainpoolCurve // Mapped domain after step 2
+
+	mappingKey   *big.Int // SK_map,PICC
+	ephemeralKey *big.Int // SK_eph,PICC
+	cardKey      []byte   // PK_eph,PICC, encoded
+	terminalKey  []byte   // PK_eph,PCD, encoded
+	kEnc, kMAC   []byte
+}
+
+// mseSetAT implements MSE:SET AT selecting PACE with the CAN (P1 C1, P2 A4).
+// It ends any secure messaging session and starts a new PACE run.
+func (s *CardSimulator) mseSetAT(apdu *domain.APDU) *domain.Response {
+	if apdu.P1 != 0xC1 || apdu.P2 != 0xA4 {
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+	s.pace = nil
+	s.sm = nil
+
+	objects, err := domain.ParseBERTLV(apdu.Data)
+	if err != nil {
+		return simStatus(domain.StatusWrongData)
+	}
+	protocol, ok := domain.FindTLV(objects, 0x80)
+	if !ok || !bytes.Equal(protocol.Value, paceECDHGMAES128) {
+		return simStatus(domain.StatusWrongData)
+	}
+	if params, ok := domain.FindTLV(objects, 0x84); ok && !bytes.Equal(params.Value, []byte{paceBrainpoolP256r1}) {
+		return simStatus(domain.StatusWrongData)
+	}
+	password, ok := domain.FindTLV(objects, 0x83)
+	if !ok || !bytes.Equal(password.Value, []byte{pacePasswordCAN}) || s.can == "" {
+		return simStatus(domain.StatusReferenceNotFound)
+	}
+
+	// K_π = KDF(f(π), 3), f(π) being the CAN in ISO 8859-1
+	key, err := paceKey([]byte(s.can), cryptoInfra.KDFCounterPassword)
+	if err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	s.pace = &simPACE{step: paceStepNonce, password: key}
+	return simStatus(domain.StatusSuccess)
+}
+
+// generalAuthenticate implements the four GENERAL AUTHENTICATE steps of PACE
+// with Generic Mapping on brainpoolP256r1. A failed step aborts the run; a
+// successful one starts secure messaging with the derived session keys.
+func (s *CardSimulator) generalAuthenticate(apdu *domain.APDU) *domain.Response {
+	if apdu.P1 != 0x00 || apdu.P2 != 0x00 {
+		return simStatus(domain.StatusKeyReferenceErr)
+	}
+	if s.pace == nil {
+		return simStatus(domain.StatusConditionsNotSatisfied)
+	}
+
+	objects, err := domain.ParseBERTLV(apdu.Data)
+	if err != nil {
+		s.pace = nil
+		return simStatus(domain.StatusWrongData)
+	}
+	template, ok := domain.FindTLV(objects, paceTagDynamicAuth)
+	if !ok {
+		s.pace = nil
+		return simStatus(domain.StatusWrongData)
+	}
+	data, err := template.Children()
+	if err != nil {
+		s.pace = nil
+		return simStatus(domain.StatusWrongData)
+	}
+
+	var resp *domain.Response
+	switch s.pace.step {
+	case paceStepNonce:
+		resp = s.pace.encryptedNonce(s.random)
+	case paceStepMapping:
+		resp = s.pace.mapNonce(data, s.random)
+	case paceStepKeyAgreement:
+		resp = s.pace.agreeKeys(data, s.random)
+	default:
+		resp = s.pace.authenticate(data)
+		if resp.StatusCode() == domain.StatusSuccess {
+			s.sm = newSimSM(s.pace.kEnc, s.pace.kMAC)
+		}
+	}
+
+	if resp.StatusCode() != domain.StatusSuccess || s.pace.step == paceStepMutualAuth {
+		s.pace = nil
+	} else {
+		s.pace.step++
+	}
+	return resp
+}
+
+// encryptedNonce draws s and returns E(K_π, s) in 80
+func (p *simPACE) encryptedNonce(random io.Reader) *domain.Response {
+	nonce := make([]byte, paceNonceLength)
+	if _, err := io.ReadFull(random, nonce); err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	encrypted, err := cryptoInfra.EncryptCBC(p.password, make([]byte, aes.BlockSize), nonce)
+	if err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	p.nonce = new(big.Int).SetBytes(nonce)
+	return simResponse(simTLV(paceTagDynamicAuth, simTLV(0x80, encrypted)), domain.StatusSuccess)
+}
+
+// mapNonce takes the terminal's mapping key from 81, returns the card's in 82
+// and maps the generator: G' = s·G + SK_map,PICC·PK_map,PCD
+func (p *simPACE) mapNonce(data []domain.TLV, random io.Reader) *domain.Response {
+	object, ok := domain.FindTLV(data, 0x81)
+	if !ok {
+		return simStatus(domain.StatusWrongData)
+	}
+	curve := cryptoInfra.NewBrainpoolP256r1()
+	terminal, err := curve.Unmarshal(object.Value)
+	if err != nil {
+		return simStatus(domain.StatusWrongData)
+	}
+	var public *cryptoDomain.Point
+	if p.mappingKey, public, err = cryptoInfra.NewECDH(curve).GenerateKey(random); err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+
+	shared := curve.ScalarMult(p.mappingKey, terminal)
+	generator := curve.Add(curve.ScalarMult(p.nonce, curve.G()), shared)
+	if shared.IsPointAtInfinity() || generator.IsPointAtInfinity() {
+		return simStatus(domain.StatusWrongData)
+	}
+	p.curve = curve.WithGenerator(generator)
+	return simResponse(simTLV(paceTagDynamicAuth, simTLV(0x82, curve.Marshal(public))), domain.StatusSuccess)
+}
+
+// agreeKeys takes the terminal's ephemeral key from 83, returns the card's in
+// 84 and derives K_enc and K_mac from the x-coordinate of the shared point
+func (p *simPACE) agreeKeys(data []domain.TLV, random io.Reader) *domain.Response {
+	object, ok := domain.FindTLV(data, 0x83)
+	if !ok {
+		return simStatus(domain.StatusWrongData)
+	}
+	terminal, err := p.curve.Unmarshal(object.Value)
+	if err != nil {
+		return simStatus(domain.StatusWrongData)
+	}
+	ecdh := cryptoInfra.NewECDH(p.curve)
+	var public *cryptoDomain.Point
+	if p.ephemeralKey, public, err = ecdh.GenerateKey(random); err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	p.cardKey = p.curve.Marshal(public)
+	p.terminalKey = object.Value
+	if bytes.Equal(p.cardKey, p.terminalKey) {
+		// The terminal must not echo the card's key (BSI TR-03110-2 §3.2)
+		return simStatus(domain.StatusWrongData)
+	}
+
+	secret, err := ecdh.SharedSecret(p.ephemeralKey, terminal)
+	if err != nil {
+		return simStatus(domain.StatusWrongData)
+	}
+	if p.kEnc, err = paceKey(secret, cryptoInfra.KDFCounterEnc); err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	if p.kMAC, err = paceKey(secret, cryptoInfra.KDFCounterMAC); err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+
+	return simResponse(simTLV(paceTagDynamicAuth, simTLV(0x84, p.cardKey)), domain.StatusSuccess)
+}
+
+// authenticate checks the terminal's token in 85 and returns the card's in 86.
+// A wrong token (wrong CAN) fails with 6300.
+func (p *simPACE) authenticate(data []domain.TLV) *domain.Response {
+	object, ok := domain.FindTLV(data, 0x85)
+	if !ok {
+		return simStatus(domain.StatusWrongData)
+	}
+	expected, err := paceToken(p.kMAC, p.cardKey)
+	if err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	if subtle.ConstantTimeCompare(expected, object.Value) != 1 {
+		return simStatus(domain.StatusAuthenticationFailed)
+	}
+	token, err := paceToken(p.kMAC, p.terminalKey)
+	if err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	return simResponse(simTLV(paceTagDynamicAuth, simTLV(0x86, token)), domain.StatusSuccess)
+}
+
+// paceToken computes the authentication token over the other side's
+// ephemeral public key: CMAC(K_mac, 7F49 {06 OID, 86 point}) truncated
+func paceToken(kMAC, publicKey []byte) ([]byte, error) {
+	input := simTLV(paceTagPublicKey, append(
+		simTLV(paceTagPublicKeyOID, paceECDHGMAES128),
+		simTLV(paceTagECPoint, publicKey)...))
+	mac, err := cryptoInfra.NewCMACProvider().Compute(kMAC, input)
+	if err != nil {
+		return nil, err
+	}
+	return mac[:paceTokenLength], nil
+}
+
+// paceKey derives an AES-128 key from a shared secret or the password
+func paceKey(secret []byte, counter uint32) ([]byte, error) {
+	return cryptoInfra.NewPACEKDF().Derive(secret, counter, paceKeyLength)
+}
+
+// simTLV encodes a BER-TLV data object with a tag of one or two bytes
+func simTLV(tag uint32, value []byte) []byte {
+	var out []byte
+	if tag > 0xFF {
+		out = append(out, byte(tag>>8))
+	}
+	out = append(out, byte(tag))
+
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xFF:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
@@ -0,0 +1,241 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	cryptoInfra "github.com/andrei-dascalu/roeid-reader/internal/crypto/infrastructure"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// runPACE is the terminal side of PACE with the CAN. It returns the secure
+// messaging session, or nil with the status word that ended the run.
+func runPACE(t *testing.T, sim *CardSimulator, can string) (*simSM, uint16) {
+	t.Helper()
+	curve := cryptoInfra.NewBrainpoolP256r1()
+
+	mse := append(simTLV(0x80, paceECDHGMAES128), simTLV(0x83, []byte{pacePasswordCAN})...)
+	mse = append(mse, simTLV(0x84, []byte{paceBrainpoolP256r1})...)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x22, P1: 0xC1, P2: 0xA4, Data: mse}, 0x9000)
+
+	step := func(last bool, tag uint32, value []byte) (domain.TLV, uint16) {
+		t.Helper()
+		cla := domain.CLAChainingBit
+		if last {
+			cla = 0x00
+		}
+		data := simTLV(paceTagDynamicAuth, nil)
+		if value != nil {
+			data = simTLV(paceTagDynamicAuth, simTLV(tag, value))
+		}
+		resp, err := sim.Transmit(context.Background(), &domain.APDU{CLA: cla, INS: 0x86, Data: data, Le: domain.MaxShortLe})
+		if err != nil {
+			t.Fatalf("GENERAL AUTHENTICATE error = %v", err)
+		}
+		if !resp.IsSuccess() {
+			return domain.TLV{}, resp.StatusCode()
+		}
+		objects, _ := domain.ParseBERTLV(resp.Data)
+		template, _ := domain.FindTLV(objects, paceTagDynamicAuth)
+		children, err := template.Children()
+		if err != nil || len(children) != 1 {
+			t.Fatalf("GENERAL AUTHENTICATE response %02X, error = %v", resp.Data, err)
+		}
+		return children[0], 0x9000
+	}
+
+	// Nonce
+	encrypted, sw := step(false, 0, nil)
+	if sw != 0x9000 {
+		return nil, sw
+	}
+	password, _ := paceKey([]byte(can), cryptoInfra.KDFCounterPassword)
+	nonce, err := cryptoInfra.DecryptCBC(password, make([]byte, aes.BlockSize), encrypted.Value)
+	if err != nil {
+		t.Fatalf("nonce decryption error = %v", err)
+	}
+
+	// Generic Mapping
+	mappingKey, mappingPublic, _ := cryptoInfra.NewECDH(curve).GenerateKey(rand.Reader)
+	cardMapping, sw := step(false, 0x81, curve.Marshal(mappingPublic))
+	if sw != 0x9000 {
+		return nil, sw
+	}
+	point, err := curve.Unmarshal(cardMapping.Value)
+	if err != nil {
+		t.Fatalf("card mapping key: %v", err)
+	}
+	generator := curve.Add(curve.ScalarMult(new(big.Int).SetBytes(nonce), curve.G()), curve.ScalarMult(mappingKey, point))
+	mapped := curve.WithGenerator(generator)
+
+	// Key agreement
+	ecdh := cryptoInfra.NewECDH(mapped)
+	ephemeralKey, ephemeralPublic, _ := ecdh.GenerateKey(rand.Reader)
+	terminalKey := mapped.Marshal(ephemeralPublic)
+	cardKey, sw := step(false, 0x83, terminalKey)
+	if sw != 0x9000 {
+		return nil, sw
+	}
+	point, err = mapped.Unmarshal(cardKey.Value)
+	if err != nil {
+		t.Fatalf("card ephemeral key: %v", err)
+	}
+	secret, err := ecdh.SharedSecret(ephemeralKey, point)
+	if err != nil {
+		t.Fatalf("shared secret: %v", err)
+	}
+	kEnc, _ := paceKey(secret, cryptoInfra.KDFCounterEnc)
+	kMAC, _ := paceKey(secret, cryptoInfra.KDFCounterMAC)
+
+	// Mutual authentication
+	token, _ := paceToken(kMAC, cardKey.Value)
+	cardToken, sw := step(true, 0x85, token)
+	if sw != 0x9000 {
+		return nil, sw
+	}
+	if want, _ := paceToken(kMAC, terminalKey); !bytes.Equal(cardToken.Value, want) {
+		t.Fatalf("card token = %02X, want %02X", cardToken.Value, want)
+	}
+	return newSimSM(kEnc, kMAC), 0x9000
+}
+
+// protect wraps a command on the terminal side of a session
+func protect(t *testing.T, sm *simSM, apdu *domain.APDU) *domain.APDU {
+	t.Helper()
+	sm.increment()
+
+	var objects []byte
+	if len(apdu.Data) > 0 {
+		iv, _ := sm.iv()
+		encrypted, err := cryptoInfra.EncryptCBC(sm.kEnc, iv, cryptoInfra.PadISO9797(apdu.Data))
+		if err != nil {
+			t.Fatalf("encryption error = %v", err)
+		}
+		objects = simTLV(0x87, append([]byte{0x01}, encrypted...))
+	}
+	if apdu.Le > 0 {
+		objects = append(objects, simTLV(0x97, []byte{byte(apdu.Le)})...)
+	}
+
+	cla := apdu.CLA | domain.CLASMAuthenticated
+	input := cryptoInfra.PadISO9797([]byte{cla, apdu.INS, apdu.P1, apdu.P2})
+	if len(objects) > 0 {
+		input = append(input, cryptoInfra.PadISO9797(objects)...)
+	}
+	mac, _ := sm.mac(input)
+	return &domain.APDU{
+		CLA:  cla,
+		INS:  apdu.INS,
+		P1:   apdu.P1,
+		P2:   apdu.P2,
+		Data: append(objects, simTLV(0x8E, mac)...),
+		Le:   domain.MaxShortLe,
+	}
+}
+
+// unprotect verifies and decrypts a response on the terminal side
+func unprotect(t *testing.T, sm *simSM, resp *domain.Response) *domain.Response {
+	t.Helper()
+	sm.increment()
+
+	objects, err := domain.ParseBERTLV(resp.Data)
+	if err != nil {
+		t.Fatalf("protected response %02X: %v", resp.Data, err)
+	}
+	mac, ok := domain.FindTLV(objects, 0x8E)
+	if !ok {
+		t.Fatalf("protected response %02X has no MAC", resp.Data)
+	}
+	if want, _ := sm.mac(cryptoInfra.PadISO9797(resp.Data[:mac.Offset])); !bytes.Equal(mac.Value, want) {
+		t.Fatalf("response MAC = %02X, want %02X", mac.Value, want)
+	}
+
+	status, ok := domain.FindTLV(objects, 0x99)
+	if !ok || len(status.Value) != 2 {
+		t.Fatalf("protected response %02X has no status word", resp.Data)
+	}
+	plain := &domain.Response{SW1: status.Value[0], SW2: status.Value[1]}
+	if encrypted, ok := domain.FindTLV(objects, 0x87); ok {
+		iv, _ := sm.iv()
+		padded, err := cryptoInfra.DecryptCBC(sm.kEnc, iv, encrypted.Value[1:])
+		if err != nil {
+			t.Fatalf("decryption error = %v", err)
+		}
+		if plain.Data, err = cryptoInfra.UnpadISO9797(padded); err != nil {
+			t.Fatalf("response padding: %v", err)
+		}
+	}
+	return plain
+}
+
+func TestCardSimulator_PACEAndSecureMessaging(t *testing.T) {
+	sim := newTestSimulator(t)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID}, 0x9000)
+
+	readPhoto := &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x82, Le: 0x04}
+	transmitOK(t, sim, readPhoto, domain.StatusSecurityAuthFailed)
+
+	sm, sw := runPACE(t, sim, "123456")
+	if sm == nil {
+		t.Fatalf("PACE failed with %04X", sw)
+	}
+
+	// Two protected reads check that both sides keep the SSC in step
+	resp := transmitOK(t, sim, protect(t, sm, readPhoto), 0x9000)
+	plain := unprotect(t, sm, resp)
+	if plain.StatusCode() != 0x9000 || !bytes.Equal(plain.Data, []byte{0xFF, 0xD8, 0xFF, 0xE0}) {
+		t.Errorf("protected READ BINARY = %02X %04X, want FFD8FFE0 9000", plain.Data, plain.StatusCode())
+	}
+	resp = transmitOK(t, sim, protect(t, sm, &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x9D, Le: 0x10}), domain.StatusWarningEOF)
+	if plain = unprotect(t, sm, resp); !bytes.Equal(plain.Data, []byte{0x77, 0x00}) {
+		t.Errorf("protected READ BINARY of EF.SOD = %02X, want 7700", plain.Data)
+	}
+
+	// Protected command data: VERIFY under SM
+	resp = transmitOK(t, sim, protect(t, sm, &domain.APDU{CLA: 0x00, INS: 0x20, P2: 0x01, Data: []byte("1234")}), 0x9000)
+	if plain = unprotect(t, sm, resp); plain.StatusCode() != 0x9000 {
+		t.Errorf("protected VERIFY SW = %04X, want 9000", plain.StatusCode())
+	}
+
+	// A plain command ends the session
+	transmitOK(t, sim, readPhoto, domain.StatusSecurityAuthFailed)
+	transmitOK(t, sim, protect(t, sm, readPhoto), domain.StatusLogicalChannelErr)
+}
+
+func TestCardSimulator_PACEWrongCAN(t *testing.T) {
+	sim := newTestSimulator(t)
+
+	if sm, sw := runPACE(t, sim, "654321"); sm != nil || sw != domain.StatusAuthenticationFailed {
+		t.Fatalf("PACE with a wrong CAN = %v, %04X, want 6300", sm, sw)
+	}
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x86, Data: []byte{0x7C, 0x00}}, domain.StatusConditionsNotSatisfied)
+}
+
+func TestCardSimulator_SecureMessagingErrors(t *testing.T) {
+	sim := newTestSimulator(t)
+	transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0xA4, P1: 0x04, P2: 0x0C, Data: simulatorCEIAID}, 0x9000)
+	readPhoto := &domain.APDU{CLA: 0x00, INS: 0xB0, P1: 0x82, Le: 0x04}
+
+	t.Run("wrong MAC", func(t *testing.T) {
+		sm, _ := runPACE(t, sim, "123456")
+		tampered := protect(t, sm, readPhoto)
+		tampered.Data[len(tampered.Data)-1] ^= 0x01
+		transmitOK(t, sim, tampered, domain.StatusSMDataIncorrect)
+		transmitOK(t, sim, protect(t, sm, readPhoto), domain.StatusLogicalChannelErr)
+	})
+
+	t.Run("missing MAC", func(t *testing.T) {
+		runPACE(t, sim, "123456")
+		transmitOK(t, sim, &domain.APDU{CLA: 0x0C, INS: 0xB0, P1: 0x82, Data: []byte{0x97, 0x01, 0x04}, Le: domain.MaxShortLe},
+			domain.StatusSMDataMissing)
+	})
+
+	t.Run("unknown password", func(t *testing.T) {
+		mse := append(simTLV(0x80, paceECDHGMAES128), simTLV(0x83, []byte{0x03})...)
+		transmitOK(t, sim, &domain.APDU{CLA: 0x00, INS: 0x22, P1: 0xC1, P2: 0xA4, Data: mse}, domain.StatusReferenceNotFound)
+	})
+}
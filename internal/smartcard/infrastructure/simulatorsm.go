@@ -0,0 +1,156 @@
+package infrastructure
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+
+	cryptoInfra "github.com/andrei-dascalu/roeid-reader/internal/crypto/infrastructure"
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// simSM is the card side of a secure messaging session established by PACE
+// (ICAO 9303-11 §9.8, AES). The send sequence counter starts at zero and is
+// incremented before each command and each response.
+type simSM struct {
+	kEnc, kMAC []byte
+	ssc        []byte // 16-byte big-endian counter
+}
+
+// newSimSM starts a session with the keys derived by PACE
+func newSimSM(kEnc, kMAC []byte) *simSM {
+	return &simSM{kEnc: kEnc, kMAC: kMAC, ssc: make([]byte, aes.BlockSize)}
+}
+
+// increment advances the send sequence counter
+func (sm *simSM) increment() {
+	for i := len(sm.ssc) - 1; i >= 0; i-- {
+		sm.ssc[i]++
+		if sm.ssc[i] != 0 {
+			return
+		}
+	}
+}
+
+// mac computes the truncated CMAC over SSC || data, data being padded
+func (sm *simSM) mac(data []byte) ([]byte, error) {
+	mac, err := cryptoInfra.NewCMACProvider().Compute(sm.kMAC, append(append([]byte{}, sm.ssc...), data...))
+	if err != nil {
+		return nil, err
+	}
+	return mac[:8], nil
+}
+
+// iv returns the IV of the next encryption: E(K_enc, SSC)
+func (sm *simSM) iv() ([]byte, error) {
+	return cryptoInfra.EncryptCBC(sm.kEnc, make([]byte, aes.BlockSize), sm.ssc)
+}
+
+// unwrap verifies and decrypts a protected command: DO 87 (encrypted data,
+// even INS) or 85 (odd INS), DO 97 (Le) and DO 8E (MAC). It returns the plain
+// command, or the status word of the SM error (6987 missing objects, 6988
+// wrong MAC or encoding), after which the session must be ended.
+func (sm *simSM) unwrap(apdu *domain.APDU) (*domain.APDU, uint16) {
+	sm.increment()
+
+	objects, err := domain.ParseBERTLV(apdu.Data)
+	if err != nil {
+		return nil, domain.StatusSMDataIncorrect
+	}
+	mac, ok := domain.FindTLV(objects, 0x8E)
+	if !ok {
+		return nil, domain.StatusSMDataMissing
+	}
+
+	var input []byte
+	if domain.CLASecureMessaging(apdu.CLA) == domain.CLASMAuthenticated {
+		input = cryptoInfra.PadISO9797([]byte{apdu.CLA, apdu.INS, apdu.P1, apdu.P2})
+	}
+	if covered := apdu.Data[:mac.Offset]; len(covered) > 0 {
+		input = append(input, cryptoInfra.PadISO9797(covered)...)
+	}
+	expected, err := sm.mac(input)
+	if err != nil || subtle.ConstantTimeCompare(expected, mac.Value) != 1 {
+		return nil, domain.StatusSMDataIncorrect
+	}
+
+	cla, err := domain.ChannelCLA(apdu.CLA&domain.CLAChainingBit, domain.CLAChannel(apdu.CLA))
+	if err != nil {
+		return nil, domain.StatusSMDataIncorrect
+	}
+	plain := &domain.APDU{
+		CLA: cla, // Same channel and chaining, without the SM indication
+		INS: apdu.INS,
+		P1:  apdu.P1,
+		P2:  apdu.P2,
+	}
+	if object, ok := domain.FindTLV(objects, 0x97); ok {
+		switch len(object.Value) {
+		case 1:
+			plain.Le = int(object.Value[0])
+			if plain.Le == 0 {
+				plain.Le = domain.MaxShortLe
+			}
+		case 2:
+			plain.Le = int(object.Value[0])<<8 | int(object.Value[1])
+			if plain.Le == 0 {
+				plain.Le = domain.MaxExtendedLe
+			}
+		default:
+			return nil, domain.StatusSMDataIncorrect
+		}
+	}
+
+	object, ok := domain.FindTLV(objects, 0x87)
+	if !ok {
+		object, ok = domain.FindTLV(objects, 0x85)
+	}
+	if ok {
+		encrypted := object.Value
+		if object.Tag == 0x87 {
+			if len(encrypted) == 0 || encrypted[0] != 0x01 {
+				return nil, domain.StatusSMDataIncorrect // Padding indicator
+			}
+			encrypted = encrypted[1:]
+		}
+		iv, err := sm.iv()
+		if err != nil {
+			return nil, domain.StatusSMDataIncorrect
+		}
+		padded, err := cryptoInfra.DecryptCBC(sm.kEnc, iv, encrypted)
+		if err != nil {
+			return nil, domain.StatusSMDataIncorrect
+		}
+		if plain.Data, err = cryptoInfra.UnpadISO9797(padded); err != nil {
+			return nil, domain.StatusSMDataIncorrect
+		}
+	}
+	return plain, 0
+}
+
+// wrap protects a response: DO 87 with the encrypted data, DO 99 with the
+// status word and DO 8E with the MAC. The outer status word repeats the
+// inner one.
+func (sm *simSM) wrap(resp *domain.Response) *domain.Response {
+	sm.increment()
+
+	var data []byte
+	if len(resp.Data) > 0 {
+		iv, err := sm.iv()
+		if err != nil {
+			return simStatus(domain.StatusExecutionError)
+		}
+		encrypted, err := cryptoInfra.EncryptCBC(sm.kEnc, iv, cryptoInfra.PadISO9797(resp.Data))
+		if err != nil {
+			return simStatus(domain.StatusExecutionError)
+		}
+		data = simTLV(0x87, append([]byte{0x01}, encrypted...))
+	}
+	data = append(data, simTLV(0x99, []byte{resp.SW1, resp.SW2})...)
+
+	mac, err := sm.mac(cryptoInfra.PadISO9797(data))
+	if err != nil {
+		return simStatus(domain.StatusExecutionError)
+	}
+	data = append(data, simTLV(0x8E, mac)...)
+	return &domain.Response{Data: data, SW1: resp.SW1, SW2: resp.SW2}
+}
@@ -0,0 +1,384 @@
+package infrastructure
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// Scopes of tag names that are not named after a parent tag
+const (
+	tlvScopeTop = ""    // Top level, and tags with a single meaning anywhere
+	tlvScopeMSE = "mse" // MANAGE SECURITY ENVIRONMENT data field
+	tlvScopeSM  = "sm"  // Secure messaging data objects
+)
+
+// fcpTagNames are the file control parameters (ISO/IEC 7816-4 §7.4.3)
+var fcpTagNames = map[uint32]string{
+	domain.TagFileSize:          "File size",
+	domain.TagTotalFileSize:     "Total file size",
+	domain.TagFileDescriptor:    "File descriptor",
+	domain.TagFileIdentifier:    "File identifier",
+	domain.TagDFName:            "DF name",
+	0x85:                        "Proprietary information",
+	0x86:                        "Security attributes (proprietary)",
+	domain.TagShortEFIdentifier: "Short EF identifier",
+	domain.TagLifeCycleStatus:   "Life cycle status",
+	0x8C:                        "Security attributes (compact)",
+	0xA1:                        "Security attributes (proprietary)",
+	0xA5:                        "Proprietary information",
+	0xAB:                        "Security attributes (expanded)",
+}
+
+// tlvTagNames names tags by scope: a scope above or the hex tag of the
+// parent template. Tags missing from a scope fall back to tlvScopeTop.
+var tlvTagNames = map[string]map[uint32]string{
+	tlvScopeTop: {
+		0x06:   "Object identifier",
+		0x42:   "Certification authority reference",
+		0x53:   "Discretionary data",
+		0x5C:   "Tag list",
+		0x5F01: "LDS version",
+		0x5F1F: "MRZ data",
+		0x5F20: "Certificate holder reference",
+		0x5F24: "Certificate expiration date",
+		0x5F25: "Certificate effective date",
+		0x5F29: "Certificate profile identifier",
+		0x5F2E: "Biometric data block",
+		0x5F36: "Unicode version",
+		0x5F37: "Signature",
+		0x60:   "EF.COM",
+		0x61:   "DG1 (MRZ)",
+		0x62:   "FCP template",
+		0x64:   "FMD template",
+		0x67:   "Auxiliary authenticated data",
+		0x6E:   "DG14 (security infos)",
+		0x6F:   "FCI template",
+		0x75:   "DG2 (encoded face)",
+		0x77:   "EF.SOD",
+		0x7C:   "Dynamic authentication data",
+		0x7F21: "CV certificate",
+		0x7F49: "Public key",
+		0x7F4C: "Certificate holder authorization template",
+		0x7F4E: "Certificate body",
+		0x7F60: "Biometric information template",
+		0x7F61: "Biometric information group template",
+	},
+	"62": fcpTagNames, // domain.TagFCP
+	"6F": fcpTagNames, // domain.TagFCI
+	// BSI TR-03110-3 §B.1 (PACE, chip and terminal authentication)
+	"7C": {
+		0x80: "Encrypted nonce",
+		0x81: "Mapping data (terminal)",
+		0x82: "Mapping data (chip)",
+		0x83: "Ephemeral public key (terminal)",
+		0x84: "Ephemeral public key (chip)",
+		0x85: "Authentication token (terminal)",
+		0x86: "Authentication token (chip)",
+		0x87: "Certification authority reference",
+		0x88: "Certification authority reference (previous)",
+		0x8A: "Encrypted chip authentication data",
+	},
+	// BSI TR-03110-3 §B.2 (MSE:Set AT, MSE:Set DST)
+	tlvScopeMSE: {
+		0x80: "Cryptographic mechanism reference",
+		0x83: "Password or public key reference",
+		0x84: "Private key or domain parameter reference",
+		0x91: "Ephemeral public key",
+	},
+	// ISO/IEC 7816-4 §10, ICAO 9303-11 §9.8
+	tlvScopeSM: {
+		0x85: "Cryptogram",
+		0x87: "Padding indicator and cryptogram",
+		0x8E: "Cryptographic checksum",
+		0x97: "Expected length",
+		0x99: "Processing status",
+	},
+	// BSI TR-03110-3 §D.3
+	"7F49": {
+		0x81: "Prime modulus",
+		0x82: "First coefficient",
+		0x83: "Second coefficient",
+		0x84: "Base point",
+		0x85: "Order of the base point",
+		0x86: "Public point",
+		0x87: "Cofactor",
+	},
+	"7F4C": {
+		0x06: "Terminal type",
+		0x53: "Relative authorization",
+	},
+}
+
+// Tags whose values are text
+var tlvTextTags = map[uint32]bool{
+	0x42: true, 0x5F01: true, 0x5F1F: true, 0x5F20: true, 0x5F36: true,
+}
+
+// Known object identifiers (BSI TR-03110-3 §A.1, §A.2)
+var knownOIDs = map[string]string{
+	"0.4.0.127.0.7.1.2":     "standardizedDomainParameters",
+	"0.4.0.127.0.7.2.2.2":   "id-TA",
+	"0.4.0.127.0.7.2.2.3":   "id-CA",
+	"0.4.0.127.0.7.2.2.4":   "id-PACE",
+	"0.4.0.127.0.7.3.1.2.1": "id-IS (inspection system)",
+	"0.4.0.127.0.7.3.1.2.2": "id-AT (authentication terminal)",
+	"0.4.0.127.0.7.3.1.2.3": "id-ST (signature terminal)",
+	"1.2.840.10045.2.1":     "ecPublicKey",
+}
+
+func init() {
+	// id-PACE-<agreement>-<mapping>-<cipher>
+	mappings := []struct {
+		arc  int
+		name string
+	}{{1, "DH-GM"}, {2, "ECDH-GM"}, {3, "DH-IM"}, {4, "ECDH-IM"}, {6, "ECDH-CAM"}}
+	ciphers := []string{"", "3DES-CBC-CBC", "AES-CBC-CMAC-128", "AES-CBC-CMAC-192", "AES-CBC-CMAC-256"}
+	for _, mapping := range mappings {
+		knownOIDs["0.4.0.127.0.7.2.2.4."+strconv.Itoa(mapping.arc)] = "id-PACE-" + mapping.name
+		for i, cipher := range ciphers[1:] {
+			oid := fmt.Sprintf("0.4.0.127.0.7.2.2.4.%d.%d", mapping.arc, i+1)
+			knownOIDs[oid] = "id-PACE-" + mapping.name + "-" + cipher
+		}
+	}
+}
+
+// PACE password references in MSE:Set AT (BSI TR-03110-3 §D.2.1.2)
+var pacePasswordReferences = map[byte]string{
+	0x01: "MRZ",
+	0x02: "CAN",
+	0x03: "PIN",
+	0x04: "PUK",
+}
+
+// tlvNode is one annotated data object; masked value bytes are "**"
+type tlvNode struct {
+	Tag      string    `json:"tag"`
+	Name     string    `json:"name,omitempty"`
+	Length   int       `json:"length"`
+	Value    string    `json:"value,omitempty"`   // Hex of primitive values
+	Meaning  string    `json:"meaning,omitempty"` // Decoded value
+	Children []tlvNode `json:"children,omitempty"`
+}
+
+// tlvTree is the annotation of a payload, logged as the "tlv" attribute
+type tlvTree []tlvNode
+
+// String renders the tree with one line per data object, children
+// indented below their template
+func (t tlvTree) String() string {
+	var b strings.Builder
+	for _, node := range t {
+		node.render(&b, 0)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// render writes the node and its children at depth
+func (n tlvNode) render(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Tag)
+	if n.Name != "" {
+		b.WriteString(" " + n.Name)
+	}
+	if n.Children != nil {
+		fmt.Fprintf(b, " (%d bytes)\n", n.Length)
+		for _, child := range n.Children {
+			child.render(b, depth+1)
+		}
+		return
+	}
+	b.WriteString(": " + n.Value)
+	if n.Meaning != "" {
+		b.WriteString(" (" + n.Meaning + ")")
+	}
+	b.WriteString("\n")
+}
+
+// annotateTLV decodes a payload at offset in its APDU as BER-TLV. It
+// returns nil when the payload is not BER-TLV or is masked entirely, and
+// shows constructed values that do not parse as hex.
+func (l *APDULogger) annotateTLV(payload []byte, offset int, redactions []span, scope string) tlvTree {
+	if len(payload) == 0 || maskedCount(redactions) >= len(payload) {
+		return nil
+	}
+	objects, err := domain.ParseBERTLV(payload)
+	if err != nil || len(objects) == 0 {
+		return nil
+	}
+	return l.annotateObjects(objects, offset, redactions, scope)
+}
+
+// annotateObjects annotates parsed data objects
+func (l *APDULogger) annotateObjects(objects []domain.TLV, offset int, redactions []span, scope string) tlvTree {
+	tree := make(tlvTree, 0, len(objects))
+	for _, object := range objects {
+		node := tlvNode{
+			Tag:    fmt.Sprintf("%X", object.Tag),
+			Name:   tagName(scope, object.Tag),
+			Length: len(object.Value),
+		}
+		if object.Constructed() {
+			if children, err := object.Children(); err == nil {
+				node.Children = l.annotateObjects(children, offset, redactions, fmt.Sprintf("%X", object.Tag))
+				tree = append(tree, node)
+				continue
+			}
+		}
+
+		start := offset + object.ValueOffset()
+		valueRedactions := shiftSpans(redactions, start, start+len(object.Value))
+		node.Value = maskedHex(object.Value, valueRedactions)
+		if len(valueRedactions) == 0 {
+			node.Meaning = l.tlvMeaning(scope, object.Tag, object.Value)
+		}
+		tree = append(tree, node)
+	}
+	return tree
+}
+
+// shiftSpans returns the parts of spans between start and end, relative
+// to start
+func shiftSpans(spans []span, start, end int) []span {
+	var shifted []span
+	for _, s := range spans {
+		if s.end > start && s.start < end {
+			shifted = append(shifted, span{max(s.start, start) - start, min(s.end, end) - start})
+		}
+	}
+	return shifted
+}
+
+// tagName names a tag in scope
+func tagName(scope string, tag uint32) string {
+	if name, ok := tlvTagNames[scope][tag]; ok {
+		return name
+	}
+	return tlvTagNames[tlvScopeTop][tag]
+}
+
+// tlvMeaning decodes well-known values
+func (l *APDULogger) tlvMeaning(scope string, tag uint32, value []byte) string {
+	switch {
+	case tag == 0x06 || (scope == tlvScopeMSE && tag == 0x80):
+		oid, err := decodeOID(value)
+		if err != nil {
+			return ""
+		}
+		if name, ok := knownOIDs[oid]; ok {
+			return name + ", " + oid
+		}
+		return oid
+	case scope == tlvScopeMSE && tag == 0x83 && len(value) == 1:
+		return pacePasswordReferences[value[0]]
+	case scope == tlvScopeSM && tag == 0x99 && len(value) == 2:
+		status := uint16(value[0])<<8 | uint16(value[1])
+		return strings.TrimSpace(fmt.Sprintf("SW=%04X %s", status, l.statusDescription(status)))
+	case scope == tlvScopeSM && tag == 0x97 && len(value) == 1:
+		return fmt.Sprintf("Le=%d", value[0])
+	case scope == tlvScopeSM && tag == 0x87 && len(value) > 0 && value[0] == 0x01:
+		return "ISO/IEC 9797-1 padding"
+	case fcpTagNames[tag] != "" && (scope == "62" || scope == "6F"):
+		return fcpMeaning(tag, value)
+	case tlvTextTags[tag] && printable(value):
+		return strconv.Quote(string(value))
+	}
+	return ""
+}
+
+// fcpMeaning decodes file control parameters
+func fcpMeaning(tag uint32, value []byte) string {
+	switch {
+	case (tag == 0x80 || tag == 0x81) && len(value) > 0 && len(value) <= 4:
+		size := 0
+		for _, b := range value {
+			size = size<<8 | int(b)
+		}
+		return fmt.Sprintf("%d bytes", size)
+	case tag == 0x82 && len(value) > 0:
+		return fileDescriptorMeaning(value[0])
+	case tag == 0x88 && len(value) == 1:
+		return fmt.Sprintf("SFI %d", value[0]>>3)
+	case tag == 0x8A && len(value) == 1:
+		return lifeCycleMeaning(value[0])
+	}
+	return ""
+}
+
+// fileDescriptorMeaning decodes a file descriptor byte (ISO/IEC 7816-4 Table 12)
+func fileDescriptorMeaning(descriptor byte) string {
+	if descriptor&0xBF == 0x38 {
+		return "DF"
+	}
+	if descriptor&0x80 != 0 {
+		return ""
+	}
+	structures := map[byte]string{
+		0x01: "transparent", 0x02: "linear fixed", 0x03: "linear fixed, TLV",
+		0x04: "linear variable", 0x05: "linear variable, TLV",
+		0x06: "cyclic", 0x07: "cyclic, TLV",
+	}
+	kind := "working EF"
+	if descriptor&0x38 == 0x08 {
+		kind = "internal EF"
+	}
+	if structure, ok := structures[descriptor&0x07]; ok {
+		return kind + ", " + structure
+	}
+	return kind
+}
+
+// lifeCycleMeaning decodes a life cycle status byte (ISO/IEC 7816-4 Table 13)
+func lifeCycleMeaning(status byte) string {
+	switch {
+	case status == 0x01:
+		return "creation"
+	case status == 0x03:
+		return "initialisation"
+	case status&0xFD == 0x05:
+		return "operational, activated"
+	case status&0xFD == 0x04:
+		return "operational, deactivated"
+	case status&0xFC == 0x0C:
+		return "termination"
+	}
+	return ""
+}
+
+// decodeOID decodes the content of an object identifier
+func decodeOID(value []byte) (string, error) {
+	if len(value) == 0 || value[len(value)-1]&0x80 != 0 {
+		return "", fmt.Errorf("truncated object identifier")
+	}
+	var arcs []string
+	arc := 0
+	for _, b := range value {
+		if arc > 1<<24 {
+			return "", fmt.Errorf("object identifier arc too large")
+		}
+		arc = arc<<7 | int(b&0x7F)
+		if b&0x80 != 0 {
+			continue
+		}
+		if len(arcs) == 0 {
+			first := min(arc/40, 2)
+			arcs = append(arcs, strconv.Itoa(first), strconv.Itoa(arc-40*first))
+		} else {
+			arcs = append(arcs, strconv.Itoa(arc))
+		}
+		arc = 0
+	}
+	return strings.Join(arcs, "."), nil
+}
+
+// printable reports whether value is printable ASCII
+func printable(value []byte) bool {
+	for _, b := range value {
+		if b < 0x20 || b > 0x7E {
+			return false
+		}
+	}
+	return len(value) > 0
+}
@@ -0,0 +1,138 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeOID(t *testing.T) {
+	tests := []struct {
+		value []byte
+		want  string
+	}{
+		{[]byte{0x04, 0x00, 0x7F, 0x00, 0x07, 0x02, 0x02, 0x04, 0x02, 0x02}, "0.4.0.127.0.7.2.2.4.2.2"},
+		{[]byte{0x2A, 0x86, 0x48, 0xCE, 0x3D, 0x02, 0x01}, "1.2.840.10045.2.1"},
+		{[]byte{0x88, 0x37}, "2.999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := decodeOID(tt.value)
+			if err != nil || got != tt.want {
+				t.Errorf("decodeOID(%X) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := decodeOID([]byte{0x2A, 0x86}); err == nil {
+		t.Error("decodeOID() of a truncated arc should fail")
+	}
+}
+
+func TestAPDULogger_AnnotateTLV(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  []byte
+		response []byte
+		want     []string
+	}{
+		{
+			name:     "FCI of SELECT",
+			command:  []byte{0x00, 0xA4, 0x04, 0x00, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x00},
+			response: []byte{0x6F, 0x0E, 0x82, 0x01, 0x38, 0x84, 0x06, 0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x8A, 0x01, 0x05, 0x90, 0x00},
+			want: []string{
+				"6F FCI template (14 bytes)",
+				"  82 File descriptor: 38 (DF)",
+				"  84 DF name: D27600012401",
+				"  8A Life cycle status: 05 (operational, activated)",
+			},
+		},
+		{
+			name: "MSE:Set AT for PACE with PIN",
+			command: []byte{0x00, 0x22, 0xC1, 0xA4, 0x0F,
+				0x80, 0x0A, 0x04, 0x00, 0x7F, 0x00, 0x07, 0x02, 0x02, 0x04, 0x02, 0x02,
+				0x83, 0x01, 0x03},
+			response: []byte{0x90, 0x00},
+			want: []string{
+				"80 Cryptographic mechanism reference: 04007F00070202040202 (id-PACE-ECDH-GM-AES-CBC-CMAC-128, 0.4.0.127.0.7.2.2.4.2.2)",
+				"83 Password or public key reference: 03 (PIN)",
+			},
+		},
+		{
+			name:     "GENERAL AUTHENTICATE with masked nonce",
+			command:  []byte{0x10, 0x86, 0x00, 0x00, 0x02, 0x7C, 0x00, 0x00},
+			response: []byte{0x7C, 0x04, 0x80, 0x02, 0xC1, 0xC2, 0x90, 0x00},
+			want: []string{
+				"7C Dynamic authentication data (4 bytes)",
+				"  80 Encrypted nonce: ****",
+			},
+		},
+		{
+			name:     "secure messaging response",
+			command:  []byte{0x0C, 0xB0, 0x00, 0x00, 0x0D, 0x97, 0x01, 0x10, 0x8E, 0x08, 1, 2, 3, 4, 5, 6, 7, 8, 0x00},
+			response: []byte{0x99, 0x02, 0x90, 0x00, 0x8E, 0x02, 0xAA, 0xBB, 0x90, 0x00},
+			want: []string{
+				"97 Expected length: 10 (Le=16)",
+				"8E Cryptographic checksum: 0102030405060708",
+				"99 Processing status: 9000 (SW=9000 OK)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			logger := NewAPDULogger(buf)
+			logger.SetAnnotateTLV(true)
+			logger.LogCommand(tt.command)
+			logger.LogResponse(tt.response)
+
+			output := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(output, "]   "+want+"\n") {
+					t.Errorf("log should contain line %q:\n%s", want, output)
+				}
+			}
+			if strings.Contains(output, "C1C2") {
+				t.Errorf("annotation must keep redacted values masked:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestAPDULogger_AnnotateTLV_Fallback(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULogger(buf)
+	logger.SetAnnotateTLV(true)
+
+	// GET CHALLENGE answers random bytes, not BER-TLV
+	logger.LogCommand([]byte{0x00, 0x84, 0x00, 0x00, 0x08})
+	logger.LogResponse([]byte{0x01, 0x82, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x90, 0x00})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], ": 01820304050607089000") {
+		t.Errorf("non-TLV payloads should be logged as hex only, got:\n%s", buf.String())
+	}
+}
+
+func TestAPDULogger_AnnotateTLV_JSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewAPDULoggerFormat(buf, LogFormatJSON)
+	logger.SetAnnotateTLV(true)
+	logger.LogResponse([]byte{0x62, 0x03, 0x80, 0x01, 0x40, 0x90, 0x00})
+
+	var record struct {
+		TLV []tlvNode `json:"tlv"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if len(record.TLV) != 1 || len(record.TLV[0].Children) != 1 {
+		t.Fatalf("tlv = %+v, want an FCP template with one object", record.TLV)
+	}
+	if size := record.TLV[0].Children[0]; size.Name != "File size" || size.Meaning != "64 bytes" {
+		t.Errorf("tlv child = %+v, want a file size of 64 bytes", size)
+	}
+}
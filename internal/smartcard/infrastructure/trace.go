@@ -0,0 +1,151 @@
+package infrastructure
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andrei-dascalu/roeid-reader/internal/smartcard/domain"
+)
+
+// CardTraceVersion is the trace file format written by RecordingCard
+const CardTraceVersion = 1
+
+// Kinds of trace entries
+const (
+	TraceExchange         = "exchange"          // Command APDU and its response or transport error
+	TraceRandom           = "random"            // Bytes read from the recorded random source
+	TraceBeginTransaction = "begin-transaction" // BeginTransaction
+	TraceEndTransaction   = "end-transaction"   // EndTransaction
+	TraceReconnect        = "reconnect"         // Reconnect with Mode
+	TraceControl          = "control"           // Reader control command, its input and output or error
+)
+
+// CardTrace is a recorded card session: the card status and every
+// operation in order. Traces contain everything sent to the card,
+// including PINs; treat them as secrets.
+type CardTrace struct {
+	Version  int          `json:"version"`
+	Recorded time.Time    `json:"recorded"`
+	Status   TraceStatus  `json:"status"`
+	Entries  []TraceEntry `json:"entries"`
+}
+
+// TraceStatus is the card status at connection time
+type TraceStatus struct {
+	ATR      string                `json:"atr"` // Hex
+	Protocol string                `json:"protocol"`
+	Reader   string                `json:"reader"`
+	Features domain.ReaderFeatures `json:"features,omitempty"` // PC/SC part 10 features, once queried
+}
+
+// TraceEntry is one recorded operation
+type TraceEntry struct {
+	Kind     string           `json:"kind"`
+	Offset   time.Duration    `json:"offset"`             // Since the session was connected
+	Elapsed  time.Duration    `json:"elapsed,omitempty"`  // Duration of the exchange
+	Command  string           `json:"command,omitempty"`  // Hex command APDU (control input for a control command)
+	Response string           `json:"response,omitempty"` // Hex response data and SW (control output for a control command)
+	Error    *TraceError      `json:"error,omitempty"`    // Transport failure instead of a response
+	Random   string           `json:"random,omitempty"`   // Hex random bytes
+	Mode     domain.ResetMode `json:"mode,omitempty"`     // Reset mode of a reconnect
+	Control  uint32           `json:"control,omitempty"`  // Control code of a control command
+}
+
+// TraceError is a recorded transport error
+type TraceError struct {
+	Code     domain.TransportErrorCode `json:"code"`
+	Message  string                    `json:"message"`
+	PCSCCode uint32                    `json:"pcscCode,omitempty"`
+}
+
+// TraceDivergenceError reports an operation that differs from the trace
+type TraceDivergenceError struct {
+	Entry    int    // Zero-based index of the trace entry
+	Expected string // Recorded operation ("" when the trace is exhausted)
+	Actual   string // Operation performed during replay
+}
+
+// Error implements the error interface
+func (e *TraceDivergenceError) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("trace entry %d: unexpected %s (trace exhausted)", e.Entry, e.Actual)
+	}
+	return fmt.Sprintf("trace entry %d: expected %s, got %s", e.Entry, e.Expected, e.Actual)
+}
+
+// LoadCardTrace reads a trace file, refusing unknown format versions
+func LoadCardTrace(path string) (*CardTrace, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read card trace: %w", err)
+	}
+	trace := &CardTrace{}
+	if err := json.Unmarshal(raw, trace); err != nil {
+		return nil, fmt.Errorf("failed to parse card trace %s: %w", path, err)
+	}
+	if trace.Version != CardTraceVersion {
+		return nil, fmt.Errorf("unsupported card trace version %d in %s (want %d)",
+			trace.Version, path, CardTraceVersion)
+	}
+	return trace, nil
+}
+
+// Save writes the trace as indented JSON, readable only by the owner
+func (t *CardTrace) Save(path string) error {
+	raw, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode card trace: %w", err)
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write card trace: %w", err)
+	}
+	return nil
+}
+
+// newTraceStatus converts a card status to its trace form
+func newTraceStatus(status *domain.CardStatus) TraceStatus {
+	return TraceStatus{
+		ATR:      hex.EncodeToString(status.ATR),
+		Protocol: status.ActiveProtocol,
+		Reader:   status.Reader,
+	}
+}
+
+// newTraceError records a transport error (other errors keep their message)
+func newTraceError(err error) *TraceError {
+	var transportErr *domain.TransportError
+	if errors.As(err, &transportErr) {
+		return &TraceError{Code: transportErr.Code, Message: transportErr.Error(), PCSCCode: transportErr.PCSCCode}
+	}
+	return &TraceError{Code: domain.ErrTransmissionFailed, Message: err.Error()}
+}
+
+// toDomain rebuilds the recorded transport error
+func (e *TraceError) toDomain() error {
+	return &domain.TransportError{Code: e.Code, Message: e.Message, PCSCCode: e.PCSCCode}
+}
+
+// describe names the entry in divergence errors. Commands are shown with
+// their secrets masked, as the errors are logged.
+func (e *TraceEntry) describe() string {
+	switch e.Kind {
+	case TraceExchange:
+		command, err := hex.DecodeString(e.Command)
+		if err != nil {
+			return "command (invalid hex)"
+		}
+		return "command " + redactedCommand(command)
+	case TraceRandom:
+		return fmt.Sprintf("random read of %d bytes", len(e.Random)/2)
+	case TraceReconnect:
+		return fmt.Sprintf("reconnect (%s)", e.Mode)
+	case TraceControl:
+		return fmt.Sprintf("control command %08X", e.Control)
+	default:
+		return e.Kind
+	}
+}